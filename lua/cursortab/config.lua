@@ -16,16 +16,61 @@
 ---@class CursortabUIConfig
 ---@field colors CursortabUIColorsConfig
 ---@field jump CursortabUIJumpConfig
+---@field diff_mode string "token" highlights changed tokens within modified lines, "line" repaints whole lines
 
 ---@class CursortabCursorPredictionConfig
 ---@field enabled boolean
 ---@field auto_advance boolean
 ---@field proximity_threshold integer
 
+---@class CursortabAdaptiveDebounceConfig
+---@field enabled boolean
+---@field min_delay integer
+---@field max_delay integer
+
+---@class CursortabRulesConfig
+---@field include string[] Globs a file path must match (empty = all paths)
+---@field exclude string[] Globs of file paths that never get completions
+---@field filetypes string[] Filetypes that get completions (empty = all filetypes)
+---@field exclude_filetypes string[] Filetypes that never get completions
+---@field max_file_size integer Max buffer size in KB (0 = no limit)
+---@field max_line_length integer Max length of any line in bytes (0 = no limit)
+---@field skip_unnamed boolean Skip buffers without a file path
+---@field ignore_files boolean Honor .cursortabignore files in the workspace
+---@field global_ignore_file string Ignore file applied to every workspace
+
+---@class CursortabNormalizationConfig
+---@field trailing_whitespace boolean Ignore changes to trailing whitespace
+---@field indentation boolean Ignore indentation-only changes
+---@field formatting boolean Ignore spacing and blank-line changes a formatter would undo
+
 ---@class CursortabBehaviorConfig
 ---@field idle_completion_delay integer
 ---@field text_change_debounce integer
 ---@field cursor_prediction CursortabCursorPredictionConfig
+---@field completion_cache_size integer
+---@field adaptive_debounce CursortabAdaptiveDebounceConfig
+---@field rules CursortabRulesConfig
+---@field normalization CursortabNormalizationConfig
+
+---@class CursortabRateLimitConfig
+---@field requests_per_second number Token bucket refill rate (0 = unlimited)
+---@field burst integer Token bucket capacity
+---@field daily_budget integer Max requests per day (0 = unlimited)
+---@field monthly_budget integer Max requests per month (0 = unlimited)
+---@field low_budget_threshold number Remaining budget fraction at which only idle triggers are sent
+
+---@class CursortabCircuitBreakerConfig
+---@field failure_threshold integer Consecutive failures before requests are paused (0 = disabled)
+---@field cooldown integer Pause before a probe request, in milliseconds
+
+---@class CursortabRedactionConfig
+---@field enabled boolean Replace secrets with placeholders before sending prompts
+---@field patterns string[] Extra regexps (Go RE2 syntax) whose matches are redacted
+
+---@class CursortabAuditLogConfig
+---@field enabled boolean Record every request sent to the provider (hashes only, no content)
+---@field path string|nil Log file (nil = cursortab.audit.jsonl next to the daemon binary)
 
 ---@class CursortabProviderConfig
 ---@field type string
@@ -35,18 +80,40 @@
 ---@field top_k integer
 ---@field completion_timeout integer
 ---@field max_diff_history_tokens integer
+---@field tokenizer string|nil tokenizer.json or tiktoken vocabulary used to count diff history and cache window tokens (nil = estimate)
 ---@field api_key string|nil API key for hosted providers (e.g., Sweep)
 ---@field api_key_env string Environment variable name for API key (default: "SWEEP_AI_TOKEN")
+---@field api_key_command string|nil Shell command printing the API key (e.g. "pass show sweep")
+---@field api_key_file string|nil File containing the API key (must not be readable by others)
+---@field api_key_ttl integer How long a key from api_key_command or api_key_file is cached, in milliseconds
+---@field rate_limit CursortabRateLimitConfig
+---@field circuit_breaker CursortabCircuitBreakerConfig
+---@field redaction CursortabRedactionConfig
+---@field audit_log CursortabAuditLogConfig
+
+---@class CursortabPrivacyConfig
+---@field enabled boolean Privacy mode everywhere
+---@field workspaces string[] Workspace directories in privacy mode
+---@field paths string[] File globs in privacy mode
+
+---@class CursortabMetricsConfig
+---@field address string|nil Serve Prometheus metrics on a loopback "host:port" or an absolute Unix socket path (nil = disabled)
 
 ---@class CursortabDebugConfig
 ---@field immediate_shutdown boolean
+---@field trace_file string|nil Record a replayable trace of the session to this file (nil = disabled)
 
 ---@class CursortabConfig
 ---@field enabled boolean
 ---@field log_level string
+---@field log_format string "text" or "json" (one JSON object per line)
+---@field log_max_size integer Log file size in MB before it is rotated
+---@field log_max_files integer Rotated log files kept
 ---@field ui CursortabUIConfig
 ---@field behavior CursortabBehaviorConfig
 ---@field provider CursortabProviderConfig
+---@field privacy CursortabPrivacyConfig
+---@field metrics CursortabMetricsConfig
 ---@field debug CursortabDebugConfig
 
 -- Default configuration
@@ -54,6 +121,9 @@
 local default_config = {
 	enabled = true,
 	log_level = "info",
+	log_format = "text",
+	log_max_size = 10,
+	log_max_files = 3,
 
 	ui = {
 		colors = {
@@ -69,6 +139,7 @@ local default_config = {
 			bg_color = "#373b45",
 			fg_color = "#bac1d1",
 		},
+		diff_mode = "token", -- "token" to highlight only changed tokens in modified lines, "line" to show whole lines
 	},
 
 	behavior = {
@@ -79,6 +150,28 @@ local default_config = {
 			auto_advance = true, -- When completion has no changes, show cursor jump to last line
 			proximity_threshold = 2, -- Min lines apart to show cursor jump between completions (0 to disable)
 		},
+		completion_cache_size = 32, -- Max cached completions for repeated buffer states (0 to disable)
+		adaptive_debounce = {
+			enabled = false, -- Learn delays from typing speed and provider latency
+			min_delay = 20, -- Lower bound in ms for adapted delays
+			max_delay = 400, -- Upper bound in ms for adapted delays (0 for no bound)
+		},
+		rules = {
+			include = {}, -- Globs a file path must match (empty for all paths)
+			exclude = { "*.lock", "package-lock.json", "pnpm-lock.yaml", "go.sum", "*.min.js", "*.min.css" },
+			filetypes = {}, -- Filetypes to complete (empty for all filetypes)
+			exclude_filetypes = {}, -- Filetypes never completed
+			max_file_size = 1024, -- Max buffer size in KB (0 for no limit)
+			max_line_length = 1000, -- Max line length in bytes, catches minified files (0 for no limit)
+			skip_unnamed = true, -- Skip buffers without a file path
+			ignore_files = true, -- Never send files matched by .cursortabignore (gitignore syntax)
+			global_ignore_file = "~/.config/cursortab/ignore", -- Ignore file applied to every workspace
+		},
+		normalization = {
+			trailing_whitespace = true, -- Drop or collapse edits that only change trailing whitespace
+			indentation = false, -- Drop or collapse edits that only re-indent lines
+			formatting = false, -- Drop or collapse spacing and blank-line edits a formatter would undo
+		},
 	},
 
 	provider = {
@@ -89,12 +182,46 @@ local default_config = {
 		top_k = 50, -- Top-k sampling
 		completion_timeout = 5000, -- Timeout in ms for completion requests
 		max_diff_history_tokens = 512, -- Max tokens for diff history (0 = no limit)
+		tokenizer = nil, -- Path to a tokenizer.json or tiktoken vocabulary for diff history and cache window token counts (nil = ~2 chars per token)
 		api_key = nil, -- API key for hosted providers (nil to use env var)
 		api_key_env = "SWEEP_AI_TOKEN", -- Environment variable name for API key
+		api_key_command = nil, -- Shell command printing the API key (e.g. "pass show sweep")
+		api_key_file = nil, -- File containing the API key (chmod 600)
+		api_key_ttl = 900000, -- ms to cache a key from api_key_command or api_key_file
+		rate_limit = {
+			requests_per_second = 0, -- Max sustained requests per second (0 = unlimited)
+			burst = 2, -- Requests allowed in a burst before the rate applies
+			daily_budget = 0, -- Max requests per day (0 = unlimited)
+			monthly_budget = 0, -- Max requests per month (0 = unlimited)
+			low_budget_threshold = 0.1, -- Below this fraction of budget left, only idle triggers are sent
+		},
+		circuit_breaker = {
+			failure_threshold = 5, -- Consecutive failures before requests are paused (0 = disabled)
+			cooldown = 30000, -- ms to pause before sending a probe request
+		},
+		redaction = {
+			enabled = true, -- Replace API keys, tokens and private keys with placeholders before sending
+			patterns = {}, -- Extra regexps (Go RE2 syntax) to redact; a capture group limits the redacted part
+		},
+		audit_log = {
+			enabled = false, -- Append a JSON line (file, line range, size, hashes) for every request sent
+			path = nil, -- Log file (nil = server/cursortab.audit.jsonl)
+		},
+	},
+
+	privacy = {
+		enabled = false, -- Privacy mode everywhere: provider asked not to retain data, no metrics, no content logging
+		workspaces = {}, -- Workspace directories (and subdirectories) in privacy mode
+		paths = {}, -- File globs in privacy mode (e.g. "secrets/**")
+	},
+
+	metrics = {
+		address = nil, -- Serve Prometheus metrics at /metrics on "127.0.0.1:9464" or a socket path like "/tmp/cursortab-metrics.sock" (nil = disabled)
 	},
 
 	debug = {
 		immediate_shutdown = false, -- Shutdown daemon immediately when no clients are connected
+		trace_file = nil, -- Record events, buffer state and provider calls for `cursortab replay` (nil = disabled)
 	},
 }
 
@@ -239,7 +366,9 @@ end
 
 -- Valid values for enum-like config options
 local valid_provider_types = { sweep = true }
+local valid_diff_modes = { token = true, line = true }
 local valid_log_levels = { trace = true, debug = true, info = true, warn = true, error = true }
+local valid_log_formats = { text = true, json = true }
 
 -- Validate configuration values
 ---@param cfg table
@@ -254,6 +383,11 @@ local function validate_config(cfg)
 		end
 	end
 
+	-- Validate diff mode
+	if cfg.ui and cfg.ui.diff_mode and not valid_diff_modes[cfg.ui.diff_mode] then
+		error(string.format("[cursortab.nvim] Invalid ui.diff_mode '%s'. Must be 'token' or 'line'", cfg.ui.diff_mode))
+	end
+
 	-- Validate log level
 	if cfg.log_level and not valid_log_levels[cfg.log_level] then
 		error(string.format(
@@ -262,6 +396,17 @@ local function validate_config(cfg)
 		))
 	end
 
+	-- Validate log format and rotation
+	if cfg.log_format and not valid_log_formats[cfg.log_format] then
+		error(string.format("[cursortab.nvim] Invalid log_format '%s'. Must be 'text' or 'json'", cfg.log_format))
+	end
+	if cfg.log_max_size and cfg.log_max_size < 1 then
+		error("[cursortab.nvim] log_max_size must be >= 1")
+	end
+	if cfg.log_max_files and cfg.log_max_files < 1 then
+		error("[cursortab.nvim] log_max_files must be >= 1")
+	end
+
 	-- Validate numeric ranges
 	if cfg.behavior then
 		if cfg.behavior.idle_completion_delay and cfg.behavior.idle_completion_delay < -1 then
@@ -270,6 +415,31 @@ local function validate_config(cfg)
 		if cfg.behavior.text_change_debounce and cfg.behavior.text_change_debounce < 0 then
 			error("[cursortab.nvim] behavior.text_change_debounce must be >= 0")
 		end
+		if cfg.behavior.completion_cache_size and cfg.behavior.completion_cache_size < 0 then
+			error("[cursortab.nvim] behavior.completion_cache_size must be >= 0")
+		end
+		local adaptive = cfg.behavior.adaptive_debounce
+		if adaptive then
+			if adaptive.min_delay and adaptive.min_delay < 0 then
+				error("[cursortab.nvim] behavior.adaptive_debounce.min_delay must be >= 0")
+			end
+			if adaptive.max_delay and adaptive.max_delay < 0 then
+				error("[cursortab.nvim] behavior.adaptive_debounce.max_delay must be >= 0")
+			end
+		end
+		local rules = cfg.behavior.rules
+		if rules then
+			for _, field in ipairs({ "include", "exclude", "filetypes", "exclude_filetypes" }) do
+				if rules[field] ~= nil and type(rules[field]) ~= "table" then
+					error("[cursortab.nvim] behavior.rules." .. field .. " must be a list of strings")
+				end
+			end
+			for _, field in ipairs({ "max_file_size", "max_line_length" }) do
+				if rules[field] and rules[field] < 0 then
+					error("[cursortab.nvim] behavior.rules." .. field .. " must be >= 0")
+				end
+			end
+		end
 	end
 
 	if cfg.provider then
@@ -282,6 +452,33 @@ local function validate_config(cfg)
 		if cfg.provider.max_diff_history_tokens and cfg.provider.max_diff_history_tokens < 0 then
 			error("[cursortab.nvim] provider.max_diff_history_tokens must be >= 0")
 		end
+		if cfg.provider.api_key_ttl and cfg.provider.api_key_ttl < 0 then
+			error("[cursortab.nvim] provider.api_key_ttl must be >= 0")
+		end
+		local rate_limit = cfg.provider.rate_limit
+		if rate_limit then
+			for _, field in ipairs({ "requests_per_second", "burst", "daily_budget", "monthly_budget" }) do
+				if rate_limit[field] and rate_limit[field] < 0 then
+					error("[cursortab.nvim] provider.rate_limit." .. field .. " must be >= 0")
+				end
+			end
+			local threshold = rate_limit.low_budget_threshold
+			if threshold and (threshold < 0 or threshold >= 1) then
+				error("[cursortab.nvim] provider.rate_limit.low_budget_threshold must be in [0, 1)")
+			end
+		end
+		local breaker = cfg.provider.circuit_breaker
+		if breaker then
+			for _, field in ipairs({ "failure_threshold", "cooldown" }) do
+				if breaker[field] and breaker[field] < 0 then
+					error("[cursortab.nvim] provider.circuit_breaker." .. field .. " must be >= 0")
+				end
+			end
+		end
+		local redaction = cfg.provider.redaction
+		if redaction and redaction.patterns ~= nil and type(redaction.patterns) ~= "table" then
+			error("[cursortab.nvim] provider.redaction.patterns must be a list of strings")
+		end
 		if cfg.provider.max_context_tokens ~= nil then
 			vim.schedule(function()
 				vim.notify(
@@ -293,6 +490,18 @@ local function validate_config(cfg)
 		end
 
 	end
+
+	if cfg.privacy then
+		for _, field in ipairs({ "workspaces", "paths" }) do
+			if cfg.privacy[field] ~= nil and type(cfg.privacy[field]) ~= "table" then
+				error("[cursortab.nvim] privacy." .. field .. " must be a list of strings")
+			end
+		end
+	end
+
+	if cfg.metrics and cfg.metrics.address ~= nil and type(cfg.metrics.address) ~= "string" then
+		error("[cursortab.nvim] metrics.address must be a string")
+	end
 end
 
 ---@class ConfigModule
@@ -313,6 +522,17 @@ function config.setup(user_config)
 	local migrated = migrate_deprecated_config(user_config or {})
 	validate_config(migrated)
 	current_config = vim.tbl_deep_extend("force", vim.deepcopy(default_config), migrated)
+
+	-- List options replace the defaults instead of being merged index by index
+	local user_rules = migrated.behavior and migrated.behavior.rules
+	if user_rules then
+		for _, field in ipairs({ "include", "exclude", "filetypes", "exclude_filetypes" }) do
+			if user_rules[field] then
+				current_config.behavior.rules[field] = vim.deepcopy(user_rules[field])
+			end
+		end
+	end
+
 	return current_config
 end
 
@@ -19,6 +19,17 @@ local function is_process_running(pid)
 end
 
 -- Start the daemon process
+-- vim.json.encode turns an empty table into an object, which the daemon cannot
+-- decode as a list. Leave empty lists out so they arrive as null.
+---@param list string[]|nil
+---@return string[]|nil
+local function json_list(list)
+	if list == nil or #list == 0 then
+		return nil
+	end
+	return list
+end
+
 local function start_daemon()
 	local plugin_dir = vim.fn.fnamemodify(debug.getinfo(1, "S").source:sub(2), ":h:h:h")
 	local binary_name = "cursortab"
@@ -52,8 +63,10 @@ local function start_daemon()
 			local api_key = cfg.provider.api_key
 			local api_key_env = cfg.provider.api_key_env or "SWEEP_AI_TOKEN"
 
-			-- If no explicit API key, check environment variable
-			if not api_key or api_key == "" then
+			-- If no explicit API key, check environment variable. Keys from a
+			-- command or file are resolved (and checked) by the daemon.
+			local daemon_resolves_key = cfg.provider.api_key_command or cfg.provider.api_key_file
+			if (not api_key or api_key == "") and not daemon_resolves_key then
 				api_key = vim.fn.getenv(api_key_env)
 				-- vim.fn.getenv returns nil if env var is not set, or the value if set
 				-- If it's an empty string or vim.NIL, treat it as not set
@@ -62,13 +75,13 @@ local function start_daemon()
 				end
 			end
 
-			if not api_key then
+			if not api_key and not daemon_resolves_key then
 				vim.notify(
 					"[cursortab.nvim] Hosted Sweep requires an API key.\n"
 						.. "Please set the "
 						.. api_key_env
 						.. " environment variable\n"
-						.. "or add api_key to your configuration:\n"
+						.. "or add api_key, api_key_command or api_key_file to your configuration:\n"
 						.. '  provider = { type = "sweep", url = "'
 						.. url
 						.. '", api_key = "your-key" }',
@@ -85,6 +98,9 @@ local function start_daemon()
 	local json_config = vim.json.encode({
 		ns_id = ns_id,
 		log_level = cfg.log_level,
+		log_format = cfg.log_format,
+		log_max_size = cfg.log_max_size,
+		log_max_files = cfg.log_max_files,
 		behavior = {
 			idle_completion_delay = cfg.behavior.idle_completion_delay,
 			text_change_debounce = cfg.behavior.text_change_debounce,
@@ -93,6 +109,28 @@ local function start_daemon()
 				auto_advance = cfg.behavior.cursor_prediction.auto_advance,
 				proximity_threshold = cfg.behavior.cursor_prediction.proximity_threshold,
 			},
+			completion_cache_size = cfg.behavior.completion_cache_size,
+			adaptive_debounce = {
+				enabled = cfg.behavior.adaptive_debounce.enabled,
+				min_delay = cfg.behavior.adaptive_debounce.min_delay,
+				max_delay = cfg.behavior.adaptive_debounce.max_delay,
+			},
+			rules = {
+				include = json_list(cfg.behavior.rules.include),
+				exclude = json_list(cfg.behavior.rules.exclude),
+				filetypes = json_list(cfg.behavior.rules.filetypes),
+				exclude_filetypes = json_list(cfg.behavior.rules.exclude_filetypes),
+				max_file_size = cfg.behavior.rules.max_file_size,
+				max_line_length = cfg.behavior.rules.max_line_length,
+				skip_unnamed = cfg.behavior.rules.skip_unnamed,
+				ignore_files = cfg.behavior.rules.ignore_files,
+				global_ignore_file = cfg.behavior.rules.global_ignore_file,
+			},
+			normalization = {
+				trailing_whitespace = cfg.behavior.normalization.trailing_whitespace,
+				indentation = cfg.behavior.normalization.indentation,
+				formatting = cfg.behavior.normalization.formatting,
+			},
 		},
 		provider = {
 			type = cfg.provider.type,
@@ -102,11 +140,43 @@ local function start_daemon()
 			top_k = cfg.provider.top_k,
 			completion_timeout = cfg.provider.completion_timeout,
 			max_diff_history_tokens = cfg.provider.max_diff_history_tokens,
+			tokenizer = cfg.provider.tokenizer and vim.fn.expand(cfg.provider.tokenizer) or nil,
 			api_key = cfg.provider.api_key,
 			api_key_env = cfg.provider.api_key_env,
+			api_key_command = cfg.provider.api_key_command,
+			api_key_file = cfg.provider.api_key_file,
+			api_key_ttl = cfg.provider.api_key_ttl,
+			rate_limit = {
+				requests_per_second = cfg.provider.rate_limit.requests_per_second,
+				burst = cfg.provider.rate_limit.burst,
+				daily_budget = cfg.provider.rate_limit.daily_budget,
+				monthly_budget = cfg.provider.rate_limit.monthly_budget,
+				low_budget_threshold = cfg.provider.rate_limit.low_budget_threshold,
+			},
+			circuit_breaker = {
+				failure_threshold = cfg.provider.circuit_breaker.failure_threshold,
+				cooldown = cfg.provider.circuit_breaker.cooldown,
+			},
+			redaction = {
+				enabled = cfg.provider.redaction.enabled,
+				patterns = json_list(cfg.provider.redaction.patterns),
+			},
+			audit_log = {
+				enabled = cfg.provider.audit_log.enabled,
+				path = cfg.provider.audit_log.path and vim.fn.expand(cfg.provider.audit_log.path) or nil,
+			},
+		},
+		privacy = {
+			enabled = cfg.privacy.enabled,
+			workspaces = json_list(cfg.privacy.workspaces),
+			paths = json_list(cfg.privacy.paths),
+		},
+		metrics = {
+			address = cfg.metrics.address,
 		},
 		debug = {
 			immediate_shutdown = cfg.debug.immediate_shutdown,
+			trace_file = cfg.debug.trace_file and vim.fn.expand(cfg.debug.trace_file) or nil,
 		},
 	})
 
@@ -276,6 +346,19 @@ function daemon.get_channel_status()
 	}
 end
 
+-- Request engine status from the daemon (nil when not connected)
+---@return table|nil
+function daemon.get_server_status()
+	if not chan or chan <= 0 then
+		return nil
+	end
+	local ok, result = pcall(vim.rpcrequest, chan, "cursortab_status")
+	if not ok or type(result) ~= "table" then
+		return nil
+	end
+	return result
+end
+
 -- Clean up stale socket and pid files
 local function cleanup_stale_files()
 	local plugin_dir = vim.fn.fnamemodify(debug.getinfo(1, "S").source:sub(2), ":h:h:h")
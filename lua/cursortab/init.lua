@@ -119,6 +119,23 @@ function M.status()
 		table.insert(status_lines, "  • Channel ID: " .. channel_status.channel_id)
 	end
 
+	-- Engine sections reported by the daemon (cache, rate limit, ...)
+	local server_status = daemon.get_server_status()
+	if server_status then
+		local sections = vim.tbl_keys(server_status)
+		table.sort(sections)
+		for _, section in ipairs(sections) do
+			table.insert(status_lines, "")
+			table.insert(status_lines, "Daemon " .. section:gsub("_", " ") .. ":")
+			local fields = server_status[section]
+			local keys = vim.tbl_keys(fields)
+			table.sort(keys)
+			for _, key in ipairs(keys) do
+				table.insert(status_lines, "  • " .. key:gsub("_", " ") .. ": " .. tostring(fields[key]))
+			end
+		end
+	end
+
 	-- Create scratch window using UI module
 	ui.create_scratch_window("Cursortab Status", status_lines, {
 		size_mode = "fit_content",
@@ -56,14 +56,20 @@ local completion_windows = {} -- Array of {win_id, buf_id} for overlay window cl
 ---@field lines string[] New content
 ---@field old_lines string[] Old content (modifications only)
 ---@field render_hint string|nil "append_chars" | "replace_chars" | "delete_chars" | nil
----@field col_start integer|nil For character-level hints
----@field col_end integer|nil For character-level hints
+---@field col_start integer|nil For character-level hints (0-indexed byte offset)
+---@field col_end integer|nil For character-level hints (0-indexed byte offset, exclusive)
+---@field spans Span[]|nil Changed token ranges within modified lines
+
+---@class Span
+---@field line integer 1-indexed line within the group's lines
+---@field col_start integer 0-indexed byte offset
+---@field col_end integer 0-indexed byte offset, exclusive
 
 ---@class DiffResult
 ---@field groups Group[] Array of groups for rendering
 ---@field startLine integer Start line of the buffer range (1-indexed, used for apply operation)
 ---@field cursor_line integer Cursor position (1-indexed, relative to content)
----@field cursor_col integer Cursor column (0-indexed)
+---@field cursor_col integer Cursor column (0-indexed byte offset)
 
 -- Helper function to close cursor prediction jump text
 local function ensure_close_cursor_prediction()
@@ -157,6 +163,43 @@ local function trim_left_display_cols(text, display_cols)
 	return trimmed_text, bytes_trimmed, trimmed_chars
 end
 
+-- Whether the byte at 0-indexed col is a UTF-8 continuation byte
+---@param line string
+---@param col integer
+---@return boolean
+local function is_continuation_byte(line, col)
+	local b = line:byte(col + 1)
+	return b ~= nil and b >= 0x80 and b < 0xC0
+end
+
+-- Clamp a byte column to the line and move it back to the start of the
+-- character it points into. Columns from the daemon are UTF-8 byte offsets
+-- computed against the line at request time, and the buffer may have changed
+-- since, so they are checked against the current text before use.
+---@param line string
+---@param col integer 0-indexed byte column
+---@return integer
+local function char_start_col(line, col)
+	col = math.max(0, math.min(col, #line))
+	while col > 0 and is_continuation_byte(line, col) do
+		col = col - 1
+	end
+	return col
+end
+
+-- Like char_start_col, but moves forward to the end of the character so an
+-- exclusive end column never cuts a character in half.
+---@param line string
+---@param col integer 0-indexed byte column
+---@return integer
+local function char_end_col(line, col)
+	col = math.max(0, math.min(col, #line))
+	while is_continuation_byte(line, col) do
+		col = col + 1
+	end
+	return col
+end
+
 -- Create transparent overlay window with syntax highlighting
 ---@param parent_win integer
 ---@param buffer_line integer
@@ -310,8 +353,7 @@ local function render_append_chars(group, nvim_line, current_buf, is_first_appen
 
 	if appended_text and appended_text ~= "" then
 		local line_content = vim.api.nvim_buf_get_lines(current_buf, nvim_line, nvim_line + 1, false)[1] or ""
-		local line_length = #line_content
-		local virt_col = math.min(col_start, line_length)
+		local virt_col = char_start_col(line_content, col_start)
 
 		local extmark_id = vim.api.nvim_buf_set_extmark(current_buf, daemon.get_namespace_id(), nvim_line, virt_col, {
 			virt_text = { { appended_text, "cursortabhl_completion" } },
@@ -335,10 +377,8 @@ end
 ---@param current_buf integer
 local function render_delete_chars(group, nvim_line, current_buf)
 	local line_content = vim.api.nvim_buf_get_lines(current_buf, nvim_line, nvim_line + 1, false)[1] or ""
-	local line_length = #line_content
-
-	local col_start = math.max(0, math.min(group.col_start or 0, line_length))
-	local col_end = math.max(col_start, math.min(group.col_end or 0, line_length))
+	local col_start = char_start_col(line_content, group.col_start or 0)
+	local col_end = math.max(col_start, char_end_col(line_content, group.col_end or 0))
 
 	if col_end > col_start then
 		local extmark_id = vim.api.nvim_buf_set_extmark(current_buf, daemon.get_namespace_id(), nvim_line, col_start, {
@@ -350,6 +390,26 @@ local function render_delete_chars(group, nvim_line, current_buf)
 	end
 end
 
+-- Highlight a byte range of an overlay line as added text. The range is in
+-- the coordinates of the untrimmed content, so bytes_trimmed (from
+-- create_overlay_window) is subtracted first.
+---@param overlay_buf integer
+---@param row integer 0-indexed line in the overlay buffer
+---@param bytes_trimmed integer|nil
+---@param col_start integer
+---@param col_end integer
+local function highlight_overlay_range(overlay_buf, row, bytes_trimmed, col_start, col_end)
+	local ov_line = vim.api.nvim_buf_get_lines(overlay_buf, row, row + 1, false)[1] or ""
+	local start_col = char_start_col(ov_line, col_start - (bytes_trimmed or 0))
+	local end_col = math.max(start_col, char_end_col(ov_line, col_end - (bytes_trimmed or 0)))
+	if end_col > start_col then
+		vim.api.nvim_buf_set_extmark(overlay_buf, daemon.get_namespace_id(), row, start_col, {
+			end_col = end_col,
+			hl_group = "cursortabhl_addition",
+		})
+	end
+end
+
 -- Render replace_chars: overlay entire line with highlight on changed portion
 ---@param group Group
 ---@param nvim_line integer 0-indexed line number
@@ -367,16 +427,7 @@ local function render_replace_chars(group, nvim_line, current_win, current_buf)
 		table.insert(completion_windows, { win_id = overlay_win, buf_id = overlay_buf })
 
 		-- Highlight the changed portion
-		local ov_line = vim.api.nvim_buf_get_lines(overlay_buf, 0, 1, false)[1] or ""
-		local ov_len = #ov_line
-		local start_col = math.max(0, (group.col_start or 0) - (bytes_trimmed or 0))
-		local end_col = math.max(start_col, math.min(ov_len, (group.col_end or start_col) - (bytes_trimmed or 0)))
-		if end_col > start_col then
-			vim.api.nvim_buf_set_extmark(overlay_buf, daemon.get_namespace_id(), 0, start_col, {
-				end_col = end_col,
-				hl_group = "cursortabhl_addition",
-			})
-		end
+		highlight_overlay_range(overlay_buf, 0, bytes_trimmed, group.col_start or 0, group.col_end or group.col_start or 0)
 	end
 end
 
@@ -410,14 +461,26 @@ local function render_single_modification(group, nvim_line, virt_line_offset, cu
 	end
 end
 
--- Render multi-line modification group: overlay each line
+-- Render modification group: overlay each line. Lines with token spans are
+-- drawn without a background and only the spans are highlighted; other lines
+-- are repainted whole.
 ---@param group Group
 ---@param virt_line_offset integer Number of virtual lines added above this point
 ---@param current_win integer
 ---@param current_buf integer
-local function render_modification_group(group, virt_line_offset, current_win, current_buf)
+---@param use_spans boolean Highlight group.spans instead of whole lines
+local function render_modification_group(group, virt_line_offset, current_win, current_buf, use_spans)
 	local syntax_ft = vim.api.nvim_get_option_value("filetype", { buf = current_buf })
 
+	---@type table<integer, Span[]>
+	local spans_by_line = {}
+	if use_spans then
+		for _, span in ipairs(group.spans or {}) do
+			spans_by_line[span.line] = spans_by_line[span.line] or {}
+			table.insert(spans_by_line[span.line], span)
+		end
+	end
+
 	for i, new_line_content in ipairs(group.lines) do
 		-- buffer_line is the first line; add offset for subsequent lines
 		local line_nvim = group.buffer_line + i - 2 -- 0-indexed
@@ -429,16 +492,20 @@ local function render_modification_group(group, virt_line_offset, current_win, c
 		local overlay_nvim_line = line_nvim + virt_line_offset
 
 		if new_line_content and new_line_content ~= "" then
-			local overlay_win, overlay_buf, _ = create_overlay_window(
+			local line_spans = spans_by_line[i]
+			local overlay_win, overlay_buf, bytes_trimmed = create_overlay_window(
 				current_win,
 				overlay_nvim_line,
 				0,
 				new_line_content,
 				syntax_ft,
-				"cursortabhl_modification",
+				not line_spans and "cursortabhl_modification" or nil,
 				original_width
 			)
 			table.insert(completion_windows, { win_id = overlay_win, buf_id = overlay_buf })
+			for _, span in ipairs(line_spans or {}) do
+				highlight_overlay_range(overlay_buf, 0, bytes_trimmed, span.col_start, span.col_end)
+			end
 		elseif original_content ~= "" then
 			-- Show deletion indicator for empty replacement
 			local overlay_win, overlay_buf, _ = create_overlay_window(
@@ -576,6 +643,7 @@ local function show_completion(diff_result)
 
 	local found_first_append = false
 	local virt_line_offset = 0 -- Track cumulative virtual lines for overlay positioning
+	local use_spans = config.get().ui.diff_mode ~= "line"
 
 	-- Process each group in order (groups are already sorted by start_line from Go)
 	for _, group in ipairs(diff_result.groups or {}) do
@@ -598,10 +666,13 @@ local function show_completion(diff_result)
 				render_delete_chars(group, nvim_line, current_buf)
 			end
 		elseif group.type == "modification" then
-			if is_single_line then
+			if use_spans and group.spans and #group.spans > 0 then
+				-- Changes are small enough to show in place, token by token
+				render_modification_group(group, virt_line_offset, current_win, current_buf, true)
+			elseif is_single_line then
 				render_single_modification(group, nvim_line, virt_line_offset, current_win, current_buf)
 			else
-				render_modification_group(group, virt_line_offset, current_win, current_buf)
+				render_modification_group(group, virt_line_offset, current_win, current_buf, false)
 			end
 		elseif group.type == "addition" then
 			local line_count = group.end_line - group.start_line + 1
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"cursortab/audit"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// runAudit implements the "audit" subcommand, which summarises the audit log
+// per file and per day. Returns the process exit code.
+func runAudit(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cursortab audit [log-file]")
+		fmt.Fprintln(stderr, "Summarise requests sent to the provider per file and per day.")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	path := fs.Arg(0)
+	if path == "" {
+		path = getAuditPath()
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab audit: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	entries, err := audit.Read(f)
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab audit: %v\n", err)
+		return 1
+	}
+	writeAuditSummary(stdout, entries, time.Local)
+	return 0
+}
+
+func writeAuditSummary(out io.Writer, entries []audit.Entry, loc *time.Location) {
+	if len(entries) == 0 {
+		fmt.Fprintln(out, "No requests recorded.")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tREQUESTS\tBYTES\tFIRST\tLAST")
+	for _, s := range audit.SummarizeFiles(entries) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", displayPath(s.FilePath), s.Requests, s.Bytes,
+			s.First.In(loc).Format(time.DateTime), s.Last.In(loc).Format(time.DateTime))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "DAY\tREQUESTS\tBYTES\tFILES")
+	for _, s := range audit.SummarizeDays(entries, loc) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", s.Day, s.Requests, s.Bytes, s.Files)
+	}
+	w.Flush()
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "[unnamed]"
+	}
+	return path
+}
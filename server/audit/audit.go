@@ -0,0 +1,176 @@
+// Package audit writes an append-only JSON lines log of every request sent
+// to a remote provider. Entries describe what left the machine (file, line
+// range, size and content hashes) but never the content itself.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Entry is one request sent to a provider.
+type Entry struct {
+	Time        time.Time         `json:"time"`
+	Provider    string            `json:"provider"`
+	Endpoint    string            `json:"endpoint"`
+	FilePath    string            `json:"file_path"`
+	StartLine   int               `json:"start_line"`            // 1-indexed first line sent
+	EndLine     int               `json:"end_line"`              // 1-indexed last line sent
+	Bytes       int               `json:"bytes"`                 // total size of the content sent
+	Hashes      map[string]string `json:"hashes"`                // content part -> sha256
+	ResponseID  string            `json:"response_id,omitempty"` // provider's ID for the response
+	PrivacyMode bool              `json:"privacy_mode,omitempty"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// Hash returns the hex SHA-256 of content.
+func Hash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Log appends entries to a file. A nil Log discards entries. Safe for
+// concurrent use.
+type Log struct {
+	mu  sync.Mutex
+	f   *os.File
+	now func() time.Time
+}
+
+// Open opens (or creates) the audit log at path for appending. The file is
+// only readable by the current user.
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return &Log{f: f, now: time.Now}, nil
+}
+
+// Record appends an entry, setting its time when unset. Each entry is a
+// single write so concurrent daemons never interleave lines.
+func (l *Log) Record(entry Entry) error {
+	if l == nil {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = l.now().UTC()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.f.Write(data)
+	return err
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.f.Close()
+}
+
+// Read parses entries from r, skipping lines that are not valid entries
+// (e.g. a partial line left by a crash).
+func Read(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// FileSummary aggregates the requests that included one file.
+type FileSummary struct {
+	FilePath string
+	Requests int
+	Bytes    int
+	First    time.Time
+	Last     time.Time
+}
+
+// DaySummary aggregates the requests sent on one day (local time).
+type DaySummary struct {
+	Day      string // YYYY-MM-DD
+	Requests int
+	Bytes    int
+	Files    int // distinct files
+}
+
+// SummarizeFiles groups entries by file path, most requests first.
+func SummarizeFiles(entries []Entry) []FileSummary {
+	byFile := make(map[string]*FileSummary)
+	for _, e := range entries {
+		s, ok := byFile[e.FilePath]
+		if !ok {
+			s = &FileSummary{FilePath: e.FilePath, First: e.Time, Last: e.Time}
+			byFile[e.FilePath] = s
+		}
+		s.Requests++
+		s.Bytes += e.Bytes
+		if e.Time.Before(s.First) {
+			s.First = e.Time
+		}
+		if e.Time.After(s.Last) {
+			s.Last = e.Time
+		}
+	}
+
+	summaries := make([]FileSummary, 0, len(byFile))
+	for _, s := range byFile {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Requests != summaries[j].Requests {
+			return summaries[i].Requests > summaries[j].Requests
+		}
+		return summaries[i].FilePath < summaries[j].FilePath
+	})
+	return summaries
+}
+
+// SummarizeDays groups entries by day in loc, oldest first.
+func SummarizeDays(entries []Entry, loc *time.Location) []DaySummary {
+	byDay := make(map[string]*DaySummary)
+	files := make(map[string]map[string]bool)
+	for _, e := range entries {
+		day := e.Time.In(loc).Format(time.DateOnly)
+		s, ok := byDay[day]
+		if !ok {
+			s = &DaySummary{Day: day}
+			byDay[day] = s
+			files[day] = make(map[string]bool)
+		}
+		s.Requests++
+		s.Bytes += e.Bytes
+		files[day][e.FilePath] = true
+	}
+
+	summaries := make([]DaySummary, 0, len(byDay))
+	for day, s := range byDay {
+		s.Files = len(files[day])
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Day < summaries[j].Day })
+	return summaries
+}
@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"cursortab/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLog_AppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	log, err := Open(path)
+	assert.NoError(t, err, "Open")
+	log.now = func() time.Time { return now }
+	assert.NoError(t, log.Record(Entry{Provider: "sweep", FilePath: "a.go", Bytes: 10, Hashes: map[string]string{"file_contents": Hash("secret")}}), "Record")
+	assert.NoError(t, log.Close(), "Close")
+
+	// Reopening appends instead of truncating
+	log, _ = Open(path)
+	log.Record(Entry{Provider: "sweep", FilePath: "b.go", ResponseID: "resp-1"})
+	log.Close()
+
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"), "one line per entry")
+	assert.NotContains(t, string(data), `"secret"`, "content never written")
+
+	info, _ := os.Stat(path)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "private file")
+
+	f, _ := os.Open(path)
+	defer f.Close()
+	entries, err := Read(f)
+	assert.NoError(t, err, "Read")
+	assert.Equal(t, 2, len(entries), "entries read back")
+	assert.Equal(t, now, entries[0].Time, "time set on record")
+	assert.Equal(t, Hash("secret"), entries[0].Hashes["file_contents"], "hash kept")
+	assert.Equal(t, "resp-1", entries[1].ResponseID, "response id kept")
+}
+
+func TestLog_NilDiscards(t *testing.T) {
+	var log *Log
+	assert.NoError(t, log.Record(Entry{}), "nil log records nothing")
+	assert.NoError(t, log.Close(), "nil log closes")
+}
+
+func TestRead_SkipsPartialLines(t *testing.T) {
+	entries, err := Read(strings.NewReader(`{"file_path":"a.go","bytes":3}` + "\n" + `{"file_pa`))
+	assert.NoError(t, err, "Read")
+	assert.Equal(t, 1, len(entries), "partial line skipped")
+}
+
+func TestSummarize(t *testing.T) {
+	day1 := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	day2 := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: day1, FilePath: "a.go", Bytes: 100},
+		{Time: day1.Add(time.Hour), FilePath: "b.go", Bytes: 50},
+		{Time: day2, FilePath: "a.go", Bytes: 200},
+	}
+
+	files := SummarizeFiles(entries)
+	assert.Equal(t, 2, len(files), "two files")
+	assert.Equal(t, "a.go", files[0].FilePath, "most requested first")
+	assert.Equal(t, 2, files[0].Requests, "requests")
+	assert.Equal(t, 300, files[0].Bytes, "bytes")
+	assert.Equal(t, day1, files[0].First, "first")
+	assert.Equal(t, day2, files[0].Last, "last")
+
+	days := SummarizeDays(entries, time.UTC)
+	assert.Equal(t, []DaySummary{
+		{Day: "2026-03-01", Requests: 2, Bytes: 150, Files: 2},
+		{Day: "2026-03-02", Requests: 1, Bytes: 200, Files: 1},
+	}, days, "per day")
+
+	// Days follow the requested time zone
+	tokyo := time.FixedZone("JST", 9*3600)
+	days = SummarizeDays([]Entry{{Time: time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)}}, tokyo)
+	assert.Equal(t, "2026-03-02", days[0].Day, "local day")
+}
@@ -21,18 +21,14 @@ type NvimBuffer struct {
 	client *nvim.Nvim // stored internally, set via SetClient
 
 	// Private state
-	lines         []string
-	row           int // 1-indexed
-	col           int // 0-indexed
-	path          string
-	version       int
-	diffHistories []*types.DiffEntry // Structured diff history for provider consumption
-	previousLines []string           // Buffer content before the most recent edit (for sweep provider)
-
-	originalLines    []string // Original file content when editing session started
-	lastModifiedLine int      // Track which line was last modified
+	textState
+	row              int // 1-indexed
+	col              int // 0-indexed byte offset, as nvim reports it
+	path             string
+	filetype         string
+	lastModifiedLine int // Track which line was last modified
 	id               nvim.Buffer
-	scrollOffsetX    int // Horizontal scroll offset (leftcol)
+	scrollOffsetX    int // Horizontal scroll offset (leftcol, in display cells)
 
 	// Viewport bounds (1-indexed line numbers)
 	viewportTop    int // First visible line (1-indexed)
@@ -40,31 +36,20 @@ type NvimBuffer struct {
 
 	config Config
 
-	// Pending completion state (committed only on accept)
-	pendingStartLine        int
-	pendingEndLineInclusive int
-	pendingLines            []string
-	hasPending              bool
+	// privacyMode suppresses debug logging of completion content
+	privacyMode bool
 }
 
 func New(config Config) *NvimBuffer {
 	return &NvimBuffer{
-		lines:                   []string{},
-		row:                     1,
-		col:                     0,
-		path:                    "",
-		version:                 0,
-		diffHistories:           []*types.DiffEntry{},
-		previousLines:           []string{},
-		originalLines:           []string{},
-		lastModifiedLine:        -1,
-		id:                      nvim.Buffer(0),
-		scrollOffsetX:           0,
-		config:                  config,
-		pendingStartLine:        0,
-		pendingEndLineInclusive: 0,
-		pendingLines:            nil,
-		hasPending:              false,
+		textState:        newTextState(),
+		row:              1,
+		col:              0,
+		path:             "",
+		lastModifiedLine: -1,
+		id:               nvim.Buffer(0),
+		scrollOffsetX:    0,
+		config:           config,
 	}
 }
 
@@ -75,55 +60,18 @@ func (b *NvimBuffer) SetClient(n *nvim.Nvim) {
 
 // Accessor methods implementing engine.Buffer interface
 
-func (b *NvimBuffer) Lines() []string { return b.lines }
-
 func (b *NvimBuffer) Row() int { return b.row }
 
 func (b *NvimBuffer) Col() int { return b.col }
 
 func (b *NvimBuffer) Path() string { return b.path }
 
-func (b *NvimBuffer) Version() int { return b.version }
+func (b *NvimBuffer) Filetype() string { return b.filetype }
 
 func (b *NvimBuffer) ViewportBounds() (top, bottom int) {
 	return b.viewportTop, b.viewportBottom
 }
 
-func (b *NvimBuffer) PreviousLines() []string { return b.previousLines }
-
-func (b *NvimBuffer) OriginalLines() []string { return b.originalLines }
-
-func (b *NvimBuffer) DiffHistories() []*types.DiffEntry { return b.diffHistories }
-
-// SetFileContext restores file-specific state when switching back to a previously edited file.
-// This is called by the engine after detecting a file switch.
-func (b *NvimBuffer) SetFileContext(previousLines, originalLines []string, diffHistories []*types.DiffEntry) {
-	if previousLines != nil {
-		b.previousLines = make([]string, len(previousLines))
-		copy(b.previousLines, previousLines)
-	} else if originalLines != nil {
-		// For new files without previous state, initialize previousLines to the original
-		// file content. This ensures providers (like sweep) have a valid "before" state
-		// to compare against, rather than falling back to current content.
-		b.previousLines = make([]string, len(originalLines))
-		copy(b.previousLines, originalLines)
-	} else {
-		b.previousLines = nil
-	}
-
-	if diffHistories != nil {
-		b.diffHistories = make([]*types.DiffEntry, len(diffHistories))
-		copy(b.diffHistories, diffHistories)
-	} else {
-		b.diffHistories = []*types.DiffEntry{}
-	}
-
-	if originalLines != nil {
-		b.originalLines = make([]string, len(originalLines))
-		copy(b.originalLines, originalLines)
-	}
-}
-
 // Sync reads current state from the editor
 func (b *NvimBuffer) Sync(workspacePath string) (*SyncResult, error) {
 	defer logger.Trace("buffer.Sync")()
@@ -141,6 +89,7 @@ func (b *NvimBuffer) Sync(workspacePath string) (*SyncResult, error) {
 	var cursor [2]int
 	var scrollOffset int
 	var viewportBounds [2]int
+	var filetype string
 
 	batch.CurrentBuffer(&currentBuf)
 	batch.BufferName(nvim.Buffer(0), &path) // Use 0 for current buffer
@@ -159,6 +108,8 @@ func (b *NvimBuffer) Sync(workspacePath string) (*SyncResult, error) {
 		return {vim.fn.line("w0"), vim.fn.line("w$")}
 	`, &viewportBounds, nil)
 
+	batch.ExecLua("return vim.bo.filetype", &filetype, nil)
+
 	if err := batch.Execute(); err != nil {
 		logger.Error("error executing sync batch: %v", err)
 		return nil, err
@@ -175,15 +126,16 @@ func (b *NvimBuffer) Sync(workspacePath string) (*SyncResult, error) {
 	// Update buffer state
 	b.lines = linesStr
 	b.row = cursor[0]              // Line (vertical position, 1-based in nvim cursor)
-	b.col = cursor[1]              // Column (horizontal position, 0-based in nvim cursor)
+	b.col = cursor[1]              // Column (0-based byte offset in nvim cursor)
 	b.scrollOffsetX = scrollOffset // Horizontal scroll offset
+	b.filetype = filetype
 
 	// Update viewport bounds (1-indexed)
 	b.viewportTop = viewportBounds[0]
 	b.viewportBottom = viewportBounds[1]
 
 	// Convert absolute path to relative workspace path
-	relativePath := makeRelativeToWorkspace(path, workspacePath)
+	relativePath := RelativeToWorkspace(path, workspacePath)
 	b.path = relativePath
 
 	// Handle buffer change
@@ -210,50 +162,16 @@ func (b *NvimBuffer) Sync(workspacePath string) (*SyncResult, error) {
 	}, nil
 }
 
-// Helper function to convert absolute path to relative workspace path
-func makeRelativeToWorkspace(absolutePath, workspacePath string) string {
+// RelativeToWorkspace returns absolutePath relative to workspacePath when
+// the file is inside the workspace, as buffers report paths, and the
+// cleaned absolute path otherwise.
+func RelativeToWorkspace(absolutePath, workspacePath string) string {
 	absolutePath = filepath.Clean(absolutePath)
-	workspacePath = filepath.Clean(workspacePath)
-
-	// If the file is within the workspace, make it relative
-	if relativePath, found := strings.CutPrefix(absolutePath, workspacePath); found {
-		relativePath = strings.TrimPrefix(relativePath, string(filepath.Separator))
-		return relativePath
-	}
-
-	return absolutePath
-}
-
-// HasChanges checks if the proposed completion would introduce actual changes
-func (b *NvimBuffer) HasChanges(startLine, endLineInclusive int, lines []string) bool {
-	// Check the original replacement range for changes
-	for i := startLine; i <= endLineInclusive; i++ {
-		relativeLineIdx := i - startLine
-
-		var l *string
-		var realL *string
-
-		if i-1 >= 0 && i-1 < len(b.lines) {
-			realL = &b.lines[i-1]
-		}
-
-		if relativeLineIdx < len(lines) {
-			l = &lines[relativeLineIdx]
-		}
-
-		if (l != nil && realL != nil && *l != *realL) ||
-			(l != nil && realL == nil) ||
-			(l == nil && realL != nil) {
-			return true
-		}
-	}
-
-	// Check if there are additional lines beyond the replacement range (insertions)
-	if startLine+len(lines)-1 > endLineInclusive {
-		return true
+	rel, err := filepath.Rel(filepath.Clean(workspacePath), absolutePath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return absolutePath
 	}
-
-	return false
+	return rel
 }
 
 // nvimBatch wraps nvim.Batch to implement the Batch interface
@@ -291,7 +209,10 @@ func (b *NvimBuffer) PrepareCompletion(startLine, endLineInc int, lines []string
 	luaDiffResult := diffResultToLuaFormat(diffResult, groups, lines, startLine)
 
 	// Debug logging for data sent to Lua
-	if jsonData, err := json.Marshal(luaDiffResult); err == nil {
+	if b.privacyMode {
+		logger.Debug("sending to lua on_completion_ready:\n  startLine: %d\n  endLineInclusive: %d\n  lines: %d\n  diffResult: <privacy mode>",
+			startLine, endLineInc, len(lines))
+	} else if jsonData, err := json.Marshal(luaDiffResult); err == nil {
 		logger.Debug("sending to lua on_completion_ready:\n  startLine: %d\n  endLineInclusive: %d\n  lines: %d\n  diffResult: %s",
 			startLine, endLineInc, len(lines), string(jsonData))
 	}
@@ -301,98 +222,6 @@ func (b *NvimBuffer) PrepareCompletion(startLine, endLineInc int, lines []string
 	return &nvimBatch{batch: applyBatch}
 }
 
-// CommitPending applies the pending edit to buffer state, increments version,
-// and appends structured diff entries showing before/after content. No-op if no pending edit.
-func (b *NvimBuffer) CommitPending() {
-	if !b.hasPending {
-		return
-	}
-
-	startLine := b.pendingStartLine
-	endLineInclusive := b.pendingEndLineInclusive
-	lines := b.pendingLines
-
-	// Extract only the affected original lines (the range being replaced)
-	var originalRangeLines []string
-	for i := startLine; i <= endLineInclusive && i-1 < len(b.originalLines); i++ {
-		originalRangeLines = append(originalRangeLines, b.originalLines[i-1])
-	}
-
-	// Extract granular diffs - one DiffEntry per contiguous changed region
-	diffEntries := extractGranularDiffs(originalRangeLines, lines)
-	b.diffHistories = append(b.diffHistories, diffEntries...)
-
-	// Compute the final buffer state after applying the completion
-	newLines := make([]string, 0, len(b.lines)-((endLineInclusive-startLine)+1)+len(lines))
-	if startLine-1 > 0 && startLine-1 <= len(b.lines) {
-		newLines = append(newLines, b.lines[:startLine-1]...)
-	}
-	newLines = append(newLines, lines...)
-	if endLineInclusive < len(b.lines) {
-		newLines = append(newLines, b.lines[endLineInclusive:]...)
-	}
-
-	// Reset checkpoint to current state for next working diff
-	b.originalLines = make([]string, len(newLines))
-	copy(b.originalLines, newLines)
-
-	// Save current lines as previous state BEFORE updating (for sweep provider)
-	b.previousLines = make([]string, len(b.lines))
-	copy(b.previousLines, b.lines)
-
-	// Commit the new content and bump version
-	b.lines = make([]string, len(newLines))
-	copy(b.lines, newLines)
-	b.version++
-
-	// Clear pending
-	b.pendingStartLine = 0
-	b.pendingEndLineInclusive = 0
-	b.pendingLines = nil
-	b.hasPending = false
-}
-
-// CommitUserEdits extracts diffs between originalLines checkpoint and current lines,
-// appends them to diffHistories, and resets the checkpoint.
-// Call this when leaving insert mode to capture manual edits.
-// Returns true if any changes were committed, false if no changes.
-func (b *NvimBuffer) CommitUserEdits() bool {
-	// Quick check: if lengths differ, there are changes
-	if len(b.lines) != len(b.originalLines) {
-		return b.commitUserEditsInternal()
-	}
-
-	// Check for content differences
-	for i := range b.lines {
-		if b.lines[i] != b.originalLines[i] {
-			return b.commitUserEditsInternal()
-		}
-	}
-
-	return false // No changes
-}
-
-func (b *NvimBuffer) commitUserEditsInternal() bool {
-	// Extract granular diffs between checkpoint and current state
-	diffEntries := extractGranularDiffs(b.originalLines, b.lines)
-	if len(diffEntries) == 0 {
-		return false
-	}
-
-	b.diffHistories = append(b.diffHistories, diffEntries...)
-
-	// Save checkpoint as previous state (for sweep provider)
-	b.previousLines = make([]string, len(b.originalLines))
-	copy(b.previousLines, b.originalLines)
-
-	// Reset checkpoint to current state
-	b.originalLines = make([]string, len(b.lines))
-	copy(b.originalLines, b.lines)
-
-	b.version++
-	return true
-}
-
 // ShowCursorTarget displays a cursor prediction indicator at the given line
 func (b *NvimBuffer) ShowCursorTarget(line int) error {
 	if b.client == nil {
@@ -410,8 +239,7 @@ func (b *NvimBuffer) ClearUI() error {
 	}
 
 	// Clear pending state to prevent stale data from being committed
-	b.hasPending = false
-	b.pendingLines = nil
+	b.clearPending()
 
 	logger.Debug("sending to lua on_reject")
 	b.executeLuaFunction("require('cursortab').on_reject()")
@@ -556,6 +384,21 @@ func (b *NvimBuffer) RegisterEventHandler(handler func(event string)) error {
 	})
 }
 
+// SetPrivacyMode enables or disables privacy mode for the current buffer.
+// In privacy mode completion content is left out of debug logs.
+func (b *NvimBuffer) SetPrivacyMode(enabled bool) {
+	b.privacyMode = enabled
+}
+
+// Notify shows a message in the editor via vim.notify
+func (b *NvimBuffer) Notify(message string, level int) error {
+	if b.client == nil {
+		return fmt.Errorf("nvim client not set")
+	}
+	b.executeLuaFunction("vim.notify(...)", message, level)
+	return nil
+}
+
 // Internal helper methods
 
 func (b *NvimBuffer) executeLuaFunction(luaCode string, args ...any) {
@@ -583,16 +426,6 @@ func applyCursorMove(batch *nvim.Batch, line, col int, center bool, mark bool) {
 	}
 }
 
-func (b *NvimBuffer) getDiffResult(startLine, endLineInclusive int, lines []string) *text.DiffResult {
-	originalLines := []string{}
-	for i := startLine; i <= endLineInclusive && i-1 < len(b.lines); i++ {
-		originalLines = append(originalLines, b.lines[i-1])
-	}
-	oldText := text.JoinLines(originalLines)
-	newText := text.JoinLines(lines)
-	return text.ComputeDiff(oldText, newText)
-}
-
 func (b *NvimBuffer) getApplyBatch(startLine, endLineInclusive int, lines []string, diffResult *text.DiffResult) *nvim.Batch {
 	// Create apply batch for the completion
 	applyBatch := b.client.NewBatch()
@@ -618,10 +451,7 @@ func (b *NvimBuffer) getApplyBatch(startLine, endLineInclusive int, lines []stri
 	}
 
 	// Mark as pending; actual commit happens on accept
-	b.pendingStartLine = startLine
-	b.pendingEndLineInclusive = endLineInclusive
-	b.pendingLines = append([]string{}, lines...)
-	b.hasPending = true
+	b.setPending(startLine, endLineInclusive, lines)
 
 	return applyBatch
 }
@@ -647,12 +477,20 @@ func diffResultToLuaFormat(diffResult *text.DiffResult, groups []*text.Group, ne
 			"old_lines":   g.OldLines,
 		}
 
-		// Add render hint for character-level optimizations
+		// Add render hint for character-level optimizations. Columns stay byte
+		// offsets; ui.lua converts to display cells where it needs them.
 		if g.RenderHint != "" {
 			luaGroup["render_hint"] = g.RenderHint
 			luaGroup["col_start"] = g.ColStart
 			luaGroup["col_end"] = g.ColEnd
 		}
+		if len(g.Spans) > 0 {
+			spans := make([]map[string]any, len(g.Spans))
+			for i, span := range g.Spans {
+				spans[i] = map[string]any{"line": span.Line, "col_start": span.ColStart, "col_end": span.ColEnd}
+			}
+			luaGroup["spans"] = spans
+		}
 
 		luaGroups = append(luaGroups, luaGroup)
 	}
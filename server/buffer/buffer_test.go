@@ -205,7 +205,7 @@ func TestExtractGranularDiffs_Deletion(t *testing.T) {
 
 // --- Helper Function Tests ---
 
-func TestMakeRelativeToWorkspace(t *testing.T) {
+func TestRelativeToWorkspace(t *testing.T) {
 	tests := []struct {
 		name          string
 		absolutePath  string
@@ -230,11 +230,17 @@ func TestMakeRelativeToWorkspace(t *testing.T) {
 			workspacePath: "/home/user/project",
 			want:          "main.go",
 		},
+		{
+			name:          "sibling sharing the workspace name as prefix",
+			absolutePath:  "/home/user/project2/main.go",
+			workspacePath: "/home/user/project",
+			want:          "/home/user/project2/main.go",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := makeRelativeToWorkspace(tt.absolutePath, tt.workspacePath)
+			got := RelativeToWorkspace(tt.absolutePath, tt.workspacePath)
 			assert.Equal(t, tt.want, got, "relative path mismatch")
 		})
 	}
@@ -0,0 +1,404 @@
+package buffer
+
+import (
+	"cursortab/text"
+	"cursortab/types"
+	"strings"
+	"sync"
+)
+
+// UICallKind names a call that changes what the editor shows.
+type UICallKind string
+
+const (
+	UIShowCompletion   UICallKind = "show_completion"    // PrepareCompletion
+	UIApplyCompletion  UICallKind = "apply_completion"   // Batch.Execute of a prepared completion
+	UIShowCursorTarget UICallKind = "show_cursor_target" // ShowCursorTarget
+	UIClear            UICallKind = "clear"              // ClearUI
+	UIMoveCursor       UICallKind = "move_cursor"        // MoveCursor
+	UINotify           UICallKind = "notify"             // Notify
+)
+
+// UICall is one UI call captured by a MemoryBuffer.
+type UICall struct {
+	Kind    UICallKind
+	Line    int      // 1-indexed: first replaced line, cursor target or cursor destination
+	EndLine int      // 1-indexed last replaced line, inclusive (completions)
+	Lines   []string // replacement lines (completions)
+	Message string   // notification text
+	Level   int      // notification level (vim.log.levels)
+}
+
+// memoryEditor is the editor side of a MemoryBuffer: what a user sees and
+// types. The engine only observes it through Sync.
+type memoryEditor struct {
+	id             int // changes when another buffer is opened
+	lines          []string
+	row, col       int
+	path           string
+	filetype       string
+	viewportTop    int // 0 = the whole buffer is visible
+	viewportBottom int
+	linterErrors   *types.LinterErrors
+}
+
+// MemoryBuffer is a Buffer held in memory, for driving the engine without
+// Neovim (tests, replay and evaluation tools). Drivers change the editor
+// side with SetLines, SetCursor, Open and friends and send editor events
+// with Emit; the engine sees the changes on its next Sync, as with Neovim.
+// Accepting a completion edits the editor text, and every UI call is
+// captured. Safe for concurrent use.
+type MemoryBuffer struct {
+	mu sync.Mutex
+
+	// Synced state, as the engine sees it
+	textState
+	row            int // 1-indexed
+	col            int // 0-indexed byte offset
+	path           string
+	filetype       string
+	viewportTop    int
+	viewportBottom int
+	syncedID       int
+
+	editor      memoryEditor
+	handler     func(event string)
+	privacyMode bool
+	uiCalls     []UICall
+	discardUI   bool
+}
+
+// NewMemory returns a buffer showing lines of the file at path, with the
+// cursor at the start of the first line. The first Sync reports it as a
+// newly entered buffer.
+func NewMemory(path, filetype string, lines []string) *MemoryBuffer {
+	return &MemoryBuffer{
+		textState: newTextState(),
+		row:       1,
+		editor: memoryEditor{
+			id:       1,
+			lines:    append([]string{}, lines...),
+			row:      1,
+			path:     path,
+			filetype: filetype,
+		},
+	}
+}
+
+// Editor-side methods used by drivers
+
+// Open switches the editor to another file. The next Sync reports a buffer
+// change.
+func (b *MemoryBuffer) Open(path, filetype string, lines []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.editor = memoryEditor{
+		id:       b.editor.id + 1,
+		lines:    append([]string{}, lines...),
+		row:      1,
+		path:     path,
+		filetype: filetype,
+	}
+}
+
+// SetLines replaces the editor text, as if the user had typed it.
+func (b *MemoryBuffer) SetLines(lines []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.editor.lines = append([]string{}, lines...)
+}
+
+// SetCursor moves the editor cursor (1-indexed row, 0-indexed byte column).
+func (b *MemoryBuffer) SetCursor(row, col int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.editor.row, b.editor.col = row, col
+}
+
+// SetViewport sets the visible lines (1-indexed, inclusive). Until it is
+// called the whole buffer is visible.
+func (b *MemoryBuffer) SetViewport(top, bottom int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.editor.viewportTop, b.editor.viewportBottom = top, bottom
+}
+
+// SetLinterErrors sets the diagnostics returned by LinterErrors.
+func (b *MemoryBuffer) SetLinterErrors(errors *types.LinterErrors) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.editor.linterErrors = errors
+}
+
+// EditorLines returns a copy of the editor text, including accepted
+// completions the engine has not synced yet.
+func (b *MemoryBuffer) EditorLines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string{}, b.editor.lines...)
+}
+
+// EditorCursor returns the editor cursor (1-indexed row, 0-indexed column).
+func (b *MemoryBuffer) EditorCursor() (row, col int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.editor.row, b.editor.col
+}
+
+// Emit sends an editor event (such as "text_changed" or "tab") to the
+// registered handler. Returns false when no handler is registered.
+func (b *MemoryBuffer) Emit(event string) bool {
+	b.mu.Lock()
+	handler := b.handler
+	b.mu.Unlock()
+	if handler == nil {
+		return false
+	}
+	handler(event)
+	return true
+}
+
+// UICalls returns the UI calls made so far, oldest first.
+func (b *MemoryBuffer) UICalls() []UICall {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]UICall{}, b.uiCalls...)
+}
+
+// DiscardUICalls stops capturing UI calls, for long-lived buffers whose
+// calls are handled elsewhere.
+func (b *MemoryBuffer) DiscardUICalls() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.discardUI = true
+	b.uiCalls = nil
+}
+
+// PrivacyMode reports whether the engine put the buffer in privacy mode.
+func (b *MemoryBuffer) PrivacyMode() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.privacyMode
+}
+
+// Accessor methods implementing engine.Buffer interface
+
+func (b *MemoryBuffer) Lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lines
+}
+
+func (b *MemoryBuffer) Row() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.row
+}
+
+func (b *MemoryBuffer) Col() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.col
+}
+
+func (b *MemoryBuffer) Path() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.path
+}
+
+func (b *MemoryBuffer) Filetype() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.filetype
+}
+
+func (b *MemoryBuffer) Version() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.version
+}
+
+func (b *MemoryBuffer) ViewportBounds() (top, bottom int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.viewportTop, b.viewportBottom
+}
+
+func (b *MemoryBuffer) PreviousLines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.previousLines
+}
+
+func (b *MemoryBuffer) OriginalLines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.originalLines
+}
+
+func (b *MemoryBuffer) DiffHistories() []*types.DiffEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.diffHistories
+}
+
+// Sync copies the editor state into the buffer state, like NvimBuffer.Sync
+// reading it from Neovim.
+func (b *MemoryBuffer) Sync(workspacePath string) (*SyncResult, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	oldPath := b.path
+	b.lines = append([]string{}, b.editor.lines...)
+	b.row, b.col = b.editor.row, b.editor.col
+	b.filetype = b.editor.filetype
+	b.viewportTop, b.viewportBottom = b.editor.viewportTop, b.editor.viewportBottom
+	if b.viewportBottom == 0 {
+		b.viewportTop, b.viewportBottom = 1, max(len(b.lines), 1)
+	}
+	b.path = RelativeToWorkspace(b.editor.path, workspacePath)
+
+	changed := b.syncedID != b.editor.id
+	if changed {
+		// New buffer: file context is restored by the engine
+		b.syncedID = b.editor.id
+		b.version = 0
+	}
+	return &SyncResult{BufferChanged: changed, OldPath: oldPath, NewPath: b.path}, nil
+}
+
+func (b *MemoryBuffer) SetFileContext(prev, orig []string, diffs []*types.DiffEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.textState.SetFileContext(prev, orig, diffs)
+}
+
+func (b *MemoryBuffer) HasChanges(startLine, endLineInc int, lines []string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.textState.HasChanges(startLine, endLineInc, lines)
+}
+
+// PrepareCompletion captures the completion and returns a batch that
+// applies it to the editor text.
+func (b *MemoryBuffer) PrepareCompletion(startLine, endLineInc int, lines []string, groups []*text.Group) Batch {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.setPending(startLine, endLineInc, lines)
+	b.captureLocked(UICall{
+		Kind:    UIShowCompletion,
+		Line:    startLine,
+		EndLine: endLineInc,
+		Lines:   append([]string{}, lines...),
+	})
+	return &memoryBatch{
+		buf:       b,
+		startLine: startLine,
+		endLine:   endLineInc,
+		lines:     append([]string{}, lines...),
+		diff:      b.getDiffResult(startLine, endLineInc, lines),
+	}
+}
+
+func (b *MemoryBuffer) CommitPending() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.textState.CommitPending()
+}
+
+func (b *MemoryBuffer) CommitUserEdits() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.textState.CommitUserEdits()
+}
+
+func (b *MemoryBuffer) ShowCursorTarget(line int) error {
+	b.recordUI(UICall{Kind: UIShowCursorTarget, Line: line})
+	return nil
+}
+
+// ClearUI captures the call and drops the pending completion.
+func (b *MemoryBuffer) ClearUI() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clearPending()
+	b.captureLocked(UICall{Kind: UIClear})
+	return nil
+}
+
+// MoveCursor moves the editor cursor to the first non-blank character of
+// line, as NvimBuffer.MoveCursor does.
+func (b *MemoryBuffer) MoveCursor(line int, center, mark bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.editor.row = line
+	b.editor.col = 0
+	if line >= 1 && line <= len(b.editor.lines) {
+		content := b.editor.lines[line-1]
+		b.editor.col = len(content) - len(strings.TrimLeft(content, " \t"))
+	}
+	b.captureLocked(UICall{Kind: UIMoveCursor, Line: line})
+	return nil
+}
+
+func (b *MemoryBuffer) LinterErrors() *types.LinterErrors {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.editor.linterErrors
+}
+
+func (b *MemoryBuffer) RegisterEventHandler(handler func(event string)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handler = handler
+	return nil
+}
+
+func (b *MemoryBuffer) Notify(message string, level int) error {
+	b.recordUI(UICall{Kind: UINotify, Message: message, Level: level})
+	return nil
+}
+
+func (b *MemoryBuffer) SetPrivacyMode(enabled bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.privacyMode = enabled
+}
+
+func (b *MemoryBuffer) recordUI(call UICall) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.captureLocked(call)
+}
+
+func (b *MemoryBuffer) captureLocked(call UICall) {
+	if !b.discardUI {
+		b.uiCalls = append(b.uiCalls, call)
+	}
+}
+
+// memoryBatch applies a prepared completion to the editor text and moves
+// the cursor to the end of the change, like the Neovim apply batch.
+type memoryBatch struct {
+	buf       *MemoryBuffer
+	startLine int
+	endLine   int
+	lines     []string
+	diff      *text.DiffResult
+}
+
+func (mb *memoryBatch) Execute() error {
+	b := mb.buf
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.editor.lines = text.SpliceLines(b.editor.lines, mb.startLine, mb.endLine, mb.lines)
+
+	if cursorLine, cursorCol := text.CalculateCursorPosition(mb.diff.Changes, mb.lines); cursorLine >= 0 && cursorCol >= 0 {
+		b.editor.row = mb.startLine + cursorLine - 1
+		b.editor.col = cursorCol
+	}
+	b.captureLocked(UICall{Kind: UIApplyCompletion, Line: mb.startLine, EndLine: mb.endLine, Lines: mb.lines})
+	return nil
+}
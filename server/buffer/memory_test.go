@@ -0,0 +1,106 @@
+package buffer
+
+import (
+	"cursortab/assert"
+	"testing"
+)
+
+func TestMemoryBuffer_SyncPicksUpEditorChanges(t *testing.T) {
+	buf := NewMemory("/work/main.go", "go", []string{"package main", ""})
+
+	result, err := buf.Sync("/work")
+	assert.NoError(t, err, "Sync")
+	assert.True(t, result.BufferChanged, "first sync enters the buffer")
+	assert.Equal(t, "main.go", buf.Path(), "path relative to workspace")
+	assert.Equal(t, "go", buf.Filetype(), "filetype")
+	top, bottom := buf.ViewportBounds()
+	assert.Equal(t, 1, top, "viewport top")
+	assert.Equal(t, 2, bottom, "whole buffer visible")
+
+	buf.SetLines([]string{"package main", "func main() {}"})
+	buf.SetCursor(2, 5)
+	result, err = buf.Sync("/work")
+	assert.NoError(t, err, "Sync")
+	assert.False(t, result.BufferChanged, "same buffer")
+	assert.Equal(t, "func main() {}", buf.Lines()[1], "edited line")
+	assert.Equal(t, 2, buf.Row(), "row")
+	assert.Equal(t, 5, buf.Col(), "col")
+}
+
+func TestMemoryBuffer_OpenSwitchesBuffer(t *testing.T) {
+	buf := NewMemory("a.go", "go", []string{"a"})
+	buf.Sync("")
+	buf.CommitPending() // bumps version
+
+	buf.Open("b.py", "python", []string{"b"})
+	result, _ := buf.Sync("")
+	assert.True(t, result.BufferChanged, "buffer changed")
+	assert.Equal(t, "a.go", result.OldPath, "old path")
+	assert.Equal(t, "b.py", result.NewPath, "new path")
+	assert.Equal(t, 0, buf.Version(), "version reset")
+}
+
+func TestMemoryBuffer_ExecuteAppliesCompletion(t *testing.T) {
+	buf := NewMemory("main.go", "go", []string{"a", "b", "c"})
+	buf.Sync("")
+
+	batch := buf.PrepareCompletion(2, 2, []string{"b changed", "inserted"}, nil)
+	assert.Equal(t, []string{"a", "b", "c"}, buf.EditorLines(), "nothing applied before Execute")
+	assert.NoError(t, batch.Execute(), "Execute")
+	assert.Equal(t, []string{"a", "b changed", "inserted", "c"}, buf.EditorLines(), "completion applied")
+	row, _ := buf.EditorCursor()
+	assert.Greater(t, row, 1, "cursor moved into the change")
+
+	calls := buf.UICalls()
+	assert.Len(t, 2, calls, "UI calls")
+	assert.Equal(t, UIShowCompletion, calls[0].Kind, "shown")
+	assert.Equal(t, UIApplyCompletion, calls[1].Kind, "applied")
+}
+
+func TestMemoryBuffer_CommitPendingRecordsDiff(t *testing.T) {
+	buf := NewMemory("main.go", "go", []string{"a", "b"})
+	buf.Sync("")
+
+	buf.PrepareCompletion(2, 2, []string{"b changed"}, nil).Execute()
+	buf.CommitPending()
+	assert.Equal(t, 1, buf.Version(), "version bumped")
+	assert.Equal(t, []string{"a", "b changed"}, buf.Lines(), "synced lines updated")
+	assert.Greater(t, len(buf.DiffHistories()), 0, "diff recorded")
+}
+
+func TestMemoryBuffer_ClearUIDropsPending(t *testing.T) {
+	buf := NewMemory("main.go", "go", []string{"a"})
+	buf.Sync("")
+
+	buf.PrepareCompletion(1, 1, []string{"b"}, nil)
+	buf.ClearUI()
+	buf.CommitPending()
+	assert.Equal(t, 0, buf.Version(), "nothing to commit")
+	assert.Equal(t, UIClear, buf.UICalls()[1].Kind, "clear captured")
+}
+
+func TestMemoryBuffer_CommitUserEdits(t *testing.T) {
+	buf := NewMemory("main.go", "go", []string{"a"})
+	buf.Sync("")
+	buf.SetFileContext(nil, []string{"a"}, nil)
+
+	buf.SetLines([]string{"a", "typed"})
+	buf.Sync("")
+	assert.True(t, buf.CommitUserEdits(), "user edits committed")
+	assert.Greater(t, len(buf.DiffHistories()), 0, "diff recorded")
+	assert.False(t, buf.CommitUserEdits(), "nothing new")
+}
+
+func TestMemoryBuffer_MoveCursorAndEmit(t *testing.T) {
+	buf := NewMemory("main.go", "go", []string{"a", "\tindented"})
+	buf.MoveCursor(2, true, true)
+	row, col := buf.EditorCursor()
+	assert.Equal(t, 2, row, "row")
+	assert.Equal(t, 1, col, "first non-blank column")
+
+	assert.False(t, buf.Emit("tab"), "no handler")
+	var got string
+	buf.RegisterEventHandler(func(event string) { got = event })
+	assert.True(t, buf.Emit("tab"), "handled")
+	assert.Equal(t, "tab", got, "event delivered")
+}
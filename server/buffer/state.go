@@ -0,0 +1,222 @@
+package buffer
+
+import (
+	"cursortab/text"
+	"cursortab/types"
+)
+
+// textState is the buffer content and edit history shared by every Buffer
+// implementation: what the engine last synced, the checkpoints diffs are
+// taken against, and the completion waiting to be accepted.
+type textState struct {
+	lines         []string
+	version       int
+	diffHistories []*types.DiffEntry // Structured diff history for provider consumption
+	previousLines []string           // Buffer content before the most recent edit (for sweep provider)
+	originalLines []string           // Original file content when editing session started
+
+	// Pending completion state (committed only on accept)
+	pendingStartLine        int
+	pendingEndLineInclusive int
+	pendingLines            []string
+	hasPending              bool
+}
+
+func newTextState() textState {
+	return textState{
+		lines:         []string{},
+		diffHistories: []*types.DiffEntry{},
+		previousLines: []string{},
+		originalLines: []string{},
+	}
+}
+
+// Accessor methods implementing engine.Buffer interface
+
+func (b *textState) Lines() []string { return b.lines }
+
+func (b *textState) Version() int { return b.version }
+
+func (b *textState) PreviousLines() []string { return b.previousLines }
+
+func (b *textState) OriginalLines() []string { return b.originalLines }
+
+func (b *textState) DiffHistories() []*types.DiffEntry { return b.diffHistories }
+
+// setPending marks a completion as prepared; it is committed on accept.
+func (b *textState) setPending(startLine, endLineInclusive int, lines []string) {
+	b.pendingStartLine = startLine
+	b.pendingEndLineInclusive = endLineInclusive
+	b.pendingLines = append([]string{}, lines...)
+	b.hasPending = true
+}
+
+// clearPending drops a prepared completion that was not accepted.
+func (b *textState) clearPending() {
+	b.hasPending = false
+	b.pendingLines = nil
+}
+
+// HasChanges checks if the proposed completion would introduce actual changes
+func (b *textState) HasChanges(startLine, endLineInclusive int, lines []string) bool {
+	// Check the original replacement range for changes
+	for i := startLine; i <= endLineInclusive; i++ {
+		relativeLineIdx := i - startLine
+
+		var l *string
+		var realL *string
+
+		if i-1 >= 0 && i-1 < len(b.lines) {
+			realL = &b.lines[i-1]
+		}
+
+		if relativeLineIdx < len(lines) {
+			l = &lines[relativeLineIdx]
+		}
+
+		if (l != nil && realL != nil && *l != *realL) ||
+			(l != nil && realL == nil) ||
+			(l == nil && realL != nil) {
+			return true
+		}
+	}
+
+	// Check if there are additional lines beyond the replacement range (insertions)
+	if startLine+len(lines)-1 > endLineInclusive {
+		return true
+	}
+
+	return false
+}
+
+// SetFileContext restores file-specific state when switching back to a previously edited file.
+// This is called by the engine after detecting a file switch.
+func (b *textState) SetFileContext(previousLines, originalLines []string, diffHistories []*types.DiffEntry) {
+	if previousLines != nil {
+		b.previousLines = make([]string, len(previousLines))
+		copy(b.previousLines, previousLines)
+	} else if originalLines != nil {
+		// For new files without previous state, initialize previousLines to the original
+		// file content. This ensures providers (like sweep) have a valid "before" state
+		// to compare against, rather than falling back to current content.
+		b.previousLines = make([]string, len(originalLines))
+		copy(b.previousLines, originalLines)
+	} else {
+		b.previousLines = nil
+	}
+
+	if diffHistories != nil {
+		b.diffHistories = make([]*types.DiffEntry, len(diffHistories))
+		copy(b.diffHistories, diffHistories)
+	} else {
+		b.diffHistories = []*types.DiffEntry{}
+	}
+
+	if originalLines != nil {
+		b.originalLines = make([]string, len(originalLines))
+		copy(b.originalLines, originalLines)
+	}
+}
+
+// CommitPending applies the pending edit to buffer state, increments version,
+// and appends structured diff entries showing before/after content. No-op if no pending edit.
+func (b *textState) CommitPending() {
+	if !b.hasPending {
+		return
+	}
+
+	startLine := b.pendingStartLine
+	endLineInclusive := b.pendingEndLineInclusive
+	lines := b.pendingLines
+
+	// Extract only the affected original lines (the range being replaced)
+	var originalRangeLines []string
+	for i := startLine; i <= endLineInclusive && i-1 < len(b.originalLines); i++ {
+		originalRangeLines = append(originalRangeLines, b.originalLines[i-1])
+	}
+
+	// Extract granular diffs - one DiffEntry per contiguous changed region
+	diffEntries := extractGranularDiffs(originalRangeLines, lines)
+	b.diffHistories = append(b.diffHistories, diffEntries...)
+
+	// Compute the final buffer state after applying the completion
+	newLines := make([]string, 0, len(b.lines)-((endLineInclusive-startLine)+1)+len(lines))
+	if startLine-1 > 0 && startLine-1 <= len(b.lines) {
+		newLines = append(newLines, b.lines[:startLine-1]...)
+	}
+	newLines = append(newLines, lines...)
+	if endLineInclusive < len(b.lines) {
+		newLines = append(newLines, b.lines[endLineInclusive:]...)
+	}
+
+	// Reset checkpoint to current state for next working diff
+	b.originalLines = make([]string, len(newLines))
+	copy(b.originalLines, newLines)
+
+	// Save current lines as previous state BEFORE updating (for sweep provider)
+	b.previousLines = make([]string, len(b.lines))
+	copy(b.previousLines, b.lines)
+
+	// Commit the new content and bump version
+	b.lines = make([]string, len(newLines))
+	copy(b.lines, newLines)
+	b.version++
+
+	// Clear pending
+	b.pendingStartLine = 0
+	b.pendingEndLineInclusive = 0
+	b.pendingLines = nil
+	b.hasPending = false
+}
+
+// CommitUserEdits extracts diffs between originalLines checkpoint and current lines,
+// appends them to diffHistories, and resets the checkpoint.
+// Call this when leaving insert mode to capture manual edits.
+// Returns true if any changes were committed, false if no changes.
+func (b *textState) CommitUserEdits() bool {
+	// Quick check: if lengths differ, there are changes
+	if len(b.lines) != len(b.originalLines) {
+		return b.commitUserEditsInternal()
+	}
+
+	// Check for content differences
+	for i := range b.lines {
+		if b.lines[i] != b.originalLines[i] {
+			return b.commitUserEditsInternal()
+		}
+	}
+
+	return false // No changes
+}
+
+func (b *textState) commitUserEditsInternal() bool {
+	// Extract granular diffs between checkpoint and current state
+	diffEntries := extractGranularDiffs(b.originalLines, b.lines)
+	if len(diffEntries) == 0 {
+		return false
+	}
+
+	b.diffHistories = append(b.diffHistories, diffEntries...)
+
+	// Save checkpoint as previous state (for sweep provider)
+	b.previousLines = make([]string, len(b.originalLines))
+	copy(b.previousLines, b.originalLines)
+
+	// Reset checkpoint to current state
+	b.originalLines = make([]string, len(b.lines))
+	copy(b.originalLines, b.lines)
+
+	b.version++
+	return true
+}
+
+// getDiffResult diffs the replaced range of the synced lines against lines.
+func (b *textState) getDiffResult(startLine, endLineInclusive int, lines []string) *text.DiffResult {
+	originalLines := []string{}
+	for i := startLine; i <= endLineInclusive && i-1 < len(b.lines); i++ {
+		originalLines = append(originalLines, b.lines[i-1])
+	}
+	oldText := text.JoinLines(originalLines)
+	newText := text.JoinLines(lines)
+	return text.ComputeDiff(oldText, newText)
+}
@@ -0,0 +1,196 @@
+// Package apikey resolves provider API keys from config, the environment,
+// a credentials file or the output of a command, caching keys that are
+// expensive to obtain.
+package apikey
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"cursortab/utils"
+)
+
+// commandTimeout bounds how long an api_key_command may run. Password
+// managers can prompt for a passphrase, so this is generous.
+const commandTimeout = 30 * time.Second
+
+// Config lists the places a key can come from. The first non-empty source
+// wins: Key, then Command, then File, then the Env variable.
+type Config struct {
+	Key     string        // Literal key from config
+	Command string        // Shell command printing the key on stdout
+	File    string        // File containing the key (must not be group/world readable)
+	Env     string        // Environment variable holding the key
+	TTL     time.Duration // How long a key from Command or File is cached (0 = until invalidated)
+}
+
+// Source returns the current API key. Safe for concurrent use.
+type Source struct {
+	config Config
+	now    func() time.Time
+	run    func(ctx context.Context, command string) ([]byte, error)
+
+	mu        sync.Mutex
+	key       string
+	fetchedAt time.Time
+	pending   *fetch // command or file read in progress, nil if none
+}
+
+// fetch is a key lookup shared by every caller waiting for it.
+type fetch struct {
+	done chan struct{}
+	key  string
+	err  error
+}
+
+// New returns a Source for config.
+func New(config Config) *Source {
+	return &Source{config: config, now: time.Now, run: runCommand}
+}
+
+// Key returns the API key, running the command or reading the file when the
+// cached key is missing or older than the TTL. The lookup is not tied to ctx:
+// a command waiting for a passphrase keeps running when the request gives up,
+// and later calls pick up its result. While a refresh is in progress callers
+// get the expired key rather than waiting for it.
+func (s *Source) Key(ctx context.Context) (string, error) {
+	switch {
+	case s.config.Key != "":
+		return s.config.Key, nil
+	case s.config.Command == "" && s.config.File == "":
+		if key := os.Getenv(s.config.Env); key != "" {
+			return key, nil
+		}
+		return "", fmt.Errorf("API key not found: set %s environment variable or provide api_key, api_key_command or api_key_file in config", s.config.Env)
+	}
+
+	s.mu.Lock()
+	if s.key != "" && (s.config.TTL <= 0 || s.now().Sub(s.fetchedAt) < s.config.TTL) {
+		key := s.key
+		s.mu.Unlock()
+		return key, nil
+	}
+	if s.key != "" && s.pending != nil {
+		key := s.key
+		s.mu.Unlock()
+		return key, nil
+	}
+	f := s.pending
+	if f == nil {
+		f = &fetch{done: make(chan struct{})}
+		s.pending = f
+		go s.fetch(f)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.key, f.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// fetch runs the command or reads the file and caches the key on success.
+func (s *Source) fetch(f *fetch) {
+	if s.config.Command != "" {
+		f.key, f.err = s.fromCommand()
+	} else {
+		f.key, f.err = s.fromFile()
+	}
+
+	s.mu.Lock()
+	if f.err == nil {
+		s.key = f.key
+		s.fetchedAt = s.now()
+	}
+	s.pending = nil
+	s.mu.Unlock()
+	close(f.done)
+}
+
+// Refreshable reports whether Invalidate can produce a different key.
+func (s *Source) Refreshable() bool {
+	return s.config.Key == "" && (s.config.Command != "" || s.config.File != "")
+}
+
+// Invalidate drops the cached key so the next Key call fetches it again.
+// Called when the provider rejects the key, so rotated keys are picked up
+// without a restart.
+func (s *Source) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.key = ""
+}
+
+func (s *Source) fromCommand() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	out, err := s.run(ctx, s.config.Command)
+	if err != nil {
+		return "", fmt.Errorf("api_key_command failed: %w", err)
+	}
+	key := firstLine(out)
+	if key == "" {
+		return "", fmt.Errorf("api_key_command printed no key")
+	}
+	return key, nil
+}
+
+func (s *Source) fromFile() (string, error) {
+	path := utils.ExpandHome(s.config.File)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("api_key_file: %w", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return "", fmt.Errorf("api_key_file %s is accessible by other users (mode %04o): run chmod 600 %s",
+			path, info.Mode().Perm(), path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("api_key_file: %w", err)
+	}
+	key := firstLine(data)
+	if key == "" {
+		return "", fmt.Errorf("api_key_file %s is empty", path)
+	}
+	return key, nil
+}
+
+// runCommand runs command through the platform shell and returns stdout.
+// Stderr is included in the error so failures such as a locked vault are
+// visible in the log.
+func runCommand(ctx context.Context, command string) ([]byte, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// firstLine returns the first line of data without surrounding whitespace.
+// Tools like pass print metadata after the secret on later lines.
+func firstLine(data []byte) string {
+	line, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimSpace(line)
+}
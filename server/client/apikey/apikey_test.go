@@ -0,0 +1,147 @@
+package apikey
+
+import (
+	"context"
+	"cursortab/assert"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestKey_StaticWinsOverOtherSources(t *testing.T) {
+	t.Setenv("TEST_API_KEY", "from-env")
+	s := New(Config{Key: "static", Command: "echo cmd", Env: "TEST_API_KEY"})
+
+	key, err := s.Key(context.Background())
+	assert.NoError(t, err, "Key")
+	assert.Equal(t, "static", key, "static key")
+	assert.False(t, s.Refreshable(), "static key cannot be refreshed")
+}
+
+func TestKey_Env(t *testing.T) {
+	t.Setenv("TEST_API_KEY", "from-env")
+	key, err := New(Config{Env: "TEST_API_KEY"}).Key(context.Background())
+	assert.NoError(t, err, "Key")
+	assert.Equal(t, "from-env", key, "env key")
+
+	_, err = New(Config{Env: "TEST_API_KEY_UNSET"}).Key(context.Background())
+	assert.Error(t, err, "missing env var")
+	assert.Contains(t, err.Error(), "TEST_API_KEY_UNSET", "error names the variable")
+}
+
+func TestKey_CommandCachedForTTL(t *testing.T) {
+	now := time.Unix(0, 0)
+	runs := 0
+	s := New(Config{Command: "pass show sweep", TTL: time.Minute})
+	s.now = func() time.Time { return now }
+	s.run = func(_ context.Context, command string) ([]byte, error) {
+		runs++
+		assert.Equal(t, "pass show sweep", command, "command")
+		return []byte("  key-" + string(rune('0'+runs)) + "\nurl: example.com\n"), nil
+	}
+
+	key, _ := s.Key(context.Background())
+	assert.Equal(t, "key-1", key, "first line, trimmed")
+	key, _ = s.Key(context.Background())
+	assert.Equal(t, "key-1", key, "cached")
+	assert.Equal(t, 1, runs, "command ran once")
+
+	now = now.Add(time.Minute)
+	key, _ = s.Key(context.Background())
+	assert.Equal(t, "key-2", key, "refetched after TTL")
+
+	s.Invalidate()
+	key, _ = s.Key(context.Background())
+	assert.Equal(t, "key-3", key, "refetched after invalidation")
+	assert.True(t, s.Refreshable(), "command keys can be refreshed")
+}
+
+func TestKey_CommandOutlivesRequest(t *testing.T) {
+	now := time.Unix(0, 0)
+	release := make(chan struct{})
+	started := make(chan context.Context, 2)
+	runs := 0
+	s := New(Config{Command: "pass show sweep", TTL: time.Minute})
+	s.now = func() time.Time { return now }
+	s.run = func(ctx context.Context, _ string) ([]byte, error) {
+		runs++
+		started <- ctx
+		<-release
+		return []byte("key-" + string(rune('0'+runs))), ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := s.Key(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "request gives up")
+	cmdCtx := <-started
+	assert.NoError(t, cmdCtx.Err(), "command not cancelled with the request")
+
+	release <- struct{}{}
+	key, err := s.Key(context.Background())
+	assert.NoError(t, err, "Key")
+	assert.Equal(t, "key-1", key, "result of the detached command")
+	assert.Equal(t, 1, runs, "command not rerun")
+
+	now = now.Add(time.Minute)
+	waited := make(chan string)
+	go func() {
+		key, _ := s.Key(context.Background())
+		waited <- key
+	}()
+	<-started
+	key, err = s.Key(context.Background())
+	assert.NoError(t, err, "Key during refresh")
+	assert.Equal(t, "key-1", key, "expired key served while refreshing")
+
+	release <- struct{}{}
+	assert.Equal(t, "key-2", <-waited, "refreshing caller gets the new key")
+}
+
+func TestKey_CommandErrors(t *testing.T) {
+	s := New(Config{Command: "false"})
+	s.run = func(context.Context, string) ([]byte, error) { return nil, errors.New("exit status 1: vault locked") }
+	_, err := s.Key(context.Background())
+	assert.Error(t, err, "failing command")
+	assert.Contains(t, err.Error(), "vault locked", "stderr included")
+
+	s.run = func(context.Context, string) ([]byte, error) { return []byte("\n"), nil }
+	_, err = s.Key(context.Background())
+	assert.Error(t, err, "empty output")
+}
+
+func TestKey_RunsShellCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	key, err := New(Config{Command: "printf 'sk-123\\n'"}).Key(context.Background())
+	assert.NoError(t, err, "Key")
+	assert.Equal(t, "sk-123", key, "command output")
+}
+
+func TestKey_File(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not enforced on windows")
+	}
+	path := filepath.Join(t.TempDir(), "sweep.key")
+	if err := os.WriteFile(path, []byte("sk-file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := New(Config{File: path}).Key(context.Background())
+	assert.Error(t, err, "world-readable file rejected")
+	assert.Contains(t, err.Error(), "chmod 600", "error explains the fix")
+
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := New(Config{File: path}).Key(context.Background())
+	assert.NoError(t, err, "Key")
+	assert.Equal(t, "sk-file", key, "file key")
+
+	_, err = New(Config{File: filepath.Join(t.TempDir(), "missing")}).Key(context.Background())
+	assert.Error(t, err, "missing file")
+}
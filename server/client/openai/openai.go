@@ -162,6 +162,7 @@ func (c *Client) DoLineStream(ctx context.Context, req *CompletionRequest, maxLi
 // runLineStream executes the streaming request and sends lines to the channel
 func (c *Client) runLineStream(ctx context.Context, req *CompletionRequest, lines chan<- string, maxLines int, stopTokens []string) StreamResult {
 	defer logger.Trace("openai.runLineStream")()
+	log := logger.FromContext(ctx, "openai")
 	req.Stream = true
 
 	// Marshal the request without HTML escaping
@@ -169,14 +170,14 @@ func (c *Client) runLineStream(ctx context.Context, req *CompletionRequest, line
 	encoder := json.NewEncoder(&reqBodyBuf)
 	encoder.SetEscapeHTML(false)
 	if err := encoder.Encode(req); err != nil {
-		logger.Error("line stream: failed to marshal request: %v", err)
+		log.Error("line stream: failed to marshal request: %v", err)
 		return StreamResult{FinishReason: "error"}
 	}
 
 	// Create HTTP request
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.URL+c.CompletionPath, &reqBodyBuf)
 	if err != nil {
-		logger.Error("line stream: failed to create request: %v", err)
+		log.Error("line stream: failed to create request: %v", err)
 		return StreamResult{FinishReason: "error"}
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
@@ -188,7 +189,7 @@ func (c *Client) runLineStream(ctx context.Context, req *CompletionRequest, line
 		if ctx.Err() != nil {
 			return StreamResult{FinishReason: "cancelled"}
 		}
-		logger.Error("line stream: failed to send request: %v", err)
+		log.Error("line stream: failed to send request: %v", err)
 		return StreamResult{FinishReason: "error"}
 	}
 	defer resp.Body.Close()
@@ -196,7 +197,7 @@ func (c *Client) runLineStream(ctx context.Context, req *CompletionRequest, line
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		logger.Error("line stream: request failed with status %d: %s", resp.StatusCode, string(body))
+		log.Error("line stream: request failed with status %d: %s", resp.StatusCode, string(body))
 		return StreamResult{FinishReason: "error"}
 	}
 
@@ -205,6 +206,7 @@ func (c *Client) runLineStream(ctx context.Context, req *CompletionRequest, line
 
 // processLineStream reads SSE events and emits complete lines
 func (c *Client) processLineStream(ctx context.Context, body io.Reader, lines chan<- string, maxLines int, stopTokens []string) StreamResult {
+	log := logger.FromContext(ctx, "openai")
 	var textBuilder strings.Builder
 	var lineBuffer strings.Builder
 	var finishReason string
@@ -250,7 +252,7 @@ func (c *Client) processLineStream(ctx context.Context, body io.Reader, lines ch
 		jsonData := strings.TrimPrefix(line, "data: ")
 		var chunk StreamChunk
 		if err := json.Unmarshal([]byte(jsonData), &chunk); err != nil {
-			logger.Debug("line stream: failed to parse chunk: %v", err)
+			log.Debug("line stream: failed to parse chunk: %v", err)
 			continue
 		}
 
@@ -302,7 +304,7 @@ func (c *Client) processLineStream(ctx context.Context, body io.Reader, lines ch
 					// Check line limit
 					if maxLines > 0 && lineCount >= maxLines {
 						stoppedEarly = true
-						logger.Debug("line stream: stopping early at %d lines (max: %d)", lineCount, maxLines)
+						log.Debug("line stream: stopping early at %d lines (max: %d)", lineCount, maxLines)
 						return StreamResult{
 							Text:         textBuilder.String(),
 							FinishReason: "length",
@@ -322,7 +324,7 @@ func (c *Client) processLineStream(ctx context.Context, body io.Reader, lines ch
 	}
 
 	if err := scanner.Err(); err != nil {
-		logger.Debug("line stream: scanner error: %v", err)
+		log.Debug("line stream: scanner error: %v", err)
 	}
 
 	// Emit any remaining content as final line (handles truncation)
@@ -371,6 +373,7 @@ func (c *Client) DoTokenStream(ctx context.Context, req *CompletionRequest, maxC
 // runTokenStream executes the streaming request and sends cumulative text to the channel
 func (c *Client) runTokenStream(ctx context.Context, req *CompletionRequest, textChan chan<- string, maxChars int, stopTokens []string) StreamResult {
 	defer logger.Trace("openai.runTokenStream")()
+	log := logger.FromContext(ctx, "openai")
 	req.Stream = true
 
 	// Marshal the request without HTML escaping
@@ -378,14 +381,14 @@ func (c *Client) runTokenStream(ctx context.Context, req *CompletionRequest, tex
 	encoder := json.NewEncoder(&reqBodyBuf)
 	encoder.SetEscapeHTML(false)
 	if err := encoder.Encode(req); err != nil {
-		logger.Error("token stream: failed to marshal request: %v", err)
+		log.Error("token stream: failed to marshal request: %v", err)
 		return StreamResult{FinishReason: "error"}
 	}
 
 	// Create HTTP request
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.URL+c.CompletionPath, &reqBodyBuf)
 	if err != nil {
-		logger.Error("token stream: failed to create request: %v", err)
+		log.Error("token stream: failed to create request: %v", err)
 		return StreamResult{FinishReason: "error"}
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
@@ -397,7 +400,7 @@ func (c *Client) runTokenStream(ctx context.Context, req *CompletionRequest, tex
 		if ctx.Err() != nil {
 			return StreamResult{FinishReason: "cancelled"}
 		}
-		logger.Error("token stream: failed to send request: %v", err)
+		log.Error("token stream: failed to send request: %v", err)
 		return StreamResult{FinishReason: "error"}
 	}
 	defer resp.Body.Close()
@@ -405,7 +408,7 @@ func (c *Client) runTokenStream(ctx context.Context, req *CompletionRequest, tex
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		logger.Error("token stream: request failed with status %d: %s", resp.StatusCode, string(body))
+		log.Error("token stream: request failed with status %d: %s", resp.StatusCode, string(body))
 		return StreamResult{FinishReason: "error"}
 	}
 
@@ -414,6 +417,7 @@ func (c *Client) runTokenStream(ctx context.Context, req *CompletionRequest, tex
 
 // processTokenStream reads SSE events and emits cumulative text after each chunk
 func (c *Client) processTokenStream(ctx context.Context, body io.Reader, textChan chan<- string, maxChars int, stopTokens []string) StreamResult {
+	log := logger.FromContext(ctx, "openai")
 	var textBuilder strings.Builder
 	var finishReason string
 	stoppedEarly := false
@@ -457,7 +461,7 @@ func (c *Client) processTokenStream(ctx context.Context, body io.Reader, textCha
 		jsonData := strings.TrimPrefix(line, "data: ")
 		var chunk StreamChunk
 		if err := json.Unmarshal([]byte(jsonData), &chunk); err != nil {
-			logger.Debug("token stream: failed to parse chunk: %v", err)
+			log.Debug("token stream: failed to parse chunk: %v", err)
 			continue
 		}
 
@@ -493,7 +497,7 @@ func (c *Client) processTokenStream(ctx context.Context, body io.Reader, textCha
 			// Check character limit
 			if maxChars > 0 && textBuilder.Len() >= maxChars {
 				stoppedEarly = true
-				logger.Debug("token stream: stopping early at %d chars (max: %d)", textBuilder.Len(), maxChars)
+				log.Debug("token stream: stopping early at %d chars (max: %d)", textBuilder.Len(), maxChars)
 				// Emit final accumulated text before stopping
 				select {
 				case textChan <- textBuilder.String():
@@ -521,7 +525,7 @@ func (c *Client) processTokenStream(ctx context.Context, body io.Reader, textCha
 	}
 
 	if err := scanner.Err(); err != nil {
-		logger.Debug("token stream: scanner error: %v", err)
+		log.Debug("token stream: scanner error: %v", err)
 	}
 
 	return StreamResult{
@@ -10,13 +10,16 @@ import (
 	"io"
 	"net"
 	"net/http"
-	"os"
+	"net/http/httptrace"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/andybalholm/brotli"
 
+	"cursortab/client/apikey"
 	"cursortab/logger"
+	"cursortab/metrics"
 )
 
 const (
@@ -25,29 +28,23 @@ const (
 	DefaultAPIKeyEnv        = "SWEEP_AI_TOKEN"
 )
 
+// retries counts DoAutocomplete retries by reason (transport, response, auth)
+var retries = metrics.NewCounter("cursortab_sweep_retries_total",
+	"Sweep autocomplete retries by reason (transport, response, auth).",
+	"reason")
+
 // Client is a reusable Sweep API client for hosted Sweep
 type Client struct {
 	HTTPClient *http.Client
 	BaseURL    string
-	APIKey     string
+	Keys       *apikey.Source
 }
 
-// NewClient creates a new Sweep client with the given base URL and API key configuration
-// If apiKey is empty, it will attempt to read from the environment variable specified by apiKeyEnv
-// (or SWEEP_AI_TOKEN if apiKeyEnv is empty)
-func NewClient(baseURL, apiKey, apiKeyEnv string) (*Client, error) {
-	// Resolve API key
-	resolvedKey := apiKey
-	if resolvedKey == "" {
-		envVar := apiKeyEnv
-		if envVar == "" {
-			envVar = DefaultAPIKeyEnv
-		}
-		resolvedKey = os.Getenv(envVar)
-	}
-
-	if resolvedKey == "" {
-		return nil, fmt.Errorf("sweep API key not found: set %s environment variable or provide api_key in config", getEnvVarName(apiKeyEnv))
+// NewClient creates a new Sweep client with the given base URL and API key source.
+// The key is resolved once up front so a missing or unreadable key is reported at startup.
+func NewClient(baseURL string, keys *apikey.Source) (*Client, error) {
+	if _, err := keys.Key(context.Background()); err != nil {
+		return nil, fmt.Errorf("sweep: %w", err)
 	}
 
 	// Use custom transport to force HTTP/1.1 (avoid HTTP/2 stream errors)
@@ -68,21 +65,45 @@ func NewClient(baseURL, apiKey, apiKeyEnv string) (*Client, error) {
 			Transport: transport,
 		},
 		BaseURL: baseURL,
-		APIKey:  resolvedKey,
+		Keys:    keys,
 	}, nil
 }
 
-// getEnvVarName returns the environment variable name to use
-func getEnvVarName(apiKeyEnv string) string {
-	if apiKeyEnv != "" {
-		return apiKeyEnv
-	}
-	return DefaultAPIKeyEnv
+// NotSentError wraps a DoAutocomplete error that happened before any
+// request body was written, so nothing left the machine.
+type NotSentError struct {
+	Err error
+}
+
+func (e *NotSentError) Error() string { return e.Err.Error() }
+func (e *NotSentError) Unwrap() error { return e.Err }
+
+// Sent reports whether the request that returned err was written to the
+// network: true on success and for errors after the body was sent.
+func Sent(err error) bool {
+	var notSent *NotSentError
+	return !errors.As(err, &notSent)
 }
 
-// DoAutocomplete sends an autocomplete request to Sweep's hosted API
-func (c *Client) DoAutocomplete(ctx context.Context, req *AutocompleteRequest) (*AutocompleteResponse, error) {
+// DoAutocomplete sends an autocomplete request to Sweep's hosted API.
+// Errors from before the request body was written are *NotSentError.
+func (c *Client) DoAutocomplete(ctx context.Context, req *AutocompleteRequest) (_ *AutocompleteResponse, err error) {
 	defer logger.Trace("sweep.DoAutocomplete")()
+	log := logger.FromContext(ctx, "sweep")
+
+	var sent atomic.Bool
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				sent.Store(true)
+			}
+		},
+	})
+	defer func() {
+		if err != nil && !sent.Load() {
+			err = &NotSentError{Err: err}
+		}
+	}()
 
 	jsonBody, err := json.Marshal(req)
 	if err != nil {
@@ -105,10 +126,11 @@ func (c *Client) DoAutocomplete(ctx context.Context, req *AutocompleteRequest) (
 	url := c.BaseURL + DefaultAutocompletePath
 	compressedBytes := compressedBody.Bytes()
 
-	logger.Debug("sweep autocomplete request: URL=%s, file_path=%s, body_len=%d, compressed_len=%d", url, req.FilePath, len(jsonBody), len(compressedBytes))
+	log.Debug("sweep autocomplete request: URL=%s, file_path=%s, body_len=%d, compressed_len=%d", url, req.FilePath, len(jsonBody), len(compressedBytes))
 
 	const maxAttempts = 3
 	var lastErr error
+	keyRefreshed := false
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		if attempt > 1 {
 			// Small backoff to avoid hammering on transient transport issues.
@@ -120,13 +142,18 @@ func (c *Client) DoAutocomplete(ctx context.Context, req *AutocompleteRequest) (
 			}
 		}
 
+		apiKey, err := c.Keys.Key(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("sweep: %w", err)
+		}
+
 		httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(compressedBytes))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
 
 		httpReq.Header.Set("Content-Type", "application/json")
-		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
 		httpReq.Header.Set("Connection", "keep-alive")
 		httpReq.Header.Set("Content-Encoding", "br")
 
@@ -134,7 +161,8 @@ func (c *Client) DoAutocomplete(ctx context.Context, req *AutocompleteRequest) (
 		if err != nil {
 			lastErr = err
 			if attempt < maxAttempts && isRetryableTransportError(err) {
-				logger.Debug("sweep autocomplete transient transport error (attempt %d/%d): %v", attempt, maxAttempts, err)
+				log.Debug("sweep autocomplete transient transport error (attempt %d/%d): %v", attempt, maxAttempts, err)
+				retries.Inc("transport")
 				continue
 			}
 			return nil, fmt.Errorf("failed to send request: %w", err)
@@ -143,21 +171,33 @@ func (c *Client) DoAutocomplete(ctx context.Context, req *AutocompleteRequest) (
 		respBody, statusCode, err := readSweepResponse(resp)
 		if err != nil {
 			lastErr = err
+			// A rejected key may have been rotated: fetch it again and retry once
+			if isAuthError(statusCode) && !keyRefreshed && c.Keys.Refreshable() {
+				log.Info("sweep: API key rejected (status %d), refreshing key", statusCode)
+				c.Keys.Invalidate()
+				keyRefreshed = true
+				attempt-- // the refresh does not count as a retry
+				retries.Inc("auth")
+				continue
+			}
 			if attempt < maxAttempts && isRetryableResponseError(statusCode, err) {
-				logger.Debug("sweep autocomplete transient response error (attempt %d/%d): %v", attempt, maxAttempts, err)
+				log.Debug("sweep autocomplete transient response error (attempt %d/%d): %v", attempt, maxAttempts, err)
+				retries.Inc("response")
 				continue
 			}
 			return nil, err
 		}
 
-		logger.Debug("sweep autocomplete raw response: %s", string(respBody))
+		if !req.PrivacyModeEnabled {
+			log.Debug("sweep autocomplete raw response: %s", string(respBody))
+		}
 
 		var autoResp AutocompleteResponse
 		if err := json.Unmarshal(respBody, &autoResp); err != nil {
 			return nil, fmt.Errorf("failed to decode response: %w", err)
 		}
 
-		logger.Debug("sweep autocomplete response: id=%s, start=%d, end=%d, completion_len=%d", autoResp.AutocompleteID, autoResp.StartIndex, autoResp.EndIndex, len(autoResp.Completion))
+		log.Debug("sweep autocomplete response: id=%s, start=%d, end=%d, completion_len=%d", autoResp.AutocompleteID, autoResp.StartIndex, autoResp.EndIndex, len(autoResp.Completion))
 		return &autoResp, nil
 	}
 
@@ -180,6 +220,10 @@ func readSweepResponse(resp *http.Response) ([]byte, int, error) {
 	return body, resp.StatusCode, nil
 }
 
+func isAuthError(statusCode int) bool {
+	return statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden
+}
+
 func isRetryableResponseError(statusCode int, err error) bool {
 	// Retry on transient read failures even when status is 200.
 	if isRetryableTransportError(err) {
@@ -223,37 +267,49 @@ func isRetryableTransportError(err error) bool {
 	return false
 }
 
-// SendMetrics sends metrics to Sweep's metrics endpoint (fire-and-forget)
+// SendMetrics sends metrics to Sweep's metrics endpoint (fire-and-forget).
+// Nothing is sent for requests made in privacy mode.
 func (c *Client) SendMetrics(ctx context.Context, req *MetricsRequest) {
 	defer logger.Trace("sweep.SendMetrics")()
+	log := logger.FromContext(ctx, "sweep")
+
+	if req.PrivacyModeEnabled {
+		return
+	}
 
 	body, err := json.Marshal(req)
 	if err != nil {
-		logger.Debug("sweep metrics: failed to marshal request: %v", err)
+		log.Debug("sweep metrics: failed to marshal request: %v", err)
 		return
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+DefaultMetricsPath, bytes.NewReader(body))
 	if err != nil {
-		logger.Debug("sweep metrics: failed to create request: %v", err)
+		log.Debug("sweep metrics: failed to create request: %v", err)
+		return
+	}
+
+	apiKey, err := c.Keys.Key(ctx)
+	if err != nil {
+		log.Debug("sweep metrics: %v", err)
 		return
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
 
 	// Fire-and-forget: don't wait for response
 	go func() {
 		resp, err := c.HTTPClient.Do(httpReq)
 		if err != nil {
-			logger.Debug("sweep metrics: failed to send: %v", err)
+			log.Debug("sweep metrics: failed to send: %v", err)
 			return
 		}
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
 			body, _ := io.ReadAll(resp.Body)
-			logger.Debug("sweep metrics: request failed with status %d: %s", resp.StatusCode, string(body))
+			log.Debug("sweep metrics: request failed with status %d: %s", resp.StatusCode, string(body))
 		}
 	}()
 }
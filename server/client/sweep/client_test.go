@@ -0,0 +1,89 @@
+package sweep
+
+import (
+	"context"
+	"cursortab/assert"
+	"cursortab/client/apikey"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewClient_MissingKey(t *testing.T) {
+	_, err := NewClient("http://localhost", apikey.New(apikey.Config{Env: "CURSORTAB_TEST_UNSET_KEY"}))
+	assert.Error(t, err, "missing key reported at startup")
+}
+
+func TestDoAutocomplete_RefreshesRejectedKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	writeKey := func(key string) {
+		if err := os.WriteFile(keyFile, []byte(key), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeKey("old-key")
+
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		seen = append(seen, auth)
+		if auth != "Bearer new-key" {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(AutocompleteResponse{Completion: "ok"})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, apikey.New(apikey.Config{File: keyFile}))
+	assert.NoError(t, err, "NewClient")
+
+	// Key rotated after the daemon started
+	writeKey("new-key")
+	before := retries.Value("auth")
+
+	resp, err := client.DoAutocomplete(context.Background(), &AutocompleteRequest{FilePath: "main.go"})
+	assert.NoError(t, err, "DoAutocomplete")
+	assert.Equal(t, "ok", resp.Completion, "completion")
+	assert.Equal(t, []string{"Bearer old-key", "Bearer new-key"}, seen, "retried once with the refreshed key")
+	assert.Equal(t, before+1, retries.Value("auth"), "retry counted")
+}
+
+func TestDoAutocomplete_StaticKeyNotRetried(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, apikey.New(apikey.Config{Key: "static"}))
+	assert.NoError(t, err, "NewClient")
+
+	_, err = client.DoAutocomplete(context.Background(), &AutocompleteRequest{})
+	assert.Error(t, err, "rejected key")
+	assert.Equal(t, 1, calls, "no retry when the key cannot change")
+	assert.True(t, Sent(err), "request reached the server")
+}
+
+func TestDoAutocomplete_NotSentBeforeWrite(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request should not be sent")
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, apikey.New(apikey.Config{Key: "static"}))
+	assert.NoError(t, err, "NewClient")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.DoAutocomplete(ctx, &AutocompleteRequest{})
+	assert.Error(t, err, "canceled")
+	assert.False(t, Sent(err), "nothing written")
+	assert.True(t, errors.Is(err, context.Canceled), "cause kept")
+	assert.True(t, Sent(nil), "success was sent")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"cursortab/complete"
+	"cursortab/engine"
+	"cursortab/types"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// runComplete implements the "complete" subcommand, which sends one
+// completion request for a file on disk through the configured provider
+// and prints the completions and their staging. Returns the process exit
+// code.
+func runComplete(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("complete", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	file := fs.String("file", "", "file to complete")
+	filetype := fs.String("filetype", "", "Neovim filetype of the file, for the behavior.rules filetype settings")
+	line := fs.Int("line", 0, "cursor line, 1-indexed")
+	col := fs.Int("col", 1, "cursor column in bytes, 1-indexed")
+	historyPath := fs.String("history", "", "unified diff of recent edits, sent as edit history")
+	configPath := fs.String("config", "", "JSON file with settings, in the shape of the setup() options")
+	format := fs.String("format", "json", "output format: json or diff")
+	workspace := fs.String("workspace", "", "workspace the file path is relative to (default: current directory)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cursortab complete --file path --line n [--col n] [--filetype ft] [--history patch] [--config file] [--format json|diff]")
+		fmt.Fprintln(stderr, "Request one completion from the configured provider and print it.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 || *file == "" || *line < 1 || *col < 1 || (*format != "json" && *format != "diff") {
+		fs.Usage()
+		return 2
+	}
+
+	config, err := loadConfigFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab complete: %v\n", err)
+		return 1
+	}
+	prov, providerConfig, err := newProvider(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab complete: %v\n", err)
+		return 1
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab complete: %v\n", err)
+		return 1
+	}
+	var history []*types.FileDiffHistory
+	if *historyPath != "" {
+		f, err := os.Open(*historyPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "cursortab complete: %v\n", err)
+			return 1
+		}
+		history, err = complete.ParsePatch(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(stderr, "cursortab complete: %s: %v\n", *historyPath, err)
+			return 1
+		}
+	}
+	if *workspace == "" {
+		if *workspace, err = os.Getwd(); err != nil {
+			fmt.Fprintf(stderr, "cursortab complete: %v\n", err)
+			return 1
+		}
+	}
+
+	content := strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	result, err := complete.Run(context.Background(), prov, complete.Request{
+		Path:     *file,
+		Filetype: *filetype,
+		Lines:    strings.Split(content, "\n"),
+		Line:     *line,
+		Col:      *col - 1,
+		History:  history,
+	}, complete.Options{
+		Workspace:          *workspace,
+		Policy:             engine.NewFilePolicy(newEngineConfig(config, providerConfig), *workspace),
+		Normalization:      newNormalization(config),
+		ProximityThreshold: config.Behavior.CursorPrediction.ProximityThreshold,
+		Timeout:            time.Duration(config.Provider.CompletionTimeout) * time.Millisecond,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab complete: %v\n", err)
+		return 1
+	}
+
+	if *format == "diff" {
+		err = result.WriteDiff(stdout)
+	} else {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(result)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab complete: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,184 @@
+// Package complete runs a single completion request through a provider and
+// stages the answer the way the engine would, for scripts and tools that
+// use the provider stack without an editor.
+package complete
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"cursortab/buffer"
+	"cursortab/engine"
+	"cursortab/text"
+	"cursortab/types"
+)
+
+// Request is the buffer state a completion is requested for.
+type Request struct {
+	Path     string   // file path, made relative to the workspace
+	Filetype string   // Neovim filetype, for the filetype rules
+	Lines    []string // file contents
+	Line     int      // cursor line, 1-indexed
+	Col      int      // cursor column, 0-indexed byte offset
+	History  []*types.FileDiffHistory
+}
+
+// Options holds the engine settings that shape the result.
+type Options struct {
+	Workspace          string
+	Normalization      types.Normalization
+	ProximityThreshold int           // max gap between changes in one stage
+	Timeout            time.Duration // provider timeout, 0 = none
+	// Policy excludes files from completion and selects privacy mode as
+	// the editor would (nil = every file is completed, never private)
+	Policy *engine.FilePolicy
+}
+
+// Completion is a suggested replacement of StartLine..EndLine (1-indexed,
+// inclusive; EndLine < StartLine inserts before StartLine).
+type Completion struct {
+	StartLine int      `json:"start_line"`
+	EndLine   int      `json:"end_line"`
+	Lines     []string `json:"lines"`
+}
+
+// Stage is one step of a staged completion, in the order the engine shows
+// them.
+type Stage struct {
+	StartLine  int      `json:"start_line"` // first buffer line replaced, 1-indexed
+	EndLine    int      `json:"end_line"`   // last buffer line replaced, inclusive
+	Lines      []string `json:"lines"`
+	CursorLine int      `json:"cursor_line"`         // cursor after accepting, 1-indexed relative to the stage
+	CursorCol  int      `json:"cursor_col"`          // 0-indexed byte offset
+	NextLine   int      `json:"next_line,omitempty"` // cursor target shown after accepting
+	NextPath   string   `json:"next_path,omitempty"` // file of the cursor target
+	LastStage  bool     `json:"last_stage,omitempty"`
+}
+
+// Target is a cursor position the provider predicts the user moves to.
+type Target struct {
+	Path string `json:"path"`
+	Line int    `json:"line"` // 1-indexed
+}
+
+// Result is the provider's answer and its staging. Only the first
+// completion is staged, as in the engine.
+type Result struct {
+	Path        string       `json:"path"`
+	Completions []Completion `json:"completions"`
+	Target      *Target      `json:"cursor_target,omitempty"`
+	// NoOp is set when the first completion only changes what the
+	// normalization settings ignore, so the engine would not show it
+	NoOp                 bool    `json:"no_op"`
+	Stages               []Stage `json:"stages"`
+	FirstNeedsNavigation bool    `json:"first_needs_navigation"`
+	LatencyMS            int64   `json:"latency_ms"`
+
+	before []string
+	after  []string // before with the staged completion applied
+}
+
+// Run sends req to prov and stages the first completion. Files excluded
+// by opts.Policy are never sent; Run returns an error with the reason.
+func Run(ctx context.Context, prov engine.Provider, req Request, opts Options) (*Result, error) {
+	if len(req.Lines) == 0 {
+		req.Lines = []string{""}
+	}
+	if req.Line < 1 || req.Line > len(req.Lines) {
+		return nil, fmt.Errorf("line %d out of range: file has %d lines", req.Line, len(req.Lines))
+	}
+	path := req.Path
+	if opts.Workspace != "" {
+		if abs, err := filepath.Abs(req.Path); err == nil {
+			path = buffer.RelativeToWorkspace(abs, opts.Workspace)
+		}
+	}
+	private := false
+	if opts.Policy != nil {
+		if ok, reason := opts.Policy.Allowed(path, req.Filetype, req.Lines); !ok {
+			return nil, fmt.Errorf("completion disabled: %s", reason)
+		}
+		private = opts.Policy.Private(path)
+	}
+
+	completionReq := &types.CompletionRequest{
+		Source:            types.CompletionSourceTyping,
+		WorkspacePath:     opts.Workspace,
+		FilePath:          path,
+		Lines:             req.Lines,
+		PreviousLines:     req.Lines,
+		FileDiffHistories: req.History,
+		CursorRow:         req.Line,
+		CursorCol:         min(max(req.Col, 0), len(req.Lines[req.Line-1])),
+		PrivacyMode:       private,
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	resp, err := prov.GetCompletion(ctx, completionReq)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Path:        path,
+		Completions: []Completion{},
+		Stages:      []Stage{},
+		LatencyMS:   time.Since(start).Milliseconds(),
+		before:      req.Lines,
+		after:       req.Lines,
+	}
+	if resp == nil {
+		return result, nil
+	}
+	for _, c := range resp.Completions {
+		result.Completions = append(result.Completions, Completion{StartLine: c.StartLine, EndLine: c.EndLineInc, Lines: c.Lines})
+	}
+	if resp.CursorTarget != nil {
+		result.Target = &Target{Path: resp.CursorTarget.RelativePath, Line: int(resp.CursorTarget.LineNumber)}
+	}
+	if len(resp.Completions) > 0 {
+		result.stage(resp.Completions[0], req.Line, opts)
+	}
+	return result, nil
+}
+
+// stage stages the completion as the engine does, with the whole file
+// visible.
+func (r *Result) stage(completion *types.Completion, cursorRow int, opts Options) {
+	lines, staging, ok := engine.StageCompletion(engine.StageView{
+		Lines:     r.before,
+		Path:      r.Path,
+		CursorRow: cursorRow,
+	}, completion, opts.Normalization, opts.ProximityThreshold)
+	if !ok {
+		r.NoOp = true
+		return
+	}
+	r.after = text.SpliceLines(r.before, completion.StartLine, completion.EndLineInc, lines)
+	if staging == nil {
+		return
+	}
+	r.FirstNeedsNavigation = staging.FirstNeedsNavigation
+	for _, s := range staging.Stages {
+		stage := Stage{
+			StartLine:  s.BufferStart,
+			EndLine:    s.BufferEnd,
+			Lines:      s.Lines,
+			CursorLine: s.CursorLine,
+			CursorCol:  s.CursorCol,
+			LastStage:  s.IsLastStage,
+		}
+		if s.CursorTarget != nil {
+			stage.NextLine = int(s.CursorTarget.LineNumber)
+			stage.NextPath = s.CursorTarget.RelativePath
+		}
+		r.Stages = append(r.Stages, stage)
+	}
+}
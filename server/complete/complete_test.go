@@ -0,0 +1,169 @@
+package complete
+
+import (
+	"context"
+	"cursortab/assert"
+	"cursortab/engine"
+	"cursortab/ignore"
+	"cursortab/types"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type stubProvider struct {
+	resp *types.CompletionResponse
+	err  error
+	req  *types.CompletionRequest
+}
+
+func (p *stubProvider) GetCompletion(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+	p.req = req
+	return p.resp, p.err
+}
+
+var testLines = []string{
+	"package main",
+	"",
+	"func add(a, b int) int {",
+	"\treturn 0",
+	"}",
+}
+
+func TestRun_StagesFirstCompletion(t *testing.T) {
+	prov := &stubProvider{resp: &types.CompletionResponse{
+		Completions: []*types.Completion{{StartLine: 4, EndLineInc: 4, Lines: []string{"\treturn a + b"}}},
+	}}
+	history := []*types.FileDiffHistory{{FileName: "add.go", DiffHistory: []*types.DiffEntry{{Original: "x", Updated: "y"}}}}
+	result, err := Run(context.Background(), prov, Request{
+		Path: "/work/add.go", Lines: testLines, Line: 4, Col: 99, History: history,
+	}, Options{Workspace: "/work", ProximityThreshold: 2})
+	assert.NoError(t, err, "Run")
+
+	assert.Equal(t, "add.go", prov.req.FilePath, "path relative to the workspace")
+	assert.Equal(t, len("\treturn 0"), prov.req.CursorCol, "column clamped to the line")
+	assert.Len(t, 1, prov.req.FileDiffHistories, "history sent")
+
+	assert.Equal(t, "add.go", result.Path, "result path")
+	assert.Len(t, 1, result.Completions, "completions kept")
+	assert.Len(t, 1, result.Stages, "one stage")
+	assert.Equal(t, 4, result.Stages[0].StartLine, "stage start")
+	assert.Equal(t, []string{"\treturn a + b"}, result.Stages[0].Lines, "stage lines")
+	assert.False(t, result.NoOp, "real change")
+
+	var diff strings.Builder
+	assert.NoError(t, result.WriteDiff(&diff), "WriteDiff")
+	assert.Equal(t, "--- a/add.go\n+++ b/add.go\n@@ -1,5 +1,5 @@\n package main\n \n func add(a, b int) int {\n-\treturn 0\n+\treturn a + b\n }\n", diff.String(), "unified diff")
+}
+
+func TestRun_NoOpCompletion(t *testing.T) {
+	prov := &stubProvider{resp: &types.CompletionResponse{
+		Completions: []*types.Completion{{StartLine: 4, EndLineInc: 4, Lines: []string{"\treturn 0  "}}},
+	}}
+	result, err := Run(context.Background(), prov, Request{Path: "add.go", Lines: testLines, Line: 4},
+		Options{Normalization: types.Normalization{TrailingWhitespace: true}})
+	assert.NoError(t, err, "Run")
+	assert.True(t, result.NoOp, "whitespace-only change")
+	assert.Len(t, 0, result.Stages, "nothing staged")
+
+	var diff strings.Builder
+	assert.NoError(t, result.WriteDiff(&diff), "WriteDiff")
+	assert.Equal(t, "", diff.String(), "empty diff")
+}
+
+func TestRun_Errors(t *testing.T) {
+	_, err := Run(context.Background(), &stubProvider{}, Request{Lines: testLines, Line: 9}, Options{})
+	assert.Error(t, err, "line out of range")
+
+	_, err = Run(context.Background(), &stubProvider{err: errors.New("down")}, Request{Lines: testLines, Line: 1}, Options{})
+	assert.Error(t, err, "provider error")
+}
+
+func TestRun_Policy(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ignore.FileName), []byte("secret/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	policy := engine.NewFilePolicy(engine.EngineConfig{
+		IgnoreFiles: true,
+		Rules:       engine.RulesConfig{ExcludeFiletypes: []string{"markdown"}},
+		Privacy:     engine.PrivacyConfig{Paths: []string{"internal/*"}},
+	}, root)
+	opts := Options{Workspace: root, Policy: policy}
+
+	prov := &stubProvider{}
+	_, err := Run(context.Background(), prov, Request{Path: filepath.Join(root, "secret/add.go"), Lines: testLines, Line: 1}, opts)
+	assert.Error(t, err, "ignored file")
+	assert.Contains(t, err.Error(), ignore.FileName, "reason given")
+	_, err = Run(context.Background(), prov, Request{Path: "README.md", Filetype: "markdown", Lines: testLines, Line: 1}, opts)
+	assert.Error(t, err, "excluded filetype")
+	assert.Nil(t, prov.req, "provider never called")
+
+	_, err = Run(context.Background(), prov, Request{Path: filepath.Join(root, "internal/add.go"), Lines: testLines, Line: 1}, opts)
+	assert.NoError(t, err, "private file completed")
+	assert.True(t, prov.req.PrivacyMode, "privacy mode requested")
+	_, err = Run(context.Background(), prov, Request{Path: filepath.Join(root, "add.go"), Lines: testLines, Line: 1}, opts)
+	assert.NoError(t, err, "other file completed")
+	assert.False(t, prov.req.PrivacyMode, "not private")
+}
+
+func TestWriteDiff_SeparateHunks(t *testing.T) {
+	var before []string
+	for i := range 20 {
+		before = append(before, strings.Repeat("x", i+1))
+	}
+	after := append([]string{"first"}, before[1:]...)
+	after[18] = "last"
+	after = append(after, "added")
+	r := &Result{Path: "f.txt", before: before, after: after}
+
+	var diff strings.Builder
+	assert.NoError(t, r.WriteDiff(&diff), "WriteDiff")
+	headers := 0
+	for _, line := range strings.Split(diff.String(), "\n") {
+		if strings.HasPrefix(line, "@@") {
+			headers++
+		}
+	}
+	assert.Equal(t, 2, headers, "distant changes in separate hunks")
+	assert.Contains(t, diff.String(), "@@ -1,4 +1,4 @@\n-x\n+first\n", "first hunk")
+	assert.Contains(t, diff.String(), "@@ -16,5 +16,6 @@\n", "second hunk with the addition")
+}
+
+func TestParsePatch(t *testing.T) {
+	patch := `diff --git a/add.go b/add.go
+index 1111111..2222222 100644
+--- a/add.go
++++ b/add.go
+@@ -1,4 +1,4 @@
+-func add() int {
++func add(a, b int) int {
+ 	// sum
+-	return 0
++	return a
+ }
+--- a/new.go	2024-01-01 00:00:00
++++ b/new.go	2024-01-01 00:00:01
+@@ -0,0 +1,2 @@
++package main
++-- not a header
+\ No newline at end of file
+`
+	histories, err := ParsePatch(strings.NewReader(patch))
+	assert.NoError(t, err, "ParsePatch")
+	assert.Len(t, 2, histories, "two files")
+	assert.Equal(t, "add.go", histories[0].FileName, "first file")
+	assert.Len(t, 2, histories[0].DiffHistory, "blocks split at context lines")
+	assert.Equal(t, "func add() int {", histories[0].DiffHistory[0].Original, "removed")
+	assert.Equal(t, "func add(a, b int) int {", histories[0].DiffHistory[0].Updated, "added")
+	assert.Equal(t, "\treturn a", histories[0].DiffHistory[1].Updated, "second block")
+	assert.Equal(t, "new.go", histories[1].FileName, "timestamp stripped")
+	assert.Equal(t, "package main\n-- not a header", histories[1].DiffHistory[0].Updated, "hunk counts decide what is content")
+
+	_, err = ParsePatch(strings.NewReader("@@ -1 +1 @@\n-a\n+b\n"))
+	assert.Error(t, err, "hunk without a file")
+	_, err = ParsePatch(strings.NewReader("--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n-a\n"))
+	assert.Error(t, err, "truncated hunk")
+}
@@ -0,0 +1,110 @@
+package complete
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+
+	"cursortab/text"
+)
+
+// diffContext is the number of unchanged lines around each hunk.
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// WriteDiff writes the staged completion as a unified diff of the file.
+// Nothing is written when there is no change.
+func (r *Result) WriteDiff(w io.Writer) error {
+	ops := lineOps(r.before, r.after)
+	bw := bufio.NewWriter(w)
+	header := false
+
+	// Line numbers before each op, 0-indexed
+	oldPos := make([]int, len(ops)+1)
+	newPos := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if op.kind != '+' {
+			oldPos[i+1]++
+		}
+		if op.kind != '-' {
+			newPos[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// Extend the hunk over changes separated by few unchanged lines
+		last := i
+		for k := i; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				last = k
+			} else if k-last > 2*diffContext {
+				break
+			}
+		}
+		start, stop := max(i-diffContext, 0), min(last+diffContext+1, len(ops))
+
+		if !header {
+			fmt.Fprintf(bw, "--- a/%s\n+++ b/%s\n", r.Path, r.Path)
+			header = true
+		}
+		oldCount, newCount := oldPos[stop]-oldPos[start], newPos[stop]-newPos[start]
+		fmt.Fprintf(bw, "@@ -%s +%s @@\n", hunkRange(oldPos[start], oldCount), hunkRange(newPos[start], newCount))
+		for _, op := range ops[start:stop] {
+			bw.WriteByte(op.kind)
+			bw.WriteString(op.line)
+			bw.WriteByte('\n')
+		}
+		i = stop
+	}
+	return bw.Flush()
+}
+
+// hunkRange formats the start and length of a hunk side. An empty side
+// starts at the line before it.
+func hunkRange(pos, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", pos)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", pos+1)
+	}
+	return fmt.Sprintf("%d,%d", pos+1, count)
+}
+
+// lineOps returns the line-level edit script turning oldLines into
+// newLines.
+func lineOps(oldLines, newLines []string) []diffOp {
+	dmp := diffmatchpatch.New()
+	chars1, chars2, lineArray := dmp.DiffLinesToChars(text.JoinLines(oldLines), text.JoinLines(newLines))
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(chars1, chars2, false), lineArray)
+
+	var ops []diffOp
+	for _, diff := range diffs {
+		if diff.Text == "" {
+			continue
+		}
+		kind := byte(' ')
+		switch diff.Type {
+		case diffmatchpatch.DiffDelete:
+			kind = '-'
+		case diffmatchpatch.DiffInsert:
+			kind = '+'
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(diff.Text, "\n"), "\n") {
+			ops = append(ops, diffOp{kind: kind, line: line})
+		}
+	}
+	return ops
+}
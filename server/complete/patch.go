@@ -0,0 +1,126 @@
+package complete
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cursortab/types"
+)
+
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+
+// ParsePatch reads a unified diff (such as git diff output) as edit
+// history: every block of changed lines becomes a diff entry of the file
+// it belongs to, oldest first.
+func ParsePatch(r io.Reader) ([]*types.FileDiffHistory, error) {
+	var histories []*types.FileDiffHistory
+	byFile := make(map[string]*types.FileDiffHistory)
+	var current *types.FileDiffHistory
+	var oldPath string
+	var removed, added []string
+	oldLeft, newLeft := 0, 0
+
+	flush := func() {
+		if len(removed) > 0 || len(added) > 0 {
+			current.DiffHistory = append(current.DiffHistory, &types.DiffEntry{
+				Original: strings.Join(removed, "\n"),
+				Updated:  strings.Join(added, "\n"),
+			})
+		}
+		removed, added = nil, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNum++
+
+		if oldLeft > 0 || newLeft > 0 {
+			kind, content := byte(' '), ""
+			if line != "" {
+				kind, content = line[0], line[1:]
+			}
+			switch kind {
+			case ' ':
+				flush()
+				oldLeft--
+				newLeft--
+			case '-':
+				removed = append(removed, content)
+				oldLeft--
+			case '+':
+				added = append(added, content)
+				newLeft--
+			case '\\': // "\ No newline at end of file"
+			default:
+				return nil, fmt.Errorf("line %d: unexpected %q in hunk", lineNum, line)
+			}
+			continue
+		}
+		if current != nil {
+			flush()
+		}
+
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			oldPath = patchPath(line[4:])
+		case strings.HasPrefix(line, "+++ "):
+			path := patchPath(line[4:])
+			if path == "/dev/null" {
+				path = oldPath
+			}
+			current = byFile[path]
+			if current == nil {
+				current = &types.FileDiffHistory{FileName: path}
+				byFile[path] = current
+				histories = append(histories, current)
+			}
+		case strings.HasPrefix(line, "@@"):
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: invalid hunk header %q", lineNum, line)
+			}
+			if current == nil {
+				return nil, fmt.Errorf("line %d: hunk without a file header", lineNum)
+			}
+			oldLeft, newLeft = hunkCount(m[1]), hunkCount(m[2])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if oldLeft > 0 || newLeft > 0 {
+		return nil, fmt.Errorf("patch ends inside a hunk")
+	}
+	if current != nil {
+		flush()
+	}
+	return histories, nil
+}
+
+// patchPath strips the a/ or b/ prefix and any timestamp from a file
+// header path.
+func patchPath(s string) string {
+	s, _, _ = strings.Cut(s, "\t")
+	if rest, ok := strings.CutPrefix(s, "a/"); ok {
+		return rest
+	}
+	if rest, ok := strings.CutPrefix(s, "b/"); ok {
+		return rest
+	}
+	return s
+}
+
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -16,7 +16,9 @@ import (
 	"cursortab/buffer"
 	"cursortab/engine"
 	"cursortab/logger"
+	"cursortab/metrics"
 	"cursortab/provider/sweep"
+	"cursortab/tokenizer"
 	"cursortab/types"
 
 	"github.com/neovim/go-client/nvim"
@@ -36,7 +38,20 @@ type Daemon struct {
 	cancel      context.CancelFunc
 }
 
-func NewDaemon(config Config) (*Daemon, error) {
+// newNormalization returns which cosmetic differences suggestions ignore.
+func newNormalization(config Config) types.Normalization {
+	return types.Normalization{
+		TrailingWhitespace: config.Behavior.Normalization.TrailingWhitespace,
+		Indentation:        config.Behavior.Normalization.Indentation,
+		Formatting:         config.Behavior.Normalization.Formatting,
+	}
+}
+
+// newProvider creates the provider selected by config, returning it with the
+// provider settings it was built from.
+func newProvider(config Config) (engine.Provider, *types.ProviderConfig, error) {
+	normalization := newNormalization(config)
+
 	providerConfig := &types.ProviderConfig{
 		ProviderURL:         config.Provider.URL,
 		ProviderTemperature: config.Provider.Temperature,
@@ -44,6 +59,25 @@ func NewDaemon(config Config) (*Daemon, error) {
 		ProviderTopK:        config.Provider.TopK,
 		APIKey:              config.Provider.APIKey,
 		APIKeyEnv:           config.Provider.APIKeyEnv,
+		APIKeyCommand:       config.Provider.APIKeyCommand,
+		APIKeyFile:          config.Provider.APIKeyFile,
+		APIKeyTTL:           time.Duration(config.Provider.APIKeyTTL) * time.Millisecond,
+		RedactSecrets:       config.Provider.Redaction.Enabled,
+		RedactPatterns:      config.Provider.Redaction.Patterns,
+		Normalization:       normalization,
+	}
+	if config.Provider.Tokenizer != "" {
+		counter, err := tokenizer.Load(config.Provider.Tokenizer)
+		if err != nil {
+			return nil, nil, err
+		}
+		providerConfig.Tokenizer = counter
+	}
+	if config.Provider.AuditLog.Enabled {
+		providerConfig.AuditLogPath = config.Provider.AuditLog.Path
+		if providerConfig.AuditLogPath == "" {
+			providerConfig.AuditLogPath = getAuditPath()
+		}
 	}
 
 	var prov engine.Provider
@@ -52,17 +86,18 @@ func NewDaemon(config Config) (*Daemon, error) {
 	case types.ProviderTypeSweep:
 		prov, provErr = sweep.NewProvider(providerConfig)
 		if provErr != nil {
-			return nil, fmt.Errorf("failed to create sweep provider: %w", provErr)
+			return nil, nil, fmt.Errorf("failed to create sweep provider: %w", provErr)
 		}
 	default:
-		return nil, fmt.Errorf("unsupported provider type: %s", config.Provider.Type)
+		return nil, nil, fmt.Errorf("unsupported provider type: %s", config.Provider.Type)
 	}
+	return prov, providerConfig, nil
+}
 
-	buf := buffer.New(buffer.Config{
-		NsID: config.NsID,
-	})
-
-	eng, err := engine.NewEngine(prov, buf, engine.EngineConfig{
+// newEngineConfig returns the engine settings for config. providerConfig
+// is the result of newProvider for the same config.
+func newEngineConfig(config Config, providerConfig *types.ProviderConfig) engine.EngineConfig {
+	return engine.EngineConfig{
 		NsID:                config.NsID,
 		CompletionTimeout:   time.Duration(config.Provider.CompletionTimeout) * time.Millisecond,
 		IdleCompletionDelay: time.Duration(config.Behavior.IdleCompletionDelay) * time.Millisecond,
@@ -72,8 +107,65 @@ func NewDaemon(config Config) (*Daemon, error) {
 			AutoAdvance:        config.Behavior.CursorPrediction.AutoAdvance,
 			ProximityThreshold: config.Behavior.CursorPrediction.ProximityThreshold,
 		},
-		MaxDiffTokens: config.Provider.MaxDiffHistoryTokens,
-	}, engine.SystemClock)
+		MaxDiffTokens:       config.Provider.MaxDiffHistoryTokens,
+		CompletionCacheSize: config.Behavior.CompletionCacheSize,
+		CacheWindowTokens:   config.Provider.MaxTokens,
+		AdaptiveDebounce: engine.AdaptiveDebounceConfig{
+			Enabled:  config.Behavior.AdaptiveDebounce.Enabled,
+			MinDelay: time.Duration(config.Behavior.AdaptiveDebounce.MinDelay) * time.Millisecond,
+			MaxDelay: time.Duration(config.Behavior.AdaptiveDebounce.MaxDelay) * time.Millisecond,
+		},
+		RateLimit: engine.RateLimitConfig{
+			Provider:           config.Provider.Type,
+			RequestsPerSecond:  config.Provider.RateLimit.RequestsPerSecond,
+			Burst:              config.Provider.RateLimit.Burst,
+			DailyBudget:        config.Provider.RateLimit.DailyBudget,
+			MonthlyBudget:      config.Provider.RateLimit.MonthlyBudget,
+			LowBudgetThreshold: config.Provider.RateLimit.LowBudgetThreshold,
+			StatePath:          getBudgetPath(),
+		},
+		Rules: engine.RulesConfig{
+			Include:          config.Behavior.Rules.Include,
+			Exclude:          config.Behavior.Rules.Exclude,
+			Filetypes:        config.Behavior.Rules.Filetypes,
+			ExcludeFiletypes: config.Behavior.Rules.ExcludeFiletypes,
+			MaxFileSize:      config.Behavior.Rules.MaxFileSize * 1024,
+			MaxLineLength:    config.Behavior.Rules.MaxLineLength,
+			SkipUnnamed:      config.Behavior.Rules.SkipUnnamed,
+		},
+		IgnoreFiles:      config.Behavior.Rules.IgnoreFiles,
+		GlobalIgnoreFile: config.Behavior.Rules.GlobalIgnoreFile,
+		Privacy: engine.PrivacyConfig{
+			Enabled:    config.Privacy.Enabled,
+			Workspaces: config.Privacy.Workspaces,
+			Paths:      config.Privacy.Paths,
+		},
+		CircuitBreaker: engine.CircuitBreakerConfig{
+			FailureThreshold: config.Provider.CircuitBreaker.FailureThreshold,
+			Cooldown:         time.Duration(config.Provider.CircuitBreaker.Cooldown) * time.Millisecond,
+		},
+		Normalization:       providerConfig.Normalization,
+		Tokenizer:           providerConfig.Tokenizer,
+		TracePath:           config.Debug.TraceFile,
+		TraceRedactPatterns: config.Provider.Redaction.Patterns,
+		Stats: engine.StatsConfig{
+			Provider:  config.Provider.Type,
+			StatePath: getStatsPath(),
+		},
+	}
+}
+
+func NewDaemon(config Config) (*Daemon, error) {
+	prov, providerConfig, err := newProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := buffer.New(buffer.Config{
+		NsID: config.NsID,
+	})
+
+	eng, err := engine.NewEngine(prov, buf, newEngineConfig(config, providerConfig), engine.SystemClock)
 	if err != nil {
 		return nil, err
 	}
@@ -106,6 +198,17 @@ func (d *Daemon) Start() error {
 
 	logger.Info("daemon listening on socket: %s", d.socketPath)
 
+	// Serve metrics when configured
+	if addr := d.config.Metrics.Address; addr != "" {
+		server, err := metrics.Listen(addr)
+		if err != nil {
+			logger.Warn("metrics disabled: %v", err)
+		} else {
+			defer server.Close()
+			logger.Info("serving metrics on %s/metrics", addr)
+		}
+	}
+
 	// Start engine
 	d.engine.Start(d.ctx)
 
@@ -187,6 +290,13 @@ func (d *Daemon) handleConnection(conn net.Conn) {
 	d.buffer.SetClient(n)
 	d.engine.RegisterEventHandler()
 
+	// Expose daemon state to :CursortabStatus
+	if err := n.RegisterHandler("cursortab_status", func() (map[string]any, error) {
+		return d.engine.Status(), nil
+	}); err != nil {
+		logger.Error("error registering status handler: %v", err)
+	}
+
 	// Serve this connection until it closes or context is done
 	select {
 	case <-d.ctx.Done():
@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"cursortab/logger"
+)
+
+// CircuitBreakerConfig controls skipping provider requests during outages.
+type CircuitBreakerConfig struct {
+	FailureThreshold int           // Consecutive failures before opening (0 = disabled)
+	Cooldown         time.Duration // Time spent open before a probe request is allowed
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerClosed:
+		return "closed"
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// circuitBreaker opens after FailureThreshold consecutive provider failures
+// and skips requests for Cooldown. It then half-opens and lets a single
+// probe through: success closes it, failure reopens it for another cooldown.
+// Safe for concurrent use: results are recorded from request goroutines.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	config    CircuitBreakerConfig
+	clock     Clock
+	state     breakerState
+	failures  int       // consecutive failures while closed
+	openedAt  time.Time // when the breaker last opened
+	probeAt   time.Time // when the current half-open probe was sent
+	skipped   int64
+	onChange  func(change breakerChange)
+	changed   *breakerChange // transition to report once b.mu is released
+	lastError string
+}
+
+// breakerChange is a breaker state transition.
+type breakerChange struct {
+	From breakerState `json:"from"`
+	To   breakerState `json:"to"`
+}
+
+func newCircuitBreaker(config CircuitBreakerConfig, clock Clock, onChange func(change breakerChange)) *circuitBreaker {
+	if config.FailureThreshold <= 0 {
+		return nil
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = 30 * time.Second
+	}
+	return &circuitBreaker{config: config, clock: clock, onChange: onChange}
+}
+
+// Allow reports whether a provider request may be sent now. While open it
+// returns false until the cooldown elapses, then admits one probe. A probe
+// that never reports back (e.g. an abandoned stream) is replaced after
+// another cooldown so the breaker cannot get stuck half-open.
+func (b *circuitBreaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.report()
+
+	now := b.clock.Now()
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < b.config.Cooldown {
+			b.skipped++
+			return false
+		}
+		b.transition(breakerHalfOpen)
+		b.probeAt = now
+		return true
+	case breakerHalfOpen:
+		if now.Sub(b.probeAt) < b.config.Cooldown {
+			b.skipped++
+			return false
+		}
+		b.probeAt = now
+		return true
+	default:
+		return true
+	}
+}
+
+// Release hands back a probe admitted by Allow that was not sent (e.g. the
+// rate limiter denied it), so the next request can probe right away instead
+// of waiting out another cooldown.
+func (b *circuitBreaker) Release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen {
+		b.probeAt = time.Time{}
+	}
+}
+
+// Record reports the outcome of a provider request. Cancellations are
+// ignored: they come from the user typing, not from the provider.
+func (b *circuitBreaker) Record(err error) {
+	if b == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	b.mu.Lock()
+	defer b.report()
+
+	if err == nil {
+		b.failures = 0
+		if b.state != breakerClosed {
+			b.transition(breakerClosed)
+		}
+		return
+	}
+
+	b.lastError = err.Error()
+	switch b.state {
+	case breakerHalfOpen:
+		b.openedAt = b.clock.Now()
+		b.transition(breakerOpen)
+	case breakerClosed:
+		b.failures++
+		if b.failures >= b.config.FailureThreshold {
+			b.openedAt = b.clock.Now()
+			b.transition(breakerOpen)
+		}
+	}
+}
+
+// transition changes state and queues the change for report. Caller must
+// hold b.mu.
+func (b *circuitBreaker) transition(to breakerState) {
+	b.changed = &breakerChange{From: b.state, To: to}
+	b.state = to
+	if to == breakerClosed {
+		b.failures = 0
+	}
+}
+
+// report releases b.mu and then fires onChange for a queued transition, so
+// the callback never runs under the lock.
+func (b *circuitBreaker) report() {
+	changed := b.changed
+	b.changed = nil
+	b.mu.Unlock()
+	if changed != nil && b.onChange != nil {
+		b.onChange(*changed)
+	}
+}
+
+// State returns the current breaker state.
+func (b *circuitBreaker) State() breakerState {
+	if b == nil {
+		return breakerClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Status returns the breaker state for the status RPC.
+func (b *circuitBreaker) Status() map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := map[string]any{
+		"state":                b.state.String(),
+		"consecutive_failures": b.failures,
+		"skipped":              b.skipped,
+	}
+	if b.lastError != "" {
+		status["last_error"] = b.lastError
+	}
+	if b.state == breakerOpen {
+		remaining := b.config.Cooldown - b.clock.Now().Sub(b.openedAt)
+		status["retry_in_ms"] = max(remaining, 0).Milliseconds()
+	}
+	return status
+}
+
+// onBreakerChange logs breaker transitions and hands them to the event loop,
+// which tells the editor about outages. It runs on provider goroutines as
+// well as the event loop, so it must not block or call into the editor.
+func (e *Engine) onBreakerChange(change breakerChange) {
+	breakerStateGauge.Set(float64(change.To))
+	switch change.To {
+	case breakerOpen:
+		if change.From == breakerClosed {
+			logger.Warn("provider failing, pausing requests for %v", e.breaker.config.Cooldown)
+		} else {
+			logger.Debug("circuit breaker probe failed, reopening")
+		}
+	case breakerHalfOpen:
+		logger.Debug("circuit breaker half-open, sending probe request")
+	case breakerClosed:
+		logger.Info("provider recovered, resuming requests")
+	}
+
+	// Results are only recorded by request goroutines (which Stop waits for
+	// before closing eventChan) and the event loop, so the send is safe; it
+	// is non-blocking because the event loop may be the caller
+	select {
+	case e.eventChan <- Event{Type: EventBreakerChanged, Data: change}:
+	default:
+		logger.Debug("event queue full, dropping breaker notification")
+	}
+}
+
+// handleBreakerChanged notifies the editor of an outage or recovery, so a
+// down provider produces one message instead of an error per keystroke.
+func (e *Engine) handleBreakerChanged(change breakerChange) {
+	switch {
+	case change.To == breakerOpen && change.From == breakerClosed:
+		e.notify("cursortab: provider unavailable, pausing completions", notifyWarn)
+	case change.To == breakerClosed:
+		e.notify("cursortab: provider recovered", notifyInfo)
+	}
+}
@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"context"
+	"cursortab/assert"
+	"cursortab/types"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errProviderDown = errors.New("connection refused")
+
+func TestCircuitBreaker_DisabledAllowsEverything(t *testing.T) {
+	b := newCircuitBreaker(CircuitBreakerConfig{}, newMockClock(), nil)
+	assert.Nil(t, b, "zero threshold disables breaker")
+
+	b.Record(errProviderDown)
+	assert.True(t, b.Allow(), "nil breaker allows")
+	assert.Equal(t, breakerClosed, b.State(), "nil breaker reports closed")
+}
+
+func TestCircuitBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	b := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 3, Cooldown: time.Second}, newMockClock(), nil)
+
+	b.Record(errProviderDown)
+	b.Record(errProviderDown)
+	assert.Equal(t, breakerClosed, b.State(), "below threshold")
+
+	b.Record(nil)
+	b.Record(errProviderDown)
+	b.Record(errProviderDown)
+	assert.Equal(t, breakerClosed, b.State(), "success resets the failure count")
+
+	b.Record(errProviderDown)
+	assert.Equal(t, breakerOpen, b.State(), "opens at threshold")
+	assert.False(t, b.Allow(), "requests skipped while open")
+}
+
+func TestCircuitBreaker_IgnoresCancellation(t *testing.T) {
+	b := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Second}, newMockClock(), nil)
+
+	b.Record(context.Canceled)
+	assert.Equal(t, breakerClosed, b.State(), "user cancellation is not a provider failure")
+
+	b.Record(context.DeadlineExceeded)
+	assert.Equal(t, breakerOpen, b.State(), "timeouts count as failures")
+}
+
+func TestCircuitBreaker_HalfOpenProbe(t *testing.T) {
+	clock := newMockClock()
+	b := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Second}, clock, nil)
+	b.Record(errProviderDown)
+
+	clock.Advance(999 * time.Millisecond)
+	assert.False(t, b.Allow(), "still cooling down")
+
+	clock.Advance(time.Millisecond)
+	assert.True(t, b.Allow(), "probe allowed after cooldown")
+	assert.Equal(t, breakerHalfOpen, b.State(), "half-open while probing")
+	assert.False(t, b.Allow(), "only one probe at a time")
+
+	b.Record(errProviderDown)
+	assert.Equal(t, breakerOpen, b.State(), "failed probe reopens")
+	assert.False(t, b.Allow(), "new cooldown after failed probe")
+
+	clock.Advance(time.Second)
+	assert.True(t, b.Allow(), "second probe")
+	b.Record(nil)
+	assert.Equal(t, breakerClosed, b.State(), "successful probe closes")
+	assert.True(t, b.Allow(), "requests resume")
+}
+
+func TestCircuitBreaker_AbandonedProbeReplaced(t *testing.T) {
+	clock := newMockClock()
+	b := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Second}, clock, nil)
+	b.Record(errProviderDown)
+	clock.Advance(time.Second)
+	assert.True(t, b.Allow(), "first probe")
+
+	clock.Advance(time.Second)
+	assert.True(t, b.Allow(), "probe that never reported is replaced")
+}
+
+func TestCircuitBreaker_ReleasedProbe(t *testing.T) {
+	clock := newMockClock()
+	b := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Second}, clock, nil)
+	b.Record(errProviderDown)
+	clock.Advance(time.Second)
+	assert.True(t, b.Allow(), "probe admitted")
+
+	b.Release()
+	assert.Equal(t, breakerHalfOpen, b.State(), "still half-open after release")
+	assert.True(t, b.Allow(), "released probe slot is reusable at once")
+	assert.False(t, b.Allow(), "only one probe at a time")
+}
+
+func TestAllowRequest_RateLimitedProbeNotWasted(t *testing.T) {
+	clock := newMockClock()
+	eng, _ := NewEngine(newMockProvider(), newMockBuffer(), EngineConfig{
+		CompletionTimeout: 5 * time.Second,
+		CircuitBreaker:    CircuitBreakerConfig{FailureThreshold: 1, Cooldown: 10 * time.Second},
+		RateLimit:         RateLimitConfig{RequestsPerSecond: 1},
+	}, clock)
+	eng.breaker.Record(errProviderDown)
+	clock.Advance(10 * time.Second)
+	eng.limiter.Allow(types.CompletionSourceTyping) // use up the token
+
+	assert.False(t, eng.allowRequest(types.CompletionSourceTyping), "rate limited")
+	clock.Advance(time.Second)
+	assert.True(t, eng.allowRequest(types.CompletionSourceTyping), "probe not held back for another cooldown")
+	assert.Equal(t, breakerHalfOpen, eng.breaker.State(), "probing")
+}
+
+func TestCircuitBreaker_Status(t *testing.T) {
+	clock := newMockClock()
+	b := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Second}, clock, nil)
+	b.Record(errProviderDown)
+	b.Allow()
+	clock.Advance(400 * time.Millisecond)
+
+	status := b.Status()
+	assert.Equal(t, "open", status["state"], "state")
+	assert.Equal(t, int64(1), status["skipped"], "skipped count")
+	assert.Equal(t, int64(600), status["retry_in_ms"], "time until probe")
+	assert.Equal(t, "connection refused", status["last_error"], "last error")
+}
+
+// handleUntilResult handles engine events until a completion result or
+// error has been handled, so breaker notifications sent before it are too.
+func handleUntilResult(t *testing.T, eng *Engine) {
+	t.Helper()
+	for {
+		select {
+		case event := <-eng.eventChan:
+			eng.handleEvent(event)
+			if event.Type == EventCompletionReady || event.Type == EventCompletionError {
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no completion result")
+		}
+	}
+}
+
+func TestRequestCompletion_BreakerOpensAndNotifies(t *testing.T) {
+	buf := newMockBuffer()
+	prov := newMockProvider()
+	prov.completionErr = errProviderDown
+	clock := newMockClock()
+
+	eng, _ := NewEngine(prov, buf, EngineConfig{
+		CompletionTimeout: 5 * time.Second,
+		CircuitBreaker:    CircuitBreakerConfig{FailureThreshold: 2, Cooldown: time.Second},
+	}, clock)
+	eng.mainCtx, eng.mainCancel = context.WithCancel(context.Background())
+	defer eng.mainCancel()
+
+	for range 2 {
+		eng.requestCompletion(types.CompletionSourceTyping)
+		handleUntilResult(t, eng)
+		eng.state = stateIdle
+	}
+	assert.Equal(t, breakerOpen, eng.breaker.State(), "breaker opened")
+	assert.Equal(t, 1, len(buf.notifications), "editor notified once")
+
+	eng.requestCompletion(types.CompletionSourceTyping)
+	assert.Equal(t, stateIdle, eng.state, "request skipped while open")
+	assert.Equal(t, 2, prov.completionCalls, "provider not called while open")
+
+	prov.mu.Lock()
+	prov.completionErr = nil
+	prov.mu.Unlock()
+	clock.Advance(time.Second)
+	eng.requestCompletion(types.CompletionSourceTyping)
+	handleUntilResult(t, eng)
+
+	assert.Equal(t, breakerClosed, eng.breaker.State(), "probe success closes breaker")
+	assert.Equal(t, 2, len(buf.notifications), "recovery notified")
+}
+
+func TestCircuitBreaker_ReportsOutsideLock(t *testing.T) {
+	clock := newMockClock()
+	var b *circuitBreaker
+	var changes []breakerChange
+	b = newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Second}, clock, func(change breakerChange) {
+		// Would deadlock if called with b.mu held
+		b.State()
+		changes = append(changes, change)
+	})
+
+	b.Record(errProviderDown)
+	clock.Advance(time.Second)
+	b.Allow()
+	b.Record(nil)
+
+	assert.Equal(t, []breakerChange{
+		{From: breakerClosed, To: breakerOpen},
+		{From: breakerOpen, To: breakerHalfOpen},
+		{From: breakerHalfOpen, To: breakerClosed},
+	}, changes, "transitions")
+}
@@ -0,0 +1,211 @@
+package engine
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"cursortab/logger"
+	"cursortab/tokenizer"
+	"cursortab/types"
+	"cursortab/utils"
+)
+
+// completionCache is an LRU cache of provider responses keyed by the content
+// around the cursor. Returning to an identical buffer state (undo, typing and
+// deleting the same characters) is served without a provider round-trip.
+type completionCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // front = most recently used
+
+	hits   int64
+	misses int64
+}
+
+// cacheKey identifies a request by a hash of its context window.
+// WindowStart is kept outside the hash so that identical windows at
+// different buffer offsets share an entry and can be rebased.
+type cacheKey struct {
+	Hash        string
+	WindowStart int // 0-indexed start of the hashed window
+	WindowEnd   int // 0-indexed, exclusive
+}
+
+type cacheEntry struct {
+	hash        string
+	windowStart int
+	windowEnd   int
+	response    *types.CompletionResponse
+}
+
+func newCompletionCache(capacity int) *completionCache {
+	if capacity <= 0 {
+		return nil
+	}
+	return &completionCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// computeCacheKey hashes the trimmed window around the cursor, the cursor
+// position relative to that window, and the recent diff history.
+func computeCacheKey(req *types.CompletionRequest, windowTokens int, counter tokenizer.Counter) cacheKey {
+	trimmed, cursorLine, _, windowStart, _ := utils.TrimContentAroundCursor(
+		req.Lines, req.CursorRow-1, req.CursorCol, windowTokens, counter,
+	)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%d\x00", req.FilePath, cursorLine, req.CursorCol)
+	for _, line := range trimmed {
+		h.Write([]byte(line))
+		h.Write([]byte{'\n'})
+	}
+	h.Write([]byte{0})
+	for _, fileHistory := range req.FileDiffHistories {
+		fmt.Fprintf(h, "%s\x00", fileHistory.FileName)
+		for _, diff := range fileHistory.DiffHistory {
+			fmt.Fprintf(h, "%d:%s\x00%d:%s\x00", len(diff.Original), diff.Original, len(diff.Updated), diff.Updated)
+		}
+	}
+
+	return cacheKey{
+		Hash:        hex.EncodeToString(h.Sum(nil)),
+		WindowStart: windowStart,
+		WindowEnd:   windowStart + len(trimmed),
+	}
+}
+
+// Get returns a copy of the cached response for key, rebased to the key's
+// window offset. Returns false on a miss or when the cached completion
+// cannot be rebased (it touches lines outside the cached window).
+func (c *completionCache) Get(key cacheKey) (*types.CompletionResponse, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key.Hash]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	resp, ok := rebaseResponse(entry, key.WindowStart-entry.windowStart)
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	c.hits++
+	return resp, true
+}
+
+// Put stores a response for key, evicting the least recently used entry
+// when the cache is full.
+func (c *completionCache) Put(key cacheKey, resp *types.CompletionResponse) {
+	if c == nil || resp == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key.Hash]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.windowStart = key.WindowStart
+		entry.windowEnd = key.WindowEnd
+		entry.response = cloneResponse(resp, 0)
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	elem := c.order.PushFront(&cacheEntry{
+		hash:        key.Hash,
+		windowStart: key.WindowStart,
+		windowEnd:   key.WindowEnd,
+		response:    cloneResponse(resp, 0),
+	})
+	c.entries[key.Hash] = elem
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).hash)
+	}
+}
+
+// Stats returns the cumulative hit and miss counts.
+func (c *completionCache) Stats() (hits, misses int64) {
+	if c == nil {
+		return 0, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Len returns the number of cached entries.
+func (c *completionCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// rebaseResponse shifts a cached response by delta lines. It is only safe
+// when every completion lies inside the window the key was built from, since
+// content outside the window may differ even when the window is unmoved.
+func rebaseResponse(entry *cacheEntry, delta int) (*types.CompletionResponse, bool) {
+	for _, comp := range entry.response.Completions {
+		if comp.StartLine-1 < entry.windowStart || comp.EndLineInc > entry.windowEnd {
+			return nil, false
+		}
+	}
+	return cloneResponse(entry.response, delta), true
+}
+
+// cloneResponse deep-copies a response, shifting all line numbers by delta.
+func cloneResponse(resp *types.CompletionResponse, delta int) *types.CompletionResponse {
+	clone := &types.CompletionResponse{
+		Completions: make([]*types.Completion, len(resp.Completions)),
+	}
+	for i, comp := range resp.Completions {
+		clone.Completions[i] = &types.Completion{
+			StartLine:  comp.StartLine + delta,
+			EndLineInc: comp.EndLineInc + delta,
+			Lines:      copyLines(comp.Lines),
+		}
+	}
+	if resp.CursorTarget != nil {
+		target := *resp.CursorTarget
+		target.LineNumber += int32(delta)
+		clone.CursorTarget = &target
+	}
+	return clone
+}
+
+// logCacheResult logs a cache lookup together with the running totals.
+func (e *Engine) logCacheResult(hit bool) {
+	hits, misses := e.cache.Stats()
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.Inc(result)
+	logger.Debug("completion cache %s (hits=%d misses=%d entries=%d)", result, hits, misses, e.cache.Len())
+}
+
+// CacheStats returns the completion cache hit and miss counts.
+func (e *Engine) CacheStats() (hits, misses int64) {
+	return e.cache.Stats()
+}
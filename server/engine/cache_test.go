@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"context"
+	"cursortab/assert"
+	"cursortab/types"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func cacheTestRequest(lines []string, row, col int) *types.CompletionRequest {
+	return &types.CompletionRequest{
+		FilePath:  "test.go",
+		Lines:     lines,
+		CursorRow: row,
+		CursorCol: col,
+	}
+}
+
+func singleCompletionResponse(start, end int, lines ...string) *types.CompletionResponse {
+	return &types.CompletionResponse{
+		Completions: []*types.Completion{{StartLine: start, EndLineInc: end, Lines: lines}},
+	}
+}
+
+func TestCacheKey_SameStateSameHash(t *testing.T) {
+	a := computeCacheKey(cacheTestRequest([]string{"a", "b", "c"}, 2, 1), 0, nil)
+	b := computeCacheKey(cacheTestRequest([]string{"a", "b", "c"}, 2, 1), 0, nil)
+	assert.Equal(t, a.Hash, b.Hash, "identical requests hash equally")
+
+	moved := computeCacheKey(cacheTestRequest([]string{"a", "b", "c"}, 3, 1), 0, nil)
+	assert.NotEqual(t, a.Hash, moved.Hash, "cursor position is part of the key")
+
+	edited := computeCacheKey(cacheTestRequest([]string{"a", "x", "c"}, 2, 1), 0, nil)
+	assert.NotEqual(t, a.Hash, edited.Hash, "content is part of the key")
+}
+
+func TestCacheKey_DiffHistoryChangesHash(t *testing.T) {
+	req := cacheTestRequest([]string{"a", "b"}, 1, 0)
+	withoutHistory := computeCacheKey(req, 0, nil)
+
+	req.FileDiffHistories = []*types.FileDiffHistory{{
+		FileName:    "test.go",
+		DiffHistory: []*types.DiffEntry{{Original: "x", Updated: "y"}},
+	}}
+	withHistory := computeCacheKey(req, 0, nil)
+
+	assert.NotEqual(t, withoutHistory.Hash, withHistory.Hash, "diff history is part of the key")
+}
+
+func TestCache_HitAndMissCounts(t *testing.T) {
+	c := newCompletionCache(4)
+	key := computeCacheKey(cacheTestRequest([]string{"a"}, 1, 0), 0, nil)
+
+	_, ok := c.Get(key)
+	assert.False(t, ok, "empty cache misses")
+
+	c.Put(key, singleCompletionResponse(1, 1, "b"))
+	resp, ok := c.Get(key)
+	assert.True(t, ok, "stored key hits")
+	assert.Equal(t, []string{"b"}, resp.Completions[0].Lines, "cached lines")
+
+	hits, misses := c.Stats()
+	assert.Equal(t, int64(1), hits, "hits")
+	assert.Equal(t, int64(1), misses, "misses")
+}
+
+func TestCache_ReturnsCopies(t *testing.T) {
+	c := newCompletionCache(4)
+	key := computeCacheKey(cacheTestRequest([]string{"a"}, 1, 0), 0, nil)
+	c.Put(key, singleCompletionResponse(1, 1, "b"))
+
+	first, _ := c.Get(key)
+	first.Completions[0].Lines[0] = "mutated"
+	first.Completions[0].StartLine = 99
+
+	second, _ := c.Get(key)
+	assert.Equal(t, "b", second.Completions[0].Lines[0], "cached lines unaffected by caller mutation")
+	assert.Equal(t, 1, second.Completions[0].StartLine, "cached start unaffected by caller mutation")
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newCompletionCache(2)
+	keys := make([]cacheKey, 3)
+	for i := range keys {
+		keys[i] = computeCacheKey(cacheTestRequest([]string{fmt.Sprintf("line %d", i)}, 1, 0), 0, nil)
+	}
+
+	c.Put(keys[0], singleCompletionResponse(1, 1, "0"))
+	c.Put(keys[1], singleCompletionResponse(1, 1, "1"))
+	c.Get(keys[0]) // keys[0] becomes most recently used
+	c.Put(keys[2], singleCompletionResponse(1, 1, "2"))
+
+	assert.Equal(t, 2, c.Len(), "capacity respected")
+	_, ok := c.Get(keys[1])
+	assert.False(t, ok, "least recently used entry evicted")
+	_, ok = c.Get(keys[0])
+	assert.True(t, ok, "recently used entry kept")
+}
+
+func TestCache_DisabledIsNilSafe(t *testing.T) {
+	c := newCompletionCache(0)
+	assert.Nil(t, c, "zero capacity disables cache")
+
+	key := computeCacheKey(cacheTestRequest([]string{"a"}, 1, 0), 0, nil)
+	c.Put(key, singleCompletionResponse(1, 1, "b"))
+	_, ok := c.Get(key)
+	assert.False(t, ok, "disabled cache never hits")
+}
+
+func TestCache_RebasesByWindowOffset(t *testing.T) {
+	// Window of ~5 lines around the cursor; shifting the whole window down by
+	// two lines (lines inserted far above) must rebase the cached completion.
+	body := []string{"func a() {", "\tx := 1", "\treturn x", "}", ""}
+	padding := make([]string, 20)
+	for i := range padding {
+		padding[i] = fmt.Sprintf("// padding line number %02d", i)
+	}
+
+	original := append(append(append([]string{}, padding...), body...), padding...)
+	shifted := append([]string{"// new 1", "// new 2"}, original...)
+
+	const tokens = 12
+	origKey := computeCacheKey(cacheTestRequest(original, 22, 2), tokens, nil)
+	shiftedKey := computeCacheKey(cacheTestRequest(shifted, 24, 2), tokens, nil)
+	assert.Equal(t, origKey.Hash, shiftedKey.Hash, "same window hashes equally")
+	assert.Equal(t, 2, shiftedKey.WindowStart-origKey.WindowStart, "window offset delta")
+
+	c := newCompletionCache(4)
+	c.Put(origKey, singleCompletionResponse(22, 22, "\tx := 2"))
+
+	resp, ok := c.Get(shiftedKey)
+	assert.True(t, ok, "rebased hit")
+	assert.Equal(t, 24, resp.Completions[0].StartLine, "start line rebased")
+	assert.Equal(t, 24, resp.Completions[0].EndLineInc, "end line rebased")
+}
+
+func TestCache_NoRebaseOutsideWindow(t *testing.T) {
+	entry := &cacheEntry{
+		windowStart: 10,
+		windowEnd:   15,
+		response:    singleCompletionResponse(3, 3, "far away"),
+	}
+
+	_, ok := rebaseResponse(entry, 2)
+	assert.False(t, ok, "completion outside window cannot be rebased")
+
+	_, ok = rebaseResponse(entry, 0)
+	assert.False(t, ok, "content outside an unmoved window may still differ")
+
+	entry.response = singleCompletionResponse(12, 13, "inside")
+	resp, ok := rebaseResponse(entry, 0)
+	assert.True(t, ok, "completion inside window served")
+	assert.Equal(t, 12, resp.Completions[0].StartLine, "unchanged start")
+}
+
+func TestRequestCompletion_ServedFromCache(t *testing.T) {
+	buf := newMockBuffer()
+	prov := newMockProvider()
+	clock := newMockClock()
+
+	eng, _ := NewEngine(prov, buf, EngineConfig{
+		CompletionTimeout:   5 * time.Second,
+		CompletionCacheSize: 8,
+		CursorPrediction:    CursorPredictionConfig{Enabled: true, ProximityThreshold: 3},
+	}, clock)
+	eng.mainCtx, eng.mainCancel = context.WithCancel(context.Background())
+	defer eng.mainCancel()
+
+	req := &types.CompletionRequest{
+		FilePath:  buf.path,
+		Lines:     buf.lines,
+		CursorRow: buf.row,
+		CursorCol: buf.col,
+	}
+	eng.cache.Put(computeCacheKey(req, 0, nil), singleCompletionResponse(1, 1, "cached line 1"))
+
+	eng.requestCompletion(types.CompletionSourceTyping)
+
+	assert.Equal(t, 0, prov.completionCalls, "provider not called on cache hit")
+	assert.Equal(t, stateHasCompletion, eng.state, "cached completion shown")
+	assert.Equal(t, []string{"cached line 1"}, buf.lastPreparedCompletion.lines, "cached lines rendered")
+
+	hits, _ := eng.CacheStats()
+	assert.Equal(t, int64(1), hits, "hit recorded")
+}
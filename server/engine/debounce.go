@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// AdaptiveDebounceConfig controls learning of trigger delays from typing
+// speed and provider latency.
+type AdaptiveDebounceConfig struct {
+	Enabled  bool
+	MinDelay time.Duration // Lower bound for adapted delays
+	MaxDelay time.Duration // Upper bound for adapted delays (0 = no bound)
+}
+
+const (
+	// debounceSampleSize is the number of recent samples kept for each signal
+	debounceSampleSize = 16
+	// burstInterval is the inter-keystroke gap below which typing counts as a burst
+	burstInterval = 250 * time.Millisecond
+	// burstFactor stretches the typical keystroke gap so the trigger waits for a real pause
+	burstFactor = 1.5
+	// referenceLatency is the provider p50 at which the configured delay is used as-is
+	referenceLatency = 400 * time.Millisecond
+	// minLatencyScale caps how far a fast provider can shorten a delay
+	minLatencyScale = 0.5
+)
+
+// adaptiveDebounce learns the user's inter-keystroke intervals and the
+// provider's recent latency. It lengthens the text change debounce during
+// bursts of fast typing and shortens delays when the provider is fast.
+// Safe for concurrent use: latencies are recorded from request goroutines.
+type adaptiveDebounce struct {
+	mu            sync.Mutex
+	config        AdaptiveDebounceConfig
+	clock         Clock
+	lastKeystroke time.Time
+	intervals     []time.Duration // most recent last
+	latencies     []time.Duration // most recent last
+}
+
+func newAdaptiveDebounce(config AdaptiveDebounceConfig, clock Clock) *adaptiveDebounce {
+	if !config.Enabled {
+		return nil
+	}
+	return &adaptiveDebounce{config: config, clock: clock}
+}
+
+// RecordKeystroke records a text change at the current clock time.
+// Gaps longer than a few seconds are treated as a new session, not a sample.
+func (d *adaptiveDebounce) RecordKeystroke() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.clock.Now()
+	if !d.lastKeystroke.IsZero() {
+		if gap := now.Sub(d.lastKeystroke); gap > 0 && gap < 5*time.Second {
+			d.intervals = appendSample(d.intervals, gap)
+		}
+	}
+	d.lastKeystroke = now
+}
+
+// RecordLatency records the duration of a successful provider request.
+func (d *adaptiveDebounce) RecordLatency(latency time.Duration) {
+	if d == nil || latency <= 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.latencies = appendSample(d.latencies, latency)
+}
+
+// TextChangeDelay returns the debounce to use after a text change.
+// Returns base unchanged when adaptation is disabled.
+func (d *adaptiveDebounce) TextChangeDelay(base time.Duration) time.Duration {
+	if d == nil {
+		return base
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delay := base
+	if typical, ok := median(d.intervals); ok && typical < burstInterval && d.inBurst() {
+		delay = max(delay, time.Duration(float64(typical)*burstFactor))
+	}
+	delay = time.Duration(float64(delay) * d.latencyScale())
+	return d.clamp(delay)
+}
+
+// IdleDelay returns the delay before an idle completion. Negative base
+// values (idle completions disabled) are returned unchanged.
+func (d *adaptiveDebounce) IdleDelay(base time.Duration) time.Duration {
+	if d == nil || base < 0 {
+		return base
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.clamp(time.Duration(float64(base) * d.latencyScale()))
+}
+
+// inBurst reports whether the last keystroke is recent enough to still be
+// part of a typing burst. Caller must hold d.mu.
+func (d *adaptiveDebounce) inBurst() bool {
+	if d.lastKeystroke.IsZero() || len(d.intervals) == 0 {
+		return false
+	}
+	last := d.intervals[len(d.intervals)-1]
+	return last < burstInterval
+}
+
+// latencyScale returns a factor in [minLatencyScale, 1] that shortens delays
+// when the provider's median latency is below referenceLatency.
+// Caller must hold d.mu.
+func (d *adaptiveDebounce) latencyScale() float64 {
+	p50, ok := median(d.latencies)
+	if !ok || p50 >= referenceLatency {
+		return 1
+	}
+	return max(minLatencyScale, float64(p50)/float64(referenceLatency))
+}
+
+// clamp bounds delay to the configured range. Caller must hold d.mu.
+func (d *adaptiveDebounce) clamp(delay time.Duration) time.Duration {
+	if delay < d.config.MinDelay {
+		delay = d.config.MinDelay
+	}
+	if d.config.MaxDelay > 0 && delay > d.config.MaxDelay {
+		delay = d.config.MaxDelay
+	}
+	return delay
+}
+
+// Stats returns the current typing and latency medians (0 if unknown).
+func (d *adaptiveDebounce) Stats() (typingP50, latencyP50 time.Duration) {
+	if d == nil {
+		return 0, 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	typingP50, _ = median(d.intervals)
+	latencyP50, _ = median(d.latencies)
+	return typingP50, latencyP50
+}
+
+func appendSample(samples []time.Duration, sample time.Duration) []time.Duration {
+	samples = append(samples, sample)
+	if len(samples) > debounceSampleSize {
+		samples = samples[len(samples)-debounceSampleSize:]
+	}
+	return samples
+}
+
+func median(samples []time.Duration) (time.Duration, bool) {
+	if len(samples) == 0 {
+		return 0, false
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2], true
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"cursortab/assert"
+	"testing"
+	"time"
+)
+
+func typeKeys(d *adaptiveDebounce, clock *mockClock, count int, gap time.Duration) {
+	for range count {
+		clock.Advance(gap)
+		d.RecordKeystroke()
+	}
+}
+
+func TestAdaptiveDebounce_DisabledReturnsBase(t *testing.T) {
+	d := newAdaptiveDebounce(AdaptiveDebounceConfig{Enabled: false}, newMockClock())
+	assert.Nil(t, d, "disabled debounce is nil")
+
+	d.RecordKeystroke()
+	d.RecordLatency(10 * time.Millisecond)
+	assert.Equal(t, 50*time.Millisecond, d.TextChangeDelay(50*time.Millisecond), "text change delay unchanged")
+	assert.Equal(t, 80*time.Millisecond, d.IdleDelay(80*time.Millisecond), "idle delay unchanged")
+}
+
+func TestAdaptiveDebounce_NoSamplesReturnsBase(t *testing.T) {
+	d := newAdaptiveDebounce(AdaptiveDebounceConfig{Enabled: true}, newMockClock())
+	assert.Equal(t, 50*time.Millisecond, d.TextChangeDelay(50*time.Millisecond), "no samples keeps base")
+}
+
+func TestAdaptiveDebounce_LengthensDuringBurst(t *testing.T) {
+	clock := newMockClock()
+	d := newAdaptiveDebounce(AdaptiveDebounceConfig{Enabled: true}, clock)
+
+	typeKeys(d, clock, 10, 100*time.Millisecond)
+
+	delay := d.TextChangeDelay(50 * time.Millisecond)
+	assert.Equal(t, 150*time.Millisecond, delay, "debounce stretched to 1.5x typical gap")
+}
+
+func TestAdaptiveDebounce_SlowTypingKeepsBase(t *testing.T) {
+	clock := newMockClock()
+	d := newAdaptiveDebounce(AdaptiveDebounceConfig{Enabled: true}, clock)
+
+	typeKeys(d, clock, 10, 600*time.Millisecond)
+
+	assert.Equal(t, 50*time.Millisecond, d.TextChangeDelay(50*time.Millisecond), "slow typing is not a burst")
+}
+
+func TestAdaptiveDebounce_PauseEndsBurst(t *testing.T) {
+	clock := newMockClock()
+	d := newAdaptiveDebounce(AdaptiveDebounceConfig{Enabled: true}, clock)
+
+	typeKeys(d, clock, 10, 100*time.Millisecond)
+	typeKeys(d, clock, 1, 2*time.Second)
+
+	assert.Equal(t, 50*time.Millisecond, d.TextChangeDelay(50*time.Millisecond), "keystroke after a pause uses base")
+}
+
+func TestAdaptiveDebounce_FastProviderShortensDelays(t *testing.T) {
+	d := newAdaptiveDebounce(AdaptiveDebounceConfig{Enabled: true}, newMockClock())
+	for range 5 {
+		d.RecordLatency(100 * time.Millisecond)
+	}
+
+	// 100ms p50 against a 400ms reference scales down to the 0.5 floor
+	assert.Equal(t, 50*time.Millisecond, d.TextChangeDelay(100*time.Millisecond), "text change delay shortened")
+	assert.Equal(t, 100*time.Millisecond, d.IdleDelay(200*time.Millisecond), "idle delay shortened")
+}
+
+func TestAdaptiveDebounce_SlowProviderKeepsBase(t *testing.T) {
+	d := newAdaptiveDebounce(AdaptiveDebounceConfig{Enabled: true}, newMockClock())
+	for range 5 {
+		d.RecordLatency(900 * time.Millisecond)
+	}
+	assert.Equal(t, 100*time.Millisecond, d.TextChangeDelay(100*time.Millisecond), "slow provider keeps base")
+}
+
+func TestAdaptiveDebounce_Clamped(t *testing.T) {
+	clock := newMockClock()
+	d := newAdaptiveDebounce(AdaptiveDebounceConfig{
+		Enabled:  true,
+		MinDelay: 40 * time.Millisecond,
+		MaxDelay: 120 * time.Millisecond,
+	}, clock)
+
+	typeKeys(d, clock, 10, 200*time.Millisecond)
+	assert.Equal(t, 120*time.Millisecond, d.TextChangeDelay(50*time.Millisecond), "clamped to max")
+
+	for range 5 {
+		d.RecordLatency(10 * time.Millisecond)
+	}
+	assert.Equal(t, 40*time.Millisecond, d.IdleDelay(50*time.Millisecond), "clamped to min")
+}
+
+func TestAdaptiveDebounce_IdleDisabledUnchanged(t *testing.T) {
+	d := newAdaptiveDebounce(AdaptiveDebounceConfig{Enabled: true, MinDelay: 20 * time.Millisecond}, newMockClock())
+	assert.Equal(t, -1*time.Millisecond, d.IdleDelay(-1*time.Millisecond), "negative idle delay passes through")
+}
+
+func TestStartTextChangeTimer_UsesAdaptiveDelay(t *testing.T) {
+	buf := newMockBuffer()
+	prov := newMockProvider()
+	clock := newMockClock()
+
+	eng, _ := NewEngine(prov, buf, EngineConfig{
+		TextChangeDebounce: 50 * time.Millisecond,
+		AdaptiveDebounce:   AdaptiveDebounceConfig{Enabled: true},
+	}, clock)
+
+	typeKeys(eng.debounce, clock, 10, 100*time.Millisecond)
+	start := clock.Now()
+	eng.startTextChangeTimer()
+
+	timer := clock.timers[len(clock.timers)-1]
+	assert.Equal(t, 150*time.Millisecond, timer.fireTime.Sub(start), "timer uses adapted delay")
+}
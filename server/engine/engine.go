@@ -12,8 +12,11 @@ import (
 	"time"
 
 	"cursortab/buffer"
+	"cursortab/ignore"
 	"cursortab/logger"
+	"cursortab/redact"
 	"cursortab/text"
+	"cursortab/tokenizer"
 	"cursortab/types"
 	"cursortab/utils"
 )
@@ -26,6 +29,7 @@ type Buffer interface {
 	Row() int
 	Col() int
 	Path() string
+	Filetype() string
 	Version() int
 	ViewportBounds() (top, bottom int)
 	PreviousLines() []string
@@ -41,8 +45,16 @@ type Buffer interface {
 	MoveCursor(line int, center, mark bool) error
 	LinterErrors() *types.LinterErrors
 	RegisterEventHandler(handler func(event string)) error
+	Notify(message string, level int) error
+	SetPrivacyMode(enabled bool) // Stop logging buffer and completion content
 }
 
+// Notification levels passed to Buffer.Notify (match vim.log.levels)
+const (
+	notifyInfo = 2
+	notifyWarn = 3
+)
+
 // Provider defines the interface that all AI providers must implement.
 type Provider interface {
 	GetCompletion(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error)
@@ -115,6 +127,9 @@ type StreamingState struct {
 	// Track if we've rendered the first stage during streaming
 	// Only render one stage during streaming; rest handled at completion
 	FirstStageRendered bool
+
+	// When the request was sent, for latency stats
+	Started time.Time
 }
 
 // TokenStreamingState holds state during token-by-token streaming
@@ -133,6 +148,9 @@ type TokenStreamingState struct {
 
 	// Line number where ghost text is shown (1-indexed)
 	LineNum int
+
+	// When the request was sent, for latency stats
+	Started time.Time
 }
 
 type state int
@@ -167,6 +185,20 @@ type EngineConfig struct {
 	TextChangeDebounce  time.Duration
 	CursorPrediction    CursorPredictionConfig
 	MaxDiffTokens       int // Maximum tokens for diff history per file (0 = no limit)
+	CompletionCacheSize int // Maximum cached completion responses (0 = disabled)
+	CacheWindowTokens   int // Token budget of the window hashed into cache keys (0 = whole file)
+	AdaptiveDebounce    AdaptiveDebounceConfig
+	RateLimit           RateLimitConfig
+	CircuitBreaker      CircuitBreakerConfig
+	Rules               RulesConfig
+	IgnoreFiles         bool   // Honor .cursortabignore files in the workspace
+	GlobalIgnoreFile    string // Additional ignore file applied to every workspace ("" = none)
+	Privacy             PrivacyConfig
+	Normalization       types.Normalization // Cosmetic differences collapsed before staging
+	Tokenizer           tokenizer.Counter   `json:"-"` // Counts tokens for MaxDiffTokens and CacheWindowTokens (nil = heuristic)
+	TracePath           string              // Record a replayable trace of the session to this file ("" = disabled)
+	TraceRedactPatterns []string            // Extra regexps (Go syntax) redacted from the trace, on top of the built-in detectors
+	Stats               StatsConfig
 }
 
 type Engine struct {
@@ -184,6 +216,7 @@ type Engine struct {
 	textChangeTimer Timer
 	mu              sync.RWMutex
 	eventChan       chan Event
+	requests        sync.WaitGroup // batch provider calls in flight
 
 	// Main context and cancel for the engine lifecycle
 	mainCtx    context.Context
@@ -191,7 +224,13 @@ type Engine struct {
 	stopped    bool
 	stopOnce   sync.Once
 
+	// Whether a completion request is pending or streaming, and a channel
+	// closed and replaced when that changes
+	inFlight        bool
+	inFlightChanged chan struct{}
+
 	// Completion state
+	requestID    string // correlates the log lines of the completion requested or shown
 	completions  []*types.Completion
 	applyBatch   buffer.Batch
 	cursorTarget *types.CursorPredictionTarget
@@ -206,6 +245,7 @@ type Engine struct {
 	prefetchedCompletions  []*types.Completion
 	prefetchedCursorTarget *types.CursorPredictionTarget
 	prefetchState          prefetchState
+	prefetchRequestID      string
 
 	// Streaming state (line-by-line)
 	streamingState  *StreamingState
@@ -222,6 +262,33 @@ type Engine struct {
 
 	// Per-file state that persists across file switches (for context restoration)
 	fileStateStore map[string]*FileState
+
+	// Provider responses keyed by buffer content around the cursor (nil = disabled)
+	cache *completionCache
+
+	// Learned trigger delays (nil = use fixed config delays)
+	debounce *adaptiveDebounce
+
+	// Client-side request rate limit and budget (nil = unlimited)
+	limiter *rateLimiter
+
+	// Skips provider requests while the provider is failing (nil = disabled)
+	breaker *circuitBreaker
+
+	// Per-path and per-filetype enable rules (nil = complete every buffer)
+	rules *completionRules
+
+	// .cursortabignore matcher; ignored files never reach the provider (nil = disabled)
+	ignores ignoreMatcher
+
+	// Decides which buffers are handled in privacy mode (nil = none)
+	privacy *privacyPolicy
+
+	// Session trace for replay (nil = not recording)
+	trace *traceRecorder
+
+	// Latencies and suggestion outcomes, rolled up per day
+	stats *statsCollector
 }
 
 func NewEngine(provider Provider, buf Buffer, config EngineConfig, clock Clock) (*Engine, error) {
@@ -232,7 +299,7 @@ func NewEngine(provider Provider, buf Buffer, config EngineConfig, clock Clock)
 	}
 	workspaceID := fmt.Sprintf("%s-%d", workspacePath, os.Getpid())
 
-	return &Engine{
+	e := &Engine{
 		WorkspacePath:          workspacePath,
 		WorkspaceID:            workspaceID,
 		provider:               provider,
@@ -241,6 +308,7 @@ func NewEngine(provider Provider, buf Buffer, config EngineConfig, clock Clock)
 		state:                  stateIdle,
 		ctx:                    nil,
 		eventChan:              make(chan Event, 100),
+		inFlightChanged:        make(chan struct{}),
 		config:                 config,
 		idleTimer:              nil,
 		textChangeTimer:        nil,
@@ -252,7 +320,41 @@ func NewEngine(provider Provider, buf Buffer, config EngineConfig, clock Clock)
 		prefetchState:          prefetchNone,
 		stopped:                false,
 		fileStateStore:         make(map[string]*FileState),
-	}, nil
+		cache:                  newCompletionCache(config.CompletionCacheSize),
+		debounce:               newAdaptiveDebounce(config.AdaptiveDebounce, clock),
+		limiter:                newRateLimiter(config.RateLimit, clock),
+		rules:                  newCompletionRules(config.Rules),
+		privacy:                newPrivacyPolicy(config.Privacy, workspacePath),
+		stats:                  newStatsCollector(config.Stats, clock),
+	}
+	e.breaker = newCircuitBreaker(config.CircuitBreaker, clock, e.onBreakerChange)
+	if config.IgnoreFiles {
+		e.ignores = ignore.New(workspacePath, config.GlobalIgnoreFile)
+	}
+	if config.TracePath != "" {
+		if sp, ok := provider.(LineStreamProvider); ok && sp.GetStreamingType() != StreamingTypeNone {
+			return nil, errors.New("trace recording requires a provider without streaming")
+		}
+		redactor, err := redact.New(config.TraceRedactPatterns)
+		if err != nil {
+			return nil, err
+		}
+		trace, err := openTrace(config.TracePath, clock, workspacePath, config, redactor)
+		if err != nil {
+			return nil, err
+		}
+		e.trace = trace
+		e.buffer = &recordingBuffer{Buffer: buf, trace: trace, private: e.privacy.Private, ignored: e.ignored}
+		e.provider = &recordingProvider{Provider: provider, trace: trace}
+	}
+	return e, nil
+}
+
+// notify shows a message in the editor, logging instead if that fails.
+func (e *Engine) notify(message string, level int) {
+	if err := e.buffer.Notify(message, level); err != nil {
+		logger.Debug("notify failed (%v): %s", err, message)
+	}
 }
 
 func (e *Engine) Start(ctx context.Context) {
@@ -274,7 +376,6 @@ func (e *Engine) Start(ctx context.Context) {
 func (e *Engine) Stop() {
 	e.stopOnce.Do(func() {
 		e.mu.Lock()
-		defer e.mu.Unlock()
 
 		logger.Info("stopping engine...")
 
@@ -299,6 +400,7 @@ func (e *Engine) Stop() {
 		e.stopTextChangeTimer()
 		// Clear any pending completions/predictions (without calling OnReject since we're stopping)
 		e.state = stateIdle
+		e.updateInFlight()
 		e.cursorTarget = nil
 		e.completions = nil
 		e.applyBatch = nil
@@ -307,8 +409,16 @@ func (e *Engine) Stop() {
 		e.prefetchedCursorTarget = nil
 		e.prefetchState = prefetchNone
 		e.completionOriginalLines = nil
+		e.mu.Unlock()
+
+		// Provider calls send their result on the event channel; wait until
+		// they have seen the cancelled context before closing it
+		e.requests.Wait()
 		// Close event channel (this will cause eventLoop to exit if it hasn't already)
 		close(e.eventChan)
+		e.trace.Close()
+		e.stats.Close()
+		e.limiter.Close()
 
 		logger.Info("engine stopped")
 	})
@@ -409,11 +519,13 @@ func (e *Engine) eventLoop(ctx context.Context) {
 			if !ok {
 				// Channel closed - stream complete
 				e.handleStreamCompleteSimple()
+				e.updateInFlight()
 				e.mu.Unlock()
 				continue
 			}
 			e.streamLineNum++
 			e.handleStreamLine(line)
+			e.updateInFlight()
 			e.mu.Unlock()
 
 		case text, ok := <-tokenChan:
@@ -432,10 +544,12 @@ func (e *Engine) eventLoop(ctx context.Context) {
 			if !ok {
 				// Channel closed - token stream complete
 				e.handleTokenStreamComplete()
+				e.updateInFlight()
 				e.mu.Unlock()
 				continue
 			}
 			e.handleTokenChunk(text)
+			e.updateInFlight()
 			e.mu.Unlock()
 
 		case event, ok := <-e.eventChan:
@@ -469,17 +583,23 @@ func (e *Engine) eventLoop(ctx context.Context) {
 func (e *Engine) handleEvent(event Event) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	defer e.updateInFlight()
 
 	// Double-check we're not stopped while holding the lock
 	if e.stopped {
 		return
 	}
 
+	e.trace.recordEvent(event)
 	logger.Debug("handle event: %v (state=%s)", event.Type, e.state)
 	defer func() {
 		logger.Debug("after event: %v (state=%s)", event.Type, e.state)
 	}()
 
+	if event.Type == EventTextChanged {
+		e.debounce.RecordKeystroke()
+	}
+
 	// Layer 1: Background/async results
 	if e.handleBackgroundEvent(event) {
 		return
@@ -502,7 +622,7 @@ func (e *Engine) handleBackgroundEvent(event Event) bool {
 
 	case EventCompletionError:
 		if err, ok := event.Data.(error); !ok || !errors.Is(err, context.Canceled) {
-			logger.Error("completion error: %v", event.Data)
+			e.log().Error("completion error: %v", event.Data)
 		}
 		return true
 
@@ -531,13 +651,28 @@ func (e *Engine) handleBackgroundEvent(event Event) bool {
 		}
 		return true
 
+	case EventBreakerChanged:
+		if change, ok := event.Data.(breakerChange); ok {
+			e.handleBreakerChanged(change)
+		}
+		return true
+
 		// Note: EventStreamLine, EventStreamComplete, EventStreamError are now handled
 		// directly in the event loop via channel selection, not through eventChan
 	}
 	return false
 }
 
+// log returns a logger tagged with the current completion's request ID
+func (e *Engine) log() logger.Entry {
+	return logger.With("engine", e.requestID)
+}
+
 func (e *Engine) reject() {
+	if e.state == stateHasCompletion || e.state == stateHasCursorTarget {
+		e.log().Debug("rejected")
+	}
+	e.stats.Decide(outcomeRejected)
 	e.clearState(ClearOptions{
 		CancelCurrent:     true,
 		CancelPrefetch:    true,
@@ -571,6 +706,13 @@ func (e *Engine) requestCompletion(source types.CompletionSource) {
 
 	e.syncBuffer()
 
+	if !e.bufferAllowed() {
+		return
+	}
+
+	e.requestID = logger.NewRequestID()
+	log := e.log()
+
 	req := &types.CompletionRequest{
 		Source:            source,
 		WorkspacePath:     e.WorkspacePath,
@@ -584,6 +726,26 @@ func (e *Engine) requestCompletion(source types.CompletionSource) {
 		CursorCol:         e.buffer.Col(),
 		ViewportHeight:    e.getViewportHeightConstraint(),
 		LinterErrors:      e.buffer.LinterErrors(),
+		PrivacyMode:       e.privateBuffer(),
+	}
+	log.Debug("completion requested: source=%s file=%s cursor=%d:%d", source, req.FilePath, req.CursorRow, req.CursorCol)
+
+	// Serve identical buffer states from the cache without a provider round-trip
+	var key cacheKey
+	if e.cache != nil {
+		key = computeCacheKey(req, e.config.CacheWindowTokens, e.config.Tokenizer)
+		if resp, ok := e.cache.Get(key); ok {
+			log.Debug("served from cache")
+			e.logCacheResult(true)
+			e.state = statePendingCompletion
+			e.handleCompletionReadyImpl(resp)
+			return
+		}
+		e.logCacheResult(false)
+	}
+
+	if !e.allowRequest(source) {
+		return
 	}
 
 	// Check if provider supports streaming
@@ -605,11 +767,17 @@ func (e *Engine) requestCompletion(source types.CompletionSource) {
 
 	ctx, cancel := context.WithTimeout(e.mainCtx, e.config.CompletionTimeout)
 	e.currentCancel = cancel
+	ctx = logger.WithRequestID(ctx, e.requestID)
 
+	e.requests.Add(1)
 	go func() {
+		defer e.requests.Done()
 		defer cancel()
 
+		start := e.clock.Now()
 		result, err := e.provider.GetCompletion(ctx, req)
+		e.breaker.Record(err)
+		e.recordRequest(err)
 
 		if err != nil {
 			select {
@@ -618,6 +786,12 @@ func (e *Engine) requestCompletion(source types.CompletionSource) {
 			}
 			return
 		}
+		e.cache.Put(key, result)
+		latency := e.clock.Now().Sub(start)
+		e.debounce.RecordLatency(latency)
+		e.recordLatency(latency)
+		e.stats.FirstLine(latency)
+		log.Debug("provider returned %d completions in %v", len(result.Completions), latency)
 
 		select {
 		case e.eventChan <- Event{Type: EventCompletionReady, Data: result}:
@@ -632,10 +806,13 @@ func (e *Engine) requestStreamingCompletion(provider LineStreamProvider, req *ty
 
 	ctx, cancel := context.WithTimeout(e.mainCtx, e.config.CompletionTimeout)
 	e.streamingCancel = cancel
+	ctx = logger.WithRequestID(ctx, e.requestID)
 
 	// Prepare the stream
 	stream, providerCtx, err := provider.PrepareLineStream(ctx, req)
 	if err != nil {
+		e.breaker.Record(err)
+		e.recordRequest(err)
 		cancel()
 		e.state = stateIdle
 		return
@@ -669,7 +846,9 @@ func (e *Engine) requestStreamingCompletion(provider LineStreamProvider, req *ty
 		),
 		ProviderContext: providerCtx,
 		Request:         req,
+		Started:         e.clock.Now(),
 	}
+	e.streamingState.StageBuilder.Normalization = e.config.Normalization
 
 	// Set stream channel directly - event loop will select on it
 	e.streamLinesChan = stream.LinesChan()
@@ -682,10 +861,13 @@ func (e *Engine) requestTokenStreamingCompletion(provider TokenStreamProvider, r
 
 	ctx, cancel := context.WithTimeout(e.mainCtx, e.config.CompletionTimeout)
 	e.streamingCancel = cancel
+	ctx = logger.WithRequestID(ctx, e.requestID)
 
 	// Prepare the stream
 	stream, providerCtx, err := provider.PrepareTokenStream(ctx, req)
 	if err != nil {
+		e.breaker.Record(err)
+		e.recordRequest(err)
 		cancel()
 		e.state = stateIdle
 		return
@@ -695,8 +877,7 @@ func (e *Engine) requestTokenStreamingCompletion(provider TokenStreamProvider, r
 	linePrefix := ""
 	if req.CursorRow >= 1 && req.CursorRow <= len(req.Lines) {
 		currentLine := req.Lines[req.CursorRow-1]
-		cursorCol := min(req.CursorCol, len(currentLine))
-		linePrefix = currentLine[:cursorCol]
+		linePrefix = currentLine[:text.RuneBoundary(currentLine, req.CursorCol)]
 	}
 
 	// Initialize token streaming state
@@ -706,6 +887,7 @@ func (e *Engine) requestTokenStreamingCompletion(provider TokenStreamProvider, r
 		Request:         req,
 		LinePrefix:      linePrefix,
 		LineNum:         req.CursorRow,
+		Started:         e.clock.Now(),
 	}
 
 	// Set token stream channel - event loop will select on it
@@ -799,6 +981,13 @@ func (e *Engine) clearCompletionUIOnly() {
 }
 
 func (e *Engine) acceptCompletion() {
+	if e.stagedCompletion != nil {
+		e.log().Debug("accepted stage %d/%d", e.stagedCompletion.CurrentIdx+1, len(e.stagedCompletion.Stages))
+	} else {
+		e.log().Debug("accepted")
+	}
+	e.stats.Decide(outcomeAccepted)
+	e.stats.StageAccepted()
 	if e.applyBatch != nil {
 		if err := e.applyBatch.Execute(); err != nil {
 			logger.Error("error applying completion: %v", err)
@@ -1008,8 +1197,8 @@ func (e *Engine) trimFileStateStore(maxFiles int) {
 // getAllFileDiffHistories returns diff history for the current file only.
 // This prevents context pollution from other files' diffs.
 func (e *Engine) getAllFileDiffHistories() []*types.FileDiffHistory {
-	// Only return diffs for the current file
-	if e.buffer.Path() == "" || len(e.buffer.DiffHistories()) == 0 {
+	// Only return diffs for the current file, never for ignored files
+	if e.buffer.Path() == "" || len(e.buffer.DiffHistories()) == 0 || e.ignored(e.buffer.Path()) {
 		return nil
 	}
 
@@ -1018,7 +1207,7 @@ func (e *Engine) getAllFileDiffHistories() []*types.FileDiffHistory {
 
 	// Apply token limiting if configured
 	if e.config.MaxDiffTokens > 0 {
-		diffs = utils.TrimDiffEntries(diffs, e.config.MaxDiffTokens)
+		diffs = utils.TrimDiffEntries(diffs, e.config.MaxDiffTokens, e.config.Tokenizer)
 	}
 
 	if len(diffs) == 0 {
@@ -1056,6 +1245,7 @@ func (e *Engine) acceptCursorTarget() {
 		return
 	}
 
+	e.log().Debug("jumped to line %d", e.cursorTarget.LineNumber)
 	err := e.buffer.MoveCursor(int(e.cursorTarget.LineNumber), true, true)
 	if err != nil {
 		logger.Error("error moving cursor: %v", err)
@@ -1139,51 +1329,80 @@ func (e *Engine) getStage(idx int) *text.Stage {
 	return stage
 }
 
-// processCompletion is the SINGLE ENTRY POINT for processing all completions.
-// It handles diff analysis, staging decisions, and showing completions.
-// Called from both fresh completion responses and prefetch paths.
-// Returns true if completion was processed successfully, false if no changes.
-func (e *Engine) processCompletion(completion *types.Completion) bool {
-	defer logger.Trace("engine.processCompletion")()
-	if completion == nil {
-		return false
-	}
+// StageView is the buffer a completion is staged against.
+type StageView struct {
+	Lines          []string
+	Path           string // file path for cursor targets
+	CursorRow      int    // 1-indexed
+	ViewportTop    int    // first visible line, 1-indexed (0 with ViewportBottom 0 = all visible)
+	ViewportBottom int    // last visible line, inclusive
+}
 
-	// Check for actual changes
-	if !e.buffer.HasChanges(completion.StartLine, completion.EndLineInc, completion.Lines) {
-		return false
+// StageCompletion prepares a completion for display: lines that only
+// change what normalization ignores keep the buffer's text, then the diff
+// is split into stages around the cursor. It returns the replacement lines
+// and the staging, which is nil when nothing needs staging; ok is false
+// when the completion changes nothing beyond what normalization ignores.
+func StageCompletion(view StageView, completion *types.Completion, normalization types.Normalization, proximityThreshold int) (lines []string, staging *text.StagingResult, ok bool) {
+	var originalLines []string
+	for i := completion.StartLine; i <= completion.EndLineInc && i-1 < len(view.Lines); i++ {
+		originalLines = append(originalLines, view.Lines[i-1])
 	}
 
-	// Extract original lines from buffer
-	bufferLines := e.buffer.Lines()
-	var originalLines []string
-	for i := completion.StartLine; i <= completion.EndLineInc && i-1 < len(bufferLines); i++ {
-		originalLines = append(originalLines, bufferLines[i-1])
+	lines = text.CollapseEquivalent(originalLines, completion.Lines, normalization)
+	if text.EquivalentLines(lines, originalLines, normalization) {
+		return nil, nil, false
 	}
 
 	// Analyze diff with viewport awareness
-	viewportTop, viewportBottom := e.buffer.ViewportBounds()
-	originalText := text.JoinLines(originalLines)
-	newText := text.JoinLines(completion.Lines)
 	diffResult := text.AnalyzeDiffForStagingWithViewport(
-		originalText, newText,
-		viewportTop, viewportBottom,
+		text.JoinLines(originalLines), text.JoinLines(lines),
+		view.ViewportTop, view.ViewportBottom,
 		completion.StartLine,
 	)
 
 	// Create stages - CreateStages handles all viewport/distance logic and returns:
 	// - nil: no staging needed (single visible+close cluster or no changes)
 	// - StagingResult with FirstNeedsNavigation: whether to show cursor prediction UI
-	stagingResult := text.CreateStages(
+	staging = text.CreateStages(
 		diffResult,
-		e.buffer.Row(),
-		viewportTop, viewportBottom,
+		view.CursorRow,
+		view.ViewportTop, view.ViewportBottom,
 		completion.StartLine,
-		e.config.CursorPrediction.ProximityThreshold,
-		e.buffer.Path(),
-		completion.Lines,
+		proximityThreshold,
+		view.Path,
+		lines,
 		originalLines, // oldLines parameter
 	)
+	return lines, staging, true
+}
+
+// processCompletion is the SINGLE ENTRY POINT for processing all completions.
+// It handles diff analysis, staging decisions, and showing completions.
+// Called from both fresh completion responses and prefetch paths.
+// Returns true if completion was processed successfully, false if no changes.
+func (e *Engine) processCompletion(completion *types.Completion) bool {
+	defer logger.Trace("engine.processCompletion")()
+	if completion == nil {
+		return false
+	}
+
+	viewportTop, viewportBottom := e.buffer.ViewportBounds()
+	lines, stagingResult, ok := StageCompletion(StageView{
+		Lines:          e.buffer.Lines(),
+		Path:           e.buffer.Path(),
+		CursorRow:      e.buffer.Row(),
+		ViewportTop:    viewportTop,
+		ViewportBottom: viewportBottom,
+	}, completion, e.config.Normalization, e.config.CursorPrediction.ProximityThreshold)
+	if !ok {
+		return false
+	}
+
+	// Check for actual changes
+	if !e.buffer.HasChanges(completion.StartLine, completion.EndLineInc, lines) {
+		return false
+	}
 
 	if stagingResult != nil && len(stagingResult.Stages) > 0 {
 		// Convert stages to any slice for storage
@@ -1196,6 +1415,9 @@ func (e *Engine) processCompletion(completion *types.Completion) bool {
 			CurrentIdx: 0,
 			SourcePath: e.buffer.Path(),
 		}
+		e.log().Debug("staged %d stages (first needs navigation: %v)", len(stagingResult.Stages), stagingResult.FirstNeedsNavigation)
+		e.stats.Staged(len(stagingResult.Stages))
+		e.stats.Shown(e.requestID, e.buffer.Filetype())
 
 		if stagingResult.FirstNeedsNavigation {
 			// First stage is outside viewport or far from cursor - show cursor prediction
@@ -1332,6 +1554,7 @@ func (e *Engine) handleStreamLine(line string) {
 
 	// First line validation
 	if !ss.Validated {
+		e.stats.FirstLine(e.clock.Now().Sub(ss.Started))
 		if sp, ok := e.provider.(LineStreamProvider); ok {
 			if err := sp.ValidateFirstLine(ss.ProviderContext, line); err != nil {
 				e.cancelStreaming()
@@ -1371,6 +1594,11 @@ func (e *Engine) handleStreamLine(line string) {
 // handleStreamCompleteSimple processes stream completion when lines channel closes.
 // Called directly from event loop.
 func (e *Engine) handleStreamCompleteSimple() {
+	// A stream that produced output proves the provider is reachable
+	if e.streamLineNum > 0 {
+		e.breaker.Record(nil)
+	}
+
 	// Clear stream channel first
 	e.streamLinesChan = nil
 	e.streamLineNum = 0
@@ -1381,6 +1609,8 @@ func (e *Engine) handleStreamCompleteSimple() {
 
 	ss := e.streamingState
 	firstStageRendered := ss.FirstStageRendered
+	e.recordRequest(nil)
+	e.recordLatency(e.clock.Now().Sub(ss.Started))
 
 	// Process pending line if not truncated
 	if ss.HasPendingLine {
@@ -1410,6 +1640,8 @@ func (e *Engine) handleStreamCompleteSimple() {
 		CurrentIdx: 0,
 		SourcePath: e.buffer.Path(),
 	}
+	e.stats.Staged(len(stagingResult.Stages))
+	e.stats.Shown(e.requestID, e.buffer.Filetype())
 
 	// If we already rendered the first stage during streaming, don't re-render it
 	if firstStageRendered {
@@ -1465,6 +1697,7 @@ func (e *Engine) renderStreamedStage(stage *text.Stage) {
 		Lines:      stage.Lines,
 	}}
 	e.cursorTarget = stage.CursorTarget
+	e.stats.Shown(e.requestID, e.buffer.Filetype())
 }
 
 // handleTokenChunk processes a cumulative text chunk from token streaming.
@@ -1475,6 +1708,10 @@ func (e *Engine) handleTokenChunk(accumulatedText string) {
 		return
 	}
 
+	if ts.AccumulatedText == "" && accumulatedText != "" {
+		e.stats.FirstLine(e.clock.Now().Sub(ts.Started))
+	}
+
 	// Update accumulated text
 	ts.AccumulatedText = accumulatedText
 
@@ -1513,6 +1750,7 @@ func (e *Engine) handleTokenChunk(accumulatedText string) {
 		Lines:      []string{fullLineText},
 	}}
 	e.completionOriginalLines = []string{oldLine}
+	e.stats.Shown(e.requestID, e.buffer.Filetype())
 }
 
 // handleTokenStreamComplete processes token stream completion when channel closes.
@@ -1535,6 +1773,13 @@ func (e *Engine) handleTokenStreamComplete() {
 	e.tokenStreamingState = nil
 	e.streamingCancel = nil
 
+	// A stream that produced output proves the provider is reachable
+	if finalText != "" {
+		e.breaker.Record(nil)
+		e.recordLatency(e.clock.Now().Sub(ts.Started))
+	}
+	e.recordRequest(nil)
+
 	// If empty, go idle
 	if finalText == "" {
 		e.buffer.ClearUI()
@@ -20,6 +20,7 @@ type mockBuffer struct {
 	row            int
 	col            int
 	path           string
+	filetype       string
 	version        int
 	viewportTop    int
 	viewportBottom int
@@ -33,6 +34,8 @@ type mockBuffer struct {
 	clearUICalls           int
 	commitPendingCalls     int
 	showCursorTargetLine   int
+	notifications          []string
+	privacyMode            bool
 	prepareCompletionCalls int
 	lastPreparedCompletion struct {
 		startLine  int
@@ -47,6 +50,7 @@ func newMockBuffer() *mockBuffer {
 		row:            1,
 		col:            0,
 		path:           "test.go",
+		filetype:       "go",
 		version:        1,
 		viewportTop:    1,
 		viewportBottom: 50,
@@ -84,6 +88,12 @@ func (b *mockBuffer) Path() string {
 	return b.path
 }
 
+func (b *mockBuffer) Filetype() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.filetype
+}
+
 func (b *mockBuffer) Version() int {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -188,6 +198,17 @@ func (b *mockBuffer) RegisterEventHandler(handler func(event string)) error {
 	return nil
 }
 
+func (b *mockBuffer) Notify(message string, level int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.notifications = append(b.notifications, message)
+	return nil
+}
+
+func (b *mockBuffer) SetPrivacyMode(enabled bool) {
+	b.privacyMode = enabled
+}
+
 // mockBatch implements buffer.Batch
 type mockBatch struct {
 	executed bool
@@ -905,3 +926,109 @@ func TestHandleCursorTarget_FarAway(t *testing.T) {
 	assert.Equal(t, stateHasCursorTarget, eng.state, "state when far away")
 	assert.Equal(t, 10, buf.showCursorTargetLine, "showCursorTargetLine")
 }
+
+func TestProcessCompletion_Normalization(t *testing.T) {
+	buf := newMockBuffer()
+	buf.lines = []string{"a := 1  ", "b := 2", "c := 3"}
+	eng := createTestEngine(buf, newMockProvider(), newMockClock())
+	eng.config.Normalization = types.Normalization{TrailingWhitespace: true}
+
+	cosmetic := &types.Completion{StartLine: 1, EndLineInc: 3, Lines: []string{"a := 1", "b := 2", "c := 3"}}
+	assert.False(t, eng.processCompletion(cosmetic), "whitespace-only suggestion dropped")
+
+	mixed := &types.Completion{StartLine: 1, EndLineInc: 3, Lines: []string{"a := 1", "b := 20", "c := 3"}}
+	assert.True(t, eng.processCompletion(mixed), "real edit shown")
+	assert.Equal(t, 2, buf.lastPreparedCompletion.startLine, "cosmetic line not staged")
+	assert.Equal(t, []string{"b := 20"}, buf.lastPreparedCompletion.lines, "real edit kept")
+}
+
+func TestEngine_InFlightSignalsRequests(t *testing.T) {
+	clock := newMockClock()
+	eng := createTestEngine(newMockBuffer(), newMockProvider(), clock)
+	eng.mainCtx, eng.mainCancel = context.WithCancel(context.Background())
+	defer eng.Stop()
+
+	busy, changed := eng.InFlight()
+	assert.False(t, busy, "idle engine")
+
+	eng.handleEvent(Event{Type: EventTextChanged})
+	clock.Advance(100 * time.Millisecond)
+	eng.handleEvent(<-eng.eventChan) // debounce timer starts the request
+	assertClosed(t, changed, "waiters woken when the request starts")
+	busy, changed = eng.InFlight()
+	assert.True(t, busy, "request in flight")
+
+	eng.handleEvent(<-eng.eventChan) // provider result
+	assertClosed(t, changed, "waiters woken when the request finishes")
+	busy, _ = eng.InFlight()
+	assert.False(t, busy, "request finished")
+}
+
+func assertClosed(t *testing.T, ch <-chan struct{}, msg string) {
+	t.Helper()
+	select {
+	case <-ch:
+	default:
+		t.Fatal(msg)
+	}
+}
+
+func TestStageCompletion(t *testing.T) {
+	view := StageView{Lines: []string{"a := 1  ", "b := 2", "c := 3"}, Path: "main.go", CursorRow: 1}
+	policy := types.Normalization{TrailingWhitespace: true}
+
+	_, _, ok := StageCompletion(view, &types.Completion{StartLine: 1, EndLineInc: 1, Lines: []string{"a := 1"}}, policy, 3)
+	assert.False(t, ok, "whitespace-only suggestion changes nothing")
+
+	// Edits far apart are split into stages
+	far := &types.Completion{StartLine: 1, EndLineInc: 3, Lines: []string{"a := 10", "b := 2", "c := 30"}}
+	lines, staging, ok := StageCompletion(view, far, policy, 0)
+	assert.True(t, ok, "real edits")
+	assert.Equal(t, "c := 30", lines[2], "edit kept")
+	assert.Equal(t, "a := 10", lines[0], "changed line replaced")
+	assert.NotNil(t, staging, "staged")
+	assert.Len(t, 2, staging.Stages, "one stage per edit")
+}
+
+var _ Buffer = (*buffer.MemoryBuffer)(nil)
+
+func TestEngine_MemoryBufferAcceptsCompletion(t *testing.T) {
+	buf := buffer.NewMemory("main.go", "go", []string{"line 1", "line 2"})
+	clock := newMockClock()
+	eng, err := NewEngine(newMockProvider(), buf, EngineConfig{
+		NsID:                1,
+		CompletionTimeout:   5 * time.Second,
+		IdleCompletionDelay: 500 * time.Millisecond,
+		TextChangeDebounce:  100 * time.Millisecond,
+	}, clock)
+	assert.NoError(t, err, "NewEngine")
+	eng.mainCtx, eng.mainCancel = context.WithCancel(context.Background())
+	defer eng.Stop()
+
+	drain := func() {
+		for {
+			select {
+			case event := <-eng.eventChan:
+				eng.handleEvent(event)
+			case <-time.After(100 * time.Millisecond):
+				return
+			}
+		}
+	}
+
+	buf.SetLines([]string{"line 1 edited", "line 2"})
+	eng.handleEvent(Event{Type: EventTextChanged})
+	clock.Advance(100 * time.Millisecond)
+	drain()
+	assert.Equal(t, stateHasCompletion, eng.state, "completion shown")
+
+	eng.handleEvent(Event{Type: EventTab})
+	assert.Equal(t, "completed line 1", buf.EditorLines()[0], "completion applied to the text")
+	calls := buf.UICalls()
+	assert.Equal(t, buffer.UIShowCompletion, calls[0].Kind, "completion shown first")
+	found := false
+	for _, call := range calls {
+		found = found || call.Kind == buffer.UIApplyCompletion
+	}
+	assert.True(t, found, "apply captured")
+}
@@ -21,6 +21,7 @@ const (
 	EventCompletionError   EventType = "completion_error"
 	EventPrefetchReady     EventType = "prefetch_ready"
 	EventPrefetchError     EventType = "prefetch_error"
+	EventBreakerChanged    EventType = "breaker_changed"
 
 	// Streaming events (handled directly via channel selection, not through eventChan)
 	EventStreamLine     EventType = "stream_line"     // A line was received from the stream
@@ -51,6 +52,7 @@ func buildEventTypeMap() map[string]EventType {
 		EventCompletionError,
 		EventPrefetchReady,
 		EventPrefetchError,
+		EventBreakerChanged,
 		EventStreamLine,
 		EventStreamComplete,
 		EventStreamError,
@@ -91,9 +93,11 @@ func (e *Engine) handleTextChangeImpl() {
 	if matches {
 		if hasRemaining {
 			// Typing matches - Lua already updated the visual, just keep completion state
+			e.stats.TypingMatched()
 			return
 		}
 		// User typed everything - completion fully typed
+		e.stats.Decide(outcomeTyped)
 		e.clearAll()
 		e.state = stateIdle
 		e.startTextChangeTimer()
@@ -213,7 +217,7 @@ func (e *Engine) handleCompletionReadyImpl(response *types.CompletionResponse) {
 	}
 
 	// No changes - handle no-op case
-	logger.Debug("no changes to completion")
+	e.log().Debug("no changes to completion")
 	if e.config.CursorPrediction.AutoAdvance && e.config.CursorPrediction.Enabled {
 		e.cursorTarget = &types.CursorPredictionTarget{
 			LineNumber:      int32(completion.EndLineInc),
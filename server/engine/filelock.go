@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// lockRetryInterval is how often a held state file lock is retried.
+	lockRetryInterval = 5 * time.Millisecond
+	// lockWaitTimeout bounds how long a save waits for another process.
+	lockWaitTimeout = 2 * time.Second
+	// lockStaleAfter is the age at which a lock left behind by a crashed
+	// process is removed. A read-merge-write takes milliseconds.
+	lockStaleAfter = 10 * time.Second
+)
+
+// lockStateFile takes an advisory lock on a state file shared between
+// processes (the daemon and the language server) so their read-merge-write
+// cycles do not overwrite each other's counts. The lock is a path+".lock"
+// file created with O_EXCL, which works on every platform. The returned
+// function releases it.
+func lockStateFile(path string) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(lockWaitTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > lockStaleAfter {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is held by another process", lockPath)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cursortab/metrics"
+)
+
+// Process-wide metrics, served when metrics.address is configured
+var (
+	providerRequests = metrics.NewCounter("cursortab_provider_requests_total",
+		"Completion requests by provider and outcome (ok, error, timeout, canceled, skipped).",
+		"provider", "outcome")
+	providerLatency = metrics.NewHistogram("cursortab_provider_latency_seconds",
+		"Time for the provider to answer a completion request completely.",
+		latencySeconds(), "provider")
+	suggestions = metrics.NewCounter("cursortab_suggestions_total",
+		"Suggestions shown and how they were answered (shown, accepted, typed, rejected).",
+		"provider", "outcome")
+	cacheRequests = metrics.NewCounter("cursortab_cache_requests_total",
+		"Completion cache lookups by result (hit, miss).",
+		"result")
+	breakerStateGauge = metrics.NewGauge("cursortab_circuit_breaker_state",
+		"Circuit breaker state: 0 closed, 1 open, 2 half-open.")
+)
+
+func init() {
+	metrics.NewCounterFunc("cursortab_event_loop_restarts_total",
+		"Event loop restarts after a recovered panic.",
+		func() float64 { return float64(eventLoopRestarts.Load()) })
+}
+
+// latencySeconds converts the stats latency buckets to seconds
+func latencySeconds() []float64 {
+	buckets := make([]float64, len(latencyBuckets))
+	for i, ms := range latencyBuckets {
+		buckets[i] = float64(ms) / 1000
+	}
+	return buckets
+}
+
+// requestOutcome classifies a provider result for providerRequests
+func requestOutcome(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
+
+// providerName is the provider label stats and metrics are kept under
+func (e *Engine) providerName() string {
+	return e.config.Stats.Provider
+}
+
+// recordRequest counts a finished provider request
+func (e *Engine) recordRequest(err error) {
+	providerRequests.Inc(e.providerName(), requestOutcome(err))
+}
+
+// recordLatency records a complete provider answer in stats and metrics
+func (e *Engine) recordLatency(d time.Duration) {
+	e.stats.ProviderLatency(d)
+	providerLatency.Observe(d.Seconds(), e.providerName())
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"context"
+	"cursortab/assert"
+	"cursortab/types"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRequestOutcome(t *testing.T) {
+	assert.Equal(t, "ok", requestOutcome(nil), "success")
+	assert.Equal(t, "timeout", requestOutcome(fmt.Errorf("send: %w", context.DeadlineExceeded)), "deadline")
+	assert.Equal(t, "canceled", requestOutcome(context.Canceled), "canceled")
+	assert.Equal(t, "error", requestOutcome(errProviderDown), "provider error")
+}
+
+func TestRequestCompletion_RecordsMetrics(t *testing.T) {
+	buf := newMockBuffer()
+	prov := newMockProvider()
+	prov.completionErr = errProviderDown
+	clock := newMockClock()
+
+	// Counters are process-wide: use a provider name of its own and compare
+	// against the counts before the test
+	const name = "metrics-test"
+	skipped := providerRequests.Value(name, "skipped")
+	failed := providerRequests.Value(name, "error")
+	succeeded := providerRequests.Value(name, "ok")
+	eng, _ := NewEngine(prov, buf, EngineConfig{
+		CompletionTimeout: 5 * time.Second,
+		CircuitBreaker:    CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Second},
+		Stats:             StatsConfig{Provider: name},
+	}, clock)
+	eng.mainCtx, eng.mainCancel = context.WithCancel(context.Background())
+	defer eng.mainCancel()
+
+	eng.requestCompletion(types.CompletionSourceTyping)
+	handleUntilResult(t, eng)
+	eng.state = stateIdle
+	assert.Equal(t, float64(breakerOpen), breakerStateGauge.Value(), "breaker state")
+
+	eng.requestCompletion(types.CompletionSourceTyping)
+	assert.Equal(t, skipped+1, providerRequests.Value(name, "skipped"), "skipped while open")
+
+	prov.mu.Lock()
+	prov.completionErr = nil
+	prov.mu.Unlock()
+	clock.Advance(time.Second)
+	eng.requestCompletion(types.CompletionSourceTyping)
+	handleUntilResult(t, eng)
+
+	assert.Equal(t, failed+1, providerRequests.Value(name, "error"), "failed request")
+	assert.Equal(t, succeeded+1, providerRequests.Value(name, "ok"), "probe request")
+	assert.Equal(t, float64(breakerClosed), breakerStateGauge.Value(), "breaker closed")
+}
@@ -36,35 +36,61 @@ func (e *Engine) requestPrefetch(source types.CompletionSource, overrideRow int,
 	// Sync buffer to ensure latest context
 	e.syncBuffer()
 
+	if !e.bufferAllowed() {
+		return
+	}
+
+	e.prefetchRequestID = logger.NewRequestID()
+	log := logger.With("engine", e.prefetchRequestID)
+
+	// Snapshot required values to avoid races with buffer mutation
+	req := &types.CompletionRequest{
+		Source:            source,
+		WorkspacePath:     e.WorkspacePath,
+		WorkspaceID:       e.WorkspaceID,
+		FilePath:          e.buffer.Path(),
+		Lines:             append([]string{}, e.buffer.Lines()...),
+		Version:           e.buffer.Version(),
+		PreviousLines:     append([]string{}, e.buffer.PreviousLines()...),
+		FileDiffHistories: e.getAllFileDiffHistories(),
+		CursorRow:         overrideRow,
+		CursorCol:         overrideCol,
+		ViewportHeight:    e.getViewportHeightConstraint(),
+		LinterErrors:      e.buffer.LinterErrors(),
+		PrivacyMode:       e.privateBuffer(),
+	}
+	log.Debug("prefetch requested: file=%s cursor=%d:%d", req.FilePath, req.CursorRow, req.CursorCol)
+
+	var key cacheKey
+	if e.cache != nil {
+		key = computeCacheKey(req, e.config.CacheWindowTokens, e.config.Tokenizer)
+		if resp, ok := e.cache.Get(key); ok {
+			e.logCacheResult(true)
+			e.prefetchState = prefetchInFlight
+			e.handlePrefetchReady(resp)
+			return
+		}
+		e.logCacheResult(false)
+	}
+
+	if !e.allowRequest(source) {
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(e.mainCtx, e.config.CompletionTimeout)
 	e.prefetchCancel = cancel
 	e.prefetchState = prefetchInFlight
+	ctx = logger.WithRequestID(ctx, e.prefetchRequestID)
 
-	// Snapshot required values to avoid races with buffer mutation
-	lines := append([]string{}, e.buffer.Lines()...)
-	previousLines := append([]string{}, e.buffer.PreviousLines()...)
-	version := e.buffer.Version()
-	filePath := e.buffer.Path()
-	linterErrors := e.buffer.LinterErrors()
-	viewportHeight := e.getViewportHeightConstraint()
-
+	e.requests.Add(1)
 	go func() {
+		defer e.requests.Done()
 		defer cancel()
 
-		result, err := e.provider.GetCompletion(ctx, &types.CompletionRequest{
-			Source:            source,
-			WorkspacePath:     e.WorkspacePath,
-			WorkspaceID:       e.WorkspaceID,
-			FilePath:          filePath,
-			Lines:             lines,
-			Version:           version,
-			PreviousLines:     previousLines,
-			FileDiffHistories: e.getAllFileDiffHistories(),
-			CursorRow:         overrideRow,
-			CursorCol:         overrideCol,
-			ViewportHeight:    viewportHeight,
-			LinterErrors:      linterErrors,
-		})
+		start := e.clock.Now()
+		result, err := e.provider.GetCompletion(ctx, req)
+		e.breaker.Record(err)
+		e.recordRequest(err)
 
 		if err != nil {
 			select {
@@ -73,6 +99,11 @@ func (e *Engine) requestPrefetch(source types.CompletionSource, overrideRow int,
 			}
 			return
 		}
+		e.cache.Put(key, result)
+		latency := e.clock.Now().Sub(start)
+		e.debounce.RecordLatency(latency)
+		e.recordLatency(latency)
+		log.Debug("provider returned %d completions in %v", len(result.Completions), latency)
 
 		select {
 		case e.eventChan <- Event{Type: EventPrefetchReady, Data: result}:
@@ -139,10 +170,11 @@ func (e *Engine) tryShowPrefetchedCompletion() bool {
 
 	comp := e.prefetchedCompletions[0]
 
-	// Clear prefetch state before processing
+	// Clear prefetch state before processing, continuing under its request ID
 	e.prefetchedCompletions = nil
 	e.prefetchedCursorTarget = nil
 	e.prefetchState = prefetchNone
+	e.requestID = e.prefetchRequestID
 
 	return e.processCompletion(comp)
 }
@@ -150,7 +182,7 @@ func (e *Engine) tryShowPrefetchedCompletion() bool {
 // handlePrefetchError processes a prefetch error
 func (e *Engine) handlePrefetchError(err error) {
 	if err != nil && !errors.Is(err, context.Canceled) {
-		logger.Error("prefetch error: %v", err)
+		logger.With("engine", e.prefetchRequestID).Error("prefetch error: %v", err)
 	}
 	previousPrefetchState := e.prefetchState
 	e.prefetchState = prefetchNone
@@ -174,17 +206,18 @@ func (e *Engine) handleDeferredCursorTarget() {
 
 		comp := e.prefetchedCompletions[0]
 
-		// Clear prefetch state before processing
+		// Clear prefetch state before processing, continuing under its request ID
 		e.prefetchedCompletions = nil
 		e.prefetchedCursorTarget = nil
 		e.prefetchState = prefetchNone
+		e.requestID = e.prefetchRequestID
 
 		if e.processCompletion(comp) {
 			return
 		}
 
 		// No changes
-		logger.Debug("no changes to completion (deferred prefetched)")
+		e.log().Debug("no changes to completion (deferred prefetched)")
 		e.handleCursorTarget()
 		return
 	}
@@ -213,17 +246,18 @@ func (e *Engine) usePrefetchedCompletion() bool {
 
 	comp := e.prefetchedCompletions[0]
 
-	// Clear prefetch state before processing
+	// Clear prefetch state before processing, continuing under its request ID
 	e.prefetchedCompletions = nil
 	e.prefetchedCursorTarget = nil
 	e.prefetchState = prefetchNone
+	e.requestID = e.prefetchRequestID
 
 	if e.processCompletion(comp) {
 		return true
 	}
 
 	// No changes - handle cursor target
-	logger.Debug("no changes to completion (prefetched)")
+	e.log().Debug("no changes to completion (prefetched)")
 	e.handleCursorTarget()
 	return true
 }
@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"path/filepath"
+	"strings"
+
+	"cursortab/utils"
+)
+
+// PrivacyConfig decides which buffers are handled in privacy mode. In privacy
+// mode the provider is asked not to retain data, metrics are not sent, and
+// buffer and completion content is never logged or written to disk.
+type PrivacyConfig struct {
+	Enabled    bool     // Privacy mode for everything
+	Workspaces []string // Workspace directories (and their subdirectories) in privacy mode
+	Paths      []string // Globs of file paths in privacy mode
+}
+
+// privacyPolicy evaluates PrivacyConfig for the daemon's workspace.
+type privacyPolicy struct {
+	workspace bool // the whole workspace is private
+	paths     []string
+}
+
+func newPrivacyPolicy(config PrivacyConfig, workspacePath string) *privacyPolicy {
+	if !config.Enabled && len(config.Workspaces) == 0 && len(config.Paths) == 0 {
+		return nil
+	}
+	p := &privacyPolicy{workspace: config.Enabled, paths: config.Paths}
+	for _, dir := range config.Workspaces {
+		if withinDir(workspacePath, utils.ExpandHome(dir)) {
+			p.workspace = true
+		}
+	}
+	return p
+}
+
+// Private reports whether a file must be handled in privacy mode.
+func (p *privacyPolicy) Private(filePath string) bool {
+	if p == nil {
+		return false
+	}
+	return p.workspace || (filePath != "" && matchAnyGlob(p.paths, filePath))
+}
+
+// Status returns the policy for the status RPC.
+func (p *privacyPolicy) Status() map[string]any {
+	return map[string]any{
+		"workspace": p.workspace,
+		"paths":     len(p.paths),
+	}
+}
+
+// privateBuffer reports whether the current buffer is in privacy mode and
+// tells the buffer, so it stops logging content too.
+func (e *Engine) privateBuffer() bool {
+	private := e.privacy.Private(e.buffer.Path())
+	e.buffer.SetPrivacyMode(private)
+	return private
+}
+
+// withinDir reports whether path is dir or inside it.
+func withinDir(path, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"context"
+	"cursortab/assert"
+	"cursortab/types"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPrivacyPolicy_DisabledIsNil(t *testing.T) {
+	p := newPrivacyPolicy(PrivacyConfig{}, "/work/app")
+	assert.Nil(t, p, "no privacy configured")
+	assert.False(t, p.Private("main.go"), "nil policy is never private")
+}
+
+func TestPrivacyPolicy_Global(t *testing.T) {
+	p := newPrivacyPolicy(PrivacyConfig{Enabled: true}, "/work/app")
+	assert.True(t, p.Private("main.go"), "every file private")
+	assert.True(t, p.Private(""), "unnamed buffers private")
+}
+
+func TestPrivacyPolicy_Workspaces(t *testing.T) {
+	config := PrivacyConfig{Workspaces: []string{"/work/client"}}
+
+	assert.True(t, newPrivacyPolicy(config, "/work/client").Private("main.go"), "workspace itself")
+	assert.True(t, newPrivacyPolicy(config, "/work/client/api").Private("main.go"), "subdirectory")
+	assert.False(t, newPrivacyPolicy(config, "/work/client-tools").Private("main.go"), "sibling with shared prefix")
+	assert.False(t, newPrivacyPolicy(config, "/work").Private("main.go"), "parent directory")
+}
+
+func TestPrivacyPolicy_HomeWorkspace(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	p := newPrivacyPolicy(PrivacyConfig{Workspaces: []string{"~/client"}}, filepath.Join(home, "client"))
+	assert.True(t, p.Private("main.go"), "~ expanded")
+}
+
+func TestPrivacyPolicy_Paths(t *testing.T) {
+	p := newPrivacyPolicy(PrivacyConfig{Paths: []string{"secrets/**", "*.env"}}, "/work/app")
+
+	assert.True(t, p.Private("secrets/prod/db.yaml"), "directory glob")
+	assert.True(t, p.Private("deploy/prod.env"), "base name glob")
+	assert.False(t, p.Private("src/main.go"), "other file")
+	assert.False(t, p.Private(""), "unnamed buffer")
+}
+
+func TestRequestCompletion_PrivacyMode(t *testing.T) {
+	buf := newMockBuffer()
+	buf.path = "secrets/keys.go"
+	prov := newMockProvider()
+	clock := newMockClock()
+
+	eng, _ := NewEngine(prov, buf, EngineConfig{
+		CompletionTimeout: 5 * time.Second,
+		Privacy:           PrivacyConfig{Paths: []string{"secrets/**"}},
+	}, clock)
+	eng.mainCtx, eng.mainCancel = context.WithCancel(context.Background())
+	defer eng.mainCancel()
+
+	eng.requestCompletion(types.CompletionSourceTyping)
+	<-eng.eventChan
+
+	prov.mu.Lock()
+	assert.True(t, prov.lastRequest.PrivacyMode, "request marked private")
+	prov.mu.Unlock()
+	assert.True(t, buf.privacyMode, "buffer told to stop logging content")
+
+	buf.path = "src/main.go"
+	eng.state = stateIdle
+	eng.requestCompletion(types.CompletionSourceTyping)
+	<-eng.eventChan
+
+	prov.mu.Lock()
+	assert.False(t, prov.lastRequest.PrivacyMode, "other files not private")
+	prov.mu.Unlock()
+	assert.False(t, buf.privacyMode, "buffer logging restored")
+}
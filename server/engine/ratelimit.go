@@ -0,0 +1,328 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"cursortab/logger"
+	"cursortab/types"
+)
+
+// RateLimitConfig controls client-side request limiting for a provider.
+type RateLimitConfig struct {
+	Provider           string  // Key for persisted budget state (e.g. "sweep")
+	RequestsPerSecond  float64 // Token bucket refill rate (0 = unlimited)
+	Burst              int     // Token bucket capacity (defaults to 1 when rate limiting)
+	DailyBudget        int     // Max requests per calendar day (0 = unlimited)
+	MonthlyBudget      int     // Max requests per calendar month (0 = unlimited)
+	LowBudgetThreshold float64 // Remaining fraction at which only idle triggers are sent
+	StatePath          string  // File for persisted budget usage ("" = not persisted)
+}
+
+// budgetSaveInterval is how often budget usage is written while requests
+// are being sent; it is also written when the engine stops.
+const budgetSaveInterval = 30 * time.Second
+
+// budgetUsage is the persisted request count for one provider.
+type budgetUsage struct {
+	Day        string `json:"day"` // 2006-01-02
+	DayCount   int    `json:"day_count"`
+	Month      string `json:"month"` // 2006-01
+	MonthCount int    `json:"month_count"`
+}
+
+// add returns u with the counts of delta added. Counts of different periods
+// are not added: the later period wins, so a process that has not rolled
+// over yet cannot resurrect yesterday's count.
+func (u budgetUsage) add(delta budgetUsage) budgetUsage {
+	u.Day, u.DayCount = addPeriod(u.Day, u.DayCount, delta.Day, delta.DayCount)
+	u.Month, u.MonthCount = addPeriod(u.Month, u.MonthCount, delta.Month, delta.MonthCount)
+	return u
+}
+
+func addPeriod(period string, count int, deltaPeriod string, delta int) (string, int) {
+	switch {
+	case deltaPeriod == period:
+		return period, count + delta
+	case deltaPeriod > period:
+		return deltaPeriod, delta
+	default:
+		return period, count
+	}
+}
+
+// rateLimiter combines a token bucket with daily and monthly request budgets.
+// When a budget runs low it degrades to idle-only triggers; when exhausted
+// no requests are sent until the period rolls over.
+type rateLimiter struct {
+	mu         sync.Mutex
+	config     RateLimitConfig
+	clock      Clock
+	tokens     float64
+	lastRefill time.Time
+	usage      budgetUsage // counts of every process sharing StatePath, as of the last save
+	unsaved    budgetUsage // counts of this process not yet merged into StatePath
+	lastSave   time.Time
+	denied     int64
+}
+
+func newRateLimiter(config RateLimitConfig, clock Clock) *rateLimiter {
+	if config.RequestsPerSecond <= 0 && config.DailyBudget <= 0 && config.MonthlyBudget <= 0 {
+		return nil
+	}
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	l := &rateLimiter{
+		config:     config,
+		clock:      clock,
+		tokens:     float64(config.Burst),
+		lastRefill: clock.Now(),
+	}
+	l.load()
+	return l
+}
+
+// Allow reports whether a request triggered by source may be sent now and
+// records it against the budget if so. The reason is set when denied.
+func (l *rateLimiter) Allow(source types.CompletionSource) (bool, string) {
+	if l == nil {
+		return true, ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.rollover(now)
+
+	if reason := l.exhaustedReason(); reason != "" {
+		l.denied++
+		return false, reason
+	}
+	if source != types.CompletionSourceIdle && l.budgetLow() {
+		l.denied++
+		return false, "budget low, idle-only triggers"
+	}
+
+	if l.config.RequestsPerSecond > 0 {
+		elapsed := now.Sub(l.lastRefill).Seconds()
+		l.tokens = min(float64(l.config.Burst), l.tokens+elapsed*l.config.RequestsPerSecond)
+		l.lastRefill = now
+		if l.tokens < 1 {
+			l.denied++
+			return false, "rate limited"
+		}
+		l.tokens--
+	}
+
+	l.usage.DayCount++
+	l.usage.MonthCount++
+	l.unsaved.DayCount++
+	l.unsaved.MonthCount++
+	if now.Sub(l.lastSave) >= budgetSaveInterval {
+		l.save()
+	}
+	return true, ""
+}
+
+// rollover resets counters when the day or month changes. Caller must hold l.mu.
+func (l *rateLimiter) rollover(now time.Time) {
+	day := now.Format("2006-01-02")
+	month := now.Format("2006-01")
+	if l.unsaved.Day != day || l.unsaved.Month != month {
+		// Save counts under the period they were made in
+		if l.unsaved.DayCount > 0 || l.unsaved.MonthCount > 0 {
+			l.save()
+		}
+		l.unsaved = budgetUsage{Day: day, Month: month}
+	}
+	if l.usage.Day != day {
+		l.usage.Day = day
+		l.usage.DayCount = 0
+	}
+	if l.usage.Month != month {
+		l.usage.Month = month
+		l.usage.MonthCount = 0
+	}
+}
+
+// exhaustedReason returns a non-empty reason when a budget is used up.
+// Caller must hold l.mu.
+func (l *rateLimiter) exhaustedReason() string {
+	if l.config.DailyBudget > 0 && l.usage.DayCount >= l.config.DailyBudget {
+		return "daily budget exhausted"
+	}
+	if l.config.MonthlyBudget > 0 && l.usage.MonthCount >= l.config.MonthlyBudget {
+		return "monthly budget exhausted"
+	}
+	return ""
+}
+
+// budgetLow reports whether either budget has less than LowBudgetThreshold
+// of its allowance remaining. Caller must hold l.mu.
+func (l *rateLimiter) budgetLow() bool {
+	if l.config.LowBudgetThreshold <= 0 {
+		return false
+	}
+	low := func(used, budget int) bool {
+		return budget > 0 && float64(budget-used) < float64(budget)*l.config.LowBudgetThreshold
+	}
+	return low(l.usage.DayCount, l.config.DailyBudget) || low(l.usage.MonthCount, l.config.MonthlyBudget)
+}
+
+// Status returns the limiter state for the status RPC.
+func (l *rateLimiter) Status() map[string]any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rollover(l.clock.Now())
+
+	mode := "normal"
+	if l.exhaustedReason() != "" {
+		mode = "exhausted"
+	} else if l.budgetLow() {
+		mode = "idle_only"
+	}
+
+	return map[string]any{
+		"provider":       l.config.Provider,
+		"mode":           mode,
+		"requests_today": l.usage.DayCount,
+		"daily_budget":   l.config.DailyBudget,
+		"requests_month": l.usage.MonthCount,
+		"monthly_budget": l.config.MonthlyBudget,
+		"denied":         l.denied,
+	}
+}
+
+// budgetFile is the on-disk layout; several providers share one file.
+type budgetFile struct {
+	Providers map[string]budgetUsage `json:"providers"`
+}
+
+// load restores persisted usage for the configured provider. Caller must not hold l.mu.
+func (l *rateLimiter) load() {
+	if l.config.StatePath == "" {
+		return
+	}
+	file, err := readBudgetFile(l.config.StatePath)
+	if err != nil {
+		logger.Warn("rate limit: could not read budget state: %v", err)
+		return
+	}
+	l.usage = file.Providers[l.config.Provider]
+}
+
+// save merges the counts made since the last save into the state file and
+// picks up those of other processes sharing it. Caller must hold l.mu.
+func (l *rateLimiter) save() {
+	l.lastSave = l.clock.Now()
+	if l.config.StatePath == "" {
+		return
+	}
+	unlock, err := lockStateFile(l.config.StatePath)
+	if err != nil {
+		logger.Warn("rate limit: not saving budget state: %v", err)
+		return
+	}
+	defer unlock()
+
+	file, err := readBudgetFile(l.config.StatePath)
+	if err != nil {
+		// Keep the unsaved counts rather than overwrite a file we cannot read
+		logger.Warn("rate limit: not saving budget state: %v", err)
+		return
+	}
+	if file.Providers == nil {
+		file.Providers = make(map[string]budgetUsage)
+	}
+	merged := file.Providers[l.config.Provider].add(l.unsaved)
+	file.Providers[l.config.Provider] = merged
+
+	if err := writeBudgetFile(l.config.StatePath, file); err != nil {
+		logger.Warn("rate limit: could not persist budget state: %v", err)
+		return
+	}
+	l.unsaved.DayCount, l.unsaved.MonthCount = 0, 0
+	if merged.Day == l.usage.Day {
+		l.usage.DayCount = merged.DayCount
+	}
+	if merged.Month == l.usage.Month {
+		l.usage.MonthCount = merged.MonthCount
+	}
+}
+
+// Close writes unsaved usage. Called when the engine stops.
+func (l *rateLimiter) Close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.unsaved.DayCount > 0 || l.unsaved.MonthCount > 0 {
+		l.save()
+	}
+}
+
+func readBudgetFile(path string) (*budgetFile, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &budgetFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file budgetFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid budget file %s: %w", path, err)
+	}
+	return &file, nil
+}
+
+// writeBudgetFile writes atomically so a crash never leaves a truncated file.
+func writeBudgetFile(path string, file *budgetFile) error {
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the
+// same directory.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// allowRequest checks the circuit breaker and rate limiter and logs the
+// reason when denied.
+func (e *Engine) allowRequest(source types.CompletionSource) bool {
+	if !e.breaker.Allow() {
+		logger.Debug("request skipped: circuit breaker %s", e.breaker.State())
+		providerRequests.Inc(e.providerName(), "skipped")
+		return false
+	}
+	ok, reason := e.limiter.Allow(source)
+	if !ok {
+		e.breaker.Release()
+		logger.Debug("request skipped: %s", reason)
+		providerRequests.Inc(e.providerName(), "skipped")
+	}
+	return ok
+}
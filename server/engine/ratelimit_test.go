@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"context"
+	"cursortab/assert"
+	"cursortab/types"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_DisabledAllowsEverything(t *testing.T) {
+	l := newRateLimiter(RateLimitConfig{}, newMockClock())
+	assert.Nil(t, l, "no limits configured")
+
+	ok, _ := l.Allow(types.CompletionSourceTyping)
+	assert.True(t, ok, "nil limiter allows")
+}
+
+func TestRateLimiter_TokenBucket(t *testing.T) {
+	clock := newMockClock()
+	l := newRateLimiter(RateLimitConfig{RequestsPerSecond: 2, Burst: 2}, clock)
+
+	ok, _ := l.Allow(types.CompletionSourceTyping)
+	assert.True(t, ok, "first burst request")
+	ok, _ = l.Allow(types.CompletionSourceTyping)
+	assert.True(t, ok, "second burst request")
+	ok, reason := l.Allow(types.CompletionSourceTyping)
+	assert.False(t, ok, "bucket empty")
+	assert.Equal(t, "rate limited", reason, "reason")
+
+	clock.Advance(500 * time.Millisecond)
+	ok, _ = l.Allow(types.CompletionSourceTyping)
+	assert.True(t, ok, "one token refilled after 500ms at 2/s")
+	ok, _ = l.Allow(types.CompletionSourceTyping)
+	assert.False(t, ok, "only one token refilled")
+}
+
+func TestRateLimiter_DegradesToIdleOnly(t *testing.T) {
+	l := newRateLimiter(RateLimitConfig{DailyBudget: 10, LowBudgetThreshold: 0.3}, newMockClock())
+
+	for range 8 {
+		ok, _ := l.Allow(types.CompletionSourceTyping)
+		assert.True(t, ok, "typing request within budget")
+	}
+
+	ok, reason := l.Allow(types.CompletionSourceTyping)
+	assert.False(t, ok, "typing denied when budget low")
+	assert.Equal(t, "budget low, idle-only triggers", reason, "reason")
+
+	ok, _ = l.Allow(types.CompletionSourceIdle)
+	assert.True(t, ok, "idle still allowed when budget low")
+	assert.Equal(t, "idle_only", l.Status()["mode"], "status mode")
+}
+
+func TestRateLimiter_BudgetExhaustedAndRollsOver(t *testing.T) {
+	clock := newMockClock()
+	clock.now = time.Date(2026, 3, 31, 23, 0, 0, 0, time.Local)
+	l := newRateLimiter(RateLimitConfig{DailyBudget: 2}, clock)
+
+	l.Allow(types.CompletionSourceIdle)
+	l.Allow(types.CompletionSourceIdle)
+	ok, reason := l.Allow(types.CompletionSourceIdle)
+	assert.False(t, ok, "daily budget exhausted")
+	assert.Equal(t, "daily budget exhausted", reason, "reason")
+	assert.Equal(t, "exhausted", l.Status()["mode"], "status mode")
+
+	clock.Advance(2 * time.Hour)
+	ok, _ = l.Allow(types.CompletionSourceIdle)
+	assert.True(t, ok, "budget resets on the next day")
+	assert.Equal(t, 1, l.Status()["requests_today"], "day count reset")
+	assert.Equal(t, 1, l.Status()["requests_month"], "month count reset on new month")
+}
+
+func TestRateLimiter_MonthlyBudget(t *testing.T) {
+	clock := newMockClock()
+	l := newRateLimiter(RateLimitConfig{MonthlyBudget: 1}, clock)
+
+	ok, _ := l.Allow(types.CompletionSourceIdle)
+	assert.True(t, ok, "first request")
+	ok, reason := l.Allow(types.CompletionSourceIdle)
+	assert.False(t, ok, "monthly budget exhausted")
+	assert.Equal(t, "monthly budget exhausted", reason, "reason")
+}
+
+func TestRateLimiter_PersistsAcrossRestarts(t *testing.T) {
+	clock := newMockClock()
+	path := filepath.Join(t.TempDir(), "budget.json")
+	config := RateLimitConfig{Provider: "sweep", DailyBudget: 3, StatePath: path}
+
+	first := newRateLimiter(config, clock)
+	first.Allow(types.CompletionSourceIdle)
+	first.Allow(types.CompletionSourceIdle)
+
+	first.Close()
+
+	other := newRateLimiter(RateLimitConfig{Provider: "other", DailyBudget: 3, StatePath: path}, clock)
+	other.Allow(types.CompletionSourceIdle)
+	other.Close()
+
+	restarted := newRateLimiter(config, clock)
+	assert.Equal(t, 2, restarted.Status()["requests_today"], "usage restored for provider")
+
+	ok, _ := restarted.Allow(types.CompletionSourceIdle)
+	assert.True(t, ok, "third request within budget")
+	ok, _ = restarted.Allow(types.CompletionSourceIdle)
+	assert.False(t, ok, "budget shared with previous run")
+
+	otherRestarted := newRateLimiter(RateLimitConfig{Provider: "other", DailyBudget: 3, StatePath: path}, clock)
+	assert.Equal(t, 1, otherRestarted.Status()["requests_today"], "providers tracked separately")
+}
+
+func TestRateLimiter_MergesConcurrentProcesses(t *testing.T) {
+	clock := newMockClock()
+	path := filepath.Join(t.TempDir(), "budget.json")
+	config := RateLimitConfig{Provider: "sweep", DailyBudget: 100, StatePath: path}
+
+	// The daemon and an LSP process sharing the budget file
+	daemon := newRateLimiter(config, clock)
+	lsp := newRateLimiter(config, clock)
+	for range 3 {
+		daemon.Allow(types.CompletionSourceIdle)
+		lsp.Allow(types.CompletionSourceIdle)
+	}
+	file, err := readBudgetFile(path)
+	assert.NoError(t, err, "read budget")
+	assert.Equal(t, 2, file.Providers["sweep"].DayCount, "only the first request of each saved before the interval")
+
+	clock.Advance(budgetSaveInterval)
+	daemon.Allow(types.CompletionSourceIdle)
+	assert.Equal(t, 5, daemon.Status()["requests_today"], "picks up what the other process saved")
+	lsp.Close()
+	daemon.Close()
+
+	file, err = readBudgetFile(path)
+	assert.NoError(t, err, "read budget")
+	assert.Equal(t, 7, file.Providers["sweep"].DayCount, "no counts lost")
+	assert.Equal(t, 7, file.Providers["sweep"].MonthCount, "month merged too")
+}
+
+func TestRateLimiter_ConcurrentSavesLoseNoCounts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.json")
+	config := RateLimitConfig{Provider: "sweep", DailyBudget: 10000, StatePath: path}
+
+	// Two processes saving at the same moment, each save after one request
+	limiters := []*rateLimiter{newRateLimiter(config, newMockClock()), newRateLimiter(config, newMockClock())}
+	const requests = 50
+	var wg sync.WaitGroup
+	for _, l := range limiters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range requests {
+				l.Allow(types.CompletionSourceIdle)
+				l.mu.Lock()
+				l.save()
+				l.mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	file, err := readBudgetFile(path)
+	assert.NoError(t, err, "read budget")
+	assert.Equal(t, 2*requests, file.Providers["sweep"].DayCount, "no counts lost")
+	_, err = os.Stat(path + ".lock")
+	assert.True(t, os.IsNotExist(err), "lock released")
+}
+
+func TestLockStateFile_RemovesStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.json")
+	assert.NoError(t, os.WriteFile(path+".lock", nil, 0o644), "seed lock")
+	old := time.Now().Add(-2 * lockStaleAfter)
+	assert.NoError(t, os.Chtimes(path+".lock", old, old), "age lock")
+
+	unlock, err := lockStateFile(path)
+	assert.NoError(t, err, "lock left by a crashed process is taken over")
+	unlock()
+}
+
+func TestRateLimiter_UnreadableStateNotOverwritten(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.json")
+	assert.NoError(t, os.WriteFile(path, []byte("{corrupt"), 0o644), "seed")
+
+	l := newRateLimiter(RateLimitConfig{Provider: "sweep", DailyBudget: 3, StatePath: path}, newMockClock())
+	l.Allow(types.CompletionSourceIdle)
+	l.Close()
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err, "read budget")
+	assert.Equal(t, "{corrupt", string(data), "left for the user to inspect")
+}
+
+func TestBudgetUsage_AddKeepsLaterPeriod(t *testing.T) {
+	stored := budgetUsage{Day: "2026-04-01", DayCount: 5, Month: "2026-04", MonthCount: 9}
+	stale := budgetUsage{Day: "2026-03-31", DayCount: 2, Month: "2026-03", MonthCount: 2}
+	assert.Equal(t, stored, stored.add(stale), "older counts dropped")
+
+	fresh := budgetUsage{Day: "2026-04-02", DayCount: 1, Month: "2026-04", MonthCount: 1}
+	assert.Equal(t, budgetUsage{Day: "2026-04-02", DayCount: 1, Month: "2026-04", MonthCount: 10}, stored.add(fresh), "new day, same month")
+}
+
+func TestRequestCompletion_SkippedWhenRateLimited(t *testing.T) {
+	buf := newMockBuffer()
+	prov := newMockProvider()
+	clock := newMockClock()
+
+	eng, _ := NewEngine(prov, buf, EngineConfig{
+		CompletionTimeout: 5 * time.Second,
+		RateLimit:         RateLimitConfig{DailyBudget: 1},
+	}, clock)
+	eng.mainCtx, eng.mainCancel = context.WithCancel(context.Background())
+	defer eng.mainCancel()
+
+	eng.limiter.Allow(types.CompletionSourceIdle) // use up the budget
+
+	eng.requestCompletion(types.CompletionSourceTyping)
+
+	assert.Equal(t, stateIdle, eng.state, "stays idle when denied")
+	assert.True(t, eng.currentCancel == nil, "no request started")
+	assert.Equal(t, int64(1), eng.limiter.Status()["denied"], "denial counted")
+}
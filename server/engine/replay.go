@@ -0,0 +1,527 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"cursortab/buffer"
+	"cursortab/text"
+	"cursortab/tokenizer"
+	"cursortab/types"
+)
+
+// replayCallTimeout bounds the wait for a replayed provider call, which
+// returns its recorded result immediately.
+const replayCallTimeout = 5 * time.Second
+
+// ReplayResult describes a replayed trace.
+type ReplayResult struct {
+	Events     int         // events fed to the engine
+	UICalls    int         // UI calls that matched the trace
+	Divergence *Divergence // first difference from the trace, nil if none
+}
+
+// Divergence is the first point where a replay did something other than
+// what the trace recorded.
+type Divergence struct {
+	Event     int    // 1-indexed event during which it happened
+	EventType string // type of that event
+	Expected  string // what the trace recorded ("" = nothing)
+	Actual    string // what the replay did ("" = nothing)
+}
+
+func (d *Divergence) String() string {
+	expected, actual := d.Expected, d.Actual
+	if expected == "" {
+		expected = "nothing"
+	}
+	if actual == "" {
+		actual = "nothing"
+	}
+	return fmt.Sprintf("event %d (%s): expected %s, got %s", d.Event, d.EventType, expected, actual)
+}
+
+// Replay feeds a recorded trace into a new engine, one event at a time,
+// with a fake clock and a buffer and provider that play back the recorded
+// state and responses. It stops at the first UI call, buffer access or
+// provider request that differs from the trace. counter replaces the
+// tokenizer of the recorded session (nil = heuristic).
+//
+// Traces of privacy mode buffers cannot be replayed: their content was not
+// recorded.
+func Replay(r io.Reader, counter tokenizer.Counter) (*ReplayResult, error) {
+	header, records, err := readTrace(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		if rec.Private {
+			return nil, errors.New("trace: privacy mode content was not recorded, the trace cannot be replayed")
+		}
+	}
+
+	clock := &replayClock{now: header.Start}
+	buf := &replayBuffer{}
+	prov := &replayProvider{calls: make(map[int]*traceRecord)}
+	for _, rec := range records {
+		switch rec.Kind {
+		case traceSync, traceContext, traceCommit, traceUserEdits, traceHasChanges, traceLinter:
+			buf.records = append(buf.records, rec)
+		case traceProvider:
+			prov.calls[rec.Call] = rec
+		}
+	}
+
+	// Replay must not touch local state: budgets and ignore files. Which
+	// files were ignored comes from the trace
+	config := *header.Config
+	config.TracePath = ""
+	config.RateLimit.StatePath = ""
+	config.Stats.StatePath = ""
+	config.IgnoreFiles = false
+	config.Tokenizer = counter
+
+	e, err := NewEngine(prov, buf, config, clock)
+	if err != nil {
+		return nil, err
+	}
+	e.WorkspacePath = header.Workspace
+	if header.Config.IgnoreFiles {
+		e.ignores = buf
+	}
+	e.privacy = newPrivacyPolicy(config.Privacy, header.Workspace)
+	e.mainCtx, e.mainCancel = context.WithCancel(context.Background())
+	defer e.Stop()
+
+	result := &ReplayResult{}
+	var expectedUI []string
+	var event *traceRecord
+
+	// check compares the UI calls of the current event and the first buffer
+	// or provider divergence against the trace.
+	check := func() *Divergence {
+		d := buf.divergence()
+		if d == nil {
+			d = prov.divergence()
+		}
+		if d == nil {
+			actual := buf.takeUI()
+			for i := 0; i < max(len(expectedUI), len(actual)) && d == nil; i++ {
+				var want, got string
+				if i < len(expectedUI) {
+					want = expectedUI[i]
+				}
+				if i < len(actual) {
+					got = actual[i]
+				}
+				if want != got {
+					d = &Divergence{Expected: want, Actual: got}
+				} else {
+					result.UICalls++
+				}
+			}
+		}
+		expectedUI = nil
+		if d != nil && event != nil {
+			d.Event = result.Events
+			d.EventType = event.Event
+		}
+		return d
+	}
+
+	for _, rec := range records {
+		switch rec.Kind {
+		case traceUI:
+			expectedUI = append(expectedUI, rec.UI)
+			continue
+		case traceEvent, traceTimer, traceResult:
+		default:
+			continue
+		}
+
+		if d := check(); d != nil {
+			result.Divergence = d
+			return result, nil
+		}
+		clock.set(header.Start.Add(time.Duration(rec.Time)))
+		event = rec
+		result.Events++
+
+		ev, err := replayEvent(rec, prov)
+		if err != nil {
+			return nil, fmt.Errorf("trace: event %d (%s): %w", result.Events, rec.Event, err)
+		}
+		e.handleEvent(*ev)
+
+		// Results of provider calls started by this event are fed from the
+		// trace when the recorded session handled them; drop the live ones
+		if err := awaitRequests(e); err != nil {
+			return nil, fmt.Errorf("trace: event %d (%s): %w", result.Events, rec.Event, err)
+		}
+	}
+	result.Divergence = check()
+	return result, nil
+}
+
+// awaitRequests waits for the engine's provider calls to finish and drops
+// the events they sent.
+func awaitRequests(e *Engine) error {
+	done := make(chan struct{})
+	go func() {
+		e.requests.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(replayCallTimeout):
+		return errors.New("provider call did not finish")
+	}
+	for {
+		select {
+		case <-e.eventChan:
+		default:
+			return nil
+		}
+	}
+}
+
+// readTrace parses a trace into its header and records.
+func readTrace(r io.Reader) (*traceRecord, []*traceRecord, error) {
+	var header *traceRecord
+	var records []*traceRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		rec := &traceRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			return nil, nil, fmt.Errorf("trace: line %d: %w", lineNum, err)
+		}
+		if header == nil {
+			if rec.Kind != traceHeader || rec.Config == nil {
+				return nil, nil, errors.New("trace: missing header")
+			}
+			if rec.Version != traceVersion {
+				return nil, nil, fmt.Errorf("trace: unsupported version %d", rec.Version)
+			}
+			header = rec
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("trace: %w", err)
+	}
+	if header == nil {
+		return nil, nil, errors.New("trace: empty")
+	}
+	return header, records, nil
+}
+
+// replayEvent rebuilds a recorded event. Provider results carry the
+// response of the recorded call that produced them.
+func replayEvent(rec *traceRecord, prov *replayProvider) (*Event, error) {
+	eventType := EventTypeFromString(rec.Event)
+	if eventType == "" {
+		return nil, fmt.Errorf("unknown event type %q", rec.Event)
+	}
+	switch eventType {
+	case EventCompletionReady, EventPrefetchReady:
+		call := prov.calls[rec.Call]
+		if call == nil {
+			return nil, fmt.Errorf("no recorded provider call %d", rec.Call)
+		}
+		return &Event{Type: eventType, Data: call.Response}, nil
+	case EventCompletionError, EventPrefetchError:
+		return &Event{Type: eventType, Data: replayError(rec.Error)}, nil
+	case EventBreakerChanged:
+		if rec.Breaker == nil {
+			return nil, errors.New("breaker change without states")
+		}
+		return &Event{Type: eventType, Data: *rec.Breaker}, nil
+	}
+	return &Event{Type: eventType}, nil
+}
+
+// replayError rebuilds a recorded error, keeping context cancellation and
+// deadlines recognizable to errors.Is.
+func replayError(message string) error {
+	for _, target := range []error{context.Canceled, context.DeadlineExceeded} {
+		if prefix, ok := strings.CutSuffix(message, target.Error()); ok {
+			return fmt.Errorf("%s%w", prefix, target)
+		}
+	}
+	return errors.New(message)
+}
+
+// replayClock is a Clock whose time is set from the trace. Its timers never
+// fire on their own; the trace says when they did.
+type replayClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *replayClock) AfterFunc(d time.Duration, f func()) Timer {
+	return replayTimer{}
+}
+
+func (c *replayClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *replayClock) set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+type replayTimer struct{}
+
+func (replayTimer) Stop() bool { return true }
+
+// replayProvider answers requests with the recorded responses, in order.
+type replayProvider struct {
+	mu    sync.Mutex
+	calls map[int]*traceRecord
+	next  int
+	diff  *Divergence
+}
+
+func (p *replayProvider) GetCompletion(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.next++
+	rec := p.calls[p.next]
+	actual := fmt.Sprintf("provider request %+v", summarizeRequest(req))
+	if rec == nil || rec.Request == nil {
+		p.diverge("", actual)
+		return nil, errors.New("replay: no recorded provider call")
+	}
+	if expected := fmt.Sprintf("provider request %+v", *rec.Request); expected != actual {
+		p.diverge(expected, actual)
+	}
+	if rec.Error != "" {
+		return nil, replayError(rec.Error)
+	}
+	return rec.Response, nil
+}
+
+func (p *replayProvider) diverge(expected, actual string) {
+	if p.diff == nil {
+		p.diff = &Divergence{Expected: expected, Actual: actual}
+	}
+}
+
+func (p *replayProvider) divergence() *Divergence {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.diff
+}
+
+// replayBuffer plays back recorded buffer states and results in the order
+// the engine asked for them, and collects the UI calls the engine makes.
+type replayBuffer struct {
+	mu      sync.Mutex
+	records []*traceRecord
+	next    int
+	diff    *Divergence
+	ui      []string
+
+	lines, previous, original []string
+	diffs                     []*types.DiffEntry
+	path, filetype            string
+	row, col, version         int
+	viewTop, viewBottom       int
+	ignored                   map[string]bool // recorded .cursortabignore decision per path
+}
+
+// take returns the next recorded buffer access, which must be of kind.
+func (b *replayBuffer) take(kind string) *traceRecord {
+	if b.diff != nil {
+		return nil
+	}
+	if b.next >= len(b.records) {
+		b.diff = &Divergence{Actual: "buffer " + kind}
+		return nil
+	}
+	rec := b.records[b.next]
+	if rec.Kind != kind {
+		b.diff = &Divergence{Expected: "buffer " + rec.Kind, Actual: "buffer " + kind}
+		return nil
+	}
+	b.next++
+	return rec
+}
+
+// apply sets the buffer to a recorded state.
+func (b *replayBuffer) apply(rec *traceRecord) {
+	if rec == nil || rec.State == nil {
+		return
+	}
+	s := rec.State
+	b.path, b.filetype = s.Path, s.Filetype
+	b.row, b.col, b.version = s.Row, s.Col, s.Version
+	b.viewTop, b.viewBottom = s.ViewTop, s.ViewBottom
+	if b.ignored == nil {
+		b.ignored = make(map[string]bool)
+	}
+	b.ignored[s.Path] = rec.Ignored
+	if rec.Ignored {
+		// Content of ignored files was left out
+		b.lines, b.previous, b.original, b.diffs = nil, nil, nil, nil
+		return
+	}
+	if s.Lines != nil {
+		b.lines = *s.Lines
+	}
+	if s.Previous != nil {
+		b.previous = *s.Previous
+	}
+	if s.Original != nil {
+		b.original = *s.Original
+	}
+	if s.Diffs != nil {
+		b.diffs = *s.Diffs
+	}
+}
+
+// Ignored replays the .cursortabignore decision recorded with the last
+// state of filePath.
+func (b *replayBuffer) Ignored(filePath string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.ignored[filePath]
+}
+
+func (b *replayBuffer) divergence() *Divergence {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.diff
+}
+
+// takeUI returns and clears the UI calls made so far.
+func (b *replayBuffer) takeUI() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ui := b.ui
+	b.ui = nil
+	return ui
+}
+
+func (b *replayBuffer) recordUI(call string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ui = append(b.ui, call)
+}
+
+func (b *replayBuffer) Sync(workspacePath string) (*buffer.SyncResult, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rec := b.take(traceSync)
+	if rec == nil {
+		return nil, errors.New("replay: diverged from trace")
+	}
+	if rec.Error != "" {
+		return nil, errors.New(rec.Error)
+	}
+	b.apply(rec)
+	return &buffer.SyncResult{BufferChanged: rec.State.Changed, OldPath: rec.State.OldPath, NewPath: b.path}, nil
+}
+
+func (b *replayBuffer) Lines() []string                   { return b.lines }
+func (b *replayBuffer) Row() int                          { return b.row }
+func (b *replayBuffer) Col() int                          { return b.col }
+func (b *replayBuffer) Path() string                      { return b.path }
+func (b *replayBuffer) Filetype() string                  { return b.filetype }
+func (b *replayBuffer) Version() int                      { return b.version }
+func (b *replayBuffer) PreviousLines() []string           { return b.previous }
+func (b *replayBuffer) OriginalLines() []string           { return b.original }
+func (b *replayBuffer) DiffHistories() []*types.DiffEntry { return b.diffs }
+
+func (b *replayBuffer) ViewportBounds() (top, bottom int) {
+	return b.viewTop, b.viewBottom
+}
+
+func (b *replayBuffer) SetFileContext(prev, orig []string, diffs []*types.DiffEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.apply(b.take(traceContext))
+}
+
+func (b *replayBuffer) HasChanges(startLine, endLineInc int, lines []string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rec := b.take(traceHasChanges)
+	return rec != nil && rec.OK
+}
+
+func (b *replayBuffer) PrepareCompletion(startLine, endLineInc int, lines []string, groups []*text.Group) buffer.Batch {
+	b.recordUI(prepareCompletionCall(startLine, endLineInc, lines, b.Ignored(b.path)))
+	return replayBatch{buf: b}
+}
+
+func (b *replayBuffer) CommitPending() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.apply(b.take(traceCommit))
+}
+
+func (b *replayBuffer) CommitUserEdits() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rec := b.take(traceUserEdits)
+	b.apply(rec)
+	return rec != nil && rec.OK
+}
+
+func (b *replayBuffer) ShowCursorTarget(line int) error {
+	b.recordUI(showCursorTargetCall(line))
+	return nil
+}
+
+func (b *replayBuffer) ClearUI() error {
+	b.recordUI(clearUICall)
+	return nil
+}
+
+func (b *replayBuffer) MoveCursor(line int, center, mark bool) error {
+	b.recordUI(moveCursorCall(line, center, mark))
+	return nil
+}
+
+func (b *replayBuffer) LinterErrors() *types.LinterErrors {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if rec := b.take(traceLinter); rec != nil {
+		return rec.Linter
+	}
+	return nil
+}
+
+func (b *replayBuffer) RegisterEventHandler(handler func(event string)) error {
+	return nil
+}
+
+func (b *replayBuffer) Notify(message string, level int) error {
+	b.recordUI(notifyCall(message, level))
+	return nil
+}
+
+func (b *replayBuffer) SetPrivacyMode(enabled bool) {}
+
+type replayBatch struct {
+	buf *replayBuffer
+}
+
+func (b replayBatch) Execute() error {
+	b.buf.recordUI(applyCall)
+	return nil
+}
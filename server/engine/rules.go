@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+
+	"cursortab/ignore"
+	"cursortab/logger"
+)
+
+// RulesConfig decides which buffers may be sent to the provider.
+// Zero values disable the corresponding check.
+type RulesConfig struct {
+	Include          []string // Globs a path must match (empty = all paths)
+	Exclude          []string // Globs that disable completions
+	Filetypes        []string // Filetypes to complete (empty = all filetypes)
+	ExcludeFiletypes []string // Filetypes never completed
+	MaxFileSize      int      // Max buffer size in bytes
+	MaxLineLength    int      // Max length in bytes of any line (catches minified files)
+	SkipUnnamed      bool     // Skip buffers without a file path
+}
+
+// completionRules evaluates RulesConfig against the current buffer.
+type completionRules struct {
+	config RulesConfig
+}
+
+func newCompletionRules(config RulesConfig) *completionRules {
+	if len(config.Include) == 0 && len(config.Exclude) == 0 &&
+		len(config.Filetypes) == 0 && len(config.ExcludeFiletypes) == 0 &&
+		config.MaxFileSize <= 0 && config.MaxLineLength <= 0 && !config.SkipUnnamed {
+		return nil
+	}
+	return &completionRules{config: config}
+}
+
+// Check reports whether a buffer may be completed. The reason is set when
+// it may not.
+func (r *completionRules) Check(filePath, filetype string, lines []string) (bool, string) {
+	if r == nil {
+		return true, ""
+	}
+	c := r.config
+
+	if filePath == "" {
+		if c.SkipUnnamed {
+			return false, "buffer has no file path"
+		}
+	} else {
+		if len(c.Include) > 0 && !matchAnyGlob(c.Include, filePath) {
+			return false, fmt.Sprintf("%s not matched by include rules", filePath)
+		}
+		if pattern, ok := firstMatchingGlob(c.Exclude, filePath); ok {
+			return false, fmt.Sprintf("%s excluded by %q", filePath, pattern)
+		}
+	}
+
+	if len(c.Filetypes) > 0 && !slices.Contains(c.Filetypes, filetype) {
+		return false, fmt.Sprintf("filetype %q not enabled", filetype)
+	}
+	if slices.Contains(c.ExcludeFiletypes, filetype) {
+		return false, fmt.Sprintf("filetype %q excluded", filetype)
+	}
+
+	if c.MaxFileSize > 0 || c.MaxLineLength > 0 {
+		size := 0
+		for i, line := range lines {
+			if c.MaxLineLength > 0 && len(line) > c.MaxLineLength {
+				return false, fmt.Sprintf("line %d is %d bytes (max %d)", i+1, len(line), c.MaxLineLength)
+			}
+			size += len(line) + 1
+		}
+		if c.MaxFileSize > 0 && size > c.MaxFileSize {
+			return false, fmt.Sprintf("buffer is %d bytes (max %d)", size, c.MaxFileSize)
+		}
+	}
+
+	return true, ""
+}
+
+// ignoreMatcher decides which files .cursortabignore files keep from the
+// provider. Replay answers from the decisions recorded in the trace.
+type ignoreMatcher interface {
+	Ignored(filePath string) bool
+}
+
+// ignored reports whether filePath is matched by a .cursortabignore file.
+func (e *Engine) ignored(filePath string) bool {
+	return e.ignores != nil && e.ignores.Ignored(filePath)
+}
+
+// bufferAllowed checks .cursortabignore files and the completion rules for
+// the current buffer and logs the reason when completions are disabled for it.
+func (e *Engine) bufferAllowed() bool {
+	ok, reason := checkFile(e.ignores, e.rules, e.buffer.Path(), e.buffer.Filetype(), e.buffer.Lines())
+	if !ok {
+		logger.Debug("completion disabled for buffer: %s", reason)
+	}
+	return ok
+}
+
+// checkFile reports whether a file may be sent to the provider: it is not
+// ignored and passes the completion rules. The reason is set when it may not.
+func checkFile(ignores ignoreMatcher, rules *completionRules, filePath, filetype string, lines []string) (bool, string) {
+	if ignores != nil && ignores.Ignored(filePath) {
+		return false, fmt.Sprintf("%s matched by %s", filePath, ignore.FileName)
+	}
+	return rules.Check(filePath, filetype, lines)
+}
+
+// FilePolicy applies the .cursortabignore, completion rule and privacy
+// settings of an EngineConfig to files completed without an engine, such
+// as by the complete command.
+type FilePolicy struct {
+	ignores ignoreMatcher
+	rules   *completionRules
+	privacy *privacyPolicy
+}
+
+// NewFilePolicy returns the policy of config for files in workspacePath.
+func NewFilePolicy(config EngineConfig, workspacePath string) *FilePolicy {
+	p := &FilePolicy{
+		rules:   newCompletionRules(config.Rules),
+		privacy: newPrivacyPolicy(config.Privacy, workspacePath),
+	}
+	if config.IgnoreFiles {
+		p.ignores = ignore.New(workspacePath, config.GlobalIgnoreFile)
+	}
+	return p
+}
+
+// Allowed reports whether a file may be sent to the provider, with the
+// reason when it may not. filePath is relative to the workspace or absolute.
+func (p *FilePolicy) Allowed(filePath, filetype string, lines []string) (bool, string) {
+	return checkFile(p.ignores, p.rules, filePath, filetype, lines)
+}
+
+// Private reports whether a file must be completed in privacy mode.
+func (p *FilePolicy) Private(filePath string) bool {
+	return p.privacy.Private(filePath)
+}
+
+func matchAnyGlob(patterns []string, filePath string) bool {
+	_, ok := firstMatchingGlob(patterns, filePath)
+	return ok
+}
+
+func firstMatchingGlob(patterns []string, filePath string) (string, bool) {
+	for _, pattern := range patterns {
+		if ignore.MatchGlob(pattern, filePath) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"context"
+	"cursortab/assert"
+	"cursortab/ignore"
+	"cursortab/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompletionRules_DisabledIsNil(t *testing.T) {
+	r := newCompletionRules(RulesConfig{})
+	assert.Nil(t, r, "no rules configured")
+
+	ok, _ := r.Check("", "", nil)
+	assert.True(t, ok, "nil rules allow everything")
+}
+
+func TestCompletionRules_Paths(t *testing.T) {
+	r := newCompletionRules(RulesConfig{
+		Include: []string{"src/**"},
+		Exclude: []string{"*.min.js"},
+	})
+
+	ok, _ := r.Check("src/app.js", "javascript", nil)
+	assert.True(t, ok, "included path")
+
+	ok, reason := r.Check("docs/readme.md", "markdown", nil)
+	assert.False(t, ok, "path outside include")
+	assert.Contains(t, reason, "include", "reason mentions include")
+
+	ok, reason = r.Check("src/vendor.min.js", "javascript", nil)
+	assert.False(t, ok, "excluded path")
+	assert.Contains(t, reason, "*.min.js", "reason names the pattern")
+}
+
+func TestCompletionRules_Filetypes(t *testing.T) {
+	allowList := newCompletionRules(RulesConfig{Filetypes: []string{"go", "lua"}})
+	ok, _ := allowList.Check("main.go", "go", nil)
+	assert.True(t, ok, "listed filetype")
+	ok, _ = allowList.Check("notes.txt", "text", nil)
+	assert.False(t, ok, "unlisted filetype")
+
+	denyList := newCompletionRules(RulesConfig{ExcludeFiletypes: []string{"markdown"}})
+	ok, _ = denyList.Check("README.md", "markdown", nil)
+	assert.False(t, ok, "excluded filetype")
+	ok, _ = denyList.Check("main.go", "go", nil)
+	assert.True(t, ok, "other filetype")
+}
+
+func TestCompletionRules_Size(t *testing.T) {
+	r := newCompletionRules(RulesConfig{MaxFileSize: 20, MaxLineLength: 10})
+
+	ok, _ := r.Check("a.go", "go", []string{"short", "lines"})
+	assert.True(t, ok, "small buffer")
+
+	ok, reason := r.Check("a.go", "go", []string{"ok", strings.Repeat("x", 11)})
+	assert.False(t, ok, "long line")
+	assert.Contains(t, reason, "line 2", "reason names the line")
+
+	ok, reason = r.Check("a.go", "go", []string{"123456789", "123456789", "123"})
+	assert.False(t, ok, "buffer too large")
+	assert.Contains(t, reason, "bytes", "reason mentions size")
+}
+
+func TestCompletionRules_Unnamed(t *testing.T) {
+	r := newCompletionRules(RulesConfig{SkipUnnamed: true})
+	ok, _ := r.Check("", "", []string{"scratch"})
+	assert.False(t, ok, "unnamed buffer skipped")
+
+	r = newCompletionRules(RulesConfig{Include: []string{"src/**"}})
+	ok, _ = r.Check("", "", []string{"scratch"})
+	assert.True(t, ok, "path rules do not apply to unnamed buffers")
+}
+
+func TestRequestCompletion_SkippedByRules(t *testing.T) {
+	buf := newMockBuffer()
+	buf.path = "package-lock.json"
+	prov := newMockProvider()
+	clock := newMockClock()
+
+	eng, _ := NewEngine(prov, buf, EngineConfig{
+		CompletionTimeout: 5 * time.Second,
+		Rules:             RulesConfig{Exclude: []string{"package-lock.json"}},
+	}, clock)
+	eng.mainCtx, eng.mainCancel = context.WithCancel(context.Background())
+	defer eng.mainCancel()
+
+	eng.requestCompletion(types.CompletionSourceTyping)
+	eng.requestPrefetch(types.CompletionSourceTyping, 1, 0)
+
+	assert.Equal(t, stateIdle, eng.state, "stays idle")
+	assert.Equal(t, prefetchNone, eng.prefetchState, "no prefetch started")
+	assert.Equal(t, 0, prov.completionCalls, "provider not called")
+}
+
+func TestRequestCompletion_SkippedForIgnoredFile(t *testing.T) {
+	buf := newMockBuffer()
+	buf.path = ".env"
+	buf.diffHistories = []*types.DiffEntry{{Original: "KEY=old", Updated: "KEY=secret"}}
+	prov := newMockProvider()
+	clock := newMockClock()
+
+	eng, _ := NewEngine(prov, buf, EngineConfig{CompletionTimeout: 5 * time.Second}, clock)
+	eng.mainCtx, eng.mainCancel = context.WithCancel(context.Background())
+	defer eng.mainCancel()
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ignore.FileName), []byte(".env\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	eng.ignores = ignore.New(root, "")
+
+	eng.requestCompletion(types.CompletionSourceTyping)
+
+	assert.Equal(t, stateIdle, eng.state, "stays idle")
+	assert.Equal(t, 0, prov.completionCalls, "provider not called")
+	assert.Nil(t, eng.getAllFileDiffHistories(), "ignored file history never included")
+}
@@ -229,10 +229,12 @@ func (e *Engine) doRejectStreamingAndDebounce(event Event) {
 		if matches {
 			if hasRemaining {
 				// Typing matches - keep completion state
+				e.stats.TypingMatched()
 				e.state = stateHasCompletion
 				return
 			}
 			// User typed everything
+			e.stats.Decide(outcomeTyped)
 			e.clearAll()
 			e.state = stateIdle
 			e.startTextChangeTimer()
@@ -255,10 +257,12 @@ func (e *Engine) doRejectStreamingAndDebounce(event Event) {
 		if matches {
 			if hasRemaining {
 				// Typing matches - keep completion state
+				e.stats.TypingMatched()
 				e.state = stateHasCompletion
 				return
 			}
 			// User typed everything
+			e.stats.Decide(outcomeTyped)
 			e.clearAll()
 			e.state = stateIdle
 			e.startTextChangeTimer()
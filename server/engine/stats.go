@@ -0,0 +1,549 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cursortab/logger"
+)
+
+// StatsConfig controls the suggestion statistics kept by the engine.
+type StatsConfig struct {
+	Provider  string // Key acceptance counts are kept under (e.g. "sweep")
+	StatePath string // File for persisted daily rollups ("" = not persisted)
+	KeepDays  int    // Daily rollups kept in the file (0 = defaultStatsKeepDays)
+}
+
+const defaultStatsKeepDays = 30
+
+// statsSaveInterval bounds how often rollups are written while running
+const statsSaveInterval = time.Minute
+
+// latencyBuckets are the upper bounds in milliseconds of the latency
+// histogram buckets; one more bucket counts everything above the last
+var latencyBuckets = []int64{25, 50, 100, 200, 300, 500, 750, 1000, 1500, 2000, 3000, 5000, 10000}
+
+// latencyHistogram counts durations in latencyBuckets.
+type latencyHistogram struct {
+	Counts []int64 `json:"counts"`
+	Count  int64   `json:"count"`
+	SumMS  int64   `json:"sum_ms"`
+	MaxMS  int64   `json:"max_ms"`
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	ms := d.Milliseconds()
+	if len(h.Counts) != len(latencyBuckets)+1 {
+		h.Counts = make([]int64, len(latencyBuckets)+1)
+	}
+	i, _ := slices.BinarySearch(latencyBuckets, ms)
+	h.Counts[i]++
+	h.Count++
+	h.SumMS += ms
+	h.MaxMS = max(h.MaxMS, ms)
+}
+
+// add adds the observations of other.
+func (h *latencyHistogram) add(other latencyHistogram) {
+	if other.Count == 0 {
+		return
+	}
+	if len(h.Counts) != len(latencyBuckets)+1 {
+		h.Counts = make([]int64, len(latencyBuckets)+1)
+	}
+	for i, n := range other.Counts {
+		if i < len(h.Counts) {
+			h.Counts[i] += n
+		}
+	}
+	h.Count += other.Count
+	h.SumMS += other.SumMS
+	h.MaxMS = max(h.MaxMS, other.MaxMS)
+}
+
+// quantile estimates the q-th quantile (0 < q <= 1) as the upper bound of
+// the bucket it falls in, capped at the largest value seen.
+func (h *latencyHistogram) quantile(q float64) int64 {
+	if h.Count == 0 {
+		return 0
+	}
+	target := int64(math.Ceil(q * float64(h.Count)))
+	var seen int64
+	for i, n := range h.Counts {
+		seen += n
+		if seen >= target && i < len(latencyBuckets) {
+			return min(latencyBuckets[i], h.MaxMS)
+		}
+	}
+	return h.MaxMS
+}
+
+// suggestionOutcome is how the user answered a shown suggestion.
+type suggestionOutcome int
+
+const (
+	outcomeAccepted suggestionOutcome = iota // accepted with Tab
+	outcomeTyped                             // typed out by the user
+	outcomeRejected
+)
+
+// String returns the outcome name used in metrics.
+func (o suggestionOutcome) String() string {
+	switch o {
+	case outcomeAccepted:
+		return "accepted"
+	case outcomeTyped:
+		return "typed"
+	default:
+		return "rejected"
+	}
+}
+
+// outcomeCounts counts suggestions and their outcomes.
+type outcomeCounts struct {
+	Shown    int64 `json:"shown"`
+	Accepted int64 `json:"accepted"`
+	Typed    int64 `json:"typed"`
+	Rejected int64 `json:"rejected"`
+}
+
+// taken returns the suggestions accepted or typed out and those answered
+// at all.
+func (c *outcomeCounts) taken() (taken, decided int64) {
+	taken = c.Accepted + c.Typed
+	return taken, taken + c.Rejected
+}
+
+// dailyStats is the rollup of one calendar day.
+type dailyStats struct {
+	Day                  string                    `json:"day"` // 2006-01-02
+	TimeToFirstLine      latencyHistogram          `json:"time_to_first_line"`
+	ProviderLatency      latencyHistogram          `json:"provider_latency"`
+	TimeToAccept         latencyHistogram          `json:"time_to_accept"`
+	TimeToReject         latencyHistogram          `json:"time_to_reject"`
+	Providers            map[string]*outcomeCounts `json:"providers"`
+	Filetypes            map[string]*outcomeCounts `json:"filetypes"`
+	Stages               map[string]int64          `json:"stages"` // suggestions by number of stages, "5+" for more
+	StagesAccepted       int64                     `json:"stages_accepted"`
+	PartialTypingMatches int64                     `json:"partial_typing_matches"`
+}
+
+func newDailyStats(day string) *dailyStats {
+	return &dailyStats{
+		Day:       day,
+		Providers: make(map[string]*outcomeCounts),
+		Filetypes: make(map[string]*outcomeCounts),
+		Stages:    make(map[string]int64),
+	}
+}
+
+// add adds the counts of other, a rollup of the same day.
+func (d *dailyStats) add(other *dailyStats) {
+	d.TimeToFirstLine.add(other.TimeToFirstLine)
+	d.ProviderLatency.add(other.ProviderLatency)
+	d.TimeToAccept.add(other.TimeToAccept)
+	d.TimeToReject.add(other.TimeToReject)
+	for _, m := range []struct{ to, from map[string]*outcomeCounts }{
+		{d.Providers, other.Providers},
+		{d.Filetypes, other.Filetypes},
+	} {
+		for key, c := range m.from {
+			total := counts(m.to, key)
+			total.Shown += c.Shown
+			total.Accepted += c.Accepted
+			total.Typed += c.Typed
+			total.Rejected += c.Rejected
+		}
+	}
+	for key, n := range other.Stages {
+		d.Stages[key] += n
+	}
+	d.StagesAccepted += other.StagesAccepted
+	d.PartialTypingMatches += other.PartialTypingMatches
+}
+
+// counts returns the outcome counters for key, creating them.
+func counts(m map[string]*outcomeCounts, key string) *outcomeCounts {
+	c := m[key]
+	if c == nil {
+		c = &outcomeCounts{}
+		m[key] = c
+	}
+	return c
+}
+
+// pendingSuggestion is a shown suggestion the user has not answered yet.
+type pendingSuggestion struct {
+	requestID string
+	filetype  string
+	shownAt   time.Time
+}
+
+// statsCollector records latencies and suggestion outcomes, rolled up per
+// day. Counts are kept in memory and merged into the state file, which the
+// daemon and the language server share. Methods are safe on a nil
+// collector and for concurrent use.
+type statsCollector struct {
+	mu       sync.Mutex
+	config   StatsConfig
+	clock    Clock
+	today    *dailyStats            // totals for today, from all processes as of the last save
+	unsaved  map[string]*dailyStats // counts per day not yet merged into the state file
+	pending  *pendingSuggestion
+	lastSave time.Time
+}
+
+func newStatsCollector(config StatsConfig, clock Clock) *statsCollector {
+	if config.KeepDays <= 0 {
+		config.KeepDays = defaultStatsKeepDays
+	}
+	s := &statsCollector{config: config, clock: clock, lastSave: clock.Now()}
+	s.today = newDailyStats(clock.Now().Format("2006-01-02"))
+	s.unsaved = make(map[string]*dailyStats)
+	s.load()
+	return s
+}
+
+// day returns the rollup for the current day, saving and replacing the
+// previous one after midnight. Counts of the previous day that cannot be
+// saved stay in s.unsaved for the next save. Caller must hold s.mu.
+func (s *statsCollector) day() *dailyStats {
+	today := s.clock.Now().Format("2006-01-02")
+	if s.today.Day != today {
+		if len(s.unsaved) > 0 {
+			s.save()
+		}
+		s.today = newDailyStats(today)
+	}
+	return s.today
+}
+
+// record applies update to today's totals and to its unsaved counts.
+// Caller must hold s.mu.
+func (s *statsCollector) record(update func(day *dailyStats)) {
+	day := s.day()
+	update(day)
+	unsaved := s.unsaved[day.Day]
+	if unsaved == nil {
+		unsaved = newDailyStats(day.Day)
+		s.unsaved[day.Day] = unsaved
+	}
+	update(unsaved)
+	s.changed()
+}
+
+// changed writes the unsaved counts when the last save is older than
+// statsSaveInterval. Caller must hold s.mu.
+func (s *statsCollector) changed() {
+	if now := s.clock.Now(); now.Sub(s.lastSave) >= statsSaveInterval {
+		s.save()
+	}
+}
+
+// FirstLine records the time from request to the first line of a
+// suggestion.
+func (s *statsCollector) FirstLine(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record(func(day *dailyStats) { day.TimeToFirstLine.observe(d) })
+}
+
+// ProviderLatency records the time a provider took to answer completely.
+func (s *statsCollector) ProviderLatency(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record(func(day *dailyStats) { day.ProviderLatency.observe(d) })
+}
+
+// Shown records that the suggestion of requestID is displayed. Showing the
+// same request again (later stages, streamed updates) is not counted.
+func (s *statsCollector) Shown(requestID, filetype string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending != nil && s.pending.requestID == requestID {
+		return
+	}
+	if filetype == "" {
+		filetype = "none"
+	}
+	s.pending = &pendingSuggestion{requestID: requestID, filetype: filetype, shownAt: s.clock.Now()}
+	s.record(func(day *dailyStats) {
+		counts(day.Providers, s.config.Provider).Shown++
+		counts(day.Filetypes, filetype).Shown++
+	})
+	suggestions.Inc(s.config.Provider, "shown")
+}
+
+// Staged records the number of stages a suggestion was split into.
+func (s *statsCollector) Staged(stages int) {
+	if s == nil || stages <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := fmt.Sprint(stages)
+	if stages >= 5 {
+		key = "5+"
+	}
+	s.record(func(day *dailyStats) { day.Stages[key]++ })
+}
+
+// Decide records the user's answer to the pending suggestion. Only the
+// first answer counts; later stages of an accepted suggestion do not.
+func (s *statsCollector) Decide(outcome suggestionOutcome) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.pending
+	if p == nil {
+		return
+	}
+	s.pending = nil
+
+	elapsed := s.clock.Now().Sub(p.shownAt)
+	s.record(func(day *dailyStats) {
+		for _, c := range []*outcomeCounts{counts(day.Providers, s.config.Provider), counts(day.Filetypes, p.filetype)} {
+			switch outcome {
+			case outcomeAccepted:
+				c.Accepted++
+			case outcomeTyped:
+				c.Typed++
+			case outcomeRejected:
+				c.Rejected++
+			}
+		}
+		if outcome == outcomeRejected {
+			day.TimeToReject.observe(elapsed)
+		} else {
+			day.TimeToAccept.observe(elapsed)
+		}
+	})
+	suggestions.Inc(s.config.Provider, outcome.String())
+}
+
+// StageAccepted records a stage accepted with Tab.
+func (s *statsCollector) StageAccepted() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record(func(day *dailyStats) { day.StagesAccepted++ })
+}
+
+// TypingMatched records typing that matched a shown suggestion, keeping it
+// on screen.
+func (s *statsCollector) TypingMatched() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record(func(day *dailyStats) { day.PartialTypingMatches++ })
+}
+
+// Status returns today's rollup for the status RPC.
+func (s *statsCollector) Status() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	day := s.day()
+
+	var total outcomeCounts
+	for _, c := range day.Providers {
+		total.Shown += c.Shown
+		total.Accepted += c.Accepted
+		total.Typed += c.Typed
+		total.Rejected += c.Rejected
+	}
+	taken, decided := total.taken()
+	rate := 0.0
+	if decided > 0 {
+		rate = math.Round(float64(taken)/float64(decided)*100) / 100
+	}
+
+	stageKeys := make([]string, 0, len(day.Stages))
+	for k := range day.Stages {
+		stageKeys = append(stageKeys, k)
+	}
+	sort.Strings(stageKeys)
+	stages := make([]string, len(stageKeys))
+	for i, k := range stageKeys {
+		stages[i] = fmt.Sprintf("%s: %d", k, day.Stages[k])
+	}
+
+	return map[string]any{
+		"day":                       day.Day,
+		"shown":                     total.Shown,
+		"accepted":                  total.Accepted,
+		"typed":                     total.Typed,
+		"rejected":                  total.Rejected,
+		"acceptance_rate":           rate,
+		"by_provider":               formatOutcomes(day.Providers),
+		"by_filetype":               formatOutcomes(day.Filetypes),
+		"stages":                    strings.Join(stages, ", "),
+		"stages_accepted":           day.StagesAccepted,
+		"partial_typing_matches":    day.PartialTypingMatches,
+		"time_to_first_line_p50_ms": day.TimeToFirstLine.quantile(0.5),
+		"time_to_first_line_p90_ms": day.TimeToFirstLine.quantile(0.9),
+		"provider_latency_p50_ms":   day.ProviderLatency.quantile(0.5),
+		"provider_latency_p90_ms":   day.ProviderLatency.quantile(0.9),
+		"time_to_accept_p50_ms":     day.TimeToAccept.quantile(0.5),
+		"time_to_reject_p50_ms":     day.TimeToReject.quantile(0.5),
+	}
+}
+
+// formatOutcomes lists taken/answered suggestions per key, most answered
+// first, as "go 12/30, lua 3/4".
+func formatOutcomes(m map[string]*outcomeCounts) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		_, di := m[keys[i]].taken()
+		_, dj := m[keys[j]].taken()
+		if di != dj {
+			return di > dj
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		taken, decided := m[k].taken()
+		parts[i] = fmt.Sprintf("%s %d/%d", k, taken, decided)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Close writes unsaved counts.
+func (s *statsCollector) Close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.unsaved) > 0 {
+		s.save()
+	}
+}
+
+// statsFile is the on-disk layout: daily rollups, oldest first.
+type statsFile struct {
+	Days []*dailyStats `json:"days"`
+}
+
+// load resumes today's rollup from the state file. Caller must not hold s.mu.
+func (s *statsCollector) load() {
+	if s.config.StatePath == "" {
+		return
+	}
+	file, err := readStatsFile(s.config.StatePath)
+	if err != nil {
+		logger.Warn("stats: could not read rollups: %v", err)
+		return
+	}
+	if day := file.day(s.today.Day); day != nil {
+		s.today = day
+	}
+}
+
+// save merges the unsaved counts into the rollups of their days in the
+// state file, dropping days past KeepDays. Caller must hold s.mu.
+func (s *statsCollector) save() {
+	s.lastSave = s.clock.Now()
+	if s.config.StatePath == "" {
+		clear(s.unsaved)
+		return
+	}
+	unlock, err := lockStateFile(s.config.StatePath)
+	if err != nil {
+		logger.Warn("stats: not saving rollups: %v", err)
+		return
+	}
+	defer unlock()
+
+	file, err := readStatsFile(s.config.StatePath)
+	if err != nil {
+		// Keep the unsaved counts rather than overwrite a file we cannot read
+		logger.Warn("stats: not saving rollups: %v", err)
+		return
+	}
+	for _, unsaved := range s.unsaved {
+		day := file.day(unsaved.Day)
+		if day == nil {
+			day = newDailyStats(unsaved.Day)
+			file.Days = append(file.Days, day)
+		}
+		day.add(unsaved)
+	}
+	sort.Slice(file.Days, func(i, j int) bool { return file.Days[i].Day < file.Days[j].Day })
+	if len(file.Days) > s.config.KeepDays {
+		file.Days = file.Days[len(file.Days)-s.config.KeepDays:]
+	}
+
+	data, err := json.Marshal(file)
+	if err == nil {
+		err = writeFileAtomic(s.config.StatePath, data)
+	}
+	if err != nil {
+		logger.Warn("stats: could not persist rollups: %v", err)
+		return
+	}
+	clear(s.unsaved)
+	if day := file.day(s.today.Day); day != nil {
+		s.today = day
+	}
+}
+
+// day returns the rollup of the given day, or nil. Missing maps are
+// created so the rollup can be added to.
+func (f *statsFile) day(day string) *dailyStats {
+	for _, d := range f.Days {
+		if d.Day != day {
+			continue
+		}
+		for _, m := range []*map[string]*outcomeCounts{&d.Providers, &d.Filetypes} {
+			if *m == nil {
+				*m = make(map[string]*outcomeCounts)
+			}
+		}
+		if d.Stages == nil {
+			d.Stages = make(map[string]int64)
+		}
+		return d
+	}
+	return nil
+}
+
+func readStatsFile(path string) (*statsFile, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &statsFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file statsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid stats file %s: %w", path, err)
+	}
+	return &file, nil
+}
@@ -0,0 +1,252 @@
+package engine
+
+import (
+	"context"
+	"cursortab/assert"
+	"cursortab/buffer"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram_Quantile(t *testing.T) {
+	var h latencyHistogram
+	assert.Equal(t, int64(0), h.quantile(0.5), "empty")
+
+	for _, ms := range []int{10, 40, 90, 120, 180, 450, 20000} {
+		h.observe(time.Duration(ms) * time.Millisecond)
+	}
+	assert.Equal(t, int64(7), h.Count, "count")
+	assert.Equal(t, int64(200), h.quantile(0.5), "median bucket bound")
+	assert.Equal(t, int64(20000), h.quantile(1), "overflow reports the max")
+	assert.Equal(t, int64(1), h.Counts[len(latencyBuckets)], "overflow bucket")
+
+	var small latencyHistogram
+	small.observe(30 * time.Millisecond)
+	assert.Equal(t, int64(30), small.quantile(0.9), "capped at the max seen")
+}
+
+func TestStats_OutcomesPerFiletype(t *testing.T) {
+	clock := newMockClock()
+	s := newStatsCollector(StatsConfig{Provider: "sweep"}, clock)
+
+	s.Shown("a", "go")
+	clock.Advance(400 * time.Millisecond)
+	s.Decide(outcomeAccepted)
+
+	s.Shown("b", "go")
+	s.TypingMatched()
+	clock.Advance(400 * time.Millisecond)
+	s.Decide(outcomeTyped)
+
+	s.Shown("c", "lua")
+	clock.Advance(2 * time.Second)
+	s.Decide(outcomeRejected)
+	s.Decide(outcomeRejected) // nothing pending
+
+	s.Shown("d", "")
+
+	status := s.Status()
+	assert.Equal(t, int64(4), status["shown"], "shown")
+	assert.Equal(t, int64(1), status["accepted"], "accepted")
+	assert.Equal(t, int64(1), status["typed"], "typed")
+	assert.Equal(t, int64(1), status["rejected"], "rejected once")
+	assert.Equal(t, 0.67, status["acceptance_rate"], "accepted or typed of answered")
+	assert.Equal(t, "sweep 2/3", status["by_provider"], "per provider")
+	assert.Equal(t, "go 2/2, lua 0/1, none 0/0", status["by_filetype"], "per filetype, most answered first")
+	assert.Equal(t, int64(1), status["partial_typing_matches"], "partial typing")
+	assert.Equal(t, int64(400), status["time_to_accept_p50_ms"], "display to accept, capped at the max")
+	assert.Equal(t, int64(2000), status["time_to_reject_p50_ms"], "display to reject")
+}
+
+func TestStats_ShownOncePerRequest(t *testing.T) {
+	s := newStatsCollector(StatsConfig{Provider: "sweep"}, newMockClock())
+
+	s.Shown("a", "go")
+	s.Shown("a", "go") // next stage or streamed update
+	s.Staged(2)
+	s.Decide(outcomeAccepted)
+	s.StageAccepted()
+	s.Decide(outcomeRejected) // second stage rejected after the first was taken
+	s.StageAccepted()
+	s.Staged(7)
+
+	status := s.Status()
+	assert.Equal(t, int64(1), status["shown"], "counted once")
+	assert.Equal(t, int64(0), status["rejected"], "first answer wins")
+	assert.Equal(t, int64(2), status["stages_accepted"], "stages accepted")
+	assert.Equal(t, "2: 1, 5+: 1", status["stages"], "stage counts")
+}
+
+func TestStats_PersistsDailyRollups(t *testing.T) {
+	clock := newMockClock()
+	clock.now = time.Date(2026, 3, 30, 23, 0, 0, 0, time.Local)
+	path := filepath.Join(t.TempDir(), "stats.json")
+	config := StatsConfig{Provider: "sweep", StatePath: path, KeepDays: 2}
+
+	first := newStatsCollector(config, clock)
+	first.Shown("a", "go")
+	first.Decide(outcomeAccepted)
+	first.Close()
+
+	restarted := newStatsCollector(config, clock)
+	assert.Equal(t, int64(1), restarted.Status()["accepted"], "today resumed after restart")
+	restarted.ProviderLatency(150 * time.Millisecond)
+
+	for _, id := range []string{"b", "c"} {
+		clock.Advance(24 * time.Hour)
+		restarted.Shown(id, "go")
+	}
+	status := restarted.Status()
+	assert.Equal(t, "2026-04-01", status["day"], "rolled over")
+	assert.Equal(t, int64(1), status["shown"], "new day starts empty")
+	restarted.Close()
+
+	file, err := readStatsFile(path)
+	assert.NoError(t, err, "read rollups")
+	assert.Len(t, 2, file.Days, "trimmed to KeepDays")
+	assert.Equal(t, "2026-03-31", file.Days[0].Day, "oldest kept day")
+	assert.Equal(t, "2026-04-01", file.Days[1].Day, "today saved on close")
+}
+
+func TestStats_MergesConcurrentProcesses(t *testing.T) {
+	clock := newMockClock()
+	path := filepath.Join(t.TempDir(), "stats.json")
+	config := StatsConfig{Provider: "sweep", StatePath: path}
+
+	// The daemon and the language server count into the same file
+	daemon := newStatsCollector(config, clock)
+	lsp := newStatsCollector(config, clock)
+	daemon.Shown("a", "go")
+	daemon.Decide(outcomeAccepted)
+	daemon.ProviderLatency(100 * time.Millisecond)
+	lsp.Shown("b", "lua")
+	lsp.Decide(outcomeRejected)
+	lsp.ProviderLatency(300 * time.Millisecond)
+	daemon.Close()
+	lsp.Close()
+
+	file, err := readStatsFile(path)
+	assert.NoError(t, err, "read rollups")
+	assert.Len(t, 1, file.Days, "one day")
+	day := file.Days[0]
+	assert.Equal(t, int64(2), day.Providers["sweep"].Shown, "shown by both processes")
+	assert.Equal(t, int64(1), day.Filetypes["go"].Accepted, "daemon outcome kept")
+	assert.Equal(t, int64(1), day.Filetypes["lua"].Rejected, "language server outcome kept")
+	assert.Equal(t, int64(2), day.ProviderLatency.Count, "latencies merged")
+	assert.Equal(t, int64(300), day.ProviderLatency.MaxMS, "largest latency kept")
+
+	// Saving again adds only what is new
+	daemon.Shown("c", "go")
+	daemon.Close()
+	file, _ = readStatsFile(path)
+	assert.Equal(t, int64(3), file.Days[0].Providers["sweep"].Shown, "no count added twice")
+	assert.Equal(t, int64(3), daemon.Status()["shown"], "totals from both processes")
+}
+
+func TestStats_UnreadableStateNotOverwritten(t *testing.T) {
+	clock := newMockClock()
+	path := filepath.Join(t.TempDir(), "stats.json")
+	if err := os.WriteFile(path, []byte(`{"days": [`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stats := newStatsCollector(StatsConfig{Provider: "sweep", StatePath: path}, clock)
+	stats.Shown("a", "go")
+	stats.Close()
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err, "read state")
+	assert.Equal(t, `{"days": [`, string(data), "history left for the user to recover")
+
+	// Counts are kept until the file can be written
+	assert.NoError(t, os.Remove(path), "remove state")
+	stats.Close()
+	file, err := readStatsFile(path)
+	assert.NoError(t, err, "read rollups")
+	assert.Equal(t, int64(1), file.Days[0].Providers["sweep"].Shown, "unsaved counts written")
+}
+
+func TestStats_UnsavedDayKeptAcrossMidnight(t *testing.T) {
+	clock := newMockClock()
+	clock.now = time.Date(2026, 3, 30, 23, 0, 0, 0, time.Local)
+	path := filepath.Join(t.TempDir(), "stats.json")
+	if err := os.WriteFile(path, []byte(`{"days": [`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stats := newStatsCollector(StatsConfig{Provider: "sweep", StatePath: path}, clock)
+	stats.Shown("a", "go")
+
+	clock.Advance(2 * time.Hour)
+	stats.Shown("b", "go") // rollover save fails on the unreadable file
+
+	assert.NoError(t, os.Remove(path), "remove state")
+	stats.Close()
+	file, err := readStatsFile(path)
+	assert.NoError(t, err, "read rollups")
+	assert.Len(t, 2, file.Days, "both days saved")
+	assert.Equal(t, "2026-03-30", file.Days[0].Day, "previous day")
+	assert.Equal(t, int64(1), file.Days[0].Providers["sweep"].Shown, "previous day's counts kept")
+	assert.Equal(t, int64(1), file.Days[1].Providers["sweep"].Shown, "today's counts")
+}
+
+func TestStats_ConcurrentSavesLoseNoCounts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	config := StatsConfig{Provider: "sweep", StatePath: path}
+	clock := newMockClock()
+	collectors := []*statsCollector{newStatsCollector(config, clock), newStatsCollector(config, clock)}
+
+	const shown = 50
+	var wg sync.WaitGroup
+	for i, s := range collectors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range shown {
+				s.Shown(fmt.Sprintf("%d-%d", i, j), "go")
+				s.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	file, err := readStatsFile(path)
+	assert.NoError(t, err, "read rollups")
+	assert.Equal(t, int64(2*shown), file.Days[0].Providers["sweep"].Shown, "no counts lost")
+}
+
+func TestEngine_RecordsStats(t *testing.T) {
+	buf := buffer.NewMemory("main.go", "go", []string{"line 1", "line 2"})
+	clock := newMockClock()
+	eng, err := NewEngine(newMockProvider(), buf, EngineConfig{
+		CompletionTimeout:  5 * time.Second,
+		TextChangeDebounce: 100 * time.Millisecond,
+		Stats:              StatsConfig{Provider: "mock"},
+	}, clock)
+	assert.NoError(t, err, "NewEngine")
+	eng.mainCtx, eng.mainCancel = context.WithCancel(context.Background())
+	defer eng.Stop()
+
+	buf.SetLines([]string{"line 1 edited", "line 2"})
+	eng.handleEvent(Event{Type: EventTextChanged})
+	clock.Advance(100 * time.Millisecond)
+	for eng.state != stateHasCompletion {
+		select {
+		case event := <-eng.eventChan:
+			eng.handleEvent(event)
+		case <-time.After(time.Second):
+			t.Fatal("no completion shown")
+		}
+	}
+	clock.Advance(300 * time.Millisecond)
+	eng.handleEvent(Event{Type: EventTab})
+
+	stats := eng.Status()["stats"].(map[string]any)
+	assert.Equal(t, int64(1), stats["shown"], "shown")
+	assert.Equal(t, int64(1), stats["accepted"], "accepted")
+	assert.Equal(t, "mock 1/1", stats["by_provider"], "per provider")
+	assert.Equal(t, "go 1/1", stats["by_filetype"], "per filetype")
+	assert.Equal(t, int64(300), stats["time_to_accept_p50_ms"], "display to accept")
+}
@@ -0,0 +1,78 @@
+package engine
+
+// State returns the name of the engine's state, such as "Idle" or
+// "PendingCompletion".
+func (e *Engine) State() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.state.String()
+}
+
+// InFlight reports whether a completion request is pending or streaming,
+// and returns a channel that is closed when that changes.
+func (e *Engine) InFlight() (bool, <-chan struct{}) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.inFlight, e.inFlightChanged
+}
+
+// updateInFlight signals InFlight waiters when a request started or
+// finished. Caller must hold e.mu for writing.
+func (e *Engine) updateInFlight() {
+	inFlight := e.state == statePendingCompletion || e.state == stateStreamingCompletion
+	if inFlight == e.inFlight {
+		return
+	}
+	e.inFlight = inFlight
+	close(e.inFlightChanged)
+	e.inFlightChanged = make(chan struct{})
+}
+
+// Status returns a snapshot of engine state for the status RPC.
+// Sections are only present for features that are enabled.
+func (e *Engine) Status() map[string]any {
+	e.mu.RLock()
+	state := e.state.String()
+	e.mu.RUnlock()
+
+	status := map[string]any{
+		"engine": map[string]any{
+			"state": state,
+		},
+	}
+
+	if e.cache != nil {
+		hits, misses := e.cache.Stats()
+		status["cache"] = map[string]any{
+			"hits":    hits,
+			"misses":  misses,
+			"entries": e.cache.Len(),
+		}
+	}
+
+	if e.debounce != nil {
+		typingP50, latencyP50 := e.debounce.Stats()
+		status["adaptive_debounce"] = map[string]any{
+			"typing_p50_ms":  typingP50.Milliseconds(),
+			"latency_p50_ms": latencyP50.Milliseconds(),
+		}
+	}
+
+	if e.limiter != nil {
+		status["rate_limit"] = e.limiter.Status()
+	}
+
+	if e.breaker != nil {
+		status["circuit_breaker"] = e.breaker.Status()
+	}
+
+	if e.privacy != nil {
+		status["privacy"] = e.privacy.Status()
+	}
+
+	if e.stats != nil {
+		status["stats"] = e.stats.Status()
+	}
+
+	return status
+}
@@ -2,7 +2,7 @@ package engine
 
 func (e *Engine) startIdleTimer() {
 	e.stopIdleTimer()
-	e.idleTimer = e.clock.AfterFunc(e.config.IdleCompletionDelay, func() {
+	e.idleTimer = e.clock.AfterFunc(e.debounce.IdleDelay(e.config.IdleCompletionDelay), func() {
 		// Check if engine is stopped before sending event
 		e.mu.RLock()
 		stopped := e.stopped
@@ -34,7 +34,7 @@ func (e *Engine) resetIdleTimer() {
 
 func (e *Engine) startTextChangeTimer() {
 	e.stopTextChangeTimer()
-	e.textChangeTimer = e.clock.AfterFunc(e.config.TextChangeDebounce, func() {
+	e.textChangeTimer = e.clock.AfterFunc(e.debounce.TextChangeDelay(e.config.TextChangeDebounce), func() {
 		// Check if engine is stopped before sending event
 		e.mu.RLock()
 		stopped := e.stopped
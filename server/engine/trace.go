@@ -0,0 +1,496 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"cursortab/buffer"
+	"cursortab/logger"
+	"cursortab/redact"
+	"cursortab/text"
+	"cursortab/types"
+)
+
+// A trace is a JSON lines file of everything the engine saw and did: the
+// events it handled, the buffer state it read, provider calls and the UI
+// calls it made. Replay feeds a trace back into a fresh engine to reproduce
+// state machine bugs without Neovim or a model.
+
+const traceVersion = 1
+
+// Trace record kinds
+const (
+	traceHeader     = "header"
+	traceEvent      = "event"       // editor event
+	traceTimer      = "timer"       // idle or text change timer fired
+	traceResult     = "result"      // provider result handled by the event loop
+	traceSync       = "sync"        // state after Buffer.Sync
+	traceContext    = "context"     // state after Buffer.SetFileContext
+	traceCommit     = "commit"      // state after Buffer.CommitPending
+	traceUserEdits  = "user_edits"  // state and result of Buffer.CommitUserEdits
+	traceHasChanges = "has_changes" // result of Buffer.HasChanges
+	traceLinter     = "linter"      // result of Buffer.LinterErrors
+	traceProvider   = "provider"    // provider request and response
+	traceUI         = "ui"          // call that changes what the editor shows
+)
+
+// traceRecord is one line of a trace. Only the fields of its kind are set.
+type traceRecord struct {
+	Kind string `json:"k"`
+	Time int64  `json:"t"` // nanoseconds since the header
+
+	Event    string                    `json:"e,omitempty"`
+	Call     int                       `json:"call,omitempty"` // provider call number, from 1
+	Error    string                    `json:"err,omitempty"`
+	OK       bool                      `json:"ok,omitempty"`
+	UI       string                    `json:"ui,omitempty"`
+	Private  bool                      `json:"private,omitempty"` // content left out (privacy mode)
+	Ignored  bool                      `json:"ignored,omitempty"` // content left out (.cursortabignore)
+	State    *traceState               `json:"state,omitempty"`
+	Request  *traceRequest             `json:"req,omitempty"`
+	Response *types.CompletionResponse `json:"resp,omitempty"`
+	Linter   *types.LinterErrors       `json:"linter,omitempty"`
+	Breaker  *breakerChange            `json:"breaker,omitempty"`
+
+	// Header only
+	Version   int           `json:"version,omitempty"`
+	Start     time.Time     `json:"start,omitzero"`
+	Workspace string        `json:"workspace,omitempty"`
+	Config    *EngineConfig `json:"config,omitempty"`
+}
+
+// traceState is the buffer state after a call that may change it. Line
+// slices that did not change since the previous state are left out (nil).
+type traceState struct {
+	Path       string              `json:"path,omitempty"`
+	Filetype   string              `json:"ft,omitempty"`
+	Row        int                 `json:"row"`
+	Col        int                 `json:"col"`
+	Version    int                 `json:"ver"`
+	ViewTop    int                 `json:"top"`
+	ViewBottom int                 `json:"bottom"`
+	Lines      *[]string           `json:"lines,omitempty"`
+	Previous   *[]string           `json:"prev,omitempty"`
+	Original   *[]string           `json:"orig,omitempty"`
+	Diffs      *[]*types.DiffEntry `json:"diffs,omitempty"`
+
+	// Sync result
+	Changed bool   `json:"changed,omitempty"`
+	OldPath string `json:"old_path,omitempty"`
+}
+
+// traceRequest identifies a provider request; its content is in the
+// preceding buffer states.
+type traceRequest struct {
+	Source  types.CompletionSource `json:"source"`
+	Path    string                 `json:"path"`
+	Version int                    `json:"ver"`
+	Row     int                    `json:"row"`
+	Col     int                    `json:"col"`
+}
+
+func summarizeRequest(req *types.CompletionRequest) traceRequest {
+	return traceRequest{
+		Source:  req.Source,
+		Path:    req.FilePath,
+		Version: req.Version,
+		Row:     req.CursorRow,
+		Col:     req.CursorCol,
+	}
+}
+
+// traceRecorder writes a trace file. A nil recorder records nothing. Safe
+// for concurrent use.
+type traceRecorder struct {
+	mu         sync.Mutex
+	f          *os.File
+	clock      Clock
+	start      time.Time
+	failed     bool                              // a write failed; recording stopped
+	calls      int                               // provider calls started
+	responses  map[*types.CompletionResponse]int // undelivered response -> provider call
+	redactions *redact.Session                   // one session, so a secret keeps its placeholder
+
+	// Content of the last recorded state, for leaving out unchanged slices
+	lines, previous, original []string
+	diffs                     []*types.DiffEntry
+	haveContent               bool
+}
+
+// openTrace creates the trace file at path and writes its header. The file
+// holds buffer content, so it is only readable by the current user, and
+// secrets found by redactor are replaced with placeholders.
+func openTrace(path string, clock Clock, workspacePath string, config EngineConfig, redactor *redact.Redactor) (*traceRecorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open trace: %w", err)
+	}
+	t := &traceRecorder{
+		f:          f,
+		clock:      clock,
+		start:      clock.Now(),
+		responses:  make(map[*types.CompletionResponse]int),
+		redactions: redactor.NewSession(),
+	}
+	t.write(traceRecord{
+		Kind:      traceHeader,
+		Version:   traceVersion,
+		Start:     t.start,
+		Workspace: workspacePath,
+		Config:    &config,
+	})
+	logger.Info("recording trace to %s", path)
+	return t, nil
+}
+
+// write appends rec as one line. The first failed write stops recording.
+func (t *traceRecorder) write(rec traceRecord) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.writeLocked(rec)
+}
+
+func (t *traceRecorder) writeLocked(rec traceRecord) {
+	if t.failed {
+		return
+	}
+	if rec.Kind != traceHeader {
+		rec.Time = t.clock.Now().Sub(t.start).Nanoseconds()
+	}
+	data, err := json.Marshal(rec)
+	if err == nil {
+		_, err = t.f.Write(append(data, '\n'))
+	}
+	if err != nil {
+		logger.Error("trace recording stopped: %v", err)
+		t.failed = true
+	}
+}
+
+// Close closes the trace file.
+func (t *traceRecorder) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failed = true // late provider results are dropped quietly
+	return t.f.Close()
+}
+
+// recordEvent records an event as the event loop handles it. Provider
+// results refer to the provider call that produced them.
+func (t *traceRecorder) recordEvent(event Event) {
+	if t == nil {
+		return
+	}
+	rec := traceRecord{Kind: traceEvent, Event: string(event.Type)}
+	switch event.Type {
+	case EventIdleTimeout, EventTextChangeTimeout:
+		rec.Kind = traceTimer
+	case EventCompletionReady, EventPrefetchReady:
+		rec.Kind = traceResult
+		if resp, ok := event.Data.(*types.CompletionResponse); ok {
+			t.mu.Lock()
+			rec.Call = t.responses[resp]
+			delete(t.responses, resp)
+			t.mu.Unlock()
+		}
+	case EventCompletionError, EventPrefetchError:
+		rec.Kind = traceResult
+		if err, ok := event.Data.(error); ok {
+			rec.Error = err.Error()
+		}
+	case EventBreakerChanged:
+		rec.Kind = traceResult
+		if change, ok := event.Data.(breakerChange); ok {
+			rec.Breaker = &change
+		}
+	}
+	t.write(rec)
+}
+
+// recordState records the state of buf after a call of the given kind.
+// Content of private and ignored buffers is left out.
+func (t *traceRecorder) recordState(rec traceRecord, buf Buffer, private, ignored bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if rec.State == nil {
+		rec.State = &traceState{}
+	}
+	s := rec.State
+	s.Path = buf.Path()
+	s.Filetype = buf.Filetype()
+	s.Row = buf.Row()
+	s.Col = buf.Col()
+	s.Version = buf.Version()
+	s.ViewTop, s.ViewBottom = buf.ViewportBounds()
+
+	if private || ignored {
+		rec.Private, rec.Ignored = private, ignored
+		t.haveContent = false
+		t.writeLocked(rec)
+		return
+	}
+	s.Lines = changedLines(&t.lines, t.redactions.Lines(buf.Lines()), t.haveContent)
+	s.Previous = changedLines(&t.previous, t.redactions.Lines(buf.PreviousLines()), t.haveContent)
+	s.Original = changedLines(&t.original, t.redactions.Lines(buf.OriginalLines()), t.haveContent)
+	if diffs := t.redactDiffs(buf.DiffHistories()); !t.haveContent || !reflect.DeepEqual(diffs, t.diffs) {
+		t.diffs = diffs
+		s.Diffs = &t.diffs
+	}
+	t.haveContent = true
+	t.writeLocked(rec)
+}
+
+// redactDiffs returns a redacted copy of diffs. Caller must hold t.mu.
+func (t *traceRecorder) redactDiffs(diffs []*types.DiffEntry) []*types.DiffEntry {
+	if diffs == nil {
+		return nil
+	}
+	out := make([]*types.DiffEntry, len(diffs))
+	for i, d := range diffs {
+		out[i] = &types.DiffEntry{Original: t.redactions.Text(d.Original), Updated: t.redactions.Text(d.Updated)}
+	}
+	return out
+}
+
+// redactResponse returns a redacted copy of resp; the engine keeps using
+// the original. Caller must hold t.mu.
+func (t *traceRecorder) redactResponse(resp *types.CompletionResponse) *types.CompletionResponse {
+	if resp == nil || t.redactions == nil {
+		return resp
+	}
+	out := &types.CompletionResponse{CursorTarget: resp.CursorTarget}
+	for _, c := range resp.Completions {
+		out.Completions = append(out.Completions, &types.Completion{
+			StartLine:  c.StartLine,
+			EndLineInc: c.EndLineInc,
+			Lines:      t.redactions.Lines(c.Lines),
+		})
+	}
+	return out
+}
+
+// recordLinter records the linter errors of a buffer, leaving them out for
+// private and ignored buffers.
+func (t *traceRecorder) recordLinter(linter *types.LinterErrors, private, ignored bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rec := traceRecord{Kind: traceLinter, Private: private, Ignored: ignored}
+	if !private && !ignored && linter != nil {
+		redacted := *linter
+		redacted.FileContents = t.redactions.Text(linter.FileContents)
+		redacted.Errors = make([]*types.LinterError, len(linter.Errors))
+		for i, le := range linter.Errors {
+			copied := *le
+			copied.Message = t.redactions.Text(le.Message)
+			redacted.Errors[i] = &copied
+		}
+		rec.Linter = &redacted
+	}
+	t.writeLocked(rec)
+}
+
+// changedLines returns a copy of lines when they differ from *last (or
+// always, when !haveLast), storing it in *last; nil when unchanged.
+func changedLines(last *[]string, lines []string, haveLast bool) *[]string {
+	if haveLast && slices.Equal(*last, lines) {
+		return nil
+	}
+	*last = append([]string{}, lines...)
+	return last
+}
+
+// startCall numbers a provider call.
+func (t *traceRecorder) startCall() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls++
+	return t.calls
+}
+
+// recordCall records a finished provider call.
+func (t *traceRecorder) recordCall(call int, req *types.CompletionRequest, resp *types.CompletionResponse, err error) {
+	if t == nil {
+		return
+	}
+	summary := summarizeRequest(req)
+	rec := traceRecord{Kind: traceProvider, Call: call, Request: &summary}
+	if err != nil {
+		rec.Error = err.Error()
+	} else if req.PrivacyMode {
+		rec.Private = true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if resp != nil {
+		t.responses[resp] = call
+	}
+	if err == nil && !req.PrivacyMode {
+		rec.Response = t.redactResponse(resp)
+	}
+	t.writeLocked(rec)
+}
+
+// recordUI records a call that changes what the editor shows.
+func (t *traceRecorder) recordUI(call string, private bool) {
+	t.write(traceRecord{Kind: traceUI, UI: call, Private: private})
+}
+
+// UI call descriptions, shared by recording and replay so they compare equal
+
+func prepareCompletionCall(startLine, endLineInc int, lines []string, private bool) string {
+	if private {
+		return fmt.Sprintf("prepare_completion %d-%d (%d lines)", startLine, endLineInc, len(lines))
+	}
+	return fmt.Sprintf("prepare_completion %d-%d %q", startLine, endLineInc, lines)
+}
+
+func showCursorTargetCall(line int) string {
+	return fmt.Sprintf("show_cursor_target %d", line)
+}
+
+func moveCursorCall(line int, center, mark bool) string {
+	return fmt.Sprintf("move_cursor %d center=%v mark=%v", line, center, mark)
+}
+
+func notifyCall(message string, level int) string {
+	return fmt.Sprintf("notify %d %q", level, message)
+}
+
+const (
+	clearUICall = "clear_ui"
+	applyCall   = "apply"
+)
+
+// recordingBuffer records the state and UI calls of the buffer it wraps.
+type recordingBuffer struct {
+	Buffer
+	trace   *traceRecorder
+	private func(filePath string) bool
+	ignored func(filePath string) bool
+}
+
+func (b *recordingBuffer) isPrivate() bool {
+	return b.private(b.Buffer.Path())
+}
+
+func (b *recordingBuffer) isIgnored() bool {
+	return b.ignored(b.Buffer.Path())
+}
+
+func (b *recordingBuffer) Sync(workspacePath string) (*buffer.SyncResult, error) {
+	result, err := b.Buffer.Sync(workspacePath)
+	if err != nil {
+		b.trace.write(traceRecord{Kind: traceSync, Error: err.Error()})
+		return result, err
+	}
+	state := &traceState{}
+	if result != nil {
+		state.Changed = result.BufferChanged
+		state.OldPath = result.OldPath
+	}
+	b.trace.recordState(traceRecord{Kind: traceSync, State: state}, b.Buffer, b.isPrivate(), b.isIgnored())
+	return result, nil
+}
+
+func (b *recordingBuffer) SetFileContext(prev, orig []string, diffs []*types.DiffEntry) {
+	b.Buffer.SetFileContext(prev, orig, diffs)
+	b.trace.recordState(traceRecord{Kind: traceContext}, b.Buffer, b.isPrivate(), b.isIgnored())
+}
+
+func (b *recordingBuffer) CommitPending() {
+	b.Buffer.CommitPending()
+	b.trace.recordState(traceRecord{Kind: traceCommit}, b.Buffer, b.isPrivate(), b.isIgnored())
+}
+
+func (b *recordingBuffer) CommitUserEdits() bool {
+	ok := b.Buffer.CommitUserEdits()
+	b.trace.recordState(traceRecord{Kind: traceUserEdits, OK: ok}, b.Buffer, b.isPrivate(), b.isIgnored())
+	return ok
+}
+
+func (b *recordingBuffer) HasChanges(startLine, endLineInc int, lines []string) bool {
+	ok := b.Buffer.HasChanges(startLine, endLineInc, lines)
+	b.trace.write(traceRecord{Kind: traceHasChanges, OK: ok})
+	return ok
+}
+
+func (b *recordingBuffer) LinterErrors() *types.LinterErrors {
+	linter := b.Buffer.LinterErrors()
+	b.trace.recordLinter(linter, b.isPrivate(), b.isIgnored())
+	return linter
+}
+
+func (b *recordingBuffer) PrepareCompletion(startLine, endLineInc int, lines []string, groups []*text.Group) buffer.Batch {
+	private := b.isPrivate()
+	b.trace.recordUI(prepareCompletionCall(startLine, endLineInc, lines, private || b.isIgnored()), private)
+	return &recordingBatch{Batch: b.Buffer.PrepareCompletion(startLine, endLineInc, lines, groups), trace: b.trace}
+}
+
+func (b *recordingBuffer) ShowCursorTarget(line int) error {
+	b.trace.recordUI(showCursorTargetCall(line), false)
+	return b.Buffer.ShowCursorTarget(line)
+}
+
+func (b *recordingBuffer) ClearUI() error {
+	b.trace.recordUI(clearUICall, false)
+	return b.Buffer.ClearUI()
+}
+
+func (b *recordingBuffer) MoveCursor(line int, center, mark bool) error {
+	b.trace.recordUI(moveCursorCall(line, center, mark), false)
+	return b.Buffer.MoveCursor(line, center, mark)
+}
+
+func (b *recordingBuffer) Notify(message string, level int) error {
+	b.trace.recordUI(notifyCall(message, level), false)
+	return b.Buffer.Notify(message, level)
+}
+
+// recordingBatch records when a prepared completion is applied.
+type recordingBatch struct {
+	buffer.Batch
+	trace *traceRecorder
+}
+
+func (b *recordingBatch) Execute() error {
+	b.trace.recordUI(applyCall, false)
+	return b.Batch.Execute()
+}
+
+// recordingProvider records the requests and responses of the provider it
+// wraps. Only batch providers are supported: streamed responses reach the
+// engine outside the event loop.
+type recordingProvider struct {
+	Provider
+	trace *traceRecorder
+}
+
+func (p *recordingProvider) GetCompletion(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+	call := p.trace.startCall()
+	resp, err := p.Provider.GetCompletion(ctx, req)
+	p.trace.recordCall(call, req, resp, err)
+	return resp, err
+}
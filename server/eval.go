@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"cursortab/eval"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runEval implements the "eval" subcommand, which runs a dataset of
+// recorded edits through the configured provider and reports how well its
+// suggestions match, and "eval mine", which builds such a dataset from git
+// history. Returns the process exit code.
+func runEval(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "mine" {
+		return runEvalMine(args[1:], stdout, stderr)
+	}
+
+	fs := flag.NewFlagSet("eval", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "", "JSON file with settings to evaluate, in the shape of the setup() options")
+	format := fs.String("format", "markdown", "report format: markdown or json")
+	outPath := fs.String("out", "", "write the report to this file instead of stdout")
+	workspace := fs.String("workspace", "", "workspace path sent with requests")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cursortab eval [--config file] [--format markdown|json] [--out file] dataset")
+		fmt.Fprintln(stderr, "       cursortab eval mine [flags] repo [pathspec...] > dataset")
+		fmt.Fprintln(stderr, "Score a provider's next-edit suggestions against a dataset of recorded edits.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || (*format != "markdown" && *format != "json") {
+		fs.Usage()
+		return 2
+	}
+
+	config, err := loadConfigFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab eval: %v\n", err)
+		return 1
+	}
+	prov, _, err := newProvider(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab eval: %v\n", err)
+		return 1
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab eval: %v\n", err)
+		return 1
+	}
+	cases, err := eval.ReadCases(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab eval: %s: %v\n", fs.Arg(0), err)
+		return 1
+	}
+
+	// Ctrl-C ends the run early but still writes the report
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	label := "default settings"
+	if *configPath != "" {
+		label = strings.TrimSuffix(filepath.Base(*configPath), filepath.Ext(*configPath))
+	}
+	report := eval.Run(ctx, prov, cases, eval.Options{
+		Label:     label,
+		Workspace: *workspace,
+		Timeout:   time.Duration(config.Provider.CompletionTimeout) * time.Millisecond,
+	})
+
+	out := stdout
+	if *outPath != "" {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "cursortab eval: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+	if *format == "json" {
+		err = report.WriteJSON(out)
+	} else {
+		err = report.WriteMarkdown(out)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab eval: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runEvalMine(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("eval mine", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var opts eval.MineOptions
+	fs.IntVar(&opts.MaxCommits, "max-commits", 500, "most recent commits to scan")
+	fs.IntVar(&opts.MaxCases, "max-cases", 0, "stop after this many cases (0 = no limit)")
+	fs.IntVar(&opts.MaxChangedLines, "max-changed-lines", 20, "skip expected edits touching more lines")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cursortab eval mine [flags] repo [pathspec...] > dataset")
+		fmt.Fprintln(stderr, "Build eval cases from consecutive commits that modify the same file.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return 2
+	}
+	opts.Paths = fs.Args()[1:]
+
+	cases, err := eval.Mine(context.Background(), fs.Arg(0), opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab eval mine: %v\n", err)
+		return 1
+	}
+	if err := eval.WriteCases(stdout, cases); err != nil {
+		fmt.Fprintf(stderr, "cursortab eval mine: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stderr, "Mined %d cases.\n", len(cases))
+	return 0
+}
+
+// loadConfigFile returns the default settings overlaid with the JSON file
+// at path, if any.
+func loadConfigFile(path string) (Config, error) {
+	config := defaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return config, err
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return config, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return config, config.Validate()
+}
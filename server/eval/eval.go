@@ -0,0 +1,262 @@
+// Package eval measures next-edit quality offline. It runs a dataset of
+// recorded edits through a provider and scores each suggestion against the
+// edit that was actually made.
+package eval
+
+import (
+	"context"
+	"cursortab/engine"
+	"cursortab/text"
+	"cursortab/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Case is one next-edit example: the file as it was when a completion
+// would have been requested and the file after the edit that followed.
+type Case struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`    // relative to the workspace
+	Before  string `json:"before"`  // file content at request time
+	History []Edit `json:"history"` // edits made before the request, oldest first
+	Cursor  Cursor `json:"cursor"`
+	After   string `json:"after"` // expected file content after the next edit
+}
+
+// Edit is one edit in a case's history.
+type Edit struct {
+	Original string `json:"original"`
+	Updated  string `json:"updated"`
+}
+
+// Cursor is the cursor position at request time.
+type Cursor struct {
+	Line int `json:"line"` // 1-indexed
+	Col  int `json:"col"`  // 0-indexed byte offset
+}
+
+// ReadCases reads a dataset of JSON cases, one per line.
+func ReadCases(r io.Reader) ([]Case, error) {
+	var cases []Case
+	dec := json.NewDecoder(r)
+	for {
+		var c Case
+		err := dec.Decode(&c)
+		if errors.Is(err, io.EOF) {
+			return cases, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("case %d: %w", len(cases)+1, err)
+		}
+		if c.Cursor.Line < 1 {
+			return nil, fmt.Errorf("case %d (%s): cursor line must be >= 1", len(cases)+1, c.Name)
+		}
+		cases = append(cases, c)
+	}
+}
+
+// WriteCases writes cases as JSON lines, the format ReadCases reads.
+func WriteCases(w io.Writer, cases []Case) error {
+	enc := json.NewEncoder(w)
+	for _, c := range cases {
+		if err := enc.Encode(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Options controls how cases are run.
+type Options struct {
+	Label     string        // name of the provider setup, shown in the report
+	Workspace string        // workspace path sent with requests
+	Timeout   time.Duration // per request, 0 = no timeout
+}
+
+// CaseResult scores the suggestion for one case.
+type CaseResult struct {
+	Name      string  `json:"name"`
+	Path      string  `json:"path"`
+	Exact     bool    `json:"exact"`               // suggestion produces exactly the expected file
+	NoOp      bool    `json:"no_op"`               // no suggestion, or one that changes nothing
+	Distance  int     `json:"distance"`            // character edit distance from the expected file
+	Expected  int     `json:"expected_distance"`   // size of the expected change in characters
+	Relative  float64 `json:"relative_distance"`   // Distance / Expected: 0 = exact, 1 = no better than no suggestion
+	LatencyMs float64 `json:"latency_ms"`          // time to the provider's answer
+	Error     string  `json:"error,omitempty"`     // request failed; scored as no suggestion
+	Predicted string  `json:"predicted,omitempty"` // suggested lines, for inexact answers
+}
+
+// Summary aggregates the results of a run.
+type Summary struct {
+	Cases          int     `json:"cases"`
+	Errors         int     `json:"errors"`
+	ExactMatchRate float64 `json:"exact_match_rate"`
+	NoOpRate       float64 `json:"no_op_rate"`    // among answered requests
+	MeanDistance   float64 `json:"mean_distance"` // characters
+	MeanRelative   float64 `json:"mean_relative_distance"`
+	MeanLatencyMs  float64 `json:"mean_latency_ms"` // latencies cover answered requests only
+	P50LatencyMs   float64 `json:"p50_latency_ms"`
+	P90LatencyMs   float64 `json:"p90_latency_ms"`
+	MaxLatencyMs   float64 `json:"max_latency_ms"`
+}
+
+// Report is the outcome of running a dataset through a provider.
+type Report struct {
+	Label   string       `json:"label,omitempty"`
+	Started time.Time    `json:"started"`
+	Summary Summary      `json:"summary"`
+	Cases   []CaseResult `json:"cases"`
+}
+
+// Run sends every case to prov, one request at a time, and scores the
+// answers. Cancelling ctx stops the run early with the cases scored so far.
+func Run(ctx context.Context, prov engine.Provider, cases []Case, opts Options) *Report {
+	report := &Report{Label: opts.Label, Started: time.Now()}
+	for _, c := range cases {
+		if ctx.Err() != nil {
+			break
+		}
+		report.Cases = append(report.Cases, runCase(ctx, prov, c, opts))
+	}
+	report.Summary = summarize(report.Cases)
+	return report
+}
+
+func runCase(ctx context.Context, prov engine.Provider, c Case, opts Options) CaseResult {
+	before, eol := splitLines(c.Before)
+	history := make([]*types.DiffEntry, len(c.History))
+	for i, edit := range c.History {
+		history[i] = &types.DiffEntry{Original: edit.Original, Updated: edit.Updated}
+	}
+	req := &types.CompletionRequest{
+		Source:        types.CompletionSourceTyping,
+		WorkspacePath: opts.Workspace,
+		FilePath:      c.Path,
+		Lines:         before,
+		Version:       len(c.History),
+		PreviousLines: before,
+		CursorRow:     c.Cursor.Line,
+		CursorCol:     c.Cursor.Col,
+	}
+	if len(history) > 0 {
+		req.FileDiffHistories = []*types.FileDiffHistory{{FileName: c.Path, DiffHistory: history}}
+	}
+
+	reqCtx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	resp, err := prov.GetCompletion(reqCtx, req)
+	latency := time.Since(start)
+
+	result := CaseResult{Name: c.Name, Path: c.Path}
+	predicted := c.Before
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.LatencyMs = float64(latency.Microseconds()) / 1000
+		if resp != nil && len(resp.Completions) > 0 {
+			completion := resp.Completions[0]
+			predicted = strings.Join(text.SpliceLines(before, completion.StartLine, completion.EndLineInc, completion.Lines), "\n") + eol
+			if predicted != c.Before {
+				result.Predicted = strings.Join(completion.Lines, "\n")
+			}
+		}
+		result.NoOp = predicted == c.Before
+	}
+
+	result.Exact = predicted == c.After
+	if result.Exact {
+		result.Predicted = ""
+	}
+	result.Distance = distance(predicted, c.After)
+	result.Expected = distance(c.Before, c.After)
+	result.Relative = float64(result.Distance) / float64(max(result.Expected, 1))
+	return result
+}
+
+// distance is the character edit distance between two files. Lines both
+// share at the start and end are skipped before diffing.
+func distance(a, b string) int {
+	if a == b {
+		return 0
+	}
+	aLines, bLines := strings.Split(a, "\n"), strings.Split(b, "\n")
+	for len(aLines) > 0 && len(bLines) > 0 && aLines[0] == bLines[0] {
+		aLines, bLines = aLines[1:], bLines[1:]
+	}
+	for len(aLines) > 0 && len(bLines) > 0 && aLines[len(aLines)-1] == bLines[len(bLines)-1] {
+		aLines, bLines = aLines[:len(aLines)-1], bLines[:len(bLines)-1]
+	}
+	dmp := diffmatchpatch.New()
+	diffs := dmp.DiffMain(strings.Join(aLines, "\n"), strings.Join(bLines, "\n"), false)
+	return dmp.DiffLevenshtein(diffs)
+}
+
+func summarize(results []CaseResult) Summary {
+	s := Summary{Cases: len(results)}
+	if len(results) == 0 {
+		return s
+	}
+	var exact, noOp int
+	var relative, dist float64
+	var latencies []float64
+	for _, r := range results {
+		if r.Exact {
+			exact++
+		}
+		relative += r.Relative
+		dist += float64(r.Distance)
+		if r.Error != "" {
+			s.Errors++
+			continue
+		}
+		if r.NoOp {
+			noOp++
+		}
+		latencies = append(latencies, r.LatencyMs)
+	}
+	s.ExactMatchRate = float64(exact) / float64(len(results))
+	s.MeanRelative = relative / float64(len(results))
+	s.MeanDistance = dist / float64(len(results))
+	if answered := len(latencies); answered > 0 {
+		s.NoOpRate = float64(noOp) / float64(answered)
+		slices.Sort(latencies)
+		var total float64
+		for _, l := range latencies {
+			total += l
+		}
+		s.MeanLatencyMs = total / float64(answered)
+		s.P50LatencyMs = percentile(latencies, 0.5)
+		s.P90LatencyMs = percentile(latencies, 0.9)
+		s.MaxLatencyMs = latencies[answered-1]
+	}
+	return s
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+// splitLines splits file content into lines the way the buffer holds them,
+// returning the final newline separately.
+func splitLines(content string) (lines []string, eol string) {
+	if trimmed, ok := strings.CutSuffix(content, "\n"); ok {
+		content, eol = trimmed, "\n"
+	}
+	return strings.Split(content, "\n"), eol
+}
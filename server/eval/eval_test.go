@@ -0,0 +1,118 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"cursortab/assert"
+	"cursortab/types"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// stubProvider answers with a fixed completion for each file path.
+type stubProvider struct {
+	completions map[string]*types.Completion
+	requests    []*types.CompletionRequest
+}
+
+func (p *stubProvider) GetCompletion(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+	p.requests = append(p.requests, req)
+	if req.FilePath == "fail.go" {
+		return nil, errors.New("provider down")
+	}
+	completion, ok := p.completions[req.FilePath]
+	if !ok {
+		return &types.CompletionResponse{}, nil
+	}
+	return &types.CompletionResponse{Completions: []*types.Completion{completion}}, nil
+}
+
+func testCases() []Case {
+	before := "func add(a, b int) int {\n\treturn 0\n}\n"
+	after := "func add(a, b int) int {\n\treturn a + b\n}\n"
+	return []Case{
+		{Name: "exact", Path: "exact.go", Before: before, After: after, Cursor: Cursor{Line: 2, Col: 1},
+			History: []Edit{{Original: "func add() int {", Updated: "func add(a, b int) int {"}}},
+		{Name: "close", Path: "close.go", Before: before, After: after, Cursor: Cursor{Line: 2}},
+		{Name: "noop", Path: "noop.go", Before: before, After: after, Cursor: Cursor{Line: 2}},
+		{Name: "fail", Path: "fail.go", Before: before, After: after, Cursor: Cursor{Line: 2}},
+	}
+}
+
+func TestRun_ScoresSuggestions(t *testing.T) {
+	prov := &stubProvider{completions: map[string]*types.Completion{
+		"exact.go": {StartLine: 2, EndLineInc: 2, Lines: []string{"\treturn a + b"}},
+		"close.go": {StartLine: 2, EndLineInc: 2, Lines: []string{"\treturn a - b"}},
+	}}
+	report := Run(context.Background(), prov, testCases(), Options{Label: "stub"})
+
+	assert.Len(t, 4, report.Cases, "one result per case")
+	exact, close, noop, fail := report.Cases[0], report.Cases[1], report.Cases[2], report.Cases[3]
+	assert.True(t, exact.Exact, "exact match")
+	assert.Equal(t, 0, exact.Distance, "no distance")
+	assert.Equal(t, 5, exact.Expected, "expected change size")
+
+	assert.False(t, close.Exact, "different edit")
+	assert.Equal(t, 1, close.Distance, "one character off")
+	assert.Equal(t, "\treturn a - b", close.Predicted, "suggestion kept for inexact answers")
+
+	assert.True(t, noop.NoOp, "no suggestion")
+	assert.Equal(t, 1.0, noop.Relative, "no better than doing nothing")
+
+	assert.Equal(t, "provider down", fail.Error, "error recorded")
+	assert.False(t, fail.NoOp, "errors are not no-ops")
+
+	s := report.Summary
+	assert.Equal(t, 4, s.Cases, "cases")
+	assert.Equal(t, 1, s.Errors, "errors")
+	assert.Equal(t, 0.25, s.ExactMatchRate, "exact match rate")
+	assert.Equal(t, 1.0/3, s.NoOpRate, "no-op rate among answered requests")
+
+	req := prov.requests[0]
+	assert.Equal(t, []string{"func add(a, b int) int {", "\treturn 0", "}"}, req.Lines, "final newline not sent as a line")
+	assert.Equal(t, 2, req.CursorRow, "cursor row")
+	assert.Len(t, 1, req.FileDiffHistories, "history sent")
+}
+
+func TestRun_StopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := Run(ctx, &stubProvider{}, testCases(), Options{})
+	assert.Len(t, 0, report.Cases, "no cases run")
+}
+
+func TestCases_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCases(&buf, testCases()), "WriteCases")
+	assert.Equal(t, 4, strings.Count(buf.String(), "\n"), "one line per case")
+
+	cases, err := ReadCases(&buf)
+	assert.NoError(t, err, "ReadCases")
+	assert.Equal(t, testCases(), cases, "cases preserved")
+
+	_, err = ReadCases(strings.NewReader(`{"name":"x","cursor":{"line":0}}`))
+	assert.Error(t, err, "cursor line validated")
+	_, err = ReadCases(strings.NewReader(`{"name":`))
+	assert.Error(t, err, "invalid JSON")
+}
+
+func TestReport_WriteMarkdown(t *testing.T) {
+	prov := &stubProvider{completions: map[string]*types.Completion{
+		"exact.go": {StartLine: 2, EndLineInc: 2, Lines: []string{"\treturn a + b"}},
+	}}
+	report := Run(context.Background(), prov, testCases(), Options{Label: "stub"})
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.WriteMarkdown(&buf), "WriteMarkdown")
+	md := buf.String()
+	assert.Contains(t, md, "# Next-edit evaluation: stub", "title")
+	assert.Contains(t, md, "| Exact match | 25.0% |", "exact match rate")
+	assert.Contains(t, md, "| noop | no-op |", "no-op case listed")
+	assert.Contains(t, md, "| fail | error: provider down |", "failed case listed")
+	assert.NotContains(t, md, "| exact |", "exact matches not listed")
+
+	buf.Reset()
+	assert.NoError(t, report.WriteJSON(&buf), "WriteJSON")
+	assert.Contains(t, buf.String(), `"exact_match_rate": 0.25`, "JSON summary")
+}
@@ -0,0 +1,156 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"cursortab/text"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// historyEdits is how many edits of the previous commit a mined case keeps
+// as its history.
+const historyEdits = 5
+
+// MineOptions limits the cases mined from a repository.
+type MineOptions struct {
+	MaxCommits      int      // most recent commits scanned, 0 = 500
+	MaxCases        int      // 0 = no limit
+	MaxChangedLines int      // target edits touching more lines are skipped, 0 = 20
+	MaxFileSize     int      // in bytes, larger files are skipped, 0 = 256 KB
+	Paths           []string // git pathspecs limiting the files, empty = all
+}
+
+// Mine builds cases from the git history of the repository at dir by
+// pairing consecutive commits that modify the same file: the edits of the
+// earlier commit become the history, and the first changed block of the
+// later one is the expected next edit. The cursor is placed at the start
+// of that block. Merge commits are skipped.
+func Mine(ctx context.Context, dir string, opts MineOptions) ([]Case, error) {
+	if opts.MaxCommits <= 0 {
+		opts.MaxCommits = 500
+	}
+	if opts.MaxChangedLines <= 0 {
+		opts.MaxChangedLines = 20
+	}
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = 256 * 1024
+	}
+
+	out, err := git(ctx, dir, append([]string{"log", "--no-merges", "--reverse", "--format=%H",
+		fmt.Sprintf("-n%d", opts.MaxCommits), "--"}, opts.Paths...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []Case
+	previous := make(map[string][]Edit) // file -> edits of the last commit that modified it
+	for _, commit := range strings.Fields(out) {
+		out, err := git(ctx, dir, append([]string{"diff-tree", "--no-commit-id", "-r", "--name-only",
+			"--diff-filter=M", commit, "--"}, opts.Paths...)...)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range strings.Split(strings.TrimSpace(out), "\n") {
+			if path == "" {
+				continue
+			}
+			before, errBefore := git(ctx, dir, "show", commit+"^:"+path)
+			after, errAfter := git(ctx, dir, "show", commit+":"+path)
+			if errBefore != nil || errAfter != nil || !isText(before, opts.MaxFileSize) || !isText(after, opts.MaxFileSize) {
+				delete(previous, path)
+				continue
+			}
+
+			beforeLines, eol := splitLines(before)
+			afterLines, _ := splitLines(after)
+			hunks := lineHunks(beforeLines, afterLines)
+			history, paired := previous[path]
+			previous[path] = hunkEdits(hunks)
+			if !paired || len(hunks) == 0 || max(len(hunks[0].old), len(hunks[0].new)) > opts.MaxChangedLines {
+				continue
+			}
+
+			target := hunks[0]
+			expected := append(append(append([]string{}, beforeLines[:target.start]...), target.new...),
+				beforeLines[target.start+len(target.old):]...)
+			cases = append(cases, Case{
+				Name:    commit[:min(len(commit), 12)] + ":" + path,
+				Path:    path,
+				Before:  before,
+				History: history,
+				Cursor:  Cursor{Line: min(target.start+1, len(beforeLines))},
+				After:   strings.Join(expected, "\n") + eol,
+			})
+			if opts.MaxCases > 0 && len(cases) >= opts.MaxCases {
+				return cases, nil
+			}
+		}
+	}
+	return cases, nil
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+func isText(content string, maxSize int) bool {
+	return len(content) <= maxSize && !strings.Contains(content, "\x00")
+}
+
+// hunk is one contiguous block of changed lines.
+type hunk struct {
+	start int // 0-indexed first line in the old text
+	old   []string
+	new   []string
+}
+
+// lineHunks returns the changed blocks between two versions of a file.
+func lineHunks(oldLines, newLines []string) []hunk {
+	dmp := diffmatchpatch.New()
+	chars1, chars2, lineArray := dmp.DiffLinesToChars(text.JoinLines(oldLines), text.JoinLines(newLines))
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(chars1, chars2, false), lineArray)
+
+	var hunks []hunk
+	line := 0
+	open := false // the last hunk is still growing
+	for _, diff := range diffs {
+		lines := strings.Split(strings.TrimSuffix(diff.Text, "\n"), "\n")
+		if diff.Type == diffmatchpatch.DiffEqual {
+			line += len(lines)
+			open = false
+			continue
+		}
+		if !open {
+			hunks = append(hunks, hunk{start: line})
+			open = true
+		}
+		h := &hunks[len(hunks)-1]
+		if diff.Type == diffmatchpatch.DiffDelete {
+			h.old = append(h.old, lines...)
+			line += len(lines)
+		} else {
+			h.new = append(h.new, lines...)
+		}
+	}
+	return hunks
+}
+
+// hunkEdits converts hunks into history edits, keeping the last few.
+func hunkEdits(hunks []hunk) []Edit {
+	edits := make([]Edit, 0, len(hunks))
+	for _, h := range hunks {
+		edits = append(edits, Edit{Original: strings.Join(h.old, "\n"), Updated: strings.Join(h.new, "\n")})
+	}
+	return edits[max(len(edits)-historyEdits, 0):]
+}
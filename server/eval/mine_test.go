@@ -0,0 +1,53 @@
+package eval
+
+import (
+	"context"
+	"cursortab/assert"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestMine_PairsConsecutiveCommits(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+	commit := func(content, message string) {
+		t.Helper()
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(content), 0o644), "write")
+		run("add", "main.go")
+		run("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", message)
+	}
+	run("init", "-q")
+	commit("package main\n\nfunc a() {}\n\nfunc b() {}\n\nfunc c() {}\n", "initial")
+	commit("package main\n\nfunc a(x int) {}\n\nfunc b() {}\n\nfunc c() {}\n", "add parameter")
+	commit("package main\n\nfunc a(x int) {}\n\nfunc b(x int) {}\n\nfunc c() {}\n// end\n", "use it")
+
+	cases, err := Mine(context.Background(), dir, MineOptions{})
+	assert.NoError(t, err, "Mine")
+	assert.Len(t, 1, cases, "first change has no previous commit to pair with")
+
+	c := cases[0]
+	assert.Equal(t, "main.go", c.Path, "path")
+	assert.Equal(t, "package main\n\nfunc a(x int) {}\n\nfunc b() {}\n\nfunc c() {}\n", c.Before, "before")
+	assert.Equal(t, "package main\n\nfunc a(x int) {}\n\nfunc b(x int) {}\n\nfunc c() {}\n", c.After, "only the first changed block expected")
+	assert.Equal(t, []Edit{{Original: "func a() {}", Updated: "func a(x int) {}"}}, c.History, "previous commit as history")
+	assert.Equal(t, 5, c.Cursor.Line, "cursor on the changed line")
+
+	cases, err = Mine(context.Background(), dir, MineOptions{MaxChangedLines: 1, Paths: []string{"other.go"}})
+	assert.NoError(t, err, "Mine")
+	assert.Len(t, 0, cases, "pathspec filters files")
+
+	_, err = Mine(context.Background(), filepath.Join(dir, "missing"), MineOptions{})
+	assert.Error(t, err, "not a repository")
+}
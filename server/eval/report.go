@@ -0,0 +1,74 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteMarkdown writes the summary and the cases without an exact match as
+// markdown tables.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	title := "Next-edit evaluation"
+	if r.Label != "" {
+		title += ": " + r.Label
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "Run at %s.\n\n", r.Started.Format(time.DateTime))
+
+	s := r.Summary
+	b.WriteString("| Metric | Value |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Cases | %d |\n", s.Cases)
+	fmt.Fprintf(&b, "| Errors | %d |\n", s.Errors)
+	fmt.Fprintf(&b, "| Exact match | %.1f%% |\n", s.ExactMatchRate*100)
+	fmt.Fprintf(&b, "| No-op rate | %.1f%% |\n", s.NoOpRate*100)
+	fmt.Fprintf(&b, "| Mean edit distance | %.1f chars |\n", s.MeanDistance)
+	fmt.Fprintf(&b, "| Mean relative distance | %.3f |\n", s.MeanRelative)
+	fmt.Fprintf(&b, "| Latency mean / p50 / p90 / max | %.0f / %.0f / %.0f / %.0f ms |\n",
+		s.MeanLatencyMs, s.P50LatencyMs, s.P90LatencyMs, s.MaxLatencyMs)
+
+	var misses []CaseResult
+	for _, c := range r.Cases {
+		if !c.Exact {
+			misses = append(misses, c)
+		}
+	}
+	if len(misses) > 0 {
+		b.WriteString("\n## Cases without an exact match\n\n")
+		b.WriteString("| Case | Outcome | Distance | Relative | Latency |\n|---|---|---|---|---|\n")
+		for _, c := range misses {
+			fmt.Fprintf(&b, "| %s | %s | %d / %d | %.3f | %.0f ms |\n",
+				markdownCell(c.Name), outcome(c), c.Distance, c.Expected, c.Relative, c.LatencyMs)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func outcome(c CaseResult) string {
+	switch {
+	case c.Error != "":
+		return "error: " + markdownCell(c.Error)
+	case c.NoOp:
+		return "no-op"
+	default:
+		return "different edit"
+	}
+}
+
+// markdownCell keeps text on one table row.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,258 @@
+// Package ignore implements .cursortabignore files, which use gitignore
+// syntax to keep files from ever being sent to a provider.
+package ignore
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cursortab/utils"
+)
+
+// FileName is the name of per-directory ignore files.
+const FileName = ".cursortabignore"
+
+// rule is a single compiled ignore pattern.
+type rule struct {
+	segments []string // pattern split on "/"
+	negate   bool     // "!pattern" re-includes
+	dirOnly  bool     // "pattern/" only matches directories
+	anchored bool     // pattern contains a non-trailing "/"
+}
+
+// ruleSet is the parsed content of one ignore file. base is the directory
+// the file lives in, relative to the workspace root ("" for the root).
+type ruleSet struct {
+	base    string
+	rules   []rule
+	modTime time.Time
+}
+
+// Matcher decides whether a path is ignored. It reads the global ignore
+// file, the workspace root .cursortabignore and any nested .cursortabignore
+// in the directories leading to a file. Files are re-read when they change.
+// Safe for concurrent use.
+type Matcher struct {
+	root       string
+	globalPath string
+
+	mu    sync.Mutex
+	cache map[string]*ruleSet // keyed by ignore file path
+}
+
+// New returns a Matcher for the workspace at root. globalPath may be empty.
+func New(root, globalPath string) *Matcher {
+	return &Matcher{
+		root:       filepath.Clean(root),
+		globalPath: utils.ExpandHome(globalPath),
+		cache:      make(map[string]*ruleSet),
+	}
+}
+
+// Ignored reports whether filePath (relative to the workspace root, or
+// absolute) is ignored. A file is ignored when it or any of its parent
+// directories matches, and as in git a file cannot be re-included when a
+// parent directory is ignored.
+func (m *Matcher) Ignored(filePath string) bool {
+	if m == nil || filePath == "" {
+		return false
+	}
+
+	rel, inside := m.relative(filePath)
+	parts := strings.Split(rel, "/")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sets := m.ruleSetsFor(parts, inside)
+	for i := range parts {
+		isDir := i < len(parts)-1
+		if m.match(sets, strings.Join(parts[:i+1], "/"), isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// relative converts filePath to a slash-separated path relative to the root.
+// Paths outside the workspace are returned without their leading slash and
+// only the global rules apply to them.
+func (m *Matcher) relative(filePath string) (string, bool) {
+	if !filepath.IsAbs(filePath) {
+		return filepath.ToSlash(filepath.Clean(filePath)), true
+	}
+	rel, err := filepath.Rel(m.root, filePath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return strings.TrimPrefix(filepath.ToSlash(filePath), "/"), false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// ruleSetsFor returns the rule sets that apply to a path, lowest priority
+// first: global, workspace root, then each nested directory.
+// Caller must hold m.mu.
+func (m *Matcher) ruleSetsFor(parts []string, inside bool) []*ruleSet {
+	var sets []*ruleSet
+	if m.globalPath != "" {
+		if set := m.load(m.globalPath, ""); set != nil {
+			sets = append(sets, set)
+		}
+	}
+	if !inside {
+		return sets
+	}
+	for i := 0; i < len(parts); i++ {
+		base := strings.Join(parts[:i], "/")
+		file := filepath.Join(m.root, filepath.FromSlash(base), FileName)
+		if set := m.load(file, base); set != nil {
+			sets = append(sets, set)
+		}
+	}
+	return sets
+}
+
+// match applies rule sets in priority order; the last matching rule wins.
+// Caller must hold m.mu.
+func (m *Matcher) match(sets []*ruleSet, rel string, isDir bool) bool {
+	ignored := false
+	for _, set := range sets {
+		local := rel
+		if set.base != "" {
+			var ok bool
+			if local, ok = strings.CutPrefix(rel, set.base+"/"); !ok {
+				continue
+			}
+		}
+		for _, r := range set.rules {
+			if r.matches(local, isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// load returns the cached rules for an ignore file, re-reading it when its
+// modification time changes. Returns nil when the file does not exist.
+// Caller must hold m.mu.
+func (m *Matcher) load(file, base string) *ruleSet {
+	info, err := os.Stat(file)
+	if err != nil {
+		delete(m.cache, file)
+		return nil
+	}
+	if set, ok := m.cache[file]; ok && set.modTime.Equal(info.ModTime()) {
+		return set
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	set := &ruleSet{base: base, rules: parse(f), modTime: info.ModTime()}
+	m.cache[file] = set
+	return set
+}
+
+// parse reads gitignore-syntax patterns from r.
+func parse(r io.Reader) []rule {
+	var rules []rule
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if rl, ok := parseLine(scanner.Text()); ok {
+			rules = append(rules, rl)
+		}
+	}
+	return rules
+}
+
+func parseLine(line string) (rule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are ignored unless escaped with a backslash
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	var r rule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	line = strings.ReplaceAll(line, "\\ ", " ")
+
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+	r.segments = strings.Split(line, "/")
+	return r, true
+}
+
+// matches reports whether the rule matches rel, a path relative to the
+// directory of the ignore file that defined the rule.
+func (r rule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], path.Base(rel))
+		return ok
+	}
+	return matchSegments(r.segments, strings.Split(rel, "/"))
+}
+
+// MatchGlob matches a glob against a file path using ignore file rules:
+// patterns without a slash match the base name at any depth, other patterns
+// match the whole path, with "**" matching any number of directories.
+func MatchGlob(pattern, filePath string) bool {
+	filePath = filepath.ToSlash(filePath)
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(filePath))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(filePath, "/"))
+}
+
+// matchSegments matches pattern segments against path segments, with "**"
+// matching zero or more whole segments.
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(rest, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
@@ -0,0 +1,153 @@
+package ignore
+
+import (
+	"cursortab/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.lock", "Cargo.lock", true},
+		{"*.lock", "deps/sub/yarn.lock", true},
+		{"*.lock", "lock.go", false},
+		{"vendor/**", "vendor/a/b.go", true},
+		{"vendor/**", "src/vendor/b.go", false},
+		{"**/generated/*.go", "generated/x.go", true},
+		{"**/generated/*.go", "a/b/generated/x.go", true},
+		{"**/generated/*.go", "a/generated/sub/x.go", false},
+		{"src/*.go", "src/main.go", true},
+		{"src/*.go", "src/pkg/main.go", false},
+		{"**/node_modules/**", "/home/u/proj/node_modules/x/index.js", true},
+		{"src/**/test_?.py", "src/a/b/test_1.py", true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MatchGlob(tt.pattern, tt.path), tt.pattern+" vs "+tt.path)
+	}
+}
+
+func TestIgnored_RootFile(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, FileName), "# secrets\n.env*\n*.pem\nsecrets/\n!.env.example\n/build\n")
+	m := New(root, "")
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{".env", true},
+		{"config/.env.local", true},
+		{".env.example", false},
+		{"certs/server.pem", true},
+		{"secrets/token.txt", true},
+		{"app/secrets/token.txt", true},
+		{"secrets", false}, // dir-only pattern does not match a file named secrets
+		{"build/out.js", true},
+		{"src/build/out.js", false}, // anchored to the root
+		{"main.go", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, m.Ignored(tt.path), tt.path)
+	}
+}
+
+func TestIgnored_NestedFileTakesPrecedence(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, FileName), "*.json\n")
+	writeFile(t, filepath.Join(root, "web", FileName), "!package.json\nfixtures/\n")
+	m := New(root, "")
+
+	assert.True(t, m.Ignored("data.json"), "root rule")
+	assert.True(t, m.Ignored("api/package.json"), "nested file does not apply outside its directory")
+	assert.False(t, m.Ignored("web/package.json"), "nested negation re-includes")
+	assert.True(t, m.Ignored("web/fixtures/a.txt"), "nested rule")
+	assert.False(t, m.Ignored("fixtures/a.txt"), "nested rule scoped to its directory")
+}
+
+func TestIgnored_ParentDirectoryCannotBeReincluded(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, FileName), "private/\n!private/keep.txt\n")
+	m := New(root, "")
+
+	assert.True(t, m.Ignored("private/keep.txt"), "file under ignored directory stays ignored")
+}
+
+func TestIgnored_GlobalFile(t *testing.T) {
+	root := t.TempDir()
+	global := filepath.Join(t.TempDir(), "ignore")
+	writeFile(t, global, "*.key\n")
+	writeFile(t, filepath.Join(root, FileName), "!public.key\n")
+	m := New(root, global)
+
+	assert.True(t, m.Ignored("id.key"), "global rule")
+	assert.False(t, m.Ignored("public.key"), "workspace file overrides global")
+	assert.True(t, m.Ignored("/etc/ssl/host.key"), "global rules apply outside the workspace")
+	assert.False(t, m.Ignored("/etc/hosts"), "unmatched file outside the workspace")
+}
+
+func TestIgnored_AbsolutePathInsideWorkspace(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, FileName), "/secrets.txt\n")
+	m := New(root, "")
+
+	assert.True(t, m.Ignored(filepath.Join(root, "secrets.txt")), "absolute path resolved against root")
+}
+
+func TestIgnored_ReloadsChangedFile(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, FileName)
+	m := New(root, "")
+
+	assert.False(t, m.Ignored("notes.md"), "no ignore file yet")
+
+	writeFile(t, file, "*.md\n")
+	assert.True(t, m.Ignored("notes.md"), "new ignore file picked up")
+
+	writeFile(t, file, "*.txt\n")
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, m.Ignored("notes.md"), "changed ignore file reloaded")
+
+	os.Remove(file)
+	assert.False(t, m.Ignored("notes.txt"), "removed ignore file dropped")
+}
+
+func TestParseLine(t *testing.T) {
+	_, ok := parseLine("# comment")
+	assert.False(t, ok, "comment")
+	_, ok = parseLine("   ")
+	assert.False(t, ok, "blank")
+
+	r, ok := parseLine(`\#hash`)
+	assert.True(t, ok, "escaped hash")
+	assert.Equal(t, "#hash", r.segments[0], "escape removed")
+
+	r, _ = parseLine("trailing   ")
+	assert.Equal(t, "trailing", r.segments[0], "trailing spaces trimmed")
+
+	r, _ = parseLine("docs/")
+	assert.True(t, r.dirOnly, "trailing slash is dir-only")
+	assert.False(t, r.anchored, "trailing slash alone does not anchor")
+
+	var nilMatcher *Matcher
+	assert.False(t, nilMatcher.Ignored("a"), "nil matcher ignores nothing")
+}
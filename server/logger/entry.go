@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Entry logs with a component and request ID attached to every line. The
+// zero Entry logs like the package-level functions.
+type Entry struct {
+	component string
+	requestID string
+}
+
+// With returns an Entry for component ("" = derived from the caller's
+// package in JSON output) and requestID ("" = none)
+func With(component, requestID string) Entry {
+	return Entry{component: component, requestID: requestID}
+}
+
+// RequestID returns the entry's request ID
+func (e Entry) RequestID() string {
+	return e.requestID
+}
+
+// Debug logs a debug message
+func (e Entry) Debug(format string, v ...any) {
+	current().log(e, LogLevelDebug, format, v...)
+}
+
+// Info logs an info message
+func (e Entry) Info(format string, v ...any) {
+	current().log(e, LogLevelInfo, format, v...)
+}
+
+// Warn logs a warning message
+func (e Entry) Warn(format string, v ...any) {
+	current().log(e, LogLevelWarn, format, v...)
+}
+
+// Error logs an error message
+func (e Entry) Error(format string, v ...any) {
+	current().log(e, LogLevelError, format, v...)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the completion request ID, so
+// code below the engine logs under the same ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the request ID carried by ctx, or ""
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext returns an Entry for component with the request ID carried
+// by ctx
+func FromContext(ctx context.Context, component string) Entry {
+	return With(component, RequestIDFrom(ctx))
+}
+
+// NewRequestID returns a short random ID for correlating the log lines of
+// one completion request
+func NewRequestID() string {
+	var b [6]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
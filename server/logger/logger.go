@@ -1,9 +1,11 @@
 package logger
 
 import (
-	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -21,19 +23,21 @@ func Trace(name string) func() {
 	}
 	start := time.Now()
 	return func() {
-		globalLogger.logWithLevel(LogLevelTrace, "%s: %v", name, time.Since(start))
+		globalLogger.log(Entry{}, LogLevelTrace, "%s: %v", name, time.Since(start))
 	}
 }
 
 // defaultLogger is used before the global logger is initialized
 var defaultLogger = &LimitedLogger{
-	file:      os.Stderr,
-	lineCount: 0,
-	level:     LogLevelInfo,
+	file:  os.Stderr,
+	level: LogLevelInfo,
 }
 
-// MaxLogLines defines the maximum number of lines to keep in the log file
-const MaxLogLines = 50000
+// Defaults for Options fields left at zero
+const (
+	DefaultMaxSize  = 10 << 20 // bytes
+	DefaultMaxFiles = 3
+)
 
 // LogLevel represents the logging level
 type LogLevel int
@@ -82,29 +86,86 @@ func ParseLogLevel(s string) LogLevel {
 	}
 }
 
-// LimitedLogger wraps the standard log.Logger with line count limiting and log levels
+// Format selects how log lines are written
+type Format int
+
+const (
+	// FormatText writes "2006/01/02 15:04:05 [LEVEL] message" lines
+	FormatText Format = iota
+	// FormatJSON writes one JSON object per line
+	FormatJSON
+)
+
+// ParseFormat parses "text" or "json" into a Format
+func ParseFormat(s string) Format {
+	if strings.EqualFold(s, "json") {
+		return FormatJSON
+	}
+	return FormatText
+}
+
+// Options configures a LimitedLogger
+type Options struct {
+	Level    LogLevel
+	Format   Format
+	MaxSize  int64 // rotate when the file would grow past this many bytes (0 = DefaultMaxSize)
+	MaxFiles int   // rotated files kept next to the log as path.1 .. path.N (0 = DefaultMaxFiles)
+}
+
+// LimitedLogger writes leveled log lines to a file, rotating it by size
 type LimitedLogger struct {
-	file      *os.File
-	lineCount int
-	level     LogLevel
-	mutex     sync.Mutex
+	path     string // "" = not rotated
+	file     *os.File
+	size     int64
+	level    LogLevel
+	format   Format
+	maxSize  int64
+	maxFiles int
+	mutex    sync.Mutex
 }
 
 // Global logger instance
 var globalLogger *LimitedLogger
 
-// NewLimitedLogger creates a new LimitedLogger
-func NewLimitedLogger(file *os.File, level LogLevel) *LimitedLogger {
-	ll := &LimitedLogger{
-		file:      file,
-		lineCount: 0,
-		level:     level,
+// Open opens (or creates) the log file at path for appending and makes it
+// the global logger.
+func Open(path string, opts Options) (*LimitedLogger, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
 	}
-
-	// Count existing lines in the file
-	ll.countExistingLines()
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ll := &LimitedLogger{path: path, file: f, size: info.Size()}
+	ll.Configure(opts)
 	globalLogger = ll
-	return ll
+	return ll, nil
+}
+
+// Configure replaces the level, format and rotation settings
+func (ll *LimitedLogger) Configure(opts Options) {
+	ll.mutex.Lock()
+	defer ll.mutex.Unlock()
+	ll.level = opts.Level
+	ll.format = opts.Format
+	ll.maxSize = opts.MaxSize
+	if ll.maxSize <= 0 {
+		ll.maxSize = DefaultMaxSize
+	}
+	ll.maxFiles = opts.MaxFiles
+	if ll.maxFiles <= 0 {
+		ll.maxFiles = DefaultMaxFiles
+	}
+}
+
+// Configure applies opts to the global logger
+func Configure(opts Options) {
+	if globalLogger != nil {
+		globalLogger.Configure(opts)
+	}
 }
 
 // SetLevel sets the logging level
@@ -126,101 +187,104 @@ func (ll *LimitedLogger) shouldLog(level LogLevel) bool {
 	return level >= ll.level
 }
 
-// logWithLevel logs a message at the specified level
-func (ll *LimitedLogger) logWithLevel(level LogLevel, format string, v ...any) {
+// jsonLine is the shape of a FormatJSON log line
+type jsonLine struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Component string `json:"component,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Msg       string `json:"msg"`
+}
+
+// log formats a message with the entry's fields and writes it
+func (ll *LimitedLogger) log(entry Entry, level LogLevel, format string, v ...any) {
 	if !ll.shouldLog(level) {
 		return
 	}
-	// Format with timestamp and write through Write() for proper line counting/rotation
-	msg := fmt.Sprintf("%s [%s] %s\n", time.Now().Format("2006/01/02 15:04:05"), level.String(), fmt.Sprintf(format, v...))
-	ll.Write([]byte(msg))
+	now := time.Now()
+	msg := fmt.Sprintf(format, v...)
+
+	var line []byte
+	if ll.format == FormatJSON {
+		if entry.component == "" {
+			entry.component = callerComponent()
+		}
+		line, _ = json.Marshal(jsonLine{
+			Time:      now.Format(time.RFC3339Nano),
+			Level:     strings.ToLower(level.String()),
+			Component: entry.component,
+			RequestID: entry.requestID,
+			Msg:       msg,
+		})
+		line = append(line, '\n')
+	} else {
+		var fields string
+		switch {
+		case entry.component != "" && entry.requestID != "":
+			fields = fmt.Sprintf("[%s req=%s] ", entry.component, entry.requestID)
+		case entry.component != "":
+			fields = fmt.Sprintf("[%s] ", entry.component)
+		case entry.requestID != "":
+			fields = fmt.Sprintf("[req=%s] ", entry.requestID)
+		}
+		line = fmt.Appendf(nil, "%s [%s] %s%s\n", now.Format("2006/01/02 15:04:05"), level.String(), fields, msg)
+	}
+	ll.Write(line)
 }
 
 // Debug logs a debug message
 func (ll *LimitedLogger) Debug(format string, v ...any) {
-	ll.logWithLevel(LogLevelDebug, format, v...)
+	ll.log(Entry{}, LogLevelDebug, format, v...)
 }
 
 // Info logs an info message
 func (ll *LimitedLogger) Info(format string, v ...any) {
-	ll.logWithLevel(LogLevelInfo, format, v...)
+	ll.log(Entry{}, LogLevelInfo, format, v...)
 }
 
 // Warn logs a warning message
 func (ll *LimitedLogger) Warn(format string, v ...any) {
-	ll.logWithLevel(LogLevelWarn, format, v...)
+	ll.log(Entry{}, LogLevelWarn, format, v...)
 }
 
 // Error logs an error message
 func (ll *LimitedLogger) Error(format string, v ...any) {
-	ll.logWithLevel(LogLevelError, format, v...)
+	ll.log(Entry{}, LogLevelError, format, v...)
 }
 
 // Fatal logs an error message and exits with code 1
 func (ll *LimitedLogger) Fatal(format string, v ...any) {
-	ll.logWithLevel(LogLevelError, format, v...)
+	ll.log(Entry{}, LogLevelError, format, v...)
 	os.Exit(1)
 }
 
-// Package-level logging functions that use the global logger (or default if not initialized)
-func Debug(format string, v ...any) {
+// current returns the global logger, or the stderr logger before one is opened
+func current() *LimitedLogger {
 	if globalLogger != nil {
-		globalLogger.Debug(format, v...)
-	} else {
-		defaultLogger.Debug(format, v...)
+		return globalLogger
 	}
+	return defaultLogger
+}
+
+// Package-level logging functions that use the global logger (or default if not initialized)
+func Debug(format string, v ...any) {
+	current().log(Entry{}, LogLevelDebug, format, v...)
 }
 
 func Info(format string, v ...any) {
-	if globalLogger != nil {
-		globalLogger.Info(format, v...)
-	} else {
-		defaultLogger.Info(format, v...)
-	}
+	current().log(Entry{}, LogLevelInfo, format, v...)
 }
 
 func Warn(format string, v ...any) {
-	if globalLogger != nil {
-		globalLogger.Warn(format, v...)
-	} else {
-		defaultLogger.Warn(format, v...)
-	}
+	current().log(Entry{}, LogLevelWarn, format, v...)
 }
 
 func Error(format string, v ...any) {
-	if globalLogger != nil {
-		globalLogger.Error(format, v...)
-	} else {
-		defaultLogger.Error(format, v...)
-	}
+	current().log(Entry{}, LogLevelError, format, v...)
 }
 
 func Fatal(format string, v ...any) {
-	if globalLogger != nil {
-		globalLogger.Fatal(format, v...)
-	} else {
-		defaultLogger.Fatal(format, v...)
-	}
-}
-
-// countExistingLines counts the number of lines in the current log file
-func (ll *LimitedLogger) countExistingLines() {
-	ll.mutex.Lock()
-	defer ll.mutex.Unlock()
-
-	// Seek to beginning of file
-	ll.file.Seek(0, 0)
-	scanner := bufio.NewScanner(ll.file)
-
-	count := 0
-	for scanner.Scan() {
-		count++
-	}
-
-	ll.lineCount = count
-
-	// Seek back to end of file for appending
-	ll.file.Seek(0, 2)
+	current().Fatal(format, v...)
 }
 
 // Write implements io.Writer interface
@@ -228,52 +292,68 @@ func (ll *LimitedLogger) Write(p []byte) (n int, err error) {
 	ll.mutex.Lock()
 	defer ll.mutex.Unlock()
 
-	// Write to file
-	n, err = ll.file.Write(p)
-	if err != nil {
-		return n, err
-	}
-
-	// Count newlines in the written data
-	newlines := strings.Count(string(p), "\n")
-	ll.lineCount += newlines
-
-	// Check if we need to rotate the log file
-	if ll.lineCount > MaxLogLines {
-		ll.rotateLogFile()
+	if ll.path != "" && ll.size > 0 && ll.size+int64(len(p)) > ll.maxSize {
+		if err := ll.rotate(); err != nil {
+			return 0, err
+		}
 	}
 
+	n, err = ll.file.Write(p)
+	ll.size += int64(n)
 	return n, err
 }
 
-// rotateLogFile trims the log file to keep only the last MaxLogLines lines
-func (ll *LimitedLogger) rotateLogFile() {
-	// Read all lines from the file
-	ll.file.Seek(0, 0)
-	scanner := bufio.NewScanner(ll.file)
-	var lines []string
-
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-
-	// Keep only the last MaxLogLines
-	if len(lines) > MaxLogLines {
-		lines = lines[len(lines)-MaxLogLines:]
+// rotate shifts path.i to path.i+1, moves the current file to path.1,
+// drops files past maxFiles and starts an empty file at path
+func (ll *LimitedLogger) rotate() error {
+	ll.file.Close()
+	os.Remove(fmt.Sprintf("%s.%d", ll.path, ll.maxFiles))
+	for i := ll.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", ll.path, i), fmt.Sprintf("%s.%d", ll.path, i+1))
 	}
+	os.Rename(ll.path, ll.path+".1")
 
-	// Truncate and rewrite the file
-	ll.file.Truncate(0)
-	ll.file.Seek(0, 0)
-
-	for _, line := range lines {
-		ll.file.WriteString(line + "\n")
+	f, err := os.OpenFile(ll.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0666)
+	if err != nil {
+		// Keep writing somewhere rather than dropping every later line
+		ll.file = os.Stderr
+		ll.path = ""
+		return err
 	}
-
-	ll.lineCount = len(lines)
+	ll.file = f
+	ll.size = 0
+	return nil
 }
 
 // Close closes the underlying file
 func (ll *LimitedLogger) Close() error {
+	ll.mutex.Lock()
+	defer ll.mutex.Unlock()
 	return ll.file.Close()
 }
+
+// loggerDir is this package's source directory, used to skip its own
+// frames when looking for the caller
+var loggerDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// callerComponent names the package that called into the logger, such as
+// "engine" for cursortab/engine
+func callerComponent() string {
+	pcs := make([]uintptr, 8)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if filepath.Dir(frame.File) != loggerDir || strings.HasSuffix(frame.File, "_test.go") {
+			name := frame.Function[strings.LastIndex(frame.Function, "/")+1:]
+			pkg, _, _ := strings.Cut(name, ".")
+			return pkg
+		}
+		if !more {
+			return ""
+		}
+	}
+}
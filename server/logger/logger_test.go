@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"context"
+	"cursortab/assert"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openTestLogger(t *testing.T, opts Options) (*LimitedLogger, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cursortab.log")
+	ll, err := Open(path, opts)
+	assert.NoError(t, err, "Open")
+	t.Cleanup(func() {
+		ll.Close()
+		globalLogger = nil
+	})
+	return ll, path
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err, "read log")
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestText_Fields(t *testing.T) {
+	_, path := openTestLogger(t, Options{Level: LogLevelInfo})
+
+	Info("plain %d", 1)
+	With("engine", "abc123").Info("requested")
+	With("", "abc123").Warn("no component")
+	Debug("dropped")
+
+	lines := readLines(t, path)
+	assert.Len(t, 3, lines, "debug filtered")
+	assert.True(t, strings.HasSuffix(lines[0], " [INFO] plain 1"), "no fields: "+lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], " [INFO] [engine req=abc123] requested"), "fields: "+lines[1])
+	assert.True(t, strings.HasSuffix(lines[2], " [WARN] [req=abc123] no component"), "request only: "+lines[2])
+}
+
+func TestJSON_Fields(t *testing.T) {
+	_, path := openTestLogger(t, Options{Level: LogLevelDebug, Format: FormatJSON})
+
+	ctx := WithRequestID(context.Background(), "req1")
+	FromContext(ctx, "sweep").Debug("sent %s", "body")
+	Error("no fields")
+
+	lines := readLines(t, path)
+	assert.Len(t, 2, lines, "two lines")
+
+	var line jsonLine
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &line), "decode")
+	assert.Equal(t, "debug", line.Level, "level")
+	assert.Equal(t, "sweep", line.Component, "component")
+	assert.Equal(t, "req1", line.RequestID, "request id")
+	assert.Equal(t, "sent body", line.Msg, "message")
+	assert.NotEqual(t, "", line.Time, "time")
+
+	line = jsonLine{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &line), "decode")
+	assert.Equal(t, "logger", line.Component, "component from the calling package")
+	assert.Equal(t, "", line.RequestID, "no request id")
+	assert.NotContains(t, lines[1], "request_id", "request_id omitted")
+}
+
+func TestRotate_BySize(t *testing.T) {
+	ll, path := openTestLogger(t, Options{Level: LogLevelInfo, MaxSize: 100, MaxFiles: 2})
+
+	line := strings.Repeat("x", 39) + "\n" // 40 bytes
+	for range 7 {
+		ll.Write([]byte(line))
+	}
+
+	// 2 lines per file: current holds the 7th, .1 the 5th and 6th, .2 the
+	// 3rd and 4th, and the first file was dropped
+	assert.Len(t, 1, readLines(t, path), "current file")
+	assert.Len(t, 2, readLines(t, path+".1"), "newest rotated file")
+	assert.Len(t, 2, readLines(t, path+".2"), "oldest rotated file")
+	_, err := os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err), "files past MaxFiles dropped")
+}
+
+func TestOpen_CountsExistingSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cursortab.log")
+	assert.NoError(t, os.WriteFile(path, []byte(strings.Repeat("x", 90)+"\n"), 0o644), "seed")
+
+	ll, err := Open(path, Options{MaxSize: 100, MaxFiles: 1})
+	assert.NoError(t, err, "Open")
+	defer func() {
+		ll.Close()
+		globalLogger = nil
+	}()
+
+	ll.Write([]byte("0123456789\n"))
+	assert.Len(t, 1, readLines(t, path), "rotated before exceeding the limit")
+	assert.Len(t, 1, readLines(t, path+".1"), "existing content kept")
+}
+
+func TestRequestID(t *testing.T) {
+	assert.Equal(t, "", RequestIDFrom(context.Background()), "none by default")
+	id := NewRequestID()
+	assert.Equal(t, 12, len(id), "hex id")
+	assert.NotEqual(t, id, NewRequestID(), "ids differ")
+	assert.Equal(t, id, FromContext(WithRequestID(context.Background(), id), "engine").RequestID(), "carried by the context")
+}
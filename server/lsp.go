@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"cursortab/engine"
+	"cursortab/logger"
+	"cursortab/lsp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runLSP implements the "--lsp" mode, a language server on stdin and
+// stdout offering the engine's suggestions to any LSP client. Settings
+// come from the defaults, the --config file and then the client's
+// initializationOptions, all in the shape of the setup() options.
+// Returns the process exit code.
+func runLSP(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "", "JSON file with settings, in the shape of the setup() options")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cursortab --lsp [--config file]")
+		fmt.Fprintln(stderr, "Serve completions and next edits over the Language Server Protocol on stdio.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	base, err := loadConfigFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab lsp: %v\n", err)
+		return 1
+	}
+
+	// stdout carries the protocol, so logs only go to the log file
+	ll := setupLogger(base.logOptions())
+	defer ll.Close()
+
+	factory := func(root string, options json.RawMessage, buf engine.Buffer) (*lsp.Session, error) {
+		config := base
+		if len(options) > 0 && string(options) != "null" {
+			if err := json.Unmarshal(options, &config); err != nil {
+				return nil, fmt.Errorf("initializationOptions: %w", err)
+			}
+		}
+		if err := config.Validate(); err != nil {
+			return nil, err
+		}
+		logger.Configure(config.logOptions())
+
+		// The engine takes the workspace from the working directory
+		if root != "" {
+			if err := os.Chdir(root); err != nil {
+				return nil, err
+			}
+		}
+		prov, providerConfig, err := newProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		engineConfig := newEngineConfig(config, providerConfig)
+		eng, err := engine.NewEngine(prov, buf, engineConfig, engine.SystemClock)
+		if err != nil {
+			return nil, err
+		}
+
+		debounce := engineConfig.TextChangeDebounce
+		if engineConfig.AdaptiveDebounce.Enabled {
+			debounce = max(debounce, engineConfig.AdaptiveDebounce.MaxDelay)
+		}
+		return &lsp.Session{
+			Engine:            eng,
+			CompletionTimeout: engineConfig.CompletionTimeout,
+			Debounce:          debounce,
+		}, nil
+	}
+
+	if err := lsp.Serve(context.Background(), stdin, stdout, factory); err != nil {
+		logger.Error("lsp: %v", err)
+		return 1
+	}
+	return 0
+}
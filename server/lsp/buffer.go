@@ -0,0 +1,141 @@
+package lsp
+
+import (
+	"slices"
+
+	"cursortab/buffer"
+	"cursortab/engine"
+	"cursortab/text"
+)
+
+// Notification levels passed to Buffer.Notify (vim.log.levels)
+const (
+	levelWarn  = 3
+	levelError = 4
+)
+
+// Message types of window/showMessage
+const (
+	messageError   = 1
+	messageWarning = 2
+	messageInfo    = 3
+)
+
+// documentBuffer is the engine.Buffer of a language server session. The
+// embedded MemoryBuffer holds the active document; UI calls update the
+// suggestion returned to the client instead of drawing anything.
+type documentBuffer struct {
+	*buffer.MemoryBuffer
+	server *Server
+}
+
+var _ engine.Buffer = (*documentBuffer)(nil)
+
+func newDocumentBuffer(s *Server) *documentBuffer {
+	mem := buffer.NewMemory("", "", nil)
+	mem.DiscardUICalls()
+	return &documentBuffer{MemoryBuffer: mem, server: s}
+}
+
+// PrepareCompletion makes the completion the current suggestion.
+func (b *documentBuffer) PrepareCompletion(startLine, endLineInc int, lines []string, groups []*text.Group) buffer.Batch {
+	expected := text.SpliceLines(b.Lines(), startLine, endLineInc, lines)
+	batch := &documentBatch{
+		inner:      b.MemoryBuffer.PrepareCompletion(startLine, endLineInc, lines, groups),
+		buf:        b,
+		startLine:  startLine,
+		endLineInc: endLineInc,
+		lines:      slices.Clone(lines),
+		expected:   expected,
+	}
+
+	s := b.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batch.path = s.active
+	s.setCurrentLocked(suggestion{
+		path:       s.active,
+		edit:       true,
+		startLine:  startLine,
+		endLineInc: endLineInc,
+		lines:      batch.lines,
+	})
+	return batch
+}
+
+// ShowCursorTarget adds the jump to the current suggestion.
+func (b *documentBuffer) ShowCursorTarget(line int) error {
+	s := b.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.current
+	current.path, current.target = s.active, line
+	s.setCurrentLocked(current)
+	return nil
+}
+
+func (b *documentBuffer) ClearUI() error {
+	b.MemoryBuffer.ClearUI()
+
+	s := b.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.current.empty() {
+		s.setCurrentLocked(suggestion{})
+	}
+	return nil
+}
+
+// MoveCursor moves the client's cursor when it supports
+// window/showDocument.
+func (b *documentBuffer) MoveCursor(line int, center, mark bool) error {
+	b.MemoryBuffer.MoveCursor(line, center, mark)
+
+	s := b.server
+	s.mu.Lock()
+	doc, supported := s.docs[s.active], s.showDocument
+	s.mu.Unlock()
+	if doc != nil && supported {
+		s.showPosition(doc.uri, s.firstNonBlank(doc.lines, line))
+	}
+	return nil
+}
+
+// Notify shows the message with window/showMessage.
+func (b *documentBuffer) Notify(message string, level int) error {
+	kind := messageInfo
+	switch {
+	case level >= levelError:
+		kind = messageError
+	case level == levelWarn:
+		kind = messageWarning
+	}
+	return b.server.conn.notify("window/showMessage", showMessageParams{Type: kind, Message: "cursortab: " + message})
+}
+
+// documentBatch applies an accepted completion. Clients that already
+// inserted the text (inline completions) are left alone; the others get a
+// workspace/applyEdit request.
+type documentBatch struct {
+	inner      buffer.Batch
+	buf        *documentBuffer
+	path       string
+	startLine  int
+	endLineInc int
+	lines      []string
+	expected   []string // document text after the edit
+}
+
+func (db *documentBatch) Execute() error {
+	s := db.buf.server
+	s.mu.Lock()
+	if doc := s.docs[db.path]; doc != nil && !slices.Equal(doc.lines, db.expected) {
+		rng, newText := editRange(doc.lines, db.startLine, db.endLineInc, db.lines, s.encoding)
+		doc.lines = text.SpliceLines(doc.lines, db.startLine, db.endLineInc, db.lines)
+		s.applyEdit(doc.uri, rng, newText)
+	}
+	s.setCurrentLocked(suggestion{})
+	s.mu.Unlock()
+
+	return db.inner.Execute()
+}
@@ -0,0 +1,136 @@
+package lsp
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Position encodings a client can negotiate
+const (
+	encodingUTF8  = "utf-8"
+	encodingUTF16 = "utf-16"
+)
+
+// uriToPath returns the file path of a file:// URI. Other URIs are
+// returned unchanged so they still identify the document.
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// pathToURI is the inverse of uriToPath.
+func pathToURI(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// byteCol converts a character offset in line to a byte offset, clamped
+// to the line length.
+func byteCol(line string, character int, encoding string) int {
+	if encoding == encodingUTF8 {
+		return min(max(character, 0), len(line))
+	}
+	units := 0
+	for i, r := range line {
+		if units >= character {
+			return i
+		}
+		units += utf16Len(r)
+	}
+	return len(line)
+}
+
+// character converts a byte offset in line to a character offset.
+func character(line string, col int, encoding string) int {
+	col = min(max(col, 0), len(line))
+	if encoding == encodingUTF8 {
+		return col
+	}
+	units := 0
+	for _, r := range line[:col] {
+		units += utf16Len(r)
+	}
+	return units
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
+
+// splitText splits document text into lines. A trailing newline does not
+// start another line, matching how Neovim buffers hold files.
+func splitText(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	return strings.Split(text, "\n")
+}
+
+// applyChange applies an incremental content change to lines.
+func applyChange(lines []string, r Range, text, encoding string) []string {
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	pos := func(p Position) (int, int) {
+		if p.Line >= len(lines) {
+			return len(lines) - 1, len(lines[len(lines)-1])
+		}
+		line := max(p.Line, 0)
+		return line, byteCol(lines[line], p.Character, encoding)
+	}
+	startLine, startCol := pos(r.Start)
+	endLine, endCol := pos(r.End)
+	merged := lines[startLine][:startCol] + strings.ReplaceAll(text, "\r\n", "\n") + lines[endLine][endCol:]
+
+	result := make([]string, 0, len(lines))
+	result = append(result, lines[:startLine]...)
+	result = append(result, strings.Split(merged, "\n")...)
+	return append(result, lines[endLine+1:]...)
+}
+
+// editRange returns the range and text of an edit replacing lines
+// startLine..endLineInc (1-indexed) of lines with replacement.
+func editRange(lines []string, startLine, endLineInc int, replacement []string, encoding string) (Range, string) {
+	at := func(line, col int) Position {
+		return Position{Line: line, Character: character(lines[line], col, encoding)}
+	}
+	end := func() Position {
+		last := len(lines) - 1
+		return at(last, len(lines[last]))
+	}
+	joined := strings.Join(replacement, "\n")
+	start := max(startLine-1, 0)
+
+	switch {
+	case len(lines) == 0:
+		return Range{}, joined
+	case start >= len(lines):
+		// Lines appended after the end of the file
+		return Range{Start: end(), End: end()}, "\n" + joined
+	case endLineInc < startLine:
+		// Lines inserted before startLine
+		return Range{Start: at(start, 0), End: at(start, 0)}, joined + "\n"
+	case len(replacement) == 0:
+		// Lines deleted along with their line breaks
+		endInc := min(endLineInc, len(lines))
+		if endInc < len(lines) {
+			return Range{Start: at(start, 0), End: at(endInc, 0)}, ""
+		}
+		if start == 0 {
+			return Range{Start: at(0, 0), End: end()}, ""
+		}
+		return Range{Start: at(start-1, len(lines[start-1])), End: end()}, ""
+	default:
+		last := min(endLineInc, len(lines)) - 1
+		return Range{Start: at(start, 0), End: at(last, len(lines[last]))}, joined
+	}
+}
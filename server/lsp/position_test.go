@@ -0,0 +1,64 @@
+package lsp
+
+import (
+	"cursortab/assert"
+	"cursortab/text"
+	"testing"
+)
+
+func TestByteCol_Encodings(t *testing.T) {
+	line := "a😀é = 1"
+	assert.Equal(t, 1, byteCol(line, 1, encodingUTF16), "ascii")
+	assert.Equal(t, 5, byteCol(line, 3, encodingUTF16), "surrogate pair counts twice")
+	assert.Equal(t, 7, byteCol(line, 4, encodingUTF16), "two-byte rune")
+	assert.Equal(t, len(line), byteCol(line, 99, encodingUTF16), "clamped to the line")
+	assert.Equal(t, 5, byteCol(line, 5, encodingUTF8), "utf-8 offsets are bytes")
+
+	assert.Equal(t, 3, character(line, 5, encodingUTF16), "inverse of byteCol")
+	assert.Equal(t, 4, character(line, 7, encodingUTF16), "after the two-byte rune")
+	assert.Equal(t, 5, character(line, 5, encodingUTF8), "utf-8 unchanged")
+}
+
+func TestURIToPath_RoundTrip(t *testing.T) {
+	path := uriToPath("file:///home/me/my%20project/main.go")
+	assert.Equal(t, "/home/me/my project/main.go", path, "decoded path")
+	assert.Equal(t, "file:///home/me/my%20project/main.go", pathToURI(path), "encoded again")
+	assert.Equal(t, "untitled:Untitled-1", uriToPath("untitled:Untitled-1"), "other schemes kept")
+}
+
+func TestApplyChange(t *testing.T) {
+	lines := []string{"func a() {", "\treturn 1", "}"}
+	got := applyChange(lines, Range{Start: Position{1, 8}, End: Position{1, 9}}, "2 +\n\t\t3", encodingUTF16)
+	assert.Equal(t, []string{"func a() {", "\treturn 2 +", "\t\t3", "}"}, got, "replace inside a line with a break")
+
+	got = applyChange(lines, Range{Start: Position{0, 10}, End: Position{2, 0}}, "", encodingUTF16)
+	assert.Equal(t, []string{"func a() {}"}, got, "join lines")
+	assert.Equal(t, []string{"func a() {", "\treturn 1", "}"}, lines, "input untouched")
+}
+
+func TestEditRange(t *testing.T) {
+	lines := []string{"a", "bb", "c"}
+	tests := []struct {
+		name       string
+		start, end int
+		lines      []string
+		want       Range
+		text       string
+		result     []string
+	}{
+		{"replace", 2, 2, []string{"BB"}, Range{Position{1, 0}, Position{1, 2}}, "BB", []string{"a", "BB", "c"}},
+		{"replace and grow", 2, 3, []string{"x", "y", "z"}, Range{Position{1, 0}, Position{2, 1}}, "x\ny\nz", []string{"a", "x", "y", "z"}},
+		{"insert", 2, 1, []string{"new"}, Range{Position{1, 0}, Position{1, 0}}, "new\n", []string{"a", "new", "bb", "c"}},
+		{"append", 4, 4, []string{"d"}, Range{Position{2, 1}, Position{2, 1}}, "\nd", []string{"a", "bb", "c", "d"}},
+		{"delete", 2, 2, nil, Range{Position{1, 0}, Position{2, 0}}, "", []string{"a", "c"}},
+		{"delete last", 3, 3, nil, Range{Position{1, 2}, Position{2, 1}}, "", []string{"a", "bb"}},
+	}
+	for _, tt := range tests {
+		rng, newText := editRange(lines, tt.start, tt.end, tt.lines, encodingUTF16)
+		assert.Equal(t, tt.want, rng, tt.name+" range")
+		assert.Equal(t, tt.text, newText, tt.name+" text")
+		applied := applyChange(lines, rng, newText, encodingUTF16)
+		assert.Equal(t, tt.result, applied, tt.name+" applied by a client")
+		assert.Equal(t, tt.result, text.SpliceLines(lines, tt.start, tt.end, tt.lines), tt.name+" applied by the buffer")
+	}
+}
@@ -0,0 +1,176 @@
+package lsp
+
+import "encoding/json"
+
+// The subset of the Language Server Protocol used by the server. Field
+// names follow the specification.
+
+// MethodNextEdit is the custom request returning the engine's next edit
+// and cursor jump, for clients without inline completion support.
+const MethodNextEdit = "cursortab/nextEdit"
+
+// Commands run with workspace/executeCommand
+const (
+	CommandAccept = "cursortab.accept" // accept the current edit, or jump to the cursor target
+	CommandReject = "cursortab.reject" // dismiss the current suggestion
+)
+
+// Position is a zero-based line and character offset, counted in the
+// negotiated position encoding.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type TextDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+type VersionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+}
+
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type initializeParams struct {
+	RootURI               string          `json:"rootUri"`
+	RootPath              string          `json:"rootPath"`
+	InitializationOptions json.RawMessage `json:"initializationOptions"`
+	Capabilities          struct {
+		General struct {
+			PositionEncodings []string `json:"positionEncodings"`
+		} `json:"general"`
+		Window struct {
+			ShowDocument struct {
+				Support bool `json:"support"`
+			} `json:"showDocument"`
+		} `json:"window"`
+	} `json:"capabilities"`
+}
+
+type initializeResult struct {
+	Capabilities serverCapabilities `json:"capabilities"`
+	ServerInfo   serverInfo         `json:"serverInfo"`
+}
+
+type serverCapabilities struct {
+	PositionEncoding         string                `json:"positionEncoding"`
+	TextDocumentSync         textDocumentSync      `json:"textDocumentSync"`
+	InlineCompletionProvider bool                  `json:"inlineCompletionProvider"`
+	ExecuteCommandProvider   executeCommandOptions `json:"executeCommandProvider"`
+}
+
+type textDocumentSync struct {
+	OpenClose bool `json:"openClose"`
+	Change    int  `json:"change"` // 1 = full text
+}
+
+type executeCommandOptions struct {
+	Commands []string `json:"commands"`
+}
+
+type serverInfo struct {
+	Name string `json:"name"`
+}
+
+type didOpenParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+type didChangeParams struct {
+	TextDocument   VersionedTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []contentChange                 `json:"contentChanges"`
+}
+
+// contentChange replaces Range, or the whole text when Range is nil.
+type contentChange struct {
+	Range *Range `json:"range"`
+	Text  string `json:"text"`
+}
+
+type didCloseParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type cancelParams struct {
+	ID json.RawMessage `json:"id"`
+}
+
+type executeCommandParams struct {
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments"`
+}
+
+type Command struct {
+	Title   string `json:"title"`
+	Command string `json:"command"`
+}
+
+// InlineCompletionItem replaces Range with InsertText when accepted.
+type InlineCompletionItem struct {
+	InsertText string   `json:"insertText"`
+	Range      Range    `json:"range"`
+	Command    *Command `json:"command,omitempty"`
+}
+
+type InlineCompletionList struct {
+	Items []InlineCompletionItem `json:"items"`
+}
+
+// TextEdit replaces Range in TextDocument with NewText.
+type TextEdit struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+	NewText      string                 `json:"newText"`
+}
+
+// Jump is a cursor position the engine predicts the user moves to next.
+type Jump struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// NextEditResult answers MethodNextEdit. Either field may be null.
+type NextEditResult struct {
+	Edit *TextEdit `json:"edit"`
+	Jump *Jump     `json:"jump"`
+}
+
+type textEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+type applyEditParams struct {
+	Label string `json:"label"`
+	Edit  struct {
+		Changes map[string][]textEdit `json:"changes"`
+	} `json:"edit"`
+}
+
+type showDocumentParams struct {
+	URI       string `json:"uri"`
+	TakeFocus bool   `json:"takeFocus"`
+	Selection *Range `json:"selection,omitempty"`
+}
+
+type showMessageParams struct {
+	Type    int    `json:"type"` // 1 error, 2 warning, 3 info, 4 log
+	Message string `json:"message"`
+}
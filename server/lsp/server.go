@@ -0,0 +1,521 @@
+// Package lsp serves the engine over the Language Server Protocol, so
+// editors other than Neovim can use it. Suggestions are offered as inline
+// completions (textDocument/inlineCompletion) and, for clients without
+// inline completion support, through the custom cursortab/nextEdit request
+// that also returns predicted cursor jumps.
+//
+// The engine runs unchanged against a documentBuffer: the documents the
+// client synchronizes are the editor side of an in-memory buffer, requests
+// become the editor events Neovim would send, and UI calls become
+// suggestions returned to the client.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"cursortab/engine"
+	"cursortab/logger"
+)
+
+// Session is the engine a Factory builds for a client, with the timings
+// that decide how long requests wait for its suggestions.
+type Session struct {
+	Engine            *engine.Engine
+	CompletionTimeout time.Duration // provider timeout
+	Debounce          time.Duration // longest delay between a text change and a request
+}
+
+// Factory builds the engine session when a client initializes. root is
+// the workspace folder and options the client's initializationOptions.
+type Factory func(root string, options json.RawMessage, buf engine.Buffer) (*Session, error)
+
+// document is an open text document, as last synchronized by the client.
+type document struct {
+	uri        string
+	languageID string
+	version    int
+	lines      []string
+}
+
+// suggestion is what the engine currently shows in the active document.
+type suggestion struct {
+	path       string
+	edit       bool // startLine..endLineInc is replaced with lines
+	startLine  int
+	endLineInc int
+	lines      []string
+	target     int // cursor target line, 0 = none
+}
+
+func (s suggestion) empty() bool { return !s.edit && s.target == 0 }
+
+// Server is a language server session over one connection.
+type Server struct {
+	conn    *conn
+	factory Factory
+	ctx     context.Context
+
+	mu           sync.Mutex
+	initialized  bool
+	shutdown     bool
+	encoding     string
+	showDocument bool // client supports window/showDocument
+	docs         map[string]*document
+	active       string // path of the document the engine sees
+	current      suggestion
+	changed      chan struct{} // closed and replaced when current changes
+	cancels      map[string]context.CancelFunc
+
+	session *Session
+	buf     *documentBuffer
+}
+
+// Serve runs a language server reading requests from r and writing to w
+// until the client sends exit or closes r. It returns an error when the
+// connection fails or the client exits without shutting down first.
+func Serve(ctx context.Context, r io.Reader, w io.Writer, factory Factory) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := &Server{
+		conn:     newConn(r, w),
+		factory:  factory,
+		ctx:      ctx,
+		encoding: encodingUTF16,
+		docs:     make(map[string]*document),
+		changed:  make(chan struct{}),
+		cancels:  make(map[string]context.CancelFunc),
+	}
+	defer s.stop()
+
+	for {
+		msg, err := s.conn.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) && s.isShutdown() {
+				return nil
+			}
+			return err
+		}
+		switch {
+		case msg.Method == "":
+			s.conn.deliver(msg)
+		case msg.isRequest():
+			s.handleRequest(msg)
+		case msg.Method == "exit":
+			if !s.isShutdown() {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		default:
+			s.handleNotification(msg)
+		}
+	}
+}
+
+func (s *Server) isShutdown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shutdown
+}
+
+func (s *Server) stop() {
+	s.mu.Lock()
+	session := s.session
+	s.mu.Unlock()
+	if session != nil {
+		session.Engine.Stop()
+	}
+}
+
+// handleRequest answers a request. Requests are started in the order they
+// arrive, so editor events reach the engine in order; only waiting for a
+// suggestion happens in the background.
+func (s *Server) handleRequest(msg *message) {
+	if msg.Method != "initialize" && msg.Method != "shutdown" && !s.isInitialized() {
+		s.conn.reply(msg.ID, nil, &rpcError{Code: codeServerNotInitialized, Message: "server not initialized"})
+		return
+	}
+
+	var result any
+	var err error
+	switch msg.Method {
+	case "initialize":
+		result, err = s.initialize(msg.Params)
+	case "shutdown":
+		s.stop()
+		s.mu.Lock()
+		s.shutdown = true
+		s.mu.Unlock()
+	case "workspace/executeCommand":
+		err = s.executeCommand(msg.Params)
+	case "textDocument/inlineCompletion", MethodNextEdit:
+		var params TextDocumentPositionParams
+		if err = decodeParams(msg.Params, &params); err == nil {
+			var path string
+			var emitted bool
+			if path, emitted, err = s.activate(params); err == nil {
+				s.answerLater(msg, path, emitted)
+				return
+			}
+		}
+	default:
+		err = &rpcError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+	s.conn.reply(msg.ID, result, err)
+}
+
+// answerLater waits for the engine's suggestion in the background and
+// answers msg with it.
+func (s *Server) answerLater(msg *message, path string, emitted bool) {
+	key := string(msg.ID)
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.mu.Lock()
+	s.cancels[key] = cancel
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.cancels, key)
+			s.mu.Unlock()
+			cancel()
+		}()
+
+		current, err := s.await(ctx, path, emitted)
+		if err != nil {
+			s.conn.reply(msg.ID, nil, &rpcError{Code: codeRequestCancelled, Message: "request cancelled"})
+			return
+		}
+		if msg.Method == MethodNextEdit {
+			s.conn.reply(msg.ID, s.nextEdit(current), nil)
+		} else {
+			s.conn.reply(msg.ID, s.inlineCompletions(current), nil)
+		}
+	}()
+}
+
+func (s *Server) handleNotification(msg *message) {
+	var err error
+	switch msg.Method {
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err = decodeParams(msg.Params, &params); err == nil {
+			s.didOpen(params)
+		}
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err = decodeParams(msg.Params, &params); err == nil {
+			s.didChange(params)
+		}
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err = decodeParams(msg.Params, &params); err == nil {
+			s.didClose(params)
+		}
+	case "$/cancelRequest":
+		var params cancelParams
+		if err = decodeParams(msg.Params, &params); err == nil {
+			s.mu.Lock()
+			if cancel := s.cancels[string(params.ID)]; cancel != nil {
+				cancel()
+			}
+			s.mu.Unlock()
+		}
+	}
+	if err != nil {
+		logger.Warn("lsp: %s: %v", msg.Method, err)
+	}
+}
+
+func decodeParams(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) isInitialized() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.initialized
+}
+
+func (s *Server) initialize(params json.RawMessage) (any, error) {
+	var p initializeParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if s.isInitialized() {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "already initialized"}
+	}
+
+	root := p.RootPath
+	if p.RootURI != "" {
+		root = uriToPath(p.RootURI)
+	}
+	encoding := encodingUTF16
+	if slices.Contains(p.Capabilities.General.PositionEncodings, encodingUTF8) {
+		encoding = encodingUTF8
+	}
+
+	buf := newDocumentBuffer(s)
+	session, err := s.factory(root, p.InitializationOptions, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.encoding = encoding
+	s.showDocument = p.Capabilities.Window.ShowDocument.Support
+	s.session, s.buf = session, buf
+	s.initialized = true
+	s.mu.Unlock()
+
+	session.Engine.Start(s.ctx)
+	session.Engine.RegisterEventHandler()
+	logger.Info("lsp: initialized (root=%s, encoding=%s)", root, encoding)
+
+	return initializeResult{
+		Capabilities: serverCapabilities{
+			PositionEncoding:         encoding,
+			TextDocumentSync:         textDocumentSync{OpenClose: true, Change: 1},
+			InlineCompletionProvider: true,
+			ExecuteCommandProvider:   executeCommandOptions{Commands: []string{CommandAccept, CommandReject}},
+		},
+		ServerInfo: serverInfo{Name: "cursortab"},
+	}, nil
+}
+
+func (s *Server) didOpen(p didOpenParams) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs[uriToPath(p.TextDocument.URI)] = &document{
+		uri:        p.TextDocument.URI,
+		languageID: p.TextDocument.LanguageID,
+		version:    p.TextDocument.Version,
+		lines:      splitText(p.TextDocument.Text),
+	}
+}
+
+// didChange updates the document. The engine sees the change on the next
+// request for the document, so text a client inserts when accepting a
+// suggestion is not mistaken for typing.
+func (s *Server) didChange(p didChangeParams) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc := s.docs[uriToPath(p.TextDocument.URI)]
+	if doc == nil {
+		return
+	}
+	for _, change := range p.ContentChanges {
+		if change.Range == nil {
+			doc.lines = splitText(change.Text)
+		} else {
+			doc.lines = applyChange(doc.lines, *change.Range, change.Text, s.encoding)
+		}
+	}
+	doc.version = p.TextDocument.Version
+}
+
+func (s *Server) didClose(p didCloseParams) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := uriToPath(p.TextDocument.URI)
+	delete(s.docs, path)
+	if s.active == path {
+		s.active = ""
+		s.setCurrentLocked(suggestion{})
+	}
+}
+
+// activate shows the requested document and cursor position to the engine
+// and sends the editor event Neovim would: text_changed when the document
+// changed, or trigger_completion when there is nothing to show. Returns
+// the document path and whether an event was sent.
+func (s *Server) activate(p TextDocumentPositionParams) (string, bool, error) {
+	path := uriToPath(p.TextDocument.URI)
+
+	s.mu.Lock()
+	doc := s.docs[path]
+	if doc == nil {
+		s.mu.Unlock()
+		return "", false, &rpcError{Code: codeInvalidParams, Message: "document not open: " + p.TextDocument.URI}
+	}
+	lines := doc.lines
+	row := min(max(p.Position.Line, 0), len(lines)-1) + 1
+	col := byteCol(lines[row-1], p.Position.Character, s.encoding)
+	changed := s.active != path
+	if changed {
+		s.active = path
+		s.setCurrentLocked(suggestion{})
+		s.buf.Open(path, doc.languageID, lines)
+	} else if !slices.Equal(s.buf.EditorLines(), lines) {
+		s.buf.SetLines(lines)
+		changed = true
+	}
+	s.buf.SetCursor(row, col)
+	showing := !s.current.empty()
+	s.mu.Unlock()
+
+	switch {
+	case changed:
+		s.buf.Emit(string(engine.EventTextChanged))
+	case !showing:
+		s.buf.Emit(string(engine.EventTextChangeTimeout))
+	default:
+		return path, false, nil
+	}
+	return path, true, nil
+}
+
+// await waits until the engine has reacted to the last event and returns
+// what it shows for path. Without an event it only waits for a request
+// already in flight.
+func (s *Server) await(ctx context.Context, path string, emitted bool) (suggestion, error) {
+	settle := s.session.Debounce + 100*time.Millisecond
+	start := time.Now()
+	deadline := start.Add(s.session.CompletionTimeout + settle + time.Second)
+	sawBusy := false
+
+	for {
+		s.mu.Lock()
+		current, changed := s.current, s.changed
+		s.mu.Unlock()
+
+		busy, inFlightChanged := s.session.Engine.InFlight()
+		sawBusy = sawBusy || busy
+		settled := !emitted || sawBusy || time.Since(start) >= settle
+		if (!busy && settled) || time.Now().After(deadline) {
+			if current.path != path {
+				return suggestion{}, nil
+			}
+			return current, nil
+		}
+
+		// Wake up when the request starts or finishes, the suggestion
+		// changes, or at the next deadline
+		wake := deadline
+		if !settled {
+			wake = start.Add(settle)
+		}
+		timer := time.NewTimer(time.Until(wake))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return suggestion{}, ctx.Err()
+		case <-changed:
+		case <-inFlightChanged:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// lines returns the client's text of the document at path.
+func (s *Server) lines(path string) ([]string, string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc := s.docs[path]
+	if doc == nil {
+		return nil, "", false
+	}
+	return doc.lines, doc.uri, true
+}
+
+func (s *Server) inlineCompletions(current suggestion) InlineCompletionList {
+	list := InlineCompletionList{Items: []InlineCompletionItem{}}
+	lines, _, ok := s.lines(current.path)
+	if !ok || !current.edit {
+		return list
+	}
+	rng, text := editRange(lines, current.startLine, current.endLineInc, current.lines, s.encoding)
+	list.Items = append(list.Items, InlineCompletionItem{
+		InsertText: text,
+		Range:      rng,
+		Command:    &Command{Title: "Accept", Command: CommandAccept},
+	})
+	return list
+}
+
+func (s *Server) nextEdit(current suggestion) NextEditResult {
+	var result NextEditResult
+	lines, uri, ok := s.lines(current.path)
+	if !ok {
+		return result
+	}
+	if current.edit {
+		rng, text := editRange(lines, current.startLine, current.endLineInc, current.lines, s.encoding)
+		result.Edit = &TextEdit{TextDocument: TextDocumentIdentifier{URI: uri}, Range: rng, NewText: text}
+	}
+	if current.target > 0 && len(lines) > 0 {
+		pos := s.firstNonBlank(lines, current.target)
+		result.Jump = &Jump{TextDocument: TextDocumentIdentifier{URI: uri}, Position: pos}
+	}
+	return result
+}
+
+// firstNonBlank returns the position of the first non-blank character of
+// line (1-indexed), where the engine moves the cursor.
+func (s *Server) firstNonBlank(lines []string, line int) Position {
+	index := min(max(line, 1), len(lines)) - 1
+	content := lines[index]
+	col := len(content) - len(strings.TrimLeft(content, " \t"))
+	return Position{Line: index, Character: character(content, col, s.encoding)}
+}
+
+// executeCommand accepts or rejects the current suggestion, as Tab and Esc
+// do in Neovim.
+func (s *Server) executeCommand(params json.RawMessage) error {
+	var p executeCommandParams
+	if err := decodeParams(params, &p); err != nil {
+		return err
+	}
+	switch p.Command {
+	case CommandAccept:
+		s.buf.Emit(string(engine.EventTab))
+	case CommandReject:
+		s.buf.Emit(string(engine.EventEsc))
+	default:
+		return &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown command %q", p.Command)}
+	}
+	return nil
+}
+
+// setCurrentLocked replaces the current suggestion and wakes up waiting
+// requests. Callers hold s.mu.
+func (s *Server) setCurrentLocked(current suggestion) {
+	s.current = current
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// applyEdit asks the client to apply an edit the engine made, without
+// blocking the engine on the answer.
+func (s *Server) applyEdit(uri string, rng Range, text string) {
+	var params applyEditParams
+	params.Label = "cursortab"
+	params.Edit.Changes = map[string][]textEdit{uri: {{Range: rng, NewText: text}}}
+	go func() {
+		if err := s.conn.call(s.ctx, "workspace/applyEdit", params, nil); err != nil {
+			logger.Warn("lsp: apply edit: %v", err)
+		}
+	}()
+}
+
+// showPosition asks the client to move the cursor to pos in uri.
+func (s *Server) showPosition(uri string, pos Position) {
+	params := showDocumentParams{URI: uri, TakeFocus: true, Selection: &Range{Start: pos, End: pos}}
+	go func() {
+		if err := s.conn.call(s.ctx, "window/showDocument", params, nil); err != nil {
+			logger.Warn("lsp: show document: %v", err)
+		}
+	}()
+}
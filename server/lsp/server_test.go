@@ -0,0 +1,202 @@
+package lsp
+
+import (
+	"context"
+	"cursortab/assert"
+	"cursortab/engine"
+	"cursortab/types"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubProvider answers every request with the same completion.
+type stubProvider struct {
+	mu         sync.Mutex
+	completion *types.Completion
+	requests   int
+}
+
+func (p *stubProvider) GetCompletion(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests++
+	return &types.CompletionResponse{Completions: []*types.Completion{p.completion}}, nil
+}
+
+// testClient is the editor side of a Serve session.
+type testClient struct {
+	t         *testing.T
+	conn      *conn
+	nextID    int
+	responses chan *message
+	requests  chan *message // requests from the server
+	done      chan error    // Serve's result
+}
+
+func startServer(t *testing.T, prov engine.Provider) *testClient {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	factory := func(root string, options json.RawMessage, buf engine.Buffer) (*Session, error) {
+		eng, err := engine.NewEngine(prov, buf, engine.EngineConfig{
+			CompletionTimeout:   time.Second,
+			IdleCompletionDelay: -1,
+			TextChangeDebounce:  10 * time.Millisecond,
+		}, engine.SystemClock)
+		if err != nil {
+			return nil, err
+		}
+		return &Session{Engine: eng, CompletionTimeout: time.Second, Debounce: 10 * time.Millisecond}, nil
+	}
+
+	c := &testClient{
+		t:         t,
+		conn:      newConn(clientR, clientW),
+		responses: make(chan *message, 10),
+		requests:  make(chan *message, 10),
+		done:      make(chan error, 1),
+	}
+	go func() {
+		c.done <- Serve(context.Background(), serverR, serverW, factory)
+		serverW.Close()
+	}()
+	go func() {
+		for {
+			msg, err := c.conn.read()
+			if err != nil {
+				return
+			}
+			switch {
+			case msg.Method == "":
+				c.responses <- msg
+			case msg.isRequest():
+				c.requests <- msg
+			}
+		}
+	}()
+	t.Cleanup(func() { clientW.Close() })
+	return c
+}
+
+func (c *testClient) request(method string, params, result any) *rpcError {
+	c.t.Helper()
+	c.nextID++
+	err := c.conn.write(map[string]any{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+	assert.NoError(c.t, err, "write "+method)
+	select {
+	case resp := <-c.responses:
+		if resp.Error == nil && result != nil {
+			assert.NoError(c.t, json.Unmarshal(resp.Result, result), "decode "+method)
+		}
+		return resp.Error
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("no response to %s", method)
+		return nil
+	}
+}
+
+func (c *testClient) notify(method string, params any) {
+	c.t.Helper()
+	assert.NoError(c.t, c.conn.notify(method, params), "write "+method)
+}
+
+func (c *testClient) open(uri, text string) {
+	c.t.Helper()
+	var result initializeResult
+	rpcErr := c.request("initialize", map[string]any{
+		"rootUri":      "file:///work",
+		"capabilities": map[string]any{"general": map[string]any{"positionEncodings": []string{"utf-8"}}},
+	}, &result)
+	assert.Nil(c.t, rpcErr, "initialize")
+	assert.Equal(c.t, encodingUTF8, result.Capabilities.PositionEncoding, "utf-8 negotiated")
+	assert.True(c.t, result.Capabilities.InlineCompletionProvider, "inline completions offered")
+	c.notify("initialized", map[string]any{})
+	c.notify("textDocument/didOpen", didOpenParams{TextDocument: TextDocumentItem{URI: uri, LanguageID: "go", Version: 1, Text: text}})
+}
+
+const (
+	testURI    = "file:///work/add.go"
+	testBefore = "func add(a, b int) int {\n\treturn 0\n}\n"
+	testAfter  = "func add(a, b int) int {\n\treturn a + b\n}\n"
+)
+
+func testPosition(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: testURI}, Position: Position{line, character}}
+}
+
+func TestServe_InlineCompletionAccepted(t *testing.T) {
+	prov := &stubProvider{completion: &types.Completion{StartLine: 2, EndLineInc: 2, Lines: []string{"\treturn a + b"}}}
+	c := startServer(t, prov)
+	c.open(testURI, testBefore)
+
+	var list InlineCompletionList
+	assert.Nil(t, c.request("textDocument/inlineCompletion", testPosition(1, 8), &list), "inline completion")
+	assert.Len(t, 1, list.Items, "one suggestion")
+	item := list.Items[0]
+	assert.Equal(t, "\treturn a + b", item.InsertText, "suggested text")
+	assert.Equal(t, Range{Position{1, 0}, Position{1, 9}}, item.Range, "replaces the line")
+	assert.Equal(t, CommandAccept, item.Command.Command, "accept command")
+
+	// The client inserts the text itself, then runs the command
+	c.notify("textDocument/didChange", didChangeParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: testURI, Version: 2},
+		ContentChanges: []contentChange{{Range: &item.Range, Text: item.InsertText}},
+	})
+	assert.Nil(t, c.request("workspace/executeCommand", executeCommandParams{Command: CommandAccept}, nil), "accept")
+
+	var next NextEditResult
+	assert.Nil(t, c.request(MethodNextEdit, testPosition(1, 13), &next), "next edit")
+	assert.Nil(t, next.Edit, "nothing left to suggest")
+	select {
+	case msg := <-c.requests:
+		t.Fatalf("unexpected %s for an edit the client applied", msg.Method)
+	default:
+	}
+
+	assert.Nil(t, c.request("shutdown", nil, nil), "shutdown")
+	c.notify("exit", nil)
+	assert.NoError(t, <-c.done, "clean exit")
+}
+
+func TestServe_NextEditAppliedByServer(t *testing.T) {
+	prov := &stubProvider{completion: &types.Completion{StartLine: 2, EndLineInc: 2, Lines: []string{"\treturn a + b"}}}
+	c := startServer(t, prov)
+	c.open(testURI, testBefore)
+
+	var next NextEditResult
+	assert.Nil(t, c.request(MethodNextEdit, testPosition(1, 1), &next), "next edit")
+	assert.NotNil(t, next.Edit, "edit suggested")
+	assert.Equal(t, testURI, next.Edit.TextDocument.URI, "edit document")
+	assert.Equal(t, "\treturn a + b", next.Edit.NewText, "edit text")
+
+	// Accepting without applying the edit makes the server apply it
+	assert.Nil(t, c.request("workspace/executeCommand", executeCommandParams{Command: CommandAccept}, nil), "accept")
+	select {
+	case msg := <-c.requests:
+		assert.Equal(t, "workspace/applyEdit", msg.Method, "edit sent to the client")
+		var params applyEditParams
+		assert.NoError(t, json.Unmarshal(msg.Params, &params), "decode edit")
+		edits := params.Edit.Changes[testURI]
+		assert.Len(t, 1, edits, "one edit")
+		assert.Equal(t, "\treturn a + b", edits[0].NewText, "applied text")
+		assert.NoError(t, c.conn.reply(msg.ID, map[string]bool{"applied": true}, nil), "answer")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no workspace/applyEdit")
+	}
+
+	rpcErr := c.request("workspace/executeCommand", executeCommandParams{Command: "nope"}, nil)
+	assert.NotNil(t, rpcErr, "unknown command")
+	assert.Equal(t, codeInvalidParams, rpcErr.Code, "invalid params")
+}
+
+func TestServe_RequiresInitialize(t *testing.T) {
+	c := startServer(t, &stubProvider{})
+	rpcErr := c.request(MethodNextEdit, testPosition(0, 0), nil)
+	assert.NotNil(t, rpcErr, "rejected")
+	assert.Equal(t, codeServerNotInitialized, rpcErr.Code, "not initialized")
+
+	c.notify("exit", nil)
+	assert.Error(t, <-c.done, "exit without shutdown")
+}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"cursortab/logger"
+	"cursortab/metrics"
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"syscall"
 )
@@ -17,38 +20,122 @@ type CursorPredictionConfig struct {
 	ProximityThreshold int  `json:"proximity_threshold"`
 }
 
+// AdaptiveDebounceConfig holds settings for learned trigger delays
+type AdaptiveDebounceConfig struct {
+	Enabled  bool `json:"enabled"`
+	MinDelay int  `json:"min_delay"` // in milliseconds
+	MaxDelay int  `json:"max_delay"` // in milliseconds, 0 = no upper bound
+}
+
+// RulesConfig holds rules deciding which buffers get completions
+type RulesConfig struct {
+	Include          []string `json:"include"`            // globs, empty = all paths
+	Exclude          []string `json:"exclude"`            // globs
+	Filetypes        []string `json:"filetypes"`          // empty = all filetypes
+	ExcludeFiletypes []string `json:"exclude_filetypes"`  // filetypes never completed
+	MaxFileSize      int      `json:"max_file_size"`      // in KB, 0 = no limit
+	MaxLineLength    int      `json:"max_line_length"`    // in bytes, 0 = no limit
+	SkipUnnamed      bool     `json:"skip_unnamed"`       // skip buffers without a file path
+	IgnoreFiles      bool     `json:"ignore_files"`       // honor .cursortabignore files
+	GlobalIgnoreFile string   `json:"global_ignore_file"` // ignore file applied to every workspace
+}
+
+// NormalizationConfig holds which cosmetic differences suggestions ignore
+type NormalizationConfig struct {
+	TrailingWhitespace bool `json:"trailing_whitespace"`
+	Indentation        bool `json:"indentation"`
+	Formatting         bool `json:"formatting"` // spacing a formatter would undo
+}
+
 // BehaviorConfig holds timing and behavior settings
 type BehaviorConfig struct {
 	IdleCompletionDelay int                    `json:"idle_completion_delay"` // in milliseconds
 	TextChangeDebounce  int                    `json:"text_change_debounce"`  // in milliseconds
 	CursorPrediction    CursorPredictionConfig `json:"cursor_prediction"`
+	CompletionCacheSize int                    `json:"completion_cache_size"` // 0 disables the cache
+	AdaptiveDebounce    AdaptiveDebounceConfig `json:"adaptive_debounce"`
+	Rules               RulesConfig            `json:"rules"`
+	Normalization       NormalizationConfig    `json:"normalization"`
+}
+
+// RateLimitConfig holds client-side request limits for the provider
+type RateLimitConfig struct {
+	RequestsPerSecond  float64 `json:"requests_per_second"` // 0 = unlimited
+	Burst              int     `json:"burst"`
+	DailyBudget        int     `json:"daily_budget"`         // 0 = unlimited
+	MonthlyBudget      int     `json:"monthly_budget"`       // 0 = unlimited
+	LowBudgetThreshold float64 `json:"low_budget_threshold"` // fraction remaining before idle-only mode
+}
+
+// CircuitBreakerConfig holds outage handling settings for the provider
+type CircuitBreakerConfig struct {
+	FailureThreshold int `json:"failure_threshold"` // consecutive failures before pausing, 0 = disabled
+	Cooldown         int `json:"cooldown"`          // in milliseconds
+}
+
+// RedactionConfig holds secret redaction settings for outgoing prompts
+type RedactionConfig struct {
+	Enabled  bool     `json:"enabled"`
+	Patterns []string `json:"patterns"` // extra regexps (Go syntax) to redact
+}
+
+// AuditLogConfig holds settings for the log of requests sent to the provider
+type AuditLogConfig struct {
+	Enabled bool   `json:"enabled"`
+	Path    string `json:"path"` // empty = cursortab.audit.jsonl next to the binary
 }
 
 // ProviderConfig holds provider-specific settings
 type ProviderConfig struct {
-	Type                 string  `json:"type"` // "sweep"
-	URL                  string  `json:"url"`
-	Temperature          float64 `json:"temperature"`
-	MaxTokens            int     `json:"max_tokens"` // Max tokens to generate (also drives input trimming)
-	TopK                 int     `json:"top_k"`
-	CompletionTimeout    int     `json:"completion_timeout"` // in milliseconds
-	MaxDiffHistoryTokens int     `json:"max_diff_history_tokens"`
-	APIKey               string  `json:"api_key"`     // API key for hosted providers
-	APIKeyEnv            string  `json:"api_key_env"` // Environment variable name for API key
+	Type                 string               `json:"type"` // "sweep"
+	URL                  string               `json:"url"`
+	Temperature          float64              `json:"temperature"`
+	MaxTokens            int                  `json:"max_tokens"` // Max tokens to generate (also drives input trimming)
+	TopK                 int                  `json:"top_k"`
+	CompletionTimeout    int                  `json:"completion_timeout"` // in milliseconds
+	MaxDiffHistoryTokens int                  `json:"max_diff_history_tokens"`
+	Tokenizer            string               `json:"tokenizer"`       // tokenizer.json or tiktoken vocabulary, empty = heuristic
+	APIKey               string               `json:"api_key"`         // API key for hosted providers
+	APIKeyEnv            string               `json:"api_key_env"`     // Environment variable name for API key
+	APIKeyCommand        string               `json:"api_key_command"` // Shell command printing the API key
+	APIKeyFile           string               `json:"api_key_file"`    // File containing the API key
+	APIKeyTTL            int                  `json:"api_key_ttl"`     // Cache duration for command/file keys, in milliseconds
+	RateLimit            RateLimitConfig      `json:"rate_limit"`
+	CircuitBreaker       CircuitBreakerConfig `json:"circuit_breaker"`
+	Redaction            RedactionConfig      `json:"redaction"`
+	AuditLog             AuditLogConfig       `json:"audit_log"`
+}
+
+// PrivacyConfig holds privacy mode settings
+type PrivacyConfig struct {
+	Enabled    bool     `json:"enabled"`    // privacy mode everywhere
+	Workspaces []string `json:"workspaces"` // workspace directories in privacy mode
+	Paths      []string `json:"paths"`      // file globs in privacy mode
 }
 
 // DebugConfig holds debug settings
 type DebugConfig struct {
-	ImmediateShutdown bool `json:"immediate_shutdown"`
+	ImmediateShutdown bool   `json:"immediate_shutdown"`
+	TraceFile         string `json:"trace_file"` // replayable session trace, empty = disabled
+}
+
+// MetricsConfig holds the metrics endpoint settings
+type MetricsConfig struct {
+	Address string `json:"address"` // loopback host:port or absolute socket path, empty = disabled
 }
 
 // Config is the main configuration structure
 type Config struct {
-	NsID     int            `json:"ns_id"`
-	LogLevel string         `json:"log_level"`
-	Behavior BehaviorConfig `json:"behavior"`
-	Provider ProviderConfig `json:"provider"`
-	Debug    DebugConfig    `json:"debug"`
+	NsID        int            `json:"ns_id"`
+	LogLevel    string         `json:"log_level"`
+	LogFormat   string         `json:"log_format"`    // "text" or "json"
+	LogMaxSize  int            `json:"log_max_size"`  // MB before the log file is rotated
+	LogMaxFiles int            `json:"log_max_files"` // rotated log files kept
+	Behavior    BehaviorConfig `json:"behavior"`
+	Provider    ProviderConfig `json:"provider"`
+	Privacy     PrivacyConfig  `json:"privacy"`
+	Metrics     MetricsConfig  `json:"metrics"`
+	Debug       DebugConfig    `json:"debug"`
 }
 
 // Validate checks that the config has valid values.
@@ -64,6 +151,15 @@ func (c *Config) Validate() error {
 	if !validLogLevels[c.LogLevel] {
 		return fmt.Errorf("invalid log_level %q: must be one of trace, debug, info, warn, error", c.LogLevel)
 	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		return fmt.Errorf("invalid log_format %q: must be \"text\" or \"json\"", c.LogFormat)
+	}
+	if c.LogMaxSize < 1 {
+		return fmt.Errorf("invalid log_max_size %d: must be >= 1", c.LogMaxSize)
+	}
+	if c.LogMaxFiles < 1 {
+		return fmt.Errorf("invalid log_max_files %d: must be >= 1", c.LogMaxFiles)
+	}
 
 	// Validate numeric ranges
 	if c.Behavior.IdleCompletionDelay < -1 {
@@ -72,6 +168,29 @@ func (c *Config) Validate() error {
 	if c.Behavior.TextChangeDebounce < 0 {
 		return fmt.Errorf("invalid behavior.text_change_debounce %d: must be >= 0", c.Behavior.TextChangeDebounce)
 	}
+	if c.Behavior.AdaptiveDebounce.MinDelay < 0 {
+		return fmt.Errorf("invalid behavior.adaptive_debounce.min_delay %d: must be >= 0", c.Behavior.AdaptiveDebounce.MinDelay)
+	}
+	if c.Behavior.AdaptiveDebounce.MaxDelay < 0 {
+		return fmt.Errorf("invalid behavior.adaptive_debounce.max_delay %d: must be >= 0", c.Behavior.AdaptiveDebounce.MaxDelay)
+	}
+	if maxDelay := c.Behavior.AdaptiveDebounce.MaxDelay; maxDelay > 0 && maxDelay < c.Behavior.AdaptiveDebounce.MinDelay {
+		return fmt.Errorf("invalid behavior.adaptive_debounce.max_delay %d: must be >= min_delay", maxDelay)
+	}
+	if c.Behavior.CompletionCacheSize < 0 {
+		return fmt.Errorf("invalid behavior.completion_cache_size %d: must be >= 0", c.Behavior.CompletionCacheSize)
+	}
+	if c.Behavior.Rules.MaxFileSize < 0 {
+		return fmt.Errorf("invalid behavior.rules.max_file_size %d: must be >= 0", c.Behavior.Rules.MaxFileSize)
+	}
+	if c.Behavior.Rules.MaxLineLength < 0 {
+		return fmt.Errorf("invalid behavior.rules.max_line_length %d: must be >= 0", c.Behavior.Rules.MaxLineLength)
+	}
+	for _, pattern := range append(append([]string{}, c.Behavior.Rules.Include...), c.Behavior.Rules.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid behavior.rules glob %q: %v", pattern, err)
+		}
+	}
 	if c.Provider.MaxTokens < 0 {
 		return fmt.Errorf("invalid provider.max_tokens %d: must be >= 0", c.Provider.MaxTokens)
 	}
@@ -81,6 +200,45 @@ func (c *Config) Validate() error {
 	if c.Provider.MaxDiffHistoryTokens < 0 {
 		return fmt.Errorf("invalid provider.max_diff_history_tokens %d: must be >= 0", c.Provider.MaxDiffHistoryTokens)
 	}
+	if c.Provider.RateLimit.RequestsPerSecond < 0 {
+		return fmt.Errorf("invalid provider.rate_limit.requests_per_second %v: must be >= 0", c.Provider.RateLimit.RequestsPerSecond)
+	}
+	if c.Provider.RateLimit.Burst < 0 {
+		return fmt.Errorf("invalid provider.rate_limit.burst %d: must be >= 0", c.Provider.RateLimit.Burst)
+	}
+	if c.Provider.RateLimit.DailyBudget < 0 {
+		return fmt.Errorf("invalid provider.rate_limit.daily_budget %d: must be >= 0", c.Provider.RateLimit.DailyBudget)
+	}
+	if c.Provider.RateLimit.MonthlyBudget < 0 {
+		return fmt.Errorf("invalid provider.rate_limit.monthly_budget %d: must be >= 0", c.Provider.RateLimit.MonthlyBudget)
+	}
+	if t := c.Provider.RateLimit.LowBudgetThreshold; t < 0 || t >= 1 {
+		return fmt.Errorf("invalid provider.rate_limit.low_budget_threshold %v: must be in [0, 1)", t)
+	}
+	if c.Provider.CircuitBreaker.FailureThreshold < 0 {
+		return fmt.Errorf("invalid provider.circuit_breaker.failure_threshold %d: must be >= 0", c.Provider.CircuitBreaker.FailureThreshold)
+	}
+	if c.Provider.CircuitBreaker.Cooldown < 0 {
+		return fmt.Errorf("invalid provider.circuit_breaker.cooldown %d: must be >= 0", c.Provider.CircuitBreaker.Cooldown)
+	}
+	if c.Provider.APIKeyTTL < 0 {
+		return fmt.Errorf("invalid provider.api_key_ttl %d: must be >= 0", c.Provider.APIKeyTTL)
+	}
+	for _, pattern := range c.Provider.Redaction.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid provider.redaction pattern %q: %v", pattern, err)
+		}
+	}
+	for _, pattern := range c.Privacy.Paths {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid privacy.paths glob %q: %v", pattern, err)
+		}
+	}
+	if c.Metrics.Address != "" {
+		if err := metrics.CheckAddress(c.Metrics.Address); err != nil {
+			return fmt.Errorf("invalid metrics.address %q: %v", c.Metrics.Address, err)
+		}
+	}
 
 	return nil
 }
@@ -88,13 +246,29 @@ func (c *Config) Validate() error {
 type ServerMode string
 
 const (
-	ModeDaemon ServerMode = "daemon"
-	ModeClient ServerMode = "client"
+	ModeDaemon   ServerMode = "daemon"
+	ModeClient   ServerMode = "client"
+	ModeAudit    ServerMode = "audit"
+	ModeReplay   ServerMode = "replay"
+	ModeEval     ServerMode = "eval"
+	ModeMock     ServerMode = "mock"
+	ModeLSP      ServerMode = "lsp"
+	ModeComplete ServerMode = "complete"
 )
 
+// logOptions returns the logger settings of the config
+func (c *Config) logOptions() logger.Options {
+	return logger.Options{
+		Level:    logger.ParseLogLevel(c.LogLevel),
+		Format:   logger.ParseFormat(c.LogFormat),
+		MaxSize:  int64(c.LogMaxSize) << 20,
+		MaxFiles: c.LogMaxFiles,
+	}
+}
+
 // Setup logger to log to a file in the same directory as the executable
 // Caller must defer logger.Close()
-func setupLogger(logLevel string) *logger.LimitedLogger {
+func setupLogger(opts logger.Options) *logger.LimitedLogger {
 	execPath, err := os.Executable()
 	if err != nil {
 		logger.Fatal("error getting executable path: %v", err)
@@ -102,13 +276,11 @@ func setupLogger(logLevel string) *logger.LimitedLogger {
 	execDir := filepath.Dir(execPath)
 	logPath := filepath.Join(execDir, "cursortab.log")
 
-	f, err := os.OpenFile(logPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	ll, err := logger.Open(logPath, opts)
 	if err != nil {
 		logger.Fatal("error opening file: %v", err)
 	}
-
-	level := logger.ParseLogLevel(logLevel)
-	return logger.NewLimitedLogger(f, level)
+	return ll
 }
 
 func getSocketPath() string {
@@ -129,6 +301,33 @@ func getPidPath() string {
 	return filepath.Join(execDir, "cursortab.pid")
 }
 
+func getAuditPath() string {
+	execPath, err := os.Executable()
+	if err != nil {
+		logger.Fatal("error getting executable path: %v", err)
+	}
+	execDir := filepath.Dir(execPath)
+	return filepath.Join(execDir, "cursortab.audit.jsonl")
+}
+
+func getBudgetPath() string {
+	execPath, err := os.Executable()
+	if err != nil {
+		logger.Fatal("error getting executable path: %v", err)
+	}
+	execDir := filepath.Dir(execPath)
+	return filepath.Join(execDir, "cursortab.budget.json")
+}
+
+func getStatsPath() string {
+	execPath, err := os.Executable()
+	if err != nil {
+		logger.Fatal("error getting executable path: %v", err)
+	}
+	execDir := filepath.Dir(execPath)
+	return filepath.Join(execDir, "cursortab.stats.json")
+}
+
 func isDaemonRunning() (bool, int) {
 	pidPath := getPidPath()
 	data, err := os.ReadFile(pidPath)
@@ -166,17 +365,63 @@ func loadConfig() Config {
 	return config
 }
 
+// defaultConfig returns the defaults of lua/cursortab/config.lua, for the
+// modes that run without the Lua client (eval, lsp).
+func defaultConfig() Config {
+	globalIgnore := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		globalIgnore = filepath.Join(home, ".config", "cursortab", "ignore")
+	}
+	return Config{
+		LogLevel:    "info",
+		LogFormat:   "text",
+		LogMaxSize:  10,
+		LogMaxFiles: 3,
+		Behavior: BehaviorConfig{
+			IdleCompletionDelay: 50,
+			TextChangeDebounce:  50,
+			CursorPrediction: CursorPredictionConfig{
+				Enabled:            true,
+				AutoAdvance:        true,
+				ProximityThreshold: 2,
+			},
+			CompletionCacheSize: 32,
+			AdaptiveDebounce:    AdaptiveDebounceConfig{MinDelay: 20, MaxDelay: 400},
+			Rules: RulesConfig{
+				Exclude:          []string{"*.lock", "package-lock.json", "pnpm-lock.yaml", "go.sum", "*.min.js", "*.min.css"},
+				MaxFileSize:      1024,
+				MaxLineLength:    1000,
+				SkipUnnamed:      true,
+				IgnoreFiles:      true,
+				GlobalIgnoreFile: globalIgnore,
+			},
+			Normalization: NormalizationConfig{TrailingWhitespace: true},
+		},
+		Provider: ProviderConfig{
+			Type:                 "sweep",
+			URL:                  "https://autocomplete.sweep.dev",
+			MaxTokens:            512,
+			TopK:                 50,
+			CompletionTimeout:    5000,
+			MaxDiffHistoryTokens: 512,
+			APIKeyEnv:            "SWEEP_AI_TOKEN",
+			APIKeyTTL:            900000,
+			RateLimit:            RateLimitConfig{Burst: 2, LowBudgetThreshold: 0.1},
+			CircuitBreaker:       CircuitBreakerConfig{FailureThreshold: 5, Cooldown: 30000},
+			Redaction:            RedactionConfig{Enabled: true},
+		},
+	}
+}
+
 func runDaemon() {
 	// Setup logger early with default level
-	ll := setupLogger("info")
+	ll := setupLogger(logger.Options{Level: logger.LogLevelInfo})
 	defer ll.Close()
 
 	config := loadConfig()
 
-	// Update log level based on config
-	if config.LogLevel != "" {
-		logger.SetGlobalLevel(logger.ParseLogLevel(config.LogLevel))
-	}
+	// Apply the configured level, format and rotation
+	logger.Configure(config.logOptions())
 
 	daemon, err := NewDaemon(config)
 	if err != nil {
@@ -204,8 +449,23 @@ func main() {
 	var mode ServerMode = ModeClient
 
 	// Check command line arguments
-	if len(os.Args) > 1 && os.Args[1] == "--daemon" {
-		mode = ModeDaemon
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "--daemon":
+			mode = ModeDaemon
+		case "audit":
+			mode = ModeAudit
+		case "replay":
+			mode = ModeReplay
+		case "eval":
+			mode = ModeEval
+		case "mock":
+			mode = ModeMock
+		case "--lsp":
+			mode = ModeLSP
+		case "complete":
+			mode = ModeComplete
+		}
 	}
 
 	switch mode {
@@ -213,5 +473,17 @@ func main() {
 		runDaemon()
 	case ModeClient:
 		runClient()
+	case ModeAudit:
+		os.Exit(runAudit(os.Args[2:], os.Stdout, os.Stderr))
+	case ModeReplay:
+		os.Exit(runReplay(os.Args[2:], os.Stdout, os.Stderr))
+	case ModeEval:
+		os.Exit(runEval(os.Args[2:], os.Stdout, os.Stderr))
+	case ModeMock:
+		os.Exit(runMock(os.Args[2:], os.Stdout, os.Stderr))
+	case ModeLSP:
+		os.Exit(runLSP(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	case ModeComplete:
+		os.Exit(runComplete(os.Args[2:], os.Stdout, os.Stderr))
 	}
 }
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"cursortab/assert"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var sb strings.Builder
+	assert.NoError(t, r.Write(&sb), "Write")
+	return sb.String()
+}
+
+func TestCounter_Labels(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("requests_total", "Requests by outcome.", "provider", "outcome")
+	c.Inc("sweep", "ok")
+	c.Inc("sweep", "ok")
+	c.Inc("sweep", "error")
+	c.Add(3, `we"ird`, "ok")
+
+	assert.Equal(t, uint64(2), c.Value("sweep", "ok"), "value")
+	assert.Equal(t, uint64(0), c.Value("sweep", "canceled"), "unseen series")
+	assert.Equal(t, `# HELP requests_total Requests by outcome.
+# TYPE requests_total counter
+requests_total{provider="sweep",outcome="error"} 1
+requests_total{provider="sweep",outcome="ok"} 2
+requests_total{provider="we\"ird",outcome="ok"} 3
+`, render(t, r), "exposition")
+}
+
+func TestHistogram_CumulativeBuckets(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("latency_seconds", "Latency.", []float64{0.5, 0.1})
+	for _, v := range []float64{0.05, 0.1, 0.3, 2} {
+		h.Observe(v)
+	}
+
+	assert.Equal(t, `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 2
+latency_seconds_bucket{le="0.5"} 3
+latency_seconds_bucket{le="+Inf"} 4
+latency_seconds_sum 2.45
+latency_seconds_count 4
+`, render(t, r), "exposition")
+}
+
+func TestRegistry_SortedAndGauges(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGauge("b_state", "State.")
+	g.Set(2)
+	r.register("a_restarts", &funcMetric{name: "a_restarts", help: "Restarts.", kind: "counter", fn: func() float64 { return 1 }})
+
+	out := render(t, r)
+	assert.True(t, strings.Index(out, "a_restarts 1\n") < strings.Index(out, "b_state 2\n"), "sorted by name: "+out)
+	assert.Contains(t, out, "# TYPE b_state gauge", "gauge type")
+
+	defer func() {
+		assert.NotNil(t, recover(), "duplicate name panics")
+	}()
+	r.NewGauge("b_state", "again")
+}
+
+func TestCheckAddress(t *testing.T) {
+	for _, ok := range []string{"127.0.0.1:9464", "localhost:9464", "[::1]:9464", "/tmp/cursortab-metrics.sock"} {
+		assert.NoError(t, CheckAddress(ok), ok)
+	}
+	for _, bad := range []string{"0.0.0.0:9464", ":9464", "example.com:80", "9464", "relative.sock", "127.0.0.1:"} {
+		assert.Error(t, CheckAddress(bad), bad)
+	}
+}
+
+func TestListen_UnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.sock")
+	s, err := Listen(path)
+	assert.NoError(t, err, "Listen")
+	defer s.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		Dial: func(_, _ string) (net.Conn, error) { return net.Dial("unix", path) },
+	}}
+	resp, err := client.Get("http://unix/metrics")
+	assert.NoError(t, err, "GET /metrics")
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "status")
+	assert.Contains(t, resp.Header.Get("Content-Type"), "version=0.0.4", "content type")
+	assert.Contains(t, string(body), "# TYPE go_goroutines gauge", "process metrics")
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CheckAddress validates a listen address: an absolute Unix socket path, or
+// host:port where host is a loopback address so the metrics never leave the
+// machine.
+func CheckAddress(address string) error {
+	if filepath.IsAbs(address) {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("must be host:port or an absolute socket path: %w", err)
+	}
+	if port == "" {
+		return errors.New("missing port")
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("host %q is not a loopback address", host)
+	}
+	return nil
+}
+
+// Handler serves the registry in the text exposition format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// Server serves Default on /metrics
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+	path     string // socket file to remove on Close, "" for TCP
+}
+
+// Listen starts serving Default on address (see CheckAddress). Unix sockets
+// are created readable by the current user only.
+func Listen(address string) (*Server, error) {
+	if err := CheckAddress(address); err != nil {
+		return nil, err
+	}
+
+	s := &Server{}
+	if filepath.IsAbs(address) {
+		os.Remove(address)
+		l, err := net.Listen("unix", address)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(address, 0600); err != nil {
+			l.Close()
+			return nil, err
+		}
+		s.listener, s.path = l, address
+	} else {
+		l, err := net.Listen("tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		s.listener = l
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Default.Handler())
+	s.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go s.server.Serve(s.listener)
+	return s, nil
+}
+
+// Addr returns the address being served
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stops serving and removes the socket file
+func (s *Server) Close() error {
+	err := s.server.Close()
+	if s.path != "" {
+		os.Remove(s.path)
+	}
+	return err
+}
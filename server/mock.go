@@ -0,0 +1,60 @@
+package main
+
+import (
+	"cursortab/mockserver"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// runMock implements the "mock" subcommand, which serves scripted
+// completions on the OpenAI and Sweep endpoints for testing without a
+// model or network access. Returns the process exit code.
+func runMock(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("mock", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", "127.0.0.1:8765", "address to listen on")
+	latency := fs.Int("latency", 0, "ms added to every answer")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cursortab mock [--addr host:port] [--latency ms] [script.json]")
+		fmt.Fprintln(stderr, "Serve scripted completions on /v1/completions and /backend/next_edit_autocomplete.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	var rules []mockserver.Rule
+	if fs.NArg() == 1 {
+		var err error
+		if rules, err = mockserver.LoadScript(fs.Arg(0)); err != nil {
+			fmt.Fprintf(stderr, "cursortab mock: %v\n", err)
+			return 1
+		}
+	}
+	server, err := mockserver.New(rules)
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab mock: %v\n", err)
+		return 1
+	}
+	server.SetLatency(time.Duration(*latency) * time.Millisecond)
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab mock: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Mock provider listening on http://%s (%d rules)\n", ln.Addr(), len(rules))
+	if err := http.Serve(ln, server); err != nil {
+		fmt.Fprintf(stderr, "cursortab mock: %v\n", err)
+		return 1
+	}
+	return 0
+}
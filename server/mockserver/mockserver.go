@@ -0,0 +1,348 @@
+// Package mockserver is a stand-in for completion backends: an
+// OpenAI-compatible /v1/completions endpoint and the hosted Sweep
+// next-edit endpoint. It answers from scripted rules with configurable
+// latency, errors and truncation, so the daemon can be tested end to end
+// without network access.
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+
+	"cursortab/client/openai"
+	"cursortab/client/sweep"
+)
+
+// Endpoints answered by the server.
+const (
+	EndpointOpenAI = "openai" // POST /v1/completions
+	EndpointSweep  = "sweep"  // POST /backend/next_edit_autocomplete
+)
+
+// Rule is a scripted answer. The first rule matching a request answers it.
+type Rule struct {
+	Endpoint string `json:"endpoint"` // EndpointOpenAI or EndpointSweep, "" = both
+	Match    string `json:"match"`    // regexp searched in the prompt or file contents, "" = any
+	Path     string `json:"path"`     // regexp searched in the Sweep file path, "" = any
+	Times    int    `json:"times"`    // answers this many requests, then is skipped (0 = no limit)
+
+	Text string `json:"text"` // completion text
+	// Replace is the text the Sweep completion replaces, searched in the file
+	// contents. Empty or not found: the completion is inserted at the cursor.
+	Replace      string `json:"replace"`
+	Truncate     int    `json:"truncate"`      // cut Text to this many bytes and finish with "length" (0 = off)
+	FinishReason string `json:"finish_reason"` // "" = "stop", or "length" after truncation
+	Chunking     string `json:"chunking"`      // SSE chunks: "token" (default) or "line"
+
+	Latency    int    `json:"latency"`     // ms before answering
+	ChunkDelay int    `json:"chunk_delay"` // ms between SSE chunks
+	Status     int    `json:"status"`      // answer with this HTTP error status instead (0 = 200)
+	Error      string `json:"error"`       // body of the error answer
+}
+
+// Script is the JSON file format read by LoadScript.
+type Script struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadScript reads rules from a JSON script file.
+func LoadScript(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var script Script
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return script.Rules, nil
+}
+
+// Request is a request received by the server, kept for assertions.
+type Request struct {
+	Endpoint string
+	Prompt   string // OpenAI prompt or Sweep file contents
+	FilePath string // Sweep only
+	Stream   bool   // OpenAI only
+	Rule     int    // index of the answering rule, -1 = none matched
+}
+
+// Server answers completion requests from rules. Safe for concurrent use;
+// it implements http.Handler.
+type Server struct {
+	mu       sync.Mutex
+	rules    []compiledRule
+	requests []Request
+	latency  time.Duration
+}
+
+type compiledRule struct {
+	Rule
+	match *regexp.Regexp
+	path  *regexp.Regexp
+	used  int
+}
+
+// New returns a server answering from rules. Requests no rule matches get
+// an empty completion.
+func New(rules []Rule) (*Server, error) {
+	s := &Server{}
+	for i, rule := range rules {
+		cr := compiledRule{Rule: rule}
+		var err error
+		if rule.Match != "" {
+			if cr.match, err = regexp.Compile(rule.Match); err != nil {
+				return nil, fmt.Errorf("rule %d: match: %w", i+1, err)
+			}
+		}
+		if rule.Path != "" {
+			if cr.path, err = regexp.Compile(rule.Path); err != nil {
+				return nil, fmt.Errorf("rule %d: path: %w", i+1, err)
+			}
+		}
+		switch rule.Endpoint {
+		case "", EndpointOpenAI, EndpointSweep:
+		default:
+			return nil, fmt.Errorf("rule %d: unknown endpoint %q", i+1, rule.Endpoint)
+		}
+		s.rules = append(s.rules, cr)
+	}
+	return s, nil
+}
+
+// SetLatency delays every answer by d on top of the rule's own latency.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Requests returns the requests received so far, oldest first.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	switch r.URL.Path {
+	case openai.DefaultCompletionPath:
+		s.serveOpenAI(w, r)
+	case sweep.DefaultAutocompletePath:
+		s.serveSweep(w, r)
+	case sweep.DefaultMetricsPath:
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// answer picks the rule for a request, records it and waits out the
+// latency. Returns false when the client went away.
+func (s *Server) answer(r *http.Request, req Request) (Rule, bool) {
+	s.mu.Lock()
+	req.Rule = -1
+	var rule Rule
+	for i := range s.rules {
+		cr := &s.rules[i]
+		if cr.matches(req) {
+			cr.used++
+			req.Rule, rule = i, cr.Rule
+			break
+		}
+	}
+	s.requests = append(s.requests, req)
+	delay := s.latency + time.Duration(rule.Latency)*time.Millisecond
+	s.mu.Unlock()
+
+	return rule, sleep(r, delay)
+}
+
+func (cr *compiledRule) matches(req Request) bool {
+	if cr.Endpoint != "" && cr.Endpoint != req.Endpoint {
+		return false
+	}
+	if cr.Times > 0 && cr.used >= cr.Times {
+		return false
+	}
+	if cr.match != nil && !cr.match.MatchString(req.Prompt) {
+		return false
+	}
+	return cr.path == nil || cr.path.MatchString(req.FilePath)
+}
+
+// completion returns the rule's text and finish reason after truncation.
+func (rule Rule) completion() (string, string) {
+	text, reason := rule.Text, rule.FinishReason
+	if rule.Truncate > 0 && rule.Truncate < len(text) {
+		text, reason = text[:rule.Truncate], "length"
+	}
+	if reason == "" {
+		reason = "stop"
+	}
+	return text, reason
+}
+
+// openAIChoice is a choice in completion responses and stream chunks.
+type openAIChoice struct {
+	Index        int     `json:"index"`
+	Text         string  `json:"text"`
+	Logprobs     any     `json:"logprobs"`
+	FinishReason *string `json:"finish_reason"`
+}
+
+type openAIResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (s *Server) serveOpenAI(w http.ResponseWriter, r *http.Request) {
+	var req openai.CompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	rule, ok := s.answer(r, Request{Endpoint: EndpointOpenAI, Prompt: req.Prompt, Stream: req.Stream})
+	if !ok {
+		return
+	}
+	if rule.Status != 0 {
+		http.Error(w, rule.Error, rule.Status)
+		return
+	}
+
+	text, reason := rule.completion()
+	resp := openAIResponse{
+		ID:      fmt.Sprintf("cmpl-mock-%d", time.Now().UnixNano()),
+		Object:  "text_completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+	}
+	if !req.Stream {
+		resp.Choices = []openAIChoice{{Text: text, FinishReason: &reason}}
+		// Rough token counts, as with the character heuristic
+		resp.Usage = &openAIUsage{PromptTokens: len(req.Prompt) / 2, CompletionTokens: len(text) / 2}
+		resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+	resp.Object = "text_completion.chunk"
+	chunks := splitChunks(text, rule.Chunking)
+	for i, chunk := range chunks {
+		if i > 0 && !sleep(r, time.Duration(rule.ChunkDelay)*time.Millisecond) {
+			return
+		}
+		resp.Choices = []openAIChoice{{Text: chunk}}
+		if i == len(chunks)-1 {
+			resp.Choices[0].FinishReason = &reason
+		}
+		data, _ := json.Marshal(resp)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	io.WriteString(w, "data: [DONE]\n\n")
+}
+
+// splitChunks splits text into SSE chunks: whole lines, or words with
+// their trailing whitespace. Empty text is one empty chunk so the finish
+// reason is still sent.
+func splitChunks(text, chunking string) []string {
+	var chunks []string
+	if chunking == "line" {
+		chunks = strings.SplitAfter(text, "\n")
+	} else {
+		chunks = tokenPattern.FindAllString(text, -1)
+	}
+	if len(chunks) > 0 && chunks[len(chunks)-1] == "" {
+		chunks = chunks[:len(chunks)-1]
+	}
+	if len(chunks) == 0 {
+		return []string{""}
+	}
+	return chunks
+}
+
+var tokenPattern = regexp.MustCompile(`\s*\S+\s*|\s+`)
+
+func (s *Server) serveSweep(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "br" {
+		body = brotli.NewReader(r.Body)
+	}
+	var req sweep.AutocompleteRequest
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	rule, ok := s.answer(r, Request{Endpoint: EndpointSweep, Prompt: req.FileContents, FilePath: req.FilePath})
+	if !ok {
+		return
+	}
+	if rule.Status != 0 {
+		http.Error(w, rule.Error, rule.Status)
+		return
+	}
+
+	text, reason := rule.completion()
+	start := min(max(req.CursorPosition, 0), len(req.FileContents))
+	end := start
+	if rule.Replace != "" {
+		if i := strings.Index(req.FileContents, rule.Replace); i >= 0 {
+			start, end = i, i+len(rule.Replace)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sweep.AutocompleteResponse{
+		AutocompleteID: fmt.Sprintf("mock-%d", time.Now().UnixNano()),
+		StartIndex:     start,
+		EndIndex:       end,
+		Completion:     text,
+		Confidence:     1,
+		FinishReason:   &reason,
+	})
+}
+
+// sleep waits for d unless the client cancels the request first.
+func sleep(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return r.Context().Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
@@ -0,0 +1,130 @@
+package mockserver
+
+import (
+	"context"
+	"cursortab/assert"
+	"cursortab/client/apikey"
+	"cursortab/client/openai"
+	"cursortab/client/sweep"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, rules ...Rule) (*Server, string) {
+	t.Helper()
+	s, err := New(rules)
+	assert.NoError(t, err, "New")
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts.URL
+}
+
+func TestOpenAI_CompletionMatchesRules(t *testing.T) {
+	s, url := newTestServer(t,
+		Rule{Match: `func add`, Text: "\treturn a + b\n}"},
+		Rule{Text: "fallback"},
+	)
+	client := openai.NewClient(url, openai.DefaultCompletionPath)
+
+	resp, err := client.DoCompletion(context.Background(), &openai.CompletionRequest{Prompt: "func add(a, b int) int {\n"})
+	assert.NoError(t, err, "DoCompletion")
+	assert.Equal(t, "\treturn a + b\n}", resp.Choices[0].Text, "matching rule")
+	assert.Equal(t, "stop", resp.Choices[0].FinishReason, "finish reason")
+	assert.Greater(t, resp.Usage.TotalTokens, 0, "usage reported")
+
+	resp, _ = client.DoCompletion(context.Background(), &openai.CompletionRequest{Prompt: "other"})
+	assert.Equal(t, "fallback", resp.Choices[0].Text, "next rule")
+
+	requests := s.Requests()
+	assert.Len(t, 2, requests, "requests recorded")
+	assert.Equal(t, 0, requests[0].Rule, "first rule answered")
+	assert.Equal(t, 1, requests[1].Rule, "second rule answered")
+}
+
+func TestOpenAI_LineAndTokenStreams(t *testing.T) {
+	_, url := newTestServer(t,
+		Rule{Match: `lines`, Text: "one\ntwo\nthree", Chunking: "line"},
+		Rule{Text: "alpha beta gamma", Truncate: 10},
+	)
+	client := openai.NewClient(url, openai.DefaultCompletionPath)
+
+	stream := client.DoLineStream(context.Background(), &openai.CompletionRequest{Prompt: "lines"}, 0, nil)
+	var lines []string
+	for line := range stream.LinesChan() {
+		lines = append(lines, line)
+	}
+	result := <-stream.DoneChan()
+	assert.Equal(t, []string{"one", "two", "three"}, lines, "lines emitted")
+	assert.Equal(t, "one\ntwo\nthree", result.Text, "full text")
+	assert.Equal(t, "stop", result.FinishReason, "finish reason")
+
+	stream = client.DoTokenStream(context.Background(), &openai.CompletionRequest{Prompt: "tokens"}, 0, nil)
+	var updates []string
+	for text := range stream.LinesChan() {
+		updates = append(updates, text)
+	}
+	result = <-stream.DoneChan()
+	assert.Equal(t, []string{"alpha ", "alpha beta"}, updates, "one update per token")
+	assert.Equal(t, "length", result.FinishReason, "truncated")
+}
+
+func TestSweep_BrotliRequestAndReplace(t *testing.T) {
+	s, url := newTestServer(t, Rule{Endpoint: EndpointSweep, Path: `\.go$`, Replace: "return 0", Text: "return a + b"})
+	t.Setenv("CURSORTAB_MOCK_KEY", "test")
+	client, err := sweep.NewClient(url, apikey.New(apikey.Config{Env: "CURSORTAB_MOCK_KEY"}))
+	assert.NoError(t, err, "NewClient")
+
+	contents := "func add(a, b int) int {\n\treturn 0\n}\n"
+	resp, err := client.DoAutocomplete(context.Background(), &sweep.AutocompleteRequest{
+		FilePath:       "add.go",
+		FileContents:   contents,
+		CursorPosition: 26,
+	})
+	assert.NoError(t, err, "DoAutocomplete")
+	assert.Equal(t, "return a + b", resp.Completion, "completion")
+	assert.Equal(t, "return 0", contents[resp.StartIndex:resp.EndIndex], "replaced range")
+	assert.Equal(t, "add.go", s.Requests()[0].FilePath, "decoded file path")
+
+	resp, _ = client.DoAutocomplete(context.Background(), &sweep.AutocompleteRequest{FilePath: "notes.txt", FileContents: "x", CursorPosition: 1})
+	assert.Equal(t, "", resp.Completion, "no rule matched")
+	assert.Equal(t, 1, resp.StartIndex, "inserted at cursor")
+}
+
+func TestRules_ErrorsLatencyAndTimes(t *testing.T) {
+	s, url := newTestServer(t,
+		Rule{Times: 1, Status: 500, Error: "overloaded"},
+		Rule{Text: "ok", Latency: 50},
+	)
+	client := openai.NewClient(url, openai.DefaultCompletionPath)
+
+	_, err := client.DoCompletion(context.Background(), &openai.CompletionRequest{})
+	assert.Error(t, err, "scripted failure")
+
+	start := time.Now()
+	resp, err := client.DoCompletion(context.Background(), &openai.CompletionRequest{})
+	assert.NoError(t, err, "failure rule used up")
+	assert.Equal(t, "ok", resp.Choices[0].Text, "second rule")
+	assert.True(t, time.Since(start) >= 50*time.Millisecond, "latency applied")
+
+	s.SetLatency(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = client.DoCompletion(ctx, &openai.CompletionRequest{})
+	assert.Error(t, err, "client gave up waiting")
+}
+
+func TestLoadScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.json")
+	os.WriteFile(path, []byte(`{"rules": [{"endpoint": "sweep", "text": "x", "finish_reason": "length"}]}`), 0o600)
+	rules, err := LoadScript(path)
+	assert.NoError(t, err, "LoadScript")
+	assert.Equal(t, []Rule{{Endpoint: EndpointSweep, Text: "x", FinishReason: "length"}}, rules, "rules")
+
+	_, err = New([]Rule{{Match: "("}})
+	assert.Error(t, err, "invalid regexp")
+	_, err = New([]Rule{{Endpoint: "anthropic"}})
+	assert.Error(t, err, "unknown endpoint")
+}
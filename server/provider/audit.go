@@ -0,0 +1,52 @@
+package provider
+
+import (
+	"cursortab/audit"
+	"cursortab/logger"
+	"cursortab/types"
+)
+
+// OpenAuditLog opens the audit log for a provider config.
+// Returns nil when auditing is disabled.
+func OpenAuditLog(cfg *types.ProviderConfig) (*audit.Log, error) {
+	if cfg.AuditLogPath == "" {
+		return nil, nil
+	}
+	return audit.Open(cfg.AuditLogPath)
+}
+
+// RecordAudit appends an entry to the audit log, logging failures instead of
+// failing the completion.
+func RecordAudit(log *audit.Log, entry audit.Entry) {
+	if err := log.Record(entry); err != nil {
+		logger.Error("audit log: %v", err)
+	}
+}
+
+// auditRequest records the prompt sent for ctx. responseID and err describe
+// the outcome when known (streams are recorded before the response).
+func (p *Provider) auditRequest(ctx *Context, prompt, responseID string, err error) {
+	if p.Audit == nil {
+		return
+	}
+	req := ctx.Request
+	endLine := ctx.WindowEnd
+	if endLine == 0 {
+		endLine = len(req.Lines)
+	}
+	entry := audit.Entry{
+		Provider:    p.Name,
+		Endpoint:    p.Config.ProviderURL,
+		FilePath:    req.FilePath,
+		StartLine:   ctx.WindowStart + 1,
+		EndLine:     endLine,
+		Bytes:       len(prompt),
+		Hashes:      map[string]string{"prompt": audit.Hash(prompt)},
+		ResponseID:  responseID,
+		PrivacyMode: req.PrivacyMode,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	RecordAudit(p.Audit, entry)
+}
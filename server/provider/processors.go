@@ -2,7 +2,6 @@ package provider
 
 import (
 	"cursortab/client/openai"
-	"cursortab/logger"
 	"cursortab/text"
 	"cursortab/types"
 	"cursortab/utils"
@@ -150,6 +149,7 @@ func TrimContent() Preprocessor {
 			cursorLine,
 			ctx.Request.CursorCol,
 			p.Config.ProviderMaxTokens,
+			p.Config.Tokenizer,
 		)
 		ctx.TrimmedLines = trimmedLines
 		ctx.CursorLine = newCursorLine
@@ -175,7 +175,7 @@ func SkipIfTextAfterCursor() Preprocessor {
 		if req.CursorRow >= 1 && req.CursorRow <= len(req.Lines) {
 			currentLine := req.Lines[req.CursorRow-1]
 			if req.CursorCol < len(currentLine) {
-				logger.Debug("%s: skipping, text after cursor", p.Name)
+				ctx.Log.Debug("%s: skipping, text after cursor", p.Name)
 				return ErrSkipCompletion
 			}
 		}
@@ -189,7 +189,7 @@ func SkipIfTextAfterCursor() Preprocessor {
 func RejectEmpty() Postprocessor {
 	return func(p *Provider, ctx *Context) (*types.CompletionResponse, bool) {
 		if strings.TrimSpace(ctx.Result.Text) == "" {
-			logger.Debug("%s: rejected, empty or whitespace-only", p.Name)
+			ctx.Log.Debug("%s: rejected, empty or whitespace-only", p.Name)
 			return p.EmptyResponse(), true
 		}
 		return nil, false
@@ -200,7 +200,7 @@ func RejectEmpty() Postprocessor {
 func RejectTruncated() Postprocessor {
 	return func(p *Provider, ctx *Context) (*types.CompletionResponse, bool) {
 		if ctx.Result.FinishReason == "length" {
-			logger.Info("%s: rejected, truncated (finish_reason=length)", p.Name)
+			ctx.Log.Info("%s: rejected, truncated (finish_reason=length)", p.Name)
 			return p.EmptyResponse(), true
 		}
 		return nil, false
@@ -219,7 +219,7 @@ func DropLastLineIfTruncated() Postprocessor {
 		originalLineCount := len(lines)
 
 		if len(lines) <= 1 {
-			logger.Info("%s: rejected, truncated single line", p.Name)
+			ctx.Log.Info("%s: rejected, truncated single line", p.Name)
 			return p.EmptyResponse(), true
 		}
 
@@ -227,12 +227,12 @@ func DropLastLineIfTruncated() Postprocessor {
 		ctx.Result.Text = strings.Join(lines, "\n")
 
 		if strings.TrimSpace(ctx.Result.Text) == "" {
-			logger.Info("%s: rejected, empty after dropping truncated line", p.Name)
+			ctx.Log.Info("%s: rejected, empty after dropping truncated line", p.Name)
 			return p.EmptyResponse(), true
 		}
 
 		ctx.EndLineInc = ctx.WindowStart + len(lines)
-		logger.Info("%s: truncated, dropped last line (%d -> %d lines)",
+		ctx.Log.Info("%s: truncated, dropped last line (%d -> %d lines)",
 			p.Name, originalLineCount, len(lines))
 		return nil, false
 	}
@@ -259,14 +259,14 @@ func AnchorTruncation(threshold float64) Postprocessor {
 			newLines, oldLines, finishReason, ctx.WindowStart, ctx.WindowEnd,
 		)
 		if shouldReject {
-			logger.Debug("%s: rejected, truncation handling failed", p.Name)
+			ctx.Log.Debug("%s: rejected, truncation handling failed", p.Name)
 			return p.EmptyResponse(), true
 		}
 
 		if len(oldLines) > 10 {
 			minAllowedLines := int(float64(len(oldLines)) * threshold)
 			if len(processedLines) < minAllowedLines {
-				logger.Debug("%s: rejected, too few lines (%d < %d min)",
+				ctx.Log.Debug("%s: rejected, too few lines (%d < %d min)",
 					p.Name, len(processedLines), minAllowedLines)
 				return p.EmptyResponse(), true
 			}
@@ -275,7 +275,7 @@ func AnchorTruncation(threshold float64) Postprocessor {
 		ctx.Result.Text = strings.Join(processedLines, "\n")
 		ctx.EndLineInc = endLineInc
 
-		logger.Info("%s: truncated, replacing lines %d-%d (%d -> %d lines)",
+		ctx.Log.Info("%s: truncated, replacing lines %d-%d (%d -> %d lines)",
 			p.Name, ctx.WindowStart+1, endLineInc, originalLineCount, len(processedLines))
 		return nil, false
 	}
@@ -295,7 +295,7 @@ func ValidateAnchorPosition(maxAnchorRatio float64) Postprocessor {
 		maxAllowedAnchor := int(float64(len(oldLines)) * maxAnchorRatio)
 
 		if firstLineAnchor > maxAllowedAnchor {
-			logger.Debug("%s: rejected, first line anchors at %d (max allowed %d)",
+			ctx.Log.Debug("%s: rejected, first line anchors at %d (max allowed %d)",
 				p.Name, firstLineAnchor, maxAllowedAnchor)
 			return p.EmptyResponse(), true
 		}
@@ -406,8 +406,13 @@ func handleTruncatedCompletionWithAnchor(
 }
 
 // IsNoOpReplacement checks if replacing oldLines with newLines would result in no change.
-func IsNoOpReplacement(newLines, oldLines []string) bool {
+// Trailing whitespace at the end of the block is always ignored; policy
+// decides which other differences are cosmetic.
+func IsNoOpReplacement(newLines, oldLines []string, policy types.Normalization) bool {
 	newText := strings.TrimRight(strings.Join(newLines, "\n"), " \t\n\r")
 	oldText := strings.TrimRight(strings.Join(oldLines, "\n"), " \t\n\r")
-	return newText == oldText
+	if newText == oldText {
+		return true
+	}
+	return text.EquivalentLines(strings.Split(newText, "\n"), strings.Split(oldText, "\n"), policy)
 }
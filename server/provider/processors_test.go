@@ -384,6 +384,7 @@ func TestIsNoOpReplacement(t *testing.T) {
 		name     string
 		newLines []string
 		oldLines []string
+		policy   types.Normalization
 		want     bool
 	}{
 		{
@@ -416,11 +417,51 @@ func TestIsNoOpReplacement(t *testing.T) {
 			oldLines: []string{"line 1", "line 2"},
 			want:     false,
 		},
+		{
+			name:     "trailing whitespace inside block",
+			newLines: []string{"a  ", "b"},
+			oldLines: []string{"a", "b"},
+			want:     false,
+		},
+		{
+			name:     "trailing whitespace inside block ignored",
+			newLines: []string{"a  ", "b"},
+			oldLines: []string{"a", "b"},
+			policy:   types.Normalization{TrailingWhitespace: true},
+			want:     true,
+		},
+		{
+			name:     "reindent",
+			newLines: []string{"\tif x {", "\t\treturn", "\t}"},
+			oldLines: []string{"    if x {", "        return", "    }"},
+			want:     false,
+		},
+		{
+			name:     "reindent ignored",
+			newLines: []string{"\tif x {", "\t\treturn", "\t}"},
+			oldLines: []string{"    if x {", "        return", "    }"},
+			policy:   types.Normalization{Indentation: true},
+			want:     true,
+		},
+		{
+			name:     "formatting ignored",
+			newLines: []string{"f(a, b)", "", "x := 1"},
+			oldLines: []string{"f( a,b )", "x  :=  1"},
+			policy:   types.Normalization{Formatting: true},
+			want:     true,
+		},
+		{
+			name:     "formatting keeps real edits",
+			newLines: []string{"f(a, c)"},
+			oldLines: []string{"f(a, b)"},
+			policy:   types.Normalization{TrailingWhitespace: true, Indentation: true, Formatting: true},
+			want:     false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := IsNoOpReplacement(tt.newLines, tt.oldLines)
+			got := IsNoOpReplacement(tt.newLines, tt.oldLines, tt.policy)
 			assert.Equal(t, tt.want, got, "IsNoOpReplacement")
 		})
 	}
@@ -2,9 +2,12 @@ package provider
 
 import (
 	"context"
+	"cursortab/audit"
 	"cursortab/client/openai"
 	"cursortab/engine"
 	"cursortab/logger"
+	"cursortab/redact"
+	"cursortab/tokenizer"
 	"cursortab/types"
 	"errors"
 	"fmt"
@@ -54,6 +57,12 @@ type Context struct {
 
 	// Streaming state
 	CompletionRequest *openai.CompletionRequest // Built request for streaming
+
+	// Redactions maps placeholders in the prompt back to secrets (nil = none)
+	Redactions *redact.Session
+
+	// Log tags lines with the engine's request ID
+	Log logger.Entry
 }
 
 // GetWindowStart returns the 0-indexed start offset of the trimmed window.
@@ -80,12 +89,14 @@ type Provider struct {
 	Validators     []Validator        // Validators run on first line during streaming
 	StopTokens     []string           // Stop tokens for streaming (provider-specific)
 	DiffBuilder    DiffHistoryBuilder // Processes diff history for the prompt
+	Redactor       *redact.Redactor   // Redacts secrets from prompts (nil = disabled)
+	Audit          *audit.Log         // Records what is sent to the provider (nil = disabled)
 }
 
 // GetCompletion implements engine.Provider
 func (p *Provider) GetCompletion(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
 	defer logger.Trace("Provider.GetCompletion")()
-	pctx := &Context{Request: req}
+	pctx := &Context{Request: req, Log: logger.FromContext(ctx, "provider")}
 
 	for _, pre := range p.Preprocessors {
 		if err := pre(p, pctx); err != nil {
@@ -97,20 +108,23 @@ func (p *Provider) GetCompletion(ctx context.Context, req *types.CompletionReque
 	}
 
 	completionReq := p.PromptBuilder(p, pctx)
-	p.logRequest(completionReq, pctx.MaxLines)
+	p.redactPrompt(pctx, completionReq)
+	p.logRequest(pctx, completionReq, pctx.MaxLines)
 
 	resp, err := p.Client.DoCompletion(ctx, completionReq)
 	if err != nil {
+		p.auditRequest(pctx, completionReq.Prompt, "", err)
 		return nil, fmt.Errorf("%s: %w", p.Name, err)
 	}
+	p.auditRequest(pctx, completionReq.Prompt, resp.ID, nil)
 
 	result := &openai.StreamResult{}
 	if len(resp.Choices) > 0 {
-		result.Text = resp.Choices[0].Text
+		result.Text = pctx.Redactions.Restore(resp.Choices[0].Text)
 		result.FinishReason = resp.Choices[0].FinishReason
 	}
 	pctx.Result = result
-	p.logResponse(result)
+	p.logResponse(pctx)
 
 	for _, post := range p.Postprocessors {
 		if resp, done := post(p, pctx); done {
@@ -133,7 +147,7 @@ func (p *Provider) EmptyResponse() *types.CompletionResponse {
 // startLine and endLineInc are 1-indexed.
 func (p *Provider) BuildCompletion(ctx *Context, startLine, endLineInc int, lines []string) (*types.CompletionResponse, bool) {
 	req := ctx.Request
-	if endLineInc <= len(req.Lines) && IsNoOpReplacement(lines, req.Lines[startLine-1:endLineInc]) {
+	if endLineInc <= len(req.Lines) && IsNoOpReplacement(lines, req.Lines[startLine-1:endLineInc], p.Config.Normalization) {
 		return p.EmptyResponse(), true
 	}
 
@@ -149,8 +163,12 @@ func (p *Provider) BuildCompletion(ctx *Context, startLine, endLineInc int, line
 	}, true
 }
 
-func (p *Provider) logRequest(req *openai.CompletionRequest, maxLines int) {
-	logger.Debug("%s provider request:\n  URL: %s\n  Model: %s\n  Temperature: %.2f\n  MaxTokens: %d\n  MaxLines: %d\n  Prompt length: %d chars\n  Prompt:\n%s",
+func (p *Provider) logRequest(ctx *Context, req *openai.CompletionRequest, maxLines int) {
+	prompt := req.Prompt
+	if ctx.Request.PrivacyMode {
+		prompt = "<privacy mode>"
+	}
+	ctx.Log.Debug("%s provider request:\n  URL: %s\n  Model: %s\n  Temperature: %.2f\n  MaxTokens: %d\n  MaxLines: %d\n  Prompt length: %d chars, %d tokens\n  Prompt:\n%s",
 		p.Name,
 		p.Config.ProviderURL,
 		req.Model,
@@ -158,16 +176,22 @@ func (p *Provider) logRequest(req *openai.CompletionRequest, maxLines int) {
 		req.MaxTokens,
 		maxLines,
 		len(req.Prompt),
-		req.Prompt)
+		tokenizer.Or(p.Config.Tokenizer).Count(req.Prompt),
+		prompt)
 }
 
-func (p *Provider) logResponse(result *openai.StreamResult) {
-	logger.Debug("%s provider response:\n  Text length: %d chars\n  FinishReason: %s\n  StoppedEarly: %v\n  Text:\n%s",
+func (p *Provider) logResponse(ctx *Context) {
+	result := ctx.Result
+	text := result.Text
+	if ctx.Request.PrivacyMode {
+		text = "<privacy mode>"
+	}
+	ctx.Log.Debug("%s provider response:\n  Text length: %d chars\n  FinishReason: %s\n  StoppedEarly: %v\n  Text:\n%s",
 		p.Name,
 		len(result.Text),
 		result.FinishReason,
 		result.StoppedEarly,
-		result.Text)
+		text)
 }
 
 // GetStreamingType returns the streaming type for this provider (implements engine.LineStreamProvider)
@@ -180,7 +204,7 @@ func (p *Provider) GetStreamingType() int {
 // Returns (stream, providerContext, error). Implements engine.LineStreamProvider.
 func (p *Provider) PrepareLineStream(ctx context.Context, req *types.CompletionRequest) (engine.LineStream, any, error) {
 	defer logger.Trace("Provider.PrepareLineStream")()
-	pctx := &Context{Request: req}
+	pctx := &Context{Request: req, Log: logger.FromContext(ctx, "provider")}
 
 	for _, pre := range p.Preprocessors {
 		if err := pre(p, pctx); err != nil {
@@ -193,10 +217,12 @@ func (p *Provider) PrepareLineStream(ctx context.Context, req *types.CompletionR
 
 	completionReq := p.PromptBuilder(p, pctx)
 	pctx.CompletionRequest = completionReq
-	p.logRequest(completionReq, pctx.MaxLines)
+	p.redactPrompt(pctx, completionReq)
+	p.logRequest(pctx, completionReq, pctx.MaxLines)
 
 	stream := p.Client.DoLineStream(ctx, completionReq, pctx.MaxLines, p.StopTokens)
-	return stream, pctx, nil
+	p.auditRequest(pctx, completionReq.Prompt, "", nil)
+	return restoreStream(stream, pctx.Redactions), pctx, nil
 }
 
 // ValidateFirstLine runs validators on the first received line (implements engine.LineStreamProvider)
@@ -208,7 +234,7 @@ func (p *Provider) ValidateFirstLine(providerCtx any, firstLine string) error {
 
 	for _, validator := range p.Validators {
 		if err := validator(p, pctx, firstLine); err != nil {
-			logger.Debug("%s: first line validation failed: %v", p.Name, err)
+			pctx.Log.Debug("%s: first line validation failed: %v", p.Name, err)
 			return err
 		}
 	}
@@ -227,7 +253,7 @@ func (p *Provider) FinishLineStream(providerCtx any, text string, finishReason s
 		FinishReason: finishReason,
 		StoppedEarly: stoppedEarly,
 	}
-	p.logResponse(pctx.Result)
+	p.logResponse(pctx)
 
 	for _, post := range p.Postprocessors {
 		if resp, done := post(p, pctx); done {
@@ -242,7 +268,7 @@ func (p *Provider) FinishLineStream(providerCtx any, text string, finishReason s
 // Returns (stream, providerContext, error). Implements engine.TokenStreamProvider.
 func (p *Provider) PrepareTokenStream(ctx context.Context, req *types.CompletionRequest) (engine.LineStream, any, error) {
 	defer logger.Trace("Provider.PrepareTokenStream")()
-	pctx := &Context{Request: req}
+	pctx := &Context{Request: req, Log: logger.FromContext(ctx, "provider")}
 
 	for _, pre := range p.Preprocessors {
 		if err := pre(p, pctx); err != nil {
@@ -255,11 +281,13 @@ func (p *Provider) PrepareTokenStream(ctx context.Context, req *types.Completion
 
 	completionReq := p.PromptBuilder(p, pctx)
 	pctx.CompletionRequest = completionReq
-	p.logRequest(completionReq, 0) // maxLines=0 for token streaming
+	p.redactPrompt(pctx, completionReq)
+	p.logRequest(pctx, completionReq, 0) // maxLines=0 for token streaming
 
 	// DoTokenStream uses StopTokens and no maxChars limit (0)
 	stream := p.Client.DoTokenStream(ctx, completionReq, 0, p.StopTokens)
-	return stream, pctx, nil
+	p.auditRequest(pctx, completionReq.Prompt, "", nil)
+	return restoreStream(stream, pctx.Redactions), pctx, nil
 }
 
 // FinishTokenStream runs postprocessors on the final accumulated result (implements engine.TokenStreamProvider)
@@ -274,7 +302,7 @@ func (p *Provider) FinishTokenStream(providerCtx any, text string) (*types.Compl
 		FinishReason: "stop",
 		StoppedEarly: false,
 	}
-	p.logResponse(pctx.Result)
+	p.logResponse(pctx)
 
 	for _, post := range p.Postprocessors {
 		if resp, done := post(p, pctx); done {
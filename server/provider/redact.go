@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"sync"
+
+	"cursortab/client/openai"
+	"cursortab/engine"
+	"cursortab/redact"
+	"cursortab/types"
+)
+
+// NewRedactor builds the secret redactor for a provider config.
+// Returns nil when redaction is disabled.
+func NewRedactor(cfg *types.ProviderConfig) (*redact.Redactor, error) {
+	if !cfg.RedactSecrets {
+		return nil, nil
+	}
+	return redact.New(cfg.RedactPatterns)
+}
+
+// redactPrompt replaces secrets in the built prompt with placeholders. The
+// session is kept on the context so completions can be restored.
+func (p *Provider) redactPrompt(ctx *Context, req *openai.CompletionRequest) {
+	ctx.Redactions = p.Redactor.NewSession()
+	req.Prompt = ctx.Redactions.Text(req.Prompt)
+	if n := ctx.Redactions.Count(); n > 0 {
+		ctx.Log.Debug("%s: redacted %d secrets from prompt", p.Name, n)
+	}
+}
+
+// restoreStream wraps a stream so every line has its placeholders restored
+// before the engine sees it. Returns the stream unchanged when nothing was
+// redacted.
+func restoreStream(stream engine.LineStream, session *redact.Session) engine.LineStream {
+	if session.Count() == 0 {
+		return stream
+	}
+	rs := &restoringStream{
+		src:  stream,
+		out:  make(chan string, 100),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(rs.out)
+		for line := range stream.LinesChan() {
+			select {
+			case rs.out <- session.Restore(line):
+			case <-rs.done:
+				return
+			}
+		}
+	}()
+	return rs
+}
+
+type restoringStream struct {
+	src  engine.LineStream
+	out  chan string
+	done chan struct{}
+	once sync.Once
+}
+
+func (s *restoringStream) LinesChan() <-chan string { return s.out }
+
+func (s *restoringStream) Cancel() {
+	s.once.Do(func() { close(s.done) })
+	s.src.Cancel()
+}
@@ -3,19 +3,25 @@ package sweep
 import (
 	"context"
 	"fmt"
-	"os"
 	"strings"
 
+	"cursortab/audit"
+	"cursortab/client/apikey"
 	clientSweep "cursortab/client/sweep"
 	"cursortab/engine"
+	"cursortab/logger"
 	"cursortab/provider"
+	"cursortab/redact"
+	"cursortab/text"
 	"cursortab/types"
 )
 
 // hostedProvider implements engine.Provider using Sweep's hosted next-edit API.
 type hostedProvider struct {
-	cfg    *types.ProviderConfig
-	client sweepClient
+	cfg      *types.ProviderConfig
+	client   sweepClient
+	redactor *redact.Redactor // nil = redaction disabled
+	audit    *audit.Log       // nil = auditing disabled
 }
 
 var _ engine.Provider = (*hostedProvider)(nil)
@@ -25,46 +31,69 @@ type sweepClient interface {
 }
 
 func NewProvider(cfg *types.ProviderConfig) (engine.Provider, error) {
-	// Check if API key is available before attempting to create client
-	if cfg.APIKey == "" {
-		envVar := cfg.APIKeyEnv
-		if envVar == "" {
-			envVar = clientSweep.DefaultAPIKeyEnv
-		}
-		if os.Getenv(envVar) == "" {
-			return nil, fmt.Errorf("hosted Sweep requires API key: set %s environment variable or provide api_key in config", envVar)
-		}
+	envVar := cfg.APIKeyEnv
+	if envVar == "" {
+		envVar = clientSweep.DefaultAPIKeyEnv
+	}
+	keys := apikey.New(apikey.Config{
+		Key:     cfg.APIKey,
+		Command: cfg.APIKeyCommand,
+		File:    cfg.APIKeyFile,
+		Env:     envVar,
+		TTL:     cfg.APIKeyTTL,
+	})
+
+	c, err := clientSweep.NewClient(cfg.ProviderURL, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	redactor, err := provider.NewRedactor(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	c, err := clientSweep.NewClient(cfg.ProviderURL, cfg.APIKey, cfg.APIKeyEnv)
+	auditLog, err := provider.OpenAuditLog(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	return &hostedProvider{cfg: cfg, client: c}, nil
+	return &hostedProvider{cfg: cfg, client: c, redactor: redactor, audit: auditLog}, nil
 }
 
 func (p *hostedProvider) GetCompletion(ctx context.Context, req *types.CompletionRequest) (*types.CompletionResponse, error) {
+	log := logger.FromContext(ctx, "sweep")
+	// Secrets are replaced line by line, so line numbers are unchanged and
+	// only byte offsets need mapping
+	redactions := p.redactor.NewSession()
+	lines := redactions.Lines(req.Lines)
+
 	// Send complete file contents (like Zed does)
-	fileContents := strings.Join(req.Lines, "\n")
+	fileContents := strings.Join(lines, "\n")
 
 	// Get original file contents (before current edits)
 	originalContents := fileContents
 	if len(req.PreviousLines) > 0 {
-		originalContents = strings.Join(req.PreviousLines, "\n")
+		originalContents = redactions.Text(strings.Join(req.PreviousLines, "\n"))
+	}
+	recentChanges := redactions.Text(buildRecentChanges(req))
+	if n := redactions.Count(); n > 0 {
+		log.Debug("sweep: redacted %d secrets from request", n)
 	}
 
-	// Calculate cursor position as byte offset in the FULL file
+	// Calculate cursor position as byte offset in the FULL file. Columns are
+	// already bytes (see text/columns.go), matching use_bytes below.
 	cursorPosition := 0
 	cursorLine := req.CursorRow - 1 // Convert to 0-indexed
 	cursorCol := req.CursorCol      // Already 0-indexed
 
-	for i, line := range req.Lines {
+	for i, line := range lines {
 		if i < cursorLine {
 			cursorPosition += len(line) + 1 // +1 for newline
 			continue
 		}
 		if i == cursorLine {
+			cursorCol = redactions.Column(req.Lines[i], text.RuneBoundary(req.Lines[i], cursorCol))
 			if cursorCol > len(line) {
 				cursorCol = len(line)
 			}
@@ -79,19 +108,22 @@ func (p *hostedProvider) GetCompletion(ctx context.Context, req *types.Completio
 		Branch:               nil,
 		FilePath:             req.FilePath,
 		FileContents:         fileContents,
-		RecentChanges:        buildRecentChanges(req),
+		RecentChanges:        recentChanges,
 		CursorPosition:       cursorPosition,
 		OriginalFileContents: originalContents,
 		FileChunks:           []clientSweep.FileChunk{},
 		RetrievalChunks:      []clientSweep.FileChunk{},
 		RecentUserActions:    []clientSweep.UserAction{},
 		MultipleSuggestions:  false,
-		PrivacyModeEnabled:   false,
+		PrivacyModeEnabled:   req.PrivacyMode,
 		ChangesAboveCursor:   true,
 		UseBytes:             true,
 	}
 
 	sweepResp, err := p.client.DoAutocomplete(ctx, sweepReq)
+	if clientSweep.Sent(err) {
+		p.auditRequest(req, sweepReq, sweepResp, err)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -105,17 +137,12 @@ func (p *hostedProvider) GetCompletion(ctx context.Context, req *types.Completio
 		return emptyResponse(), nil
 	}
 
-	// Apply byte replacement to get full updated content
-	if startIndex > len(fileContents) {
-		startIndex = len(fileContents)
-	}
-	if endIndex > len(fileContents) {
-		endIndex = len(fileContents)
-	}
-	if startIndex > endIndex {
-		startIndex = endIndex
-	}
+	// Apply byte replacement to get full updated content. Indices are clamped
+	// to character boundaries so a bad offset cannot split a multibyte rune.
+	startIndex = text.RuneBoundary(fileContents, startIndex)
+	endIndex = max(startIndex, text.RuneBoundary(fileContents, endIndex))
 	updatedContent := fileContents[:startIndex] + completionText + fileContents[endIndex:]
+	updatedContent = redactions.Restore(updatedContent)
 
 	oldLines := req.Lines
 	newLines := strings.Split(updatedContent, "\n")
@@ -153,7 +180,7 @@ func (p *hostedProvider) GetCompletion(ctx context.Context, req *types.Completio
 	}
 
 	// Match generic provider no-op detection behavior
-	if endLineInc <= len(req.Lines) && provider.IsNoOpReplacement(changedNewLines, req.Lines[startLine-1:endLineInc]) {
+	if endLineInc <= len(req.Lines) && provider.IsNoOpReplacement(changedNewLines, req.Lines[startLine-1:endLineInc], p.cfg.Normalization) {
 		return emptyResponse(), nil
 	}
 
@@ -169,6 +196,42 @@ func (p *hostedProvider) GetCompletion(ctx context.Context, req *types.Completio
 	}, nil
 }
 
+// auditRequest records the content sent in sweepReq. The whole file is sent,
+// so the line range covers every line.
+func (p *hostedProvider) auditRequest(req *types.CompletionRequest, sweepReq *clientSweep.AutocompleteRequest, resp *clientSweep.AutocompleteResponse, err error) {
+	if p.audit == nil {
+		return
+	}
+	parts := map[string]string{
+		"file_contents":          sweepReq.FileContents,
+		"original_file_contents": sweepReq.OriginalFileContents,
+		"recent_changes":         sweepReq.RecentChanges,
+	}
+	entry := audit.Entry{
+		Provider:    "sweep",
+		Endpoint:    p.cfg.ProviderURL + clientSweep.DefaultAutocompletePath,
+		FilePath:    req.FilePath,
+		StartLine:   1,
+		EndLine:     len(req.Lines),
+		Hashes:      make(map[string]string, len(parts)),
+		PrivacyMode: req.PrivacyMode,
+	}
+	for name, content := range parts {
+		if content == "" {
+			continue
+		}
+		entry.Bytes += len(content)
+		entry.Hashes[name] = audit.Hash(content)
+	}
+	if resp != nil {
+		entry.ResponseID = resp.AutocompleteID
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	provider.RecordAudit(p.audit, entry)
+}
+
 func emptyResponse() *types.CompletionResponse {
 	return &types.CompletionResponse{Completions: []*types.Completion{}, CursorTarget: nil}
 }
@@ -0,0 +1,258 @@
+// Package redact replaces secrets in outgoing prompts with placeholders and
+// restores them when a completion echoes a placeholder back.
+package redact
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// placeholderFormat is the text sent in place of the n-th secret. The
+// delimiters on both sides keep __REDACTED_1__ from matching __REDACTED_10__.
+const placeholderFormat = "__REDACTED_%d__"
+
+// minEntropy is the Shannon entropy in bits per character a value assigned
+// to a key/token/password-like name must reach to be treated as a secret.
+// Random base64 and hex strings score above it; words and identifiers below.
+const minEntropy = 3.5
+
+var (
+	awsKeyPattern = regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)
+	jwtPattern    = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)
+	// assignmentPattern captures the value assigned to a secret-sounding name,
+	// e.g. API_KEY=..., "token": "...", password: ...
+	assignmentPattern = regexp.MustCompile(`(?i)(?:key|token|secret|passw(?:or)?d|pwd|credential|auth)[a-z0-9_.-]*["']?\s*[:=]+\s*["'` + "`" + `]?([A-Za-z0-9+/=_.~-]{16,})`)
+	pemBeginPattern   = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]+-----`)
+	pemEndPattern     = regexp.MustCompile(`-----END [A-Z0-9 ]+-----`)
+)
+
+// Redactor detects secrets using the built-in detectors and user patterns.
+// A nil Redactor disables redaction.
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// New returns a Redactor with additional user patterns (Go regexp syntax).
+// When a pattern has a capture group only the first group is redacted.
+func New(patterns []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// NewSession starts redacting one request. Placeholders are stable within a
+// session so the same secret in the file and in the diff history gets the
+// same placeholder. Returns nil (a no-op session) for a nil Redactor.
+func (r *Redactor) NewSession() *Session {
+	if r == nil {
+		return nil
+	}
+	return &Session{redactor: r, placeholders: make(map[string]string)}
+}
+
+// Session holds the placeholder mapping for one request. Not safe for
+// concurrent use. All methods are no-ops on a nil Session.
+type Session struct {
+	redactor     *Redactor
+	placeholders map[string]string // secret -> placeholder
+	secrets      []string          // index n-1 holds the secret for placeholder n
+	inPEM        bool
+}
+
+// span is a byte range of a line to replace.
+type span struct {
+	start, end int
+}
+
+// Text redacts multi-line text. PEM blocks are tracked across lines: the
+// BEGIN and END markers are kept and every line between them is replaced.
+func (s *Session) Text(text string) string {
+	if s == nil || text == "" {
+		return text
+	}
+	return strings.Join(s.Lines(strings.Split(text, "\n")), "\n")
+}
+
+// Lines redacts each line, keeping the line count unchanged so line numbers
+// in the response still refer to the original buffer. Returns the input
+// slice when nothing was redacted.
+func (s *Session) Lines(lines []string) []string {
+	if s == nil {
+		return lines
+	}
+	var out []string
+	for i, line := range lines {
+		redacted := s.line(line)
+		if out == nil && redacted != line {
+			out = slices.Clone(lines[:i])
+		}
+		if out != nil {
+			out = append(out, redacted)
+		}
+	}
+	s.inPEM = false
+	if out == nil {
+		return lines
+	}
+	return out
+}
+
+// Column maps a byte column in an original line to the same position in its
+// redacted form. A column inside a secret maps to the end of its placeholder.
+// Only single-line detectors are considered: callers clamp the result to the
+// redacted line, which covers lines replaced whole inside a PEM block.
+func (s *Session) Column(line string, col int) int {
+	if s == nil {
+		return col
+	}
+	shift := 0
+	for _, sp := range s.redactor.spans(line) {
+		if sp.start >= col {
+			break
+		}
+		placeholder := s.placeholder(line[sp.start:sp.end])
+		if col < sp.end {
+			return sp.start + shift + len(placeholder)
+		}
+		shift += len(placeholder) - (sp.end - sp.start)
+	}
+	return col + shift
+}
+
+// Restore replaces placeholders in text with the secrets they stand for.
+func (s *Session) Restore(text string) string {
+	if s == nil || len(s.secrets) == 0 || !strings.Contains(text, "__REDACTED_") {
+		return text
+	}
+	pairs := make([]string, 0, 2*len(s.secrets))
+	for i, secret := range s.secrets {
+		pairs = append(pairs, fmt.Sprintf(placeholderFormat, i+1), secret)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// RestoreLines restores placeholders in each line, in place.
+func (s *Session) RestoreLines(lines []string) {
+	for i, line := range lines {
+		lines[i] = s.Restore(line)
+	}
+}
+
+// Count returns the number of distinct secrets redacted so far.
+func (s *Session) Count() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.secrets)
+}
+
+func (s *Session) line(line string) string {
+	if s.inPEM {
+		if pemEndPattern.MatchString(line) {
+			s.inPEM = false
+			return line
+		}
+		if strings.TrimSpace(line) == "" {
+			return line
+		}
+		return s.placeholder(line)
+	}
+	if loc := pemBeginPattern.FindStringIndex(line); loc != nil && !pemEndPattern.MatchString(line[loc[1]:]) {
+		s.inPEM = true
+		return line
+	}
+
+	spans := s.redactor.spans(line)
+	if len(spans) == 0 {
+		return line
+	}
+	var b strings.Builder
+	prev := 0
+	for _, sp := range spans {
+		b.WriteString(line[prev:sp.start])
+		b.WriteString(s.placeholder(line[sp.start:sp.end]))
+		prev = sp.end
+	}
+	b.WriteString(line[prev:])
+	return b.String()
+}
+
+func (s *Session) placeholder(secret string) string {
+	if p, ok := s.placeholders[secret]; ok {
+		return p
+	}
+	s.secrets = append(s.secrets, secret)
+	p := fmt.Sprintf(placeholderFormat, len(s.secrets))
+	s.placeholders[secret] = p
+	return p
+}
+
+// spans returns the sorted, non-overlapping secret ranges in a line.
+func (r *Redactor) spans(line string) []span {
+	var spans []span
+	for _, loc := range awsKeyPattern.FindAllStringIndex(line, -1) {
+		spans = append(spans, span{loc[0], loc[1]})
+	}
+	for _, loc := range jwtPattern.FindAllStringIndex(line, -1) {
+		spans = append(spans, span{loc[0], loc[1]})
+	}
+	for _, m := range assignmentPattern.FindAllStringSubmatchIndex(line, -1) {
+		if entropy(line[m[2]:m[3]]) >= minEntropy {
+			spans = append(spans, span{m[2], m[3]})
+		}
+	}
+	for _, re := range r.patterns {
+		for _, m := range re.FindAllStringSubmatchIndex(line, -1) {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			if start < end {
+				spans = append(spans, span{start, end})
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	slices.SortFunc(spans, func(a, b span) int { return a.start - b.start })
+	merged := spans[:1]
+	for _, sp := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if sp.start < last.end {
+			last.end = max(last.end, sp.end)
+			continue
+		}
+		merged = append(merged, sp)
+	}
+	return merged
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	n := 0
+	for _, c := range s {
+		counts[c]++
+		n++
+	}
+	var h float64
+	for _, count := range counts {
+		p := float64(count) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"cursortab/engine"
+	"cursortab/tokenizer"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runReplay implements the "replay" subcommand, which feeds a recorded
+// session trace into the engine and reports the first UI call that differs.
+// Returns the process exit code.
+func runReplay(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	tokenizerPath := fs.String("tokenizer", "", "tokenizer.json or tiktoken vocabulary used when recording")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cursortab replay [--tokenizer file] trace-file")
+		fmt.Fprintln(stderr, "Replay a session recorded with debug.trace_file and compare the UI calls.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	var counter tokenizer.Counter
+	if *tokenizerPath != "" {
+		bpe, err := tokenizer.Load(*tokenizerPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "cursortab replay: %v\n", err)
+			return 1
+		}
+		counter = bpe
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab replay: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	result, err := engine.Replay(f, counter)
+	if err != nil {
+		fmt.Fprintf(stderr, "cursortab replay: %v\n", err)
+		return 1
+	}
+	if result.Divergence != nil {
+		fmt.Fprintf(stdout, "Diverged after %d matching UI calls: %s\n", result.UICalls, result.Divergence)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Replayed %d events, %d UI calls matched.\n", result.Events, result.UICalls)
+	return 0
+}
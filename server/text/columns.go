@@ -0,0 +1,93 @@
+package text
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Column convention
+//
+// Every column in the daemon is a 0-indexed UTF-8 byte offset into its line:
+// types.CompletionRequest.CursorCol, LineChange.ColStart/ColEnd,
+// Group.ColStart/ColEnd, the column returned by CalculateCursorPosition and
+// types.CursorRange. This is the unit Neovim uses for nvim_win_get_cursor,
+// nvim_win_set_cursor and extmark columns, so values cross the RPC boundary
+// unchanged. Columns always fall on a character boundary, and render hints
+// never split a grapheme cluster (a base character and its combining marks,
+// or an emoji ZWJ sequence). Display cells are only computed in Lua, where
+// overlay windows are positioned.
+
+// RuneBoundary clamps col to [0, len(line)] and moves it back to the start
+// of the character it points into. Use it on offsets that come from outside
+// the daemon, such as a provider's byte indices.
+func RuneBoundary(line string, col int) int {
+	if col <= 0 {
+		return 0
+	}
+	if col >= len(line) {
+		return len(line)
+	}
+	for col > 0 && !utf8.RuneStart(line[col]) {
+		col--
+	}
+	return col
+}
+
+// clusterStart moves col back to the start of the grapheme cluster that
+// contains it.
+func clusterStart(line string, col int) int {
+	col = RuneBoundary(line, col)
+	for col > 0 && col < len(line) {
+		r, _ := utf8.DecodeRuneInString(line[col:])
+		prev, size := utf8.DecodeLastRuneInString(line[:col])
+		if !extendsCluster(r) && prev != zeroWidthJoiner {
+			break
+		}
+		col -= size
+	}
+	return col
+}
+
+// clusterEnd moves col forward to the end of the grapheme cluster that
+// contains it.
+func clusterEnd(line string, col int) int {
+	if b := RuneBoundary(line, col); b < col {
+		_, size := utf8.DecodeRuneInString(line[b:])
+		col = b + size
+	}
+	col = RuneBoundary(line, col)
+	for col > 0 && col < len(line) {
+		r, size := utf8.DecodeRuneInString(line[col:])
+		prev, _ := utf8.DecodeLastRuneInString(line[:col])
+		if !extendsCluster(r) && prev != zeroWidthJoiner {
+			break
+		}
+		col += size
+	}
+	return col
+}
+
+// snapColumns widens the character range [start, end) of line so that it
+// covers whole grapheme clusters.
+func snapColumns(line string, start, end int) (int, int) {
+	start = clusterStart(line, start)
+	end = max(start, clusterEnd(line, end))
+	return start, end
+}
+
+const zeroWidthJoiner = '\u200d'
+
+// extendsCluster reports whether r attaches to the character before it:
+// combining marks, variation selectors, emoji skin tone modifiers and the
+// zero width joiner itself.
+func extendsCluster(r rune) bool {
+	switch {
+	case r == zeroWidthJoiner:
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r >= 0xE0100 && r <= 0xE01EF:
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF:
+		return true
+	}
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc)
+}
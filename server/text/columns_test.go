@@ -0,0 +1,46 @@
+package text
+
+import (
+	"cursortab/assert"
+	"testing"
+)
+
+func TestRuneBoundary(t *testing.T) {
+	line := "a日b" // 日 is bytes 1..3
+	assert.Equal(t, 0, RuneBoundary(line, -1), "clamped below")
+	assert.Equal(t, 1, RuneBoundary(line, 1), "on boundary")
+	assert.Equal(t, 1, RuneBoundary(line, 2), "inside rune")
+	assert.Equal(t, 1, RuneBoundary(line, 3), "last byte of rune")
+	assert.Equal(t, 4, RuneBoundary(line, 4), "after rune")
+	assert.Equal(t, len(line), RuneBoundary(line, 99), "clamped above")
+}
+
+func TestSnapColumns_GraphemeClusters(t *testing.T) {
+	// Combining acute accent attaches to the preceding e
+	line := "cafe\u0301!"
+	start, end := snapColumns(line, 4, 6)
+	assert.Equal(t, 3, start, "start moved to base character")
+	assert.Equal(t, 6, end, "end after combining mark")
+
+	// Family emoji joined with ZWJ is one cluster
+	family := "x👨\u200d👩\u200d👧y"
+	start, end = snapColumns(family, len("x👨\u200d👩"), len("x👨\u200d👩\u200d👧"))
+	assert.Equal(t, 1, start, "start at first emoji")
+	assert.Equal(t, len(family)-1, end, "end at last emoji")
+
+	// Skin tone modifier and variation selector extend the previous rune
+	wave := "👋🏽 ok"
+	start, end = snapColumns(wave, len("👋"), len("👋"))
+	assert.Equal(t, 0, start, "modifier attached")
+	assert.Equal(t, len("👋🏽"), end, "modifier included")
+
+	heart := "❤\ufe0f"
+	start, end = snapColumns(heart, 0, len("❤"))
+	assert.Equal(t, 0, start, "start unchanged")
+	assert.Equal(t, len(heart), end, "variation selector included")
+
+	// Plain CJK text is left alone
+	start, end = snapColumns("日本語", 3, 6)
+	assert.Equal(t, 3, start, "cjk start")
+	assert.Equal(t, 6, end, "cjk end")
+}
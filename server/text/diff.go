@@ -3,6 +3,7 @@ package text
 import (
 	"cursortab/logger"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/sergi/go-diff/diffmatchpatch"
 )
@@ -43,6 +44,7 @@ const (
 	ChangeAppendChars
 	ChangeDeleteChars
 	ChangeReplaceChars
+	ChangeMoved // Destination line of a block moved from elsewhere (see Move)
 )
 
 // String returns the string representation of ChangeType
@@ -60,6 +62,8 @@ func (ct ChangeType) String() string {
 		return "delete_chars"
 	case ChangeReplaceChars:
 		return "replace_chars"
+	case ChangeMoved:
+		return "moved"
 	default:
 		return "unknown"
 	}
@@ -72,8 +76,10 @@ type LineChange struct {
 	NewLineNum int    // Position in new text (1-indexed), -1 if pure deletion
 	Content    string // new content
 	OldContent string // For modifications to compare changes
-	ColStart   int    // Start column (0-based) for character-level changes
-	ColEnd     int    // End column (0-based) for character-level changes
+	ColStart   int    // Start byte column (0-based) for character-level changes
+	ColEnd     int    // End byte column (0-based, exclusive) for character-level changes
+	MovedFrom  int    // ChangeMoved: source line in old text (1-indexed); OldLineNum stays the insertion anchor
+	MovedTo    int    // Deletion that is the source of a move: destination line in new text (1-indexed)
 }
 
 // LineMapping tracks correspondence between new and old line coordinates.
@@ -86,6 +92,7 @@ type LineMapping struct {
 // DiffResult contains all categorized change operations mapped by line number
 type DiffResult struct {
 	Changes      map[int]LineChange // Map of line number (1-indexed) to change operation
+	Moves        []Move             // Blocks deleted in one place and inserted elsewhere
 	LineMapping  *LineMapping       // Coordinate mapping between old and new line numbers
 	OldLineCount int                // Number of lines in original text
 	NewLineCount int                // Number of lines in new text
@@ -215,6 +222,7 @@ func ComputeDiff(text1, text2 string) *DiffResult {
 
 	// Build line mapping and process diffs
 	result.LineMapping = processLineDiffsWithMapping(lineDiffs, result, oldLineCount, newLineCount)
+	detectMoves(result)
 
 	return result
 }
@@ -236,7 +244,8 @@ func LineSimilarity(line1, line2 string) float64 {
 	diffs := dmp.DiffMain(line1, line2, false)
 	levenshteinDist := dmp.DiffLevenshtein(diffs)
 
-	maxLen := max(len(line1), len(line2))
+	// DiffLevenshtein counts runes, so normalize by rune length too
+	maxLen := max(utf8.RuneCountInString(line1), utf8.RuneCountInString(line2))
 	if maxLen == 0 {
 		return 0.0
 	}
@@ -456,8 +465,22 @@ func handleModificationsWithMapping(deletedLines, insertedLines []string,
 	}
 }
 
-// categorizeLineChangeWithColumns determines the type of change between two lines and returns column range
+// categorizeLineChangeWithColumns determines the type of change between two lines and returns column range.
+// Columns are byte offsets widened to whole grapheme clusters: into oldLine for
+// delete_chars, into newLine otherwise.
 func categorizeLineChangeWithColumns(oldLine, newLine string) (ChangeType, int, int) {
+	changeType, colStart, colEnd := categorizeLineChange(oldLine, newLine)
+	switch changeType {
+	case ChangeDeleteChars:
+		colStart, colEnd = snapColumns(oldLine, colStart, colEnd)
+	case ChangeAppendChars, ChangeReplaceChars:
+		colStart, colEnd = snapColumns(newLine, colStart, colEnd)
+	}
+	return changeType, colStart, colEnd
+}
+
+// categorizeLineChange categorizes the change using the raw diff columns
+func categorizeLineChange(oldLine, newLine string) (ChangeType, int, int) {
 	// Handle empty old line with non-empty new line (filling an empty line)
 	// This should be append_chars so it renders as inline ghost text, not a virtual line
 	if oldLine == "" && newLine != "" {
@@ -580,8 +603,8 @@ func isComplexModification(deletedText, insertedText string) bool {
 	}
 
 	// Check length ratio (avoid division by zero)
-	deletedLen := len(deletedText)
-	insertedLen := len(insertedText)
+	deletedLen := utf8.RuneCountInString(deletedText)
+	insertedLen := utf8.RuneCountInString(insertedText)
 
 	if deletedLen == 0 {
 		// Deletion is empty but insertion exists - treat as modification if insertion is substantial
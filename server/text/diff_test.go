@@ -1232,3 +1232,44 @@ func TestDiffWithTabs(t *testing.T) {
 	_, exists := actual.Changes[2]
 	assert.True(t, exists, "change at line 2")
 }
+
+func TestChangeCharsMultibyteColumnsAreBytes(t *testing.T) {
+	// Append after CJK text: columns are byte offsets, not rune counts
+	actual := ComputeDiff(`name := "日本"`, `name := "日本語"`)
+	assertChangesEqual(t, map[int]LineChange{
+		1: {
+			Type:       ChangeReplaceChars,
+			Content:    `name := "日本語"`,
+			OldContent: `name := "日本"`,
+			ColStart:   len(`name := "日本`),
+			ColEnd:     len(`name := "日本語`),
+		},
+	}, actual.Changes)
+
+	// Emoji replaced in the middle of the line
+	actual = ComputeDiff("status 😀 ok", "status 😁 ok")
+	change := actual.Changes[1]
+	assert.Equal(t, ChangeReplaceChars, change.Type, "replace_chars")
+	assert.Equal(t, "😁", change.Content[change.ColStart:change.ColEnd], "range covers the whole emoji")
+
+	// Deleting a CJK word uses columns into the old line
+	actual = ComputeDiff("// 日本語 comment", "// comment")
+	change = actual.Changes[1]
+	assert.Equal(t, ChangeDeleteChars, change.Type, "delete_chars")
+	assert.Equal(t, " 日本語", change.OldContent[change.ColStart:change.ColEnd], "deleted range")
+}
+
+func TestChangeCharsCombiningMarkKeepsCluster(t *testing.T) {
+	// Adding a combining accent must not render the mark on its own
+	actual := ComputeDiff("cafe", "café")
+	change := actual.Changes[1]
+	assert.Equal(t, ChangeAppendChars, change.Type, "append_chars")
+	assert.Equal(t, 3, change.ColStart, "starts at the base character")
+	assert.Equal(t, "é", change.Content[change.ColStart:change.ColEnd], "whole cluster")
+}
+
+func TestLineSimilarityMultibyte(t *testing.T) {
+	// One of three characters differs, whatever their encoded size
+	assert.Equal(t, LineSimilarity("abc", "abd"), LineSimilarity("日本語", "日本人"), "rune-based ratio")
+	assert.True(t, LineSimilarity("日本語", "中文字") < 0.3, "unrelated CJK lines are not similar")
+}
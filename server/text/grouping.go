@@ -14,10 +14,15 @@ type Group struct {
 	// Computed by staging.go using GetBufferLineForChange for correct coordinate mapping.
 	BufferLine int
 
-	// Character-level rendering hints (single-line only)
+	// Character-level rendering hints (single-line only). Columns are byte
+	// offsets, the unit of Neovim extmark columns (see columns.go).
 	RenderHint string // "", "append_chars", "replace_chars", "delete_chars"
 	ColStart   int    // For character-level changes
-	ColEnd     int    // For character-level changes
+	ColEnd     int    // For character-level changes (exclusive)
+
+	// Spans are the token-level changes of modification lines that are not
+	// mostly rewritten. Lines without spans are shown whole.
+	Spans []Span
 }
 
 // GroupChanges groups consecutive same-type changes for efficient rendering.
@@ -76,6 +81,7 @@ func GroupChanges(changes map[int]LineChange) []*Group {
 			if groupType == "modification" {
 				currentGroup.OldLines = []string{change.OldContent}
 			}
+			addSpans(currentGroup, change)
 			// Set RenderHint for this single-line group
 			setRenderHint(currentGroup, change)
 		} else {
@@ -85,6 +91,7 @@ func GroupChanges(changes map[int]LineChange) []*Group {
 			if groupType == "modification" {
 				currentGroup.OldLines = append(currentGroup.OldLines, change.OldContent)
 			}
+			addSpans(currentGroup, change)
 		}
 	}
 
@@ -113,7 +120,7 @@ func getChangeRenderHint(ct ChangeType) string {
 // changeTypeToGroupType converts a ChangeType to a group type string
 func changeTypeToGroupType(ct ChangeType) string {
 	switch ct {
-	case ChangeAddition:
+	case ChangeAddition, ChangeMoved:
 		return "addition"
 	case ChangeModification, ChangeAppendChars, ChangeDeleteChars, ChangeReplaceChars:
 		return "modification"
@@ -124,6 +131,20 @@ func changeTypeToGroupType(ct ChangeType) string {
 	}
 }
 
+// addSpans appends the token-level spans of change, which must be the
+// group's last line. Character-level hints already locate their change, so
+// only general modifications get spans.
+func addSpans(group *Group, change LineChange) {
+	if change.Type != ChangeModification {
+		return
+	}
+	line := len(group.Lines)
+	for _, span := range tokenSpans(change.OldContent, change.Content) {
+		span.Line = line
+		group.Spans = append(group.Spans, span)
+	}
+}
+
 // setRenderHint sets the render hint for character-level optimizations
 func setRenderHint(group *Group, change LineChange) {
 	switch change.Type {
@@ -148,7 +169,7 @@ func setRenderHint(group *Group, change LineChange) {
 
 // CalculateCursorPosition computes optimal cursor position from changes
 // Priority: modifications > additions > char-level > deletions
-// Returns (line, col) where line is 1-indexed and col is 0-indexed
+// Returns (line, col) where line is 1-indexed and col is a 0-indexed byte offset
 // Returns (-1, -1) if no cursor positioning is needed
 func CalculateCursorPosition(changes map[int]LineChange, newLines []string) (int, int) {
 	if len(changes) == 0 {
@@ -176,7 +197,7 @@ func CalculateCursorPosition(changes map[int]LineChange, newLines []string) (int
 			if lineNum > lastModification {
 				lastModification = lineNum
 			}
-		case ChangeAddition:
+		case ChangeAddition, ChangeMoved:
 			if lineNum > lastAddition {
 				lastAddition = lineNum
 			}
@@ -13,6 +13,10 @@ type IncrementalDiffBuilder struct {
 	Changes     map[int]LineChange // Changes keyed by new line number (1-indexed)
 	LineMapping *LineMapping       // Coordinate mapping between old and new
 
+	// Normalization collapses cosmetic edits: an equivalent line is kept as
+	// the old text and is not a change
+	Normalization types.Normalization
+
 	// Tracking state
 	oldLineIdx          int          // Current position in old lines (0-indexed)
 	usedOldLines        map[int]bool // Old line indices that have been matched
@@ -66,9 +70,10 @@ func (b *IncrementalDiffBuilder) AddLine(line string) *LineChange {
 		b.LineMapping.OldToNew[oldLineNum-1] = newLineNum
 	}
 
-	// Check for exact match
+	// Check for exact match (or a cosmetic-only edit, which is collapsed)
 	oldContent := b.OldLines[oldLineNum-1]
-	if oldContent == line {
+	if Equivalent(oldContent, line, b.Normalization) {
+		b.NewLines[newLineNum-1] = oldContent
 		// Advance oldLineIdx past matched lines
 		if oldLineNum > b.oldLineIdx {
 			b.oldLineIdx = oldLineNum
@@ -154,6 +159,7 @@ type IncrementalStageBuilder struct {
 	ViewportBottom     int
 	CursorRow          int
 	FilePath           string
+	Normalization      types.Normalization // Passed to the diff builder
 
 	// State
 	diffBuilder            *IncrementalDiffBuilder
@@ -189,6 +195,7 @@ func NewIncrementalStageBuilder(
 // AddLine processes a new line and returns a newly finalized stage if any.
 // Returns nil if no stage was finalized on this line.
 func (b *IncrementalStageBuilder) AddLine(line string) *Stage {
+	b.diffBuilder.Normalization = b.Normalization
 	change := b.diffBuilder.AddLine(line)
 	lineNum := len(b.diffBuilder.NewLines) // 1-indexed
 
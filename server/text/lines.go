@@ -0,0 +1,14 @@
+package text
+
+// SpliceLines returns lines with startLine..endLineInc (1-indexed,
+// inclusive) replaced by replacement, the way buffers apply a completion.
+// An endLineInc before startLine inserts before startLine; out of range
+// lines are clamped. lines is not modified.
+func SpliceLines(lines []string, startLine, endLineInc int, replacement []string) []string {
+	start := min(max(startLine-1, 0), len(lines))
+	end := min(max(endLineInc, start), len(lines))
+	result := make([]string, 0, len(lines)-(end-start)+len(replacement))
+	result = append(result, lines[:start]...)
+	result = append(result, replacement...)
+	return append(result, lines[end:]...)
+}
@@ -0,0 +1,27 @@
+package text
+
+import (
+	"cursortab/assert"
+	"testing"
+)
+
+func TestSpliceLines(t *testing.T) {
+	lines := []string{"a", "b", "c"}
+	tests := []struct {
+		name        string
+		start, end  int
+		replacement []string
+		want        []string
+	}{
+		{"replace", 2, 2, []string{"B"}, []string{"a", "B", "c"}},
+		{"grow", 2, 3, []string{"x", "y", "z"}, []string{"a", "x", "y", "z"}},
+		{"insert", 2, 1, []string{"new"}, []string{"a", "new", "b", "c"}},
+		{"append", 4, 4, []string{"d"}, []string{"a", "b", "c", "d"}},
+		{"delete", 2, 2, nil, []string{"a", "c"}},
+		{"clamped", 0, 9, []string{"all"}, []string{"all"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, SpliceLines(lines, tt.start, tt.end, tt.replacement), tt.name)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, lines, "input unchanged")
+}
@@ -0,0 +1,260 @@
+package text
+
+import (
+	"sort"
+	"strings"
+)
+
+// Move is a block of lines deleted in one place and inserted, nearly
+// unchanged, elsewhere: a moved function or a reordered import.
+type Move struct {
+	OldStart int // Source in old text (1-indexed)
+	OldEnd   int // Inclusive
+	NewStart int // Destination in new text (1-indexed)
+	NewEnd   int // Inclusive
+}
+
+const (
+	// moveSimilarityThreshold is the mean LineSimilarity a deleted run and an
+	// inserted run need to count as a move.
+	moveSimilarityThreshold = 0.8
+	// minMoveChars is the non-whitespace content a run needs, so that lone
+	// braces or blank lines are not reported as moves.
+	minMoveChars = 8
+)
+
+// lineRun is a run of consecutive deleted or inserted lines.
+type lineRun struct {
+	start int // 1-indexed, in old text for deletions and new text for insertions
+	lines []string
+}
+
+// detectMoves pairs runs of deleted lines with runs of inserted lines that
+// have the same length and similar content. Each pair is recorded in
+// r.Moves; the inserted lines become ChangeMoved and the deleted lines keep
+// ChangeDeletion with MovedTo set, so rendering shows both ends.
+func detectMoves(r *DiffResult) {
+	deleted := collectRuns(r, ChangeDeletion, func(c LineChange) int { return c.OldLineNum })
+	inserted := collectRuns(r, ChangeAddition, func(c LineChange) int { return c.NewLineNum })
+	if len(deleted) == 0 || len(inserted) == 0 {
+		return
+	}
+
+	used := make([]bool, len(inserted))
+	for _, del := range deleted {
+		best, bestScore := -1, moveSimilarityThreshold
+		for i, ins := range inserted {
+			if used[i] || len(ins.lines) != len(del.lines) {
+				continue
+			}
+			if score := runSimilarity(del.lines, ins.lines); score >= bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best == -1 {
+			continue
+		}
+		used[best] = true
+		ins := inserted[best]
+
+		for j := range del.lines {
+			oldLine, newLine := del.start+j, ins.start+j
+			source := r.Changes[oldLine]
+			source.MovedTo = newLine
+			r.Changes[oldLine] = source
+
+			dest := r.Changes[newLine]
+			dest.Type = ChangeMoved
+			dest.MovedFrom = oldLine
+			dest.OldContent = del.lines[j]
+			r.Changes[newLine] = dest
+		}
+		r.Moves = append(r.Moves, Move{
+			OldStart: del.start,
+			OldEnd:   del.start + len(del.lines) - 1,
+			NewStart: ins.start,
+			NewEnd:   ins.start + len(ins.lines) - 1,
+		})
+	}
+}
+
+// collectRuns gathers changes of type ct into runs of consecutive line
+// numbers (as given by lineOf), with blank lines trimmed from both ends:
+// blank separators rarely move with the block.
+func collectRuns(r *DiffResult, ct ChangeType, lineOf func(LineChange) int) []lineRun {
+	byLine := make(map[int]string)
+	var lineNums []int
+	for _, change := range r.Changes {
+		if change.Type != ct {
+			continue
+		}
+		if n := lineOf(change); n > 0 {
+			byLine[n] = change.Content
+			lineNums = append(lineNums, n)
+		}
+	}
+	sort.Ints(lineNums)
+
+	var runs []lineRun
+	var current *lineRun
+	flush := func() {
+		if current != nil {
+			if run, ok := trimRun(*current); ok {
+				runs = append(runs, run)
+			}
+		}
+		current = nil
+	}
+	for _, n := range lineNums {
+		if current == nil || n != current.start+len(current.lines) {
+			flush()
+			current = &lineRun{start: n}
+		}
+		current.lines = append(current.lines, byLine[n])
+	}
+	flush()
+	return runs
+}
+
+// trimRun drops blank lines at both ends of run. Reports false if what is
+// left is too small to be a meaningful move.
+func trimRun(run lineRun) (lineRun, bool) {
+	for len(run.lines) > 0 && strings.TrimSpace(run.lines[0]) == "" {
+		run.lines = run.lines[1:]
+		run.start++
+	}
+	for len(run.lines) > 0 && strings.TrimSpace(run.lines[len(run.lines)-1]) == "" {
+		run.lines = run.lines[:len(run.lines)-1]
+	}
+	chars := 0
+	for _, line := range run.lines {
+		chars += len(strings.Join(strings.Fields(line), ""))
+	}
+	return run, chars >= minMoveChars
+}
+
+// runSimilarity is the mean LineSimilarity of the aligned lines.
+func runSimilarity(a, b []string) float64 {
+	total := 0.0
+	for i := range a {
+		total += LineSimilarity(strings.TrimSpace(a[i]), strings.TrimSpace(b[i]))
+	}
+	return total / float64(len(a))
+}
+
+// mergeMoveStages makes sure both ends of every move are applied in one
+// step. Stages holding the source and the destination are merged, widened
+// to a region that is the same in old and new text, and any stage that
+// overlaps the widened region is absorbed, since applying the region
+// already applies its changes.
+func mergeMoveStages(stages []*Stage, diff *DiffResult, baseLineOffset int) []*Stage {
+	if len(diff.Moves) == 0 || len(stages) < 2 {
+		return stages
+	}
+
+	stageOf := func(lineNum int, ct ChangeType) int {
+		for i, stage := range stages {
+			if change, ok := stage.rawChanges[lineNum]; ok && change.Type == ct {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for _, move := range diff.Moves {
+		src := stageOf(move.OldStart, ChangeDeletion)
+		dst := stageOf(move.NewStart, ChangeMoved)
+		if src == -1 || dst == -1 || src == dst {
+			continue
+		}
+		merged := stages[src]
+		absorbStage(merged, stages[dst])
+		stages = append(stages[:dst], stages[dst+1:]...)
+
+		// Widening can reach other stages; absorb them until stable
+		for {
+			alignMoveStage(merged, diff, baseLineOffset)
+			overlap := -1
+			for i, stage := range stages {
+				if stage != merged && stage.BufferStart <= merged.BufferEnd && stage.BufferEnd >= merged.BufferStart {
+					overlap = i
+					break
+				}
+			}
+			if overlap == -1 {
+				break
+			}
+			absorbStage(merged, stages[overlap])
+			stages = append(stages[:overlap], stages[overlap+1:]...)
+		}
+	}
+	return stages
+}
+
+// absorbStage moves the changes of other into stage.
+func absorbStage(stage, other *Stage) {
+	for lineNum, change := range other.rawChanges {
+		stage.rawChanges[lineNum] = change
+	}
+	stage.startLine = min(stage.startLine, other.startLine)
+	stage.endLine = max(stage.endLine, other.endLine)
+}
+
+// alignMoveStage sets the buffer range and new line range of a stage that
+// spans a move. The ranges are widened until the lines just outside them
+// are unchanged lines mapped to each other (or the edges of the text), so
+// replacing the old range with the new range applies exactly this stage.
+func alignMoveStage(stage *Stage, diff *DiffResult, baseLineOffset int) {
+	m := diff.LineMapping
+	oldStart, oldEnd := diff.OldLineCount+1, 0
+	newStart, newEnd := diff.NewLineCount+1, 0
+	for _, change := range stage.rawChanges {
+		if change.OldLineNum > 0 && change.Type != ChangeAddition && change.Type != ChangeMoved {
+			oldStart, oldEnd = min(oldStart, change.OldLineNum), max(oldEnd, change.OldLineNum)
+		}
+		if change.NewLineNum > 0 && change.Type != ChangeDeletion {
+			newStart, newEnd = min(newStart, change.NewLineNum), max(newEnd, change.NewLineNum)
+		}
+	}
+
+	for {
+		// Region each range implies on the other side
+		impliedNewStart := mappedBefore(m.OldToNew, oldStart) + 1
+		impliedNewEnd := mappedAfter(m.OldToNew, oldEnd, diff.NewLineCount+1) - 1
+		impliedOldStart := mappedBefore(m.NewToOld, newStart) + 1
+		impliedOldEnd := mappedAfter(m.NewToOld, newEnd, diff.OldLineCount+1) - 1
+
+		nextOldStart, nextOldEnd := min(oldStart, impliedOldStart), max(oldEnd, impliedOldEnd)
+		nextNewStart, nextNewEnd := min(newStart, impliedNewStart), max(newEnd, impliedNewEnd)
+		if nextOldStart == oldStart && nextOldEnd == oldEnd && nextNewStart == newStart && nextNewEnd == newEnd {
+			break
+		}
+		oldStart, oldEnd, newStart, newEnd = nextOldStart, nextOldEnd, nextNewStart, nextNewEnd
+	}
+
+	stage.BufferStart = oldStart + baseLineOffset - 1
+	stage.BufferEnd = oldEnd + baseLineOffset - 1
+	stage.newStart, stage.newEnd = newStart, newEnd
+}
+
+// mappedBefore returns the counterpart of the closest mapped line before
+// line, or 0 if there is none. mapping is NewToOld or OldToNew.
+func mappedBefore(mapping []int, line int) int {
+	for i := min(line-1, len(mapping)); i >= 1; i-- {
+		if mapping[i-1] > 0 {
+			return mapping[i-1]
+		}
+	}
+	return 0
+}
+
+// mappedAfter returns the counterpart of the closest mapped line after
+// line, or edge if there is none.
+func mappedAfter(mapping []int, line, edge int) int {
+	for i := max(line+1, 1); i <= len(mapping); i++ {
+		if mapping[i-1] > 0 {
+			return mapping[i-1]
+		}
+	}
+	return edge
+}
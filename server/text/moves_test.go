@@ -0,0 +1,108 @@
+package text
+
+import (
+	"cursortab/assert"
+	"fmt"
+	"testing"
+)
+
+// movedFunctionFixture moves helper() from the top of the file to the bottom,
+// with enough unchanged lines in between to land in separate stages.
+func movedFunctionFixture() (oldLines, newLines []string) {
+	helper := []string{"func helper(x int) int {", "\treturn x * 2", "}"}
+	var middle []string
+	for i := 1; i <= 12; i++ {
+		middle = append(middle, fmt.Sprintf("var v%d = %d", i, i))
+	}
+
+	oldLines = append(oldLines, "package main", "")
+	oldLines = append(oldLines, helper...)
+	oldLines = append(oldLines, "")
+	oldLines = append(oldLines, middle...)
+
+	newLines = append(newLines, "package main", "")
+	newLines = append(newLines, middle...)
+	newLines = append(newLines, "")
+	newLines = append(newLines, helper...)
+	return oldLines, newLines
+}
+
+func TestComputeDiff_DetectsMovedBlock(t *testing.T) {
+	oldLines, newLines := movedFunctionFixture()
+	diff := ComputeDiff(JoinLines(oldLines), JoinLines(newLines))
+
+	assert.Equal(t, []Move{{OldStart: 3, OldEnd: 5, NewStart: 16, NewEnd: 18}}, diff.Moves, "one move")
+
+	dest := diff.Changes[16]
+	assert.Equal(t, ChangeMoved, dest.Type, "destination is moved")
+	assert.Equal(t, 3, dest.MovedFrom, "source line")
+	assert.Equal(t, "func helper(x int) int {", dest.OldContent, "source content")
+
+	source := diff.Changes[3]
+	assert.Equal(t, ChangeDeletion, source.Type, "source still deleted")
+	assert.Equal(t, 16, source.MovedTo, "destination line")
+	assert.Equal(t, "moved", ChangeMoved.String(), "change type name")
+}
+
+func TestComputeDiff_ReorderedImports(t *testing.T) {
+	oldText := JoinLines([]string{"import (", `	"strings"`, `	"fmt"`, `	"os"`, ")"})
+	newText := JoinLines([]string{"import (", `	"fmt"`, `	"os"`, `	"strings"`, ")"})
+
+	diff := ComputeDiff(oldText, newText)
+	assert.Equal(t, 1, len(diff.Moves), "moved import")
+	assert.Equal(t, ChangeMoved, diff.Changes[diff.Moves[0].NewStart].Type, "destination marked")
+}
+
+func TestComputeDiff_NoMoveForDissimilarOrTinyRuns(t *testing.T) {
+	// Different content in the deleted and inserted runs
+	diff := ComputeDiff(
+		JoinLines([]string{"alpha := compute(1)", "keep 1", "keep 2", "keep 3"}),
+		JoinLines([]string{"keep 1", "keep 2", "keep 3", "totally unrelated line"}),
+	)
+	assert.Equal(t, 0, len(diff.Moves), "dissimilar runs")
+
+	// A lone closing brace moving is not worth reporting
+	diff = ComputeDiff(
+		JoinLines([]string{"}", "keep 1", "keep 2"}),
+		JoinLines([]string{"keep 1", "keep 2", "}"}),
+	)
+	assert.Equal(t, 0, len(diff.Moves), "tiny run")
+}
+
+// applyStage replaces the stage's buffer range in lines with its content.
+func applyStage(lines []string, stage *Stage) []string {
+	out := append([]string{}, lines[:stage.BufferStart-1]...)
+	out = append(out, stage.Lines...)
+	return append(out, lines[stage.BufferEnd:]...)
+}
+
+func TestCreateStages_KeepsMoveInOneStage(t *testing.T) {
+	oldLines, newLines := movedFunctionFixture()
+	diff := ComputeDiff(JoinLines(oldLines), JoinLines(newLines))
+
+	result := CreateStages(diff, 3, 1, 50, 1, 2, "main.go", newLines, oldLines)
+	assert.Equal(t, 1, len(result.Stages), "source and destination in one stage")
+
+	stage := result.Stages[0]
+	assert.Equal(t, newLines, applyStage(oldLines, stage), "accepting the stage applies the move")
+
+	moved := 0
+	for _, change := range stage.Changes {
+		if change.Type == ChangeMoved {
+			moved++
+		}
+	}
+	assert.Equal(t, 3, moved, "moved lines kept in stage")
+}
+
+func TestCreateStages_MoveAbsorbsChangesInBetween(t *testing.T) {
+	oldLines, newLines := movedFunctionFixture()
+	// Also edit a line between the two ends of the move
+	newLines[8] = "var v7 = 700"
+
+	diff := ComputeDiff(JoinLines(oldLines), JoinLines(newLines))
+	result := CreateStages(diff, 3, 1, 50, 1, 1, "main.go", newLines, oldLines)
+
+	assert.Equal(t, 1, len(result.Stages), "edit inside the moved region is part of the stage")
+	assert.Equal(t, newLines, applyStage(oldLines, result.Stages[0]), "stage applies both")
+}
@@ -0,0 +1,106 @@
+package text
+
+import (
+	"cursortab/types"
+	"strings"
+	"unicode"
+)
+
+// NormalizeLine returns the form of line compared under policy.
+func NormalizeLine(line string, policy types.Normalization) string {
+	if policy.Formatting {
+		return formattingKey(line)
+	}
+	if policy.Indentation {
+		line = strings.TrimLeft(line, " \t")
+	}
+	if policy.TrailingWhitespace {
+		line = strings.TrimRight(line, " \t\r")
+	}
+	return line
+}
+
+// Equivalent reports whether a and b differ only in ways policy ignores.
+func Equivalent(a, b string, policy types.Normalization) bool {
+	return a == b || NormalizeLine(a, policy) == NormalizeLine(b, policy)
+}
+
+// EquivalentLines reports whether two blocks differ only in ways policy
+// ignores. With Formatting, blank lines are skipped as well.
+func EquivalentLines(a, b []string, policy types.Normalization) bool {
+	if policy.Formatting {
+		a, b = nonBlank(a), nonBlank(b)
+	}
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equivalent(a[i], b[i], policy) {
+			return false
+		}
+	}
+	return true
+}
+
+// CollapseEquivalent returns newLines with every modified line that is
+// equivalent to the old line it replaces restored to the old text, so a
+// suggestion does not show or apply cosmetic edits next to its real ones.
+// newLines is not modified.
+func CollapseEquivalent(oldLines, newLines []string, policy types.Normalization) []string {
+	if policy == (types.Normalization{}) {
+		return newLines
+	}
+
+	var collapsed []string
+	diff := ComputeDiff(JoinLines(oldLines), JoinLines(newLines))
+	for _, change := range diff.Changes {
+		switch change.Type {
+		case ChangeDeletion, ChangeAddition, ChangeMoved:
+			continue
+		}
+		idx := change.NewLineNum - 1
+		if idx < 0 || idx >= len(newLines) || !Equivalent(change.OldContent, change.Content, policy) {
+			continue
+		}
+		if collapsed == nil {
+			collapsed = append([]string{}, newLines...)
+		}
+		collapsed[idx] = change.OldContent
+	}
+	if collapsed == nil {
+		return newLines
+	}
+	return collapsed
+}
+
+// formattingKey collapses whitespace runs to one space and drops
+// whitespace next to punctuation, so "f( a,b )" and "f(a, b)" compare equal
+// while "return x" and "returnx" do not.
+func formattingKey(line string) string {
+	var b strings.Builder
+	pendingSpace := false
+	var prev rune
+	for _, r := range strings.TrimSpace(line) {
+		if unicode.IsSpace(r) {
+			pendingSpace = true
+			continue
+		}
+		if pendingSpace && isWordRune(prev) && isWordRune(r) {
+			b.WriteByte(' ')
+		}
+		pendingSpace = false
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
+
+func nonBlank(lines []string) []string {
+	var out []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
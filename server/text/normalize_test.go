@@ -0,0 +1,68 @@
+package text
+
+import (
+	"cursortab/assert"
+	"cursortab/types"
+	"testing"
+)
+
+func TestNormalizeLine(t *testing.T) {
+	trailing := types.Normalization{TrailingWhitespace: true}
+	indent := types.Normalization{Indentation: true}
+	format := types.Normalization{Formatting: true}
+
+	assert.Equal(t, "  x := 1  ", NormalizeLine("  x := 1  ", types.Normalization{}), "zero policy keeps line")
+	assert.Equal(t, "  x := 1", NormalizeLine("  x := 1 \t", trailing), "trailing trimmed")
+	assert.Equal(t, "x := 1  ", NormalizeLine("\t\tx := 1  ", indent), "indentation trimmed")
+	assert.Equal(t, "f(a,b)", NormalizeLine("  f( a , b )  ", format), "spacing around punctuation dropped")
+	assert.Equal(t, "return x", NormalizeLine("return    x", format), "space between words kept")
+}
+
+func TestEquivalent(t *testing.T) {
+	format := types.Normalization{Formatting: true}
+
+	assert.True(t, Equivalent("a", "a", types.Normalization{}), "identical lines")
+	assert.False(t, Equivalent("a ", "a", types.Normalization{}), "zero policy is exact")
+	assert.True(t, Equivalent("a ", "a", types.Normalization{TrailingWhitespace: true}), "trailing whitespace ignored")
+	assert.True(t, Equivalent("\tif x {", "    if x {", types.Normalization{Indentation: true}), "tabs for spaces")
+	assert.True(t, Equivalent("f(a, b)", "f(a,b)", format), "formatter spacing")
+	assert.False(t, Equivalent("return x", "returnx", format), "joined words differ")
+	assert.False(t, Equivalent("f(a, b)", "f(a, c)", format), "real edit")
+}
+
+func TestEquivalentLines(t *testing.T) {
+	old := []string{"func f() {", "\treturn 1", "}"}
+
+	assert.True(t, EquivalentLines(old, []string{"func f() {", "    return 1", "}"},
+		types.Normalization{Indentation: true}), "reindented block")
+	assert.False(t, EquivalentLines(old, []string{"func f() {", "    return 1", "}"},
+		types.Normalization{TrailingWhitespace: true}), "indentation counts without policy")
+	assert.True(t, EquivalentLines(old, []string{"func f() {", "", "\treturn 1", "}"},
+		types.Normalization{Formatting: true}), "blank line skipped with formatting")
+	assert.False(t, EquivalentLines(old, []string{"func f() {", "", "\treturn 1", "}"},
+		types.Normalization{Indentation: true}), "blank line counts without formatting")
+}
+
+func TestCollapseEquivalent(t *testing.T) {
+	old := []string{"a := 1  ", "b := 2", "c := 3"}
+	newLines := []string{"a := 1", "b := 20", "c := 3"}
+	policy := types.Normalization{TrailingWhitespace: true}
+
+	collapsed := CollapseEquivalent(old, newLines, policy)
+	assert.Len(t, 3, collapsed, "line count")
+	assert.Equal(t, "a := 1  ", collapsed[0], "cosmetic edit reverted")
+	assert.Equal(t, "b := 20", collapsed[1], "real edit kept")
+	assert.Equal(t, "a := 1", newLines[0], "input not modified")
+
+	same := CollapseEquivalent(old, newLines, types.Normalization{})
+	assert.Equal(t, "a := 1", same[0], "zero policy keeps edits")
+}
+
+func TestIncrementalDiffBuilder_Normalization(t *testing.T) {
+	builder := NewIncrementalDiffBuilder([]string{"x := 1  ", "y := 2"})
+	builder.Normalization = types.Normalization{TrailingWhitespace: true}
+
+	assert.Nil(t, builder.AddLine("x := 1"), "cosmetic edit is not a change")
+	assert.Equal(t, "x := 1  ", builder.NewLines[0], "old text kept")
+	assert.NotNil(t, builder.AddLine("y := 3"), "real edit reported")
+}
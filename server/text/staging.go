@@ -22,6 +22,8 @@ type Stage struct {
 	rawChanges map[int]LineChange // Original changes with absolute line nums
 	startLine  int                // First change line (absolute, 1-indexed)
 	endLine    int                // Last change line (absolute, 1-indexed)
+	newStart   int                // New line range set by alignMoveStage (0 = derive from changes)
+	newEnd     int
 }
 
 // StagingResult contains the result of CreateStages
@@ -80,6 +82,9 @@ func CreateStages(
 	outViewStages := groupChangesIntoStages(diff, outViewChanges, proximityThreshold, baseLineOffset)
 	allStages := append(inViewStages, outViewStages...)
 
+	// Keep both ends of moved blocks in one stage
+	allStages = mergeMoveStages(allStages, diff, baseLineOffset)
+
 	if len(allStages) == 0 {
 		return nil
 	}
@@ -221,7 +226,7 @@ func getStageBufferRange(stage *Stage, baseLineOffset int, diff *DiffResult, buf
 			bufferLines[lineNum] = bufferLine
 		}
 
-		isAddition := change.Type == ChangeAddition
+		isAddition := change.Type == ChangeAddition || change.Type == ChangeMoved
 
 		if isAddition {
 			hasAdditions = true
@@ -290,6 +295,10 @@ func getStageBufferRange(stage *Stage, baseLineOffset int, diff *DiffResult, buf
 
 // getStageNewLineRange determines the new line range for content extraction.
 func getStageNewLineRange(stage *Stage) (int, int) {
+	if stage.newStart > 0 {
+		return stage.newStart, stage.newEnd
+	}
+
 	minNewLine := -1
 	maxNewLine := -1
 
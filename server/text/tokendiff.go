@@ -0,0 +1,142 @@
+package text
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Span is a changed byte range [ColStart, ColEnd) in one line of a group's
+// new content. Spans let the UI highlight several edits on a line, such as
+// two renamed arguments, instead of repainting the whole line.
+type Span struct {
+	Line     int // 1-indexed line within Group.Lines
+	ColStart int // Byte offset (see columns.go)
+	ColEnd   int // Byte offset, exclusive
+}
+
+// maxSpanCoverage is the fraction of a line that may be covered by spans.
+// Above it the line is mostly new and whole-line rendering reads better.
+const maxSpanCoverage = 0.6
+
+// tokenSpans diffs oldLine and newLine token by token and returns the
+// changed ranges of newLine. Tokens are identifier/number runs, whitespace
+// runs and single punctuation characters. Returns nil when the change is
+// better shown as a whole line: nothing in common, most of the line changed,
+// or tokens removed without replacement (they would be invisible in spans).
+func tokenSpans(oldLine, newLine string) []Span {
+	if oldLine == "" || newLine == "" || oldLine == newLine {
+		return nil
+	}
+
+	oldTokens := tokenize(oldLine)
+	newTokens := tokenize(newLine)
+	if len(oldTokens)+len(newTokens) >= 0xD800 {
+		return nil // token runes would reach the surrogate range
+	}
+	oldRunes, newRunes, tokenArray := tokensToRunes(oldTokens, newTokens)
+
+	dmp := diffmatchpatch.New()
+	diffs := dmp.DiffMainRunes(oldRunes, newRunes, false)
+
+	var spans []Span
+	pos := 0
+	for i, diff := range diffs {
+		size := 0
+		for _, r := range diff.Text {
+			size += len(tokenArray[r])
+		}
+		switch diff.Type {
+		case diffmatchpatch.DiffEqual:
+			pos += size
+		case diffmatchpatch.DiffInsert:
+			spans = appendSpan(spans, newLine, pos, pos+size)
+			pos += size
+		case diffmatchpatch.DiffDelete:
+			replaced := (i > 0 && diffs[i-1].Type == diffmatchpatch.DiffInsert) ||
+				(i+1 < len(diffs) && diffs[i+1].Type == diffmatchpatch.DiffInsert)
+			if !replaced {
+				return nil
+			}
+		}
+	}
+
+	covered := 0
+	for _, s := range spans {
+		covered += s.ColEnd - s.ColStart
+	}
+	if len(spans) == 0 || float64(covered) > maxSpanCoverage*float64(len(newLine)) {
+		return nil
+	}
+	return spans
+}
+
+// appendSpan adds [start, end) to spans, merging it into the previous span
+// when only whitespace separates them.
+func appendSpan(spans []Span, line string, start, end int) []Span {
+	if n := len(spans); n > 0 && strings.TrimSpace(line[spans[n-1].ColEnd:start]) == "" {
+		spans[n-1].ColEnd = end
+		return spans
+	}
+	return append(spans, Span{Line: 1, ColStart: start, ColEnd: end})
+}
+
+// tokenize splits line into identifier/number runs, whitespace runs and
+// single other characters (combining marks stay with their base).
+func tokenize(line string) []string {
+	var tokens []string
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		end := i + size
+		switch {
+		case isWordRune(r):
+			for end < len(line) {
+				next, n := utf8.DecodeRuneInString(line[end:])
+				if !isWordRune(next) && !extendsCluster(next) {
+					break
+				}
+				end += n
+			}
+		case unicode.IsSpace(r):
+			for end < len(line) {
+				next, n := utf8.DecodeRuneInString(line[end:])
+				if !unicode.IsSpace(next) {
+					break
+				}
+				end += n
+			}
+		default:
+			end = clusterEnd(line, end)
+		}
+		tokens = append(tokens, line[i:end])
+		i = end
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// tokensToRunes maps each distinct token to a rune so the character diff
+// can run over tokens, like diffmatchpatch.DiffLinesToRunes does for lines.
+func tokensToRunes(oldTokens, newTokens []string) ([]rune, []rune, []string) {
+	var tokenArray []string
+	index := make(map[string]rune)
+	encode := func(tokens []string) []rune {
+		runes := make([]rune, len(tokens))
+		for i, tok := range tokens {
+			r, ok := index[tok]
+			if !ok {
+				r = rune(len(tokenArray))
+				index[tok] = r
+				tokenArray = append(tokenArray, tok)
+			}
+			runes[i] = r
+		}
+		return runes
+	}
+	return encode(oldTokens), encode(newTokens), tokenArray
+}
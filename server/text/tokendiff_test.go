@@ -0,0 +1,55 @@
+package text
+
+import (
+	"cursortab/assert"
+	"testing"
+)
+
+func TestTokenSpans_RenamedArguments(t *testing.T) {
+	oldLine := "result := compute(alpha, beta, ctx)"
+	newLine := "result := compute(gamma, delta, ctx)"
+
+	spans := tokenSpans(oldLine, newLine)
+	assert.Equal(t, 2, len(spans), "one span per renamed argument")
+	assert.Equal(t, "gamma", newLine[spans[0].ColStart:spans[0].ColEnd], "first span")
+	assert.Equal(t, "delta", newLine[spans[1].ColStart:spans[1].ColEnd], "second span")
+}
+
+func TestTokenSpans_MergesAcrossWhitespace(t *testing.T) {
+	newLine := "return new value, nil"
+	spans := tokenSpans("return old thing, nil", newLine)
+	assert.Equal(t, 1, len(spans), "adjacent words merged")
+	assert.Equal(t, "new value", newLine[spans[0].ColStart:spans[0].ColEnd], "merged span")
+}
+
+func TestTokenSpans_Multibyte(t *testing.T) {
+	newLine := `msg := "こんばんは 世界" // 挨拶`
+	spans := tokenSpans(`msg := "こんにちは 世界" // 挨拶`, newLine)
+	assert.Equal(t, 1, len(spans), "one span")
+	assert.Equal(t, "こんばんは", newLine[spans[0].ColStart:spans[0].ColEnd], "byte range covers the word")
+}
+
+func TestTokenSpans_FallsBackToWholeLine(t *testing.T) {
+	assert.Equal(t, 0, len(tokenSpans("foo(a, b, c)", "foo(a, c)")), "removed tokens have nothing to highlight")
+	assert.Equal(t, 0, len(tokenSpans("x = 1", "for i in range(10): print(i)")), "mostly rewritten")
+	assert.Equal(t, 0, len(tokenSpans("", "new")), "empty old line")
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"foo_1", "(", "a", ",", "  ", "cafe\u0301", ")"}, tokenize("foo_1(a,  cafe\u0301)"), "tokens")
+}
+
+func TestGroupChanges_Spans(t *testing.T) {
+	changes := map[int]LineChange{
+		3: {Type: ChangeModification, OldContent: "call(alpha, beta, ctx)", Content: "call(gamma, delta, ctx)"},
+		4: {Type: ChangeModification, OldContent: "x := old + y", Content: "x := fresh + y"},
+	}
+
+	groups := GroupChanges(changes)
+	assert.Equal(t, 1, len(groups), "one group")
+	assert.Equal(t, []Span{
+		{Line: 1, ColStart: 5, ColEnd: 10},
+		{Line: 1, ColStart: 12, ColEnd: 17},
+		{Line: 2, ColStart: 5, ColEnd: 10},
+	}, groups[0].Spans, "spans carry their line within the group")
+}
@@ -0,0 +1,258 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// preTokenize splits text into words before merging, like the cl100k
+// pattern without its lookahead (which Go's regexp does not support).
+var preTokenize = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\pL\pN]?\pL+|\pN{1,3}| ?[^\s\pL\pN]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+const (
+	// maxWordBytes splits longer words (base64 blobs, minified code) before
+	// merging, which is quadratic in the word length.
+	maxWordBytes = 256
+	// maxCacheEntries bounds the per-word count cache.
+	maxCacheEntries = 50000
+)
+
+// BPE counts tokens with byte pair encoding: each word starts as single
+// bytes (or whole characters the vocabulary knows) and the adjacent pair
+// whose concatenation has the lowest rank is merged until none is left.
+type BPE struct {
+	ranks map[string]int      // merged token bytes -> merge priority
+	units map[string]struct{} // multi-byte characters that are tokens
+
+	mu    sync.Mutex
+	cache map[string]int
+}
+
+func newBPE() *BPE {
+	return &BPE{
+		ranks: make(map[string]int),
+		units: make(map[string]struct{}),
+		cache: make(map[string]int),
+	}
+}
+
+// Count returns the number of tokens in text.
+func (b *BPE) Count(text string) int {
+	total := 0
+	for _, word := range preTokenize.FindAllString(text, -1) {
+		for len(word) > maxWordBytes {
+			cut := maxWordBytes
+			for cut > 0 && !utf8.RuneStart(word[cut]) {
+				cut--
+			}
+			total += b.countWord(word[:cut])
+			word = word[cut:]
+		}
+		total += b.countWord(word)
+	}
+	return total
+}
+
+func (b *BPE) countWord(word string) int {
+	b.mu.Lock()
+	n, ok := b.cache[word]
+	b.mu.Unlock()
+	if ok {
+		return n
+	}
+
+	n = len(b.merge(word))
+
+	b.mu.Lock()
+	if len(b.cache) >= maxCacheEntries {
+		b.cache = make(map[string]int)
+	}
+	b.cache[word] = n
+	b.mu.Unlock()
+	return n
+}
+
+// merge returns the tokens of word.
+func (b *BPE) merge(word string) []string {
+	var pieces []string
+	for i := 0; i < len(word); {
+		_, size := utf8.DecodeRuneInString(word[i:])
+		if _, ok := b.units[word[i:i+size]]; ok && size > 1 {
+			pieces = append(pieces, word[i:i+size])
+			i += size
+			continue
+		}
+		for j := i; j < i+size; j++ {
+			pieces = append(pieces, word[j:j+1])
+		}
+		i += size
+	}
+
+	for len(pieces) > 1 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+1 < len(pieces); i++ {
+			if rank, ok := b.ranks[pieces[i]+pieces[i+1]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best == -1 {
+			break
+		}
+		pieces[best] += pieces[best+1]
+		pieces = append(pieces[:best+1], pieces[best+2:]...)
+	}
+	return pieces
+}
+
+// addToken records a vocabulary entry. Single characters are starting
+// units; longer tokens are merge results ranked by rank.
+func (b *BPE) addToken(token string, rank int) {
+	if r, size := utf8.DecodeRuneInString(token); r != utf8.RuneError && size == len(token) {
+		b.units[token] = struct{}{}
+		return
+	}
+	if old, ok := b.ranks[token]; !ok || rank < old {
+		b.ranks[token] = rank
+	}
+}
+
+// parseTiktoken reads "<base64 token> <rank>" lines.
+func parseTiktoken(data []byte) (*BPE, error) {
+	b := newBPE()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		encoded, rankText, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"<base64 token> <rank>\"", lineNum)
+		}
+		token, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid base64 token", lineNum)
+		}
+		rank, err := strconv.Atoi(rankText)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rank", lineNum)
+		}
+		b.addToken(string(token), rank)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(b.ranks) == 0 {
+		return nil, errors.New("no tokens")
+	}
+	return b, nil
+}
+
+// huggingFaceFile is the part of a tokenizer.json needed for counting.
+type huggingFaceFile struct {
+	Model struct {
+		Type   string            `json:"type"`
+		Vocab  map[string]int    `json:"vocab"`
+		Merges []json.RawMessage `json:"merges"` // "a b" or ["a", "b"]
+	} `json:"model"`
+	PreTokenizer json.RawMessage `json:"pre_tokenizer"`
+}
+
+// parseHuggingFace reads a BPE tokenizer.json. Byte-level vocabularies
+// (GPT-2 style) are mapped back to raw bytes; others (SentencePiece style)
+// have "▁" read as a space and <0xNN> byte fallback tokens decoded.
+func parseHuggingFace(data []byte) (*BPE, error) {
+	var file huggingFaceFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Model.Type != "" && file.Model.Type != "BPE" {
+		return nil, fmt.Errorf("unsupported model type %q, only BPE is supported", file.Model.Type)
+	}
+	if len(file.Model.Merges) == 0 {
+		return nil, errors.New("no merges")
+	}
+
+	decode := decodeMetaspace
+	if bytes.Contains(file.PreTokenizer, []byte(`"ByteLevel"`)) {
+		decode = decodeByteLevel
+	}
+
+	b := newBPE()
+	for token := range file.Model.Vocab {
+		if raw, ok := decode(token); ok && utf8.RuneCountInString(raw) == 1 {
+			b.addToken(raw, 0)
+		}
+	}
+	for rank, rawMerge := range file.Model.Merges {
+		var left, right string
+		var pair []string
+		var text string
+		switch {
+		case json.Unmarshal(rawMerge, &text) == nil:
+			var ok bool
+			if left, right, ok = strings.Cut(text, " "); !ok {
+				continue
+			}
+		case json.Unmarshal(rawMerge, &pair) == nil && len(pair) == 2:
+			left, right = pair[0], pair[1]
+		default:
+			continue
+		}
+		l, okLeft := decode(left)
+		r, okRight := decode(right)
+		if okLeft && okRight {
+			b.addToken(l+r, rank)
+		}
+	}
+	return b, nil
+}
+
+// byteDecoder maps the printable runes of GPT-2's byte-level alphabet back
+// to the bytes they stand for.
+var byteDecoder = func() map[rune]byte {
+	decoder := make(map[rune]byte, 256)
+	n := 0
+	for c := 0; c < 256; c++ {
+		printable := (c >= '!' && c <= '~') || (c >= 0xA1 && c <= 0xAC) || (c >= 0xAE && c <= 0xFF)
+		if printable {
+			decoder[rune(c)] = byte(c)
+		} else {
+			decoder[rune(256+n)] = byte(c)
+			n++
+		}
+	}
+	return decoder
+}()
+
+func decodeByteLevel(token string) (string, bool) {
+	var out strings.Builder
+	for _, r := range token {
+		c, ok := byteDecoder[r]
+		if !ok {
+			return "", false
+		}
+		out.WriteByte(c)
+	}
+	return out.String(), true
+}
+
+func decodeMetaspace(token string) (string, bool) {
+	if len(token) == 6 && strings.HasPrefix(token, "<0x") && strings.HasSuffix(token, ">") {
+		if c, err := strconv.ParseUint(token[3:5], 16, 8); err == nil {
+			return string([]byte{byte(c)}), true
+		}
+	}
+	return strings.ReplaceAll(token, "▁", " "), true
+}
@@ -0,0 +1,62 @@
+// Package tokenizer counts tokens for prompt budgeting. A BPE vocabulary
+// loaded from a tokenizer.json or tiktoken file gives counts close to what
+// the model sees; the character heuristic is used when none is configured.
+package tokenizer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Counter counts the tokens in a piece of text.
+type Counter interface {
+	Count(text string) int
+}
+
+// DefaultCharsPerToken is a conservative estimate for mixed content (code
+// and JSON) when no vocabulary is available.
+const DefaultCharsPerToken = 2
+
+// Heuristic estimates tokens as a fixed number of bytes per token.
+type Heuristic struct {
+	CharsPerToken int
+}
+
+// Count returns len(text) / CharsPerToken, rounded up.
+func (h Heuristic) Count(text string) int {
+	perToken := max(h.CharsPerToken, 1)
+	return (len(text) + perToken - 1) / perToken
+}
+
+// Default is the counter used when none is configured.
+var Default Counter = Heuristic{CharsPerToken: DefaultCharsPerToken}
+
+// Or returns c, or Default when c is nil.
+func Or(c Counter) Counter {
+	if c == nil {
+		return Default
+	}
+	return c
+}
+
+// Load reads a BPE vocabulary. Files ending in .json are read as a Hugging
+// Face tokenizer.json; anything else as a tiktoken vocabulary (one
+// "<base64 token> <rank>" pair per line).
+func Load(path string) (*BPE, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer: %w", err)
+	}
+	var bpe *BPE
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		bpe, err = parseHuggingFace(data)
+	} else {
+		bpe, err = parseTiktoken(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer: %s: %w", path, err)
+	}
+	return bpe, nil
+}
@@ -0,0 +1,108 @@
+package tokenizer
+
+import (
+	"cursortab/assert"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHeuristic(t *testing.T) {
+	h := Heuristic{CharsPerToken: 2}
+	assert.Equal(t, 0, h.Count(""), "empty")
+	assert.Equal(t, 1, h.Count("a"), "rounds up")
+	assert.Equal(t, 2, h.Count("abcd"), "exact")
+	assert.Equal(t, 4, Heuristic{}.Count("abcd"), "zero treated as one")
+	assert.Equal(t, Default, Or(nil), "nil falls back to default")
+}
+
+// writeTiktoken writes a vocabulary of all single bytes plus merged, in
+// rank order.
+func writeTiktoken(t *testing.T, merged ...string) string {
+	var b strings.Builder
+	rank := 0
+	for c := range 256 {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(c)}), rank)
+		rank++
+	}
+	for _, token := range merged {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+		rank++
+	}
+	path := filepath.Join(t.TempDir(), "vocab.tiktoken")
+	assert.NoError(t, os.WriteFile(path, []byte(b.String()), 0o644), "write vocab")
+	return path
+}
+
+func TestLoad_Tiktoken(t *testing.T) {
+	path := writeTiktoken(t, "re", "ret", "ur", "urn", "return", " x")
+	bpe, err := Load(path)
+	assert.NoError(t, err, "Load")
+
+	assert.Equal(t, 1, bpe.Count("return"), "fully merged word")
+	assert.Equal(t, 2, bpe.Count("return x"), "word and space-prefixed word")
+	assert.Equal(t, 3, bpe.Count("ret y"), "partial merge and unknown pair")
+	assert.Equal(t, 0, bpe.Count(""), "empty")
+}
+
+func TestLoad_HuggingFaceByteLevel(t *testing.T) {
+	// "Ġ" is the byte-level spelling of a space
+	json := `{
+		"model": {
+			"type": "BPE",
+			"vocab": {"f": 0, "o": 1, "Ġ": 2, "fo": 3, "foo": 4, "Ġf": 5},
+			"merges": ["f o", ["fo", "o"], "Ġ f"]
+		},
+		"pre_tokenizer": {"type": "ByteLevel", "add_prefix_space": false}
+	}`
+	path := filepath.Join(t.TempDir(), "tokenizer.json")
+	assert.NoError(t, os.WriteFile(path, []byte(json), 0o644), "write tokenizer.json")
+
+	bpe, err := Load(path)
+	assert.NoError(t, err, "Load")
+	assert.Equal(t, 1, bpe.Count("foo"), "merged word")
+	assert.Equal(t, 3, bpe.Count("foo fo"), "foo, space+f, o")
+}
+
+func TestLoad_HuggingFaceMetaspace(t *testing.T) {
+	json := `{
+		"model": {
+			"type": "BPE",
+			"vocab": {"▁": 0, "a": 1, "b": 2, "▁a": 3, "▁ab": 4, "é": 5},
+			"merges": ["▁ a", "▁a b"]
+		},
+		"pre_tokenizer": {"type": "Metaspace", "replacement": "▁"}
+	}`
+	path := filepath.Join(t.TempDir(), "tokenizer.json")
+	assert.NoError(t, os.WriteFile(path, []byte(json), 0o644), "write tokenizer.json")
+
+	bpe, err := Load(path)
+	assert.NoError(t, err, "Load")
+	assert.Equal(t, 1, bpe.Count(" ab"), "metaspace read as space")
+	assert.Equal(t, 1, bpe.Count("é"), "known multi-byte character is one token")
+}
+
+func TestLoad_Errors(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err, "missing file")
+
+	path := filepath.Join(t.TempDir(), "tokenizer.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"model": {"type": "Unigram", "vocab": {}}}`), 0o644), "write")
+	_, err = Load(path)
+	assert.Error(t, err, "unsupported model")
+
+	path = filepath.Join(t.TempDir(), "vocab.tiktoken")
+	assert.NoError(t, os.WriteFile(path, []byte("not-base64!! 1\n"), 0o644), "write")
+	_, err = Load(path)
+	assert.Error(t, err, "invalid tiktoken line")
+}
+
+func TestBPE_LongWordsAreSplit(t *testing.T) {
+	bpe, err := Load(writeTiktoken(t, "aa"))
+	assert.NoError(t, err, "Load")
+	word := strings.Repeat("a", 1000)
+	assert.Equal(t, 500, bpe.Count(word), "pairs merged across chunks")
+}
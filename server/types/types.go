@@ -1,6 +1,13 @@
 package types
 
-// Completion represents a code completion with line range and content
+import (
+	"cursortab/tokenizer"
+	"time"
+)
+
+// Completion represents a code completion with line range and content.
+// Completions replace whole lines, so they carry no columns; every column in
+// the daemon is a 0-indexed UTF-8 byte offset, as in Neovim's API.
 type Completion struct {
 	StartLine  int // 1-indexed
 	EndLineInc int // 1-indexed, inclusive
@@ -14,6 +21,14 @@ const (
 	CompletionSourceIdle
 )
 
+// String returns the name of the source, as used in logs
+func (s CompletionSource) String() string {
+	if s == CompletionSourceIdle {
+		return "idle"
+	}
+	return "typing"
+}
+
 // CursorPredictionTarget represents the target for cursor jump with additional metadata
 type CursorPredictionTarget struct {
 	RelativePath    string
@@ -46,11 +61,14 @@ type CompletionRequest struct {
 	FileDiffHistories []*FileDiffHistory
 	// Cursor position
 	CursorRow int // 1-indexed
-	CursorCol int // 0-indexed
+	CursorCol int // 0-indexed byte offset into the cursor line
 	// Viewport constraint: only set when staging is disabled (0 = no limit)
 	ViewportHeight int
 	// Linter errors if LSP is active
 	LinterErrors *LinterErrors
+	// PrivacyMode asks the provider not to retain data and disables content
+	// logging and metrics for this request
+	PrivacyMode bool
 }
 
 // CompletionResponse contains both completions and cursor prediction target
@@ -98,9 +116,9 @@ func (d *DiffEntry) GetUpdated() string { return d.Updated }
 // CursorRange represents a range in the file (follows LSP conventions)
 type CursorRange struct {
 	StartLine      int // 1-indexed
-	StartCharacter int // 0-indexed
+	StartCharacter int // 0-indexed byte offset (as reported by vim.diagnostic)
 	EndLine        int // 1-indexed
-	EndCharacter   int // 0-indexed
+	EndCharacter   int // 0-indexed byte offset
 }
 
 // ProviderType represents the type of provider
@@ -112,10 +130,28 @@ const (
 
 // ProviderConfig holds configuration for providers
 type ProviderConfig struct {
-	ProviderURL         string  // Hosted Sweep base URL (e.g., "https://autocomplete.sweep.dev")
-	ProviderTemperature float64 // Sampling temperature
-	ProviderMaxTokens   int     // Max tokens to generate (also drives input trimming)
-	ProviderTopK        int     // Top-k sampling
-	APIKey              string  // API key for hosted providers (Sweep)
-	APIKeyEnv           string  // Environment variable name for API key
+	ProviderURL         string        // Hosted Sweep base URL (e.g., "https://autocomplete.sweep.dev")
+	ProviderTemperature float64       // Sampling temperature
+	ProviderMaxTokens   int           // Max tokens to generate (also drives input trimming)
+	ProviderTopK        int           // Top-k sampling
+	APIKey              string        // API key for hosted providers (Sweep)
+	APIKeyEnv           string        // Environment variable name for API key
+	APIKeyCommand       string        // Shell command printing the API key
+	APIKeyFile          string        // File containing the API key
+	APIKeyTTL           time.Duration // How long a key from APIKeyCommand or APIKeyFile is cached
+	RedactSecrets       bool          // Replace detected secrets with placeholders before sending
+	RedactPatterns      []string      // Extra regexps (Go syntax) whose matches are redacted
+	AuditLogPath        string        // Append-only log of requests sent to the provider ("" = disabled)
+	Normalization       Normalization // Which cosmetic differences make a completion a no-op
+
+	// Tokenizer counts tokens for content trimming and the debug log (nil = character heuristic)
+	Tokenizer tokenizer.Counter
+}
+
+// Normalization selects the differences ignored when deciding whether a
+// suggested line changes anything. The zero value compares lines exactly.
+type Normalization struct {
+	TrailingWhitespace bool // Ignore trailing spaces and tabs
+	Indentation        bool // Ignore changes to leading whitespace, including tabs vs spaces
+	Formatting         bool // Ignore whitespace changes a formatter would undo: spacing inside lines and blank lines
 }
@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"cursortab/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandHome(t *testing.T) {
+	t.Setenv("HOME", "/home/dev")
+
+	assert.Equal(t, "/home/dev", ExpandHome("~"), "home itself")
+	assert.Equal(t, filepath.Join("/home/dev", ".config/cursortab/ignore"), ExpandHome("~/.config/cursortab/ignore"), "under home")
+	assert.Equal(t, "~other/file", ExpandHome("~other/file"), "other users not expanded")
+	assert.Equal(t, "/etc/hosts", ExpandHome("/etc/hosts"), "absolute path unchanged")
+}
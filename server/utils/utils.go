@@ -1,19 +1,41 @@
 package utils
 
-// Token estimation constants
-const (
-	AvgCharsPerToken = 2 // Conservative estimate for mixed content (code + JSON)
-)
-
-// EstimateCharsFromTokens estimates the number of characters for a given token count
-func EstimateCharsFromTokens(tokens int) int {
-	return tokens * AvgCharsPerToken
+import "cursortab/tokenizer"
+
+// lineCosts returns the token cost of each line (newline included), counted
+// on first use.
+func lineCosts(lines []string, counter tokenizer.Counter) func(i int) int {
+	counter = tokenizer.Or(counter)
+	costs := make([]int, len(lines))
+	for i := range costs {
+		costs[i] = -1
+	}
+	return func(i int) int {
+		if costs[i] < 0 {
+			costs[i] = counter.Count(lines[i] + "\n")
+		}
+		return costs[i]
+	}
+}
+
+// fitsBudget reports whether all lines fit in maxTokens, counting only until
+// the budget is exceeded.
+func fitsBudget(cost func(i int) int, n, maxTokens int) bool {
+	total := 0
+	for i := range n {
+		total += cost(i)
+		if total > maxTokens {
+			return false
+		}
+	}
+	return true
 }
 
 // TrimContentAroundCursor trims the content to fit within maxTokens while preserving
-// context around the cursor position. Returns the trimmed lines, adjusted cursor position,
-// trim offset, and whether trimming occurred.
-func TrimContentAroundCursor(lines []string, cursorRow, cursorCol, maxTokens int) ([]string, int, int, int, bool) {
+// context around the cursor position. Tokens are counted with counter (nil = the
+// heuristic). Returns the trimmed lines, adjusted cursor position, trim offset, and
+// whether trimming occurred.
+func TrimContentAroundCursor(lines []string, cursorRow, cursorCol, maxTokens int, counter tokenizer.Counter) ([]string, int, int, int, bool) {
 	// Handle empty file
 	if len(lines) == 0 {
 		return lines, 0, cursorCol, 0, false
@@ -31,61 +53,54 @@ func TrimContentAroundCursor(lines []string, cursorRow, cursorCol, maxTokens int
 		return lines, cursorRow, cursorCol, 0, false
 	}
 
-	maxChars := EstimateCharsFromTokens(maxTokens)
-
-	// Calculate total content size
-	totalChars := 0
-	for _, line := range lines {
-		totalChars += len(line) + 1 // +1 for newline
-	}
+	cost := lineCosts(lines, counter)
 
 	// If content is already within limits, return as-is
-	if totalChars <= maxChars {
+	if fitsBudget(cost, len(lines), maxTokens) {
 		return lines, cursorRow, cursorCol, 0, false
 	}
 
 	// Balanced approach: allocate half budget before cursor, half after
 	// This ensures we see context both above AND below the cursor
-	cursorLineChars := len(lines[cursorRow]) + 1
-	remainingBudget := maxChars - cursorLineChars
+	remainingBudget := maxTokens - cost(cursorRow)
 	halfBudget := remainingBudget / 2
 
 	// Expand BEFORE cursor (up to half budget)
 	startLine := cursorRow
-	charsBefore := 0
-	for startLine > 0 && charsBefore < halfBudget {
-		newChars := len(lines[startLine-1]) + 1
-		if charsBefore+newChars <= halfBudget {
+	tokensBefore := 0
+	for startLine > 0 && tokensBefore < halfBudget {
+		newTokens := cost(startLine - 1)
+		if tokensBefore+newTokens <= halfBudget {
 			startLine--
-			charsBefore += newChars
+			tokensBefore += newTokens
 		} else {
 			break
 		}
 	}
 
 	// Expand AFTER cursor (up to half budget + any unused from before)
-	unusedBefore := halfBudget - charsBefore
+	unusedBefore := halfBudget - tokensBefore
 	budgetAfter := halfBudget + unusedBefore
 	endLine := cursorRow
-	charsAfter := 0
-	for endLine < len(lines)-1 && charsAfter < budgetAfter {
-		newChars := len(lines[endLine+1]) + 1
-		if charsAfter+newChars <= budgetAfter {
+	tokensAfter := 0
+	for endLine < len(lines)-1 && tokensAfter < budgetAfter {
+		newTokens := cost(endLine + 1)
+		if tokensAfter+newTokens <= budgetAfter {
 			endLine++
-			charsAfter += newChars
+			tokensAfter += newTokens
 		} else {
 			break
 		}
 	}
 
 	// If we have unused budget after expanding down, try expanding up more
-	unusedAfter := budgetAfter - charsAfter
+	unusedAfter := budgetAfter - tokensAfter
 	if unusedAfter > 0 {
 		for startLine > 0 {
-			newChars := len(lines[startLine-1]) + 1
-			if charsBefore+newChars <= halfBudget+unusedAfter {
+			newTokens := cost(startLine - 1)
+			if tokensBefore+newTokens <= halfBudget+unusedAfter {
 				startLine--
-				charsBefore += newChars
+				tokensBefore += newTokens
 			} else {
 				break
 			}
@@ -111,26 +126,27 @@ type DiffEntry interface {
 	GetUpdated() string
 }
 
-// TrimDiffEntries trims diff entries to fit within maxTokens.
+// TrimDiffEntries trims diff entries to fit within maxTokens, counted with
+// counter (nil = the heuristic).
 // Keeps the most recent entries and removes older ones if over limit.
-func TrimDiffEntries[T DiffEntry](diffs []T, maxTokens int) []T {
+func TrimDiffEntries[T DiffEntry](diffs []T, maxTokens int, counter tokenizer.Counter) []T {
 	if len(diffs) == 0 || maxTokens <= 0 {
 		return diffs
 	}
 
-	maxChars := EstimateCharsFromTokens(maxTokens)
+	counter = tokenizer.Or(counter)
 
 	// Iterate from newest (end) to oldest (start), keeping entries within limit
-	totalChars := 0
+	totalTokens := 0
 	cutoffIndex := 0
 
 	for i := len(diffs) - 1; i >= 0; i-- {
-		entryChars := len(diffs[i].GetOriginal()) + len(diffs[i].GetUpdated())
-		if totalChars+entryChars > maxChars && i < len(diffs)-1 {
+		entryTokens := counter.Count(diffs[i].GetOriginal()) + counter.Count(diffs[i].GetUpdated())
+		if totalTokens+entryTokens > maxTokens && i < len(diffs)-1 {
 			cutoffIndex = i + 1
 			break
 		}
-		totalChars += entryChars
+		totalTokens += entryTokens
 	}
 
 	if cutoffIndex > 0 {
@@ -138,4 +154,3 @@ func TrimDiffEntries[T DiffEntry](diffs []T, maxTokens int) []T {
 	}
 	return diffs
 }
-
@@ -2,12 +2,14 @@ package utils
 
 import (
 	"cursortab/assert"
+	"cursortab/tokenizer"
+	"fmt"
 	"testing"
 )
 
 func TestTrimContentAroundCursor_EmptyFile(t *testing.T) {
 	lines := []string{}
-	trimmed, cursorRow, cursorCol, offset, didTrim := TrimContentAroundCursor(lines, 0, 0, 100)
+	trimmed, cursorRow, cursorCol, offset, didTrim := TrimContentAroundCursor(lines, 0, 0, 100, nil)
 
 	assert.Equal(t, 0, len(trimmed), "trimmed length")
 	assert.Equal(t, 0, cursorRow, "cursorRow")
@@ -18,7 +20,7 @@ func TestTrimContentAroundCursor_EmptyFile(t *testing.T) {
 
 func TestTrimContentAroundCursor_SmallFile(t *testing.T) {
 	lines := []string{"line 1", "line 2", "line 3"}
-	trimmed, cursorRow, cursorCol, offset, didTrim := TrimContentAroundCursor(lines, 1, 5, 1000)
+	trimmed, cursorRow, cursorCol, offset, didTrim := TrimContentAroundCursor(lines, 1, 5, 1000, nil)
 
 	// Small file should not be trimmed
 	assert.Equal(t, 3, len(trimmed), "trimmed length")
@@ -36,7 +38,7 @@ func TestTrimContentAroundCursor_LargeFileTrims(t *testing.T) {
 	}
 
 	// Very small token limit forces trimming
-	trimmed, cursorRow, _, _, didTrim := TrimContentAroundCursor(lines, 50, 0, 20)
+	trimmed, cursorRow, _, _, didTrim := TrimContentAroundCursor(lines, 50, 0, 20, nil)
 
 	assert.True(t, didTrim, "didTrim should be true")
 
@@ -50,17 +52,17 @@ func TestTrimContentAroundCursor_CursorClamping(t *testing.T) {
 	lines := []string{"line 1", "line 2", "line 3"}
 
 	// Test cursor beyond file
-	_, cursorRow, _, _, _ := TrimContentAroundCursor(lines, 100, 0, 1000)
+	_, cursorRow, _, _, _ := TrimContentAroundCursor(lines, 100, 0, 1000, nil)
 	assert.Equal(t, 2, cursorRow, "cursorRow clamped to last line")
 
 	// Test negative cursor
-	_, cursorRow, _, _, _ = TrimContentAroundCursor(lines, -5, 0, 1000)
+	_, cursorRow, _, _, _ = TrimContentAroundCursor(lines, -5, 0, 1000, nil)
 	assert.Equal(t, 0, cursorRow, "cursorRow clamped to first line")
 }
 
 func TestTrimContentAroundCursor_ZeroMaxTokens(t *testing.T) {
 	lines := []string{"line 1", "line 2", "line 3"}
-	trimmed, _, _, _, didTrim := TrimContentAroundCursor(lines, 1, 0, 0)
+	trimmed, _, _, _, didTrim := TrimContentAroundCursor(lines, 1, 0, 0, nil)
 
 	// maxTokens <= 0 should return content as-is
 	assert.Equal(t, 3, len(trimmed), "trimmed length")
@@ -76,7 +78,7 @@ func TestTrimContentAroundCursor_BalancedTrimming(t *testing.T) {
 
 	// Cursor at line 25 (middle), budget for ~10 lines
 	// Each line is 2 chars, so 20 tokens = 40 chars = ~20 lines
-	_, _, _, _, didTrim := TrimContentAroundCursor(lines, 25, 0, 20)
+	_, _, _, _, didTrim := TrimContentAroundCursor(lines, 25, 0, 20, nil)
 
 	assert.True(t, didTrim, "didTrim should be true")
 }
@@ -92,7 +94,7 @@ func (m *mockDiffEntry) GetUpdated() string  { return m.updated }
 
 func TestTrimDiffEntries_EmptySlice(t *testing.T) {
 	var diffs []*mockDiffEntry
-	result := TrimDiffEntries(diffs, 100)
+	result := TrimDiffEntries(diffs, 100, nil)
 
 	assert.Equal(t, 0, len(result), "result length")
 }
@@ -101,7 +103,7 @@ func TestTrimDiffEntries_ZeroMaxTokens(t *testing.T) {
 	diffs := []*mockDiffEntry{
 		{original: "old", updated: "new"},
 	}
-	result := TrimDiffEntries(diffs, 0)
+	result := TrimDiffEntries(diffs, 0, nil)
 
 	// Should return as-is when maxTokens <= 0
 	assert.Equal(t, 1, len(result), "result length")
@@ -114,7 +116,7 @@ func TestTrimDiffEntries_FitsWithinLimit(t *testing.T) {
 	}
 
 	// Each entry is ~2 chars, total ~4 chars = ~2 tokens
-	result := TrimDiffEntries(diffs, 100)
+	result := TrimDiffEntries(diffs, 100, nil)
 
 	assert.Equal(t, 2, len(result), "result length")
 }
@@ -128,7 +130,7 @@ func TestTrimDiffEntries_ExceedsLimit(t *testing.T) {
 	}
 
 	// Very small limit - should keep only most recent
-	result := TrimDiffEntries(diffs, 5)
+	result := TrimDiffEntries(diffs, 5, nil)
 
 	// Should keep only the most recent entries that fit
 	assert.Less(t, len(result), 4, "result length")
@@ -147,7 +149,7 @@ func TestTrimDiffEntries_KeepsMostRecent(t *testing.T) {
 	}
 
 	// Limit that allows only one or two entries
-	result := TrimDiffEntries(diffs, 10)
+	result := TrimDiffEntries(diffs, 10, nil)
 
 	// Check that newest is included
 	found := false
@@ -161,3 +163,55 @@ func TestTrimDiffEntries_KeepsMostRecent(t *testing.T) {
 		assert.True(t, found, "newest entry should be included when space allows")
 	}
 }
+
+// blockFile returns a Go file of n small functions, each 5 lines plus a blank
+// line, after a 3-line header.
+func blockFile(n int) []string {
+	lines := []string{"package main", "", "import \"fmt\""}
+	for i := range n {
+		lines = append(lines,
+			"",
+			fmt.Sprintf("func f%d() {", i),
+			"\tif true {",
+			fmt.Sprintf("\t\tfmt.Println(%d)", i),
+			"\t}",
+			"}",
+		)
+	}
+	return lines
+}
+
+func totalTokens(lines []string) int {
+	n := 0
+	for _, line := range lines {
+		n += tokenizer.Default.Count(line + "\n")
+	}
+	return n
+}
+
+// charCounter counts one token per byte.
+type charCounter struct{}
+
+func (charCounter) Count(text string) int { return len(text) }
+
+func TestTrimContentAroundCursor_UsesCounter(t *testing.T) {
+	lines := make([]string, 20)
+	for i := range lines {
+		lines[i] = "123456789" // 10 tokens per line with charCounter, 5 with the heuristic
+	}
+
+	heuristic, _, _, _, _ := TrimContentAroundCursor(lines, 10, 0, 50, nil)
+	counted, _, _, _, _ := TrimContentAroundCursor(lines, 10, 0, 50, charCounter{})
+
+	assert.Equal(t, 10, len(heuristic), "heuristic window")
+	assert.Equal(t, 5, len(counted), "counter window")
+}
+
+func TestTrimDiffEntries_UsesCounter(t *testing.T) {
+	diffs := []*mockDiffEntry{
+		{original: "aaaa", updated: "bbbb"},
+		{original: "cccc", updated: "dddd"},
+	}
+	assert.Len(t, 2, TrimDiffEntries(diffs, 8, nil), "heuristic keeps both")
+	assert.Len(t, 1, TrimDiffEntries(diffs, 8, charCounter{}), "counter keeps the newest")
+}